@@ -0,0 +1,244 @@
+package announce
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
+	"hubs-cms-go/service"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// timeout bounds an announcement started from a request, including the processing of the gallery by mastodon
+const timeout = time.Minute
+
+var (
+	// lock serializes announcements so that an item changed twice in a row is not posted twice
+	lock    sync.Mutex
+	running sync.WaitGroup
+
+	htmlTagRegexp = regexp.MustCompile(`<[^>]*>`)
+)
+
+// Start announces the changed event or room in the background, see Event and Room
+func Start(ctx context.Context, collection, id string, announceNew bool) {
+	if !config.IsAnnouncerEnabled() {
+		return
+	}
+
+	running.Add(1)
+	go func() {
+		defer running.Done()
+
+		ctx, cancel := context.WithTimeout(service.Detach(ctx), timeout)
+		defer cancel()
+
+		var err error
+		switch collection {
+		case "event":
+			err = Event(ctx, id, announceNew)
+		case "room":
+			err = Room(ctx, id, announceNew)
+		}
+		if err != nil {
+			logger.Ctx(ctx).Error.Printf("[announce.Start] announce %v %v error: %v\n", collection, id, err)
+		}
+	}()
+}
+
+// Wait waits for the announcements in progress until ctx is done
+func Wait(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// Event edits the status the event was announced with, or posts a new one when announceNew is set.
+// The id of a new status is stored on the event as mastodon_status_id
+func Event(ctx context.Context, eventID string, announceNew bool) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	data, err := service.GetDirectusEvent(service.NoCache(ctx), eventID, config.EnvVariable.AnnouncerLocale)
+	if err != nil {
+		return err
+	}
+	if len(data.MastodonStatusID) == 0 && !announceNew {
+		return nil
+	}
+
+	event := dto.NewEventResponse(data, nil)
+	hashtags := make([]string, 0, len(event.Hashtags))
+	for _, hashtag := range event.Hashtags {
+		hashtags = append(hashtags, hashtag.Value)
+	}
+	status := composeStatus(event.Title, formatEventTime(event.StartTime, event.EndTime), event.Description, config.GetAnnouncerEventURL(eventID), hashtags)
+
+	statusID, err := publish(ctx, "event", eventID, data.MastodonStatusID, status, data.Gallery, event.Title)
+	if err != nil || statusID == data.MastodonStatusID {
+		return err
+	}
+	return service.PatchDirectusEvent(ctx, eventID, map[string]string{"mastodon_status_id": statusID})
+}
+
+// Room edits the status the room was announced with, or posts a new one when announceNew is set.
+// Private rooms are not announced and the statuses of rooms made private are left as they are
+func Room(ctx context.Context, roomID string, announceNew bool) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	room, err := service.GetDirectusRoom(service.NoCache(ctx), roomID, config.EnvVariable.AnnouncerLocale)
+	if err != nil {
+		return err
+	}
+	if !room.IsPublic || (len(room.MastodonStatusID) == 0 && !announceNew) {
+		return nil
+	}
+
+	link, err := config.GetHubsURL(room.HubsID)
+	if err != nil {
+		return err
+	}
+	status := composeStatus(room.Title, "", room.Description, link, nil)
+
+	statusID, err := publish(ctx, "room", roomID, room.MastodonStatusID, status, room.Gallery.ID, room.Title)
+	if err != nil || statusID == room.MastodonStatusID {
+		return err
+	}
+	return service.PatchDirectusRoom(ctx, roomID, map[string]string{"mastodon_status_id": statusID})
+}
+
+// publish edits the status statusID, or posts a new one when it is empty or was deleted on mastodon.
+// A gallery which cannot be attached is left out of the status
+func publish(ctx context.Context, collection, id, statusID, text, galleryID, description string) (string, error) {
+	token := config.EnvVariable.MastodonAnnouncerToken
+	status := dto.MastodonPostStatusRequest{Status: text, Visibility: strings.ToLower(config.EnvVariable.AnnouncerVisibility)}
+
+	if len(galleryID) > 0 {
+		mediaID, err := uploadGallery(ctx, token, galleryID, description)
+		if err != nil {
+			logger.Ctx(ctx).Warn.Printf("[announce.publish] attach gallery %v of %v %v error: %v\n", galleryID, collection, id, err)
+		} else {
+			status.MediaIDs = []string{mediaID}
+		}
+	}
+
+	if len(statusID) > 0 {
+		_, err := service.UpdateMastodonStatus(ctx, token, statusID, status)
+		var statusErr *service.MastodonStatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+			metrics.AnnouncementsTotal.WithLabelValues(collection, "edited", metrics.Result(err)).Inc()
+			return statusID, err
+		}
+		logger.Ctx(ctx).Info.Printf("[announce.publish] status %v of %v %v was deleted, post a new one\n", statusID, collection, id)
+	}
+
+	// mastodon answers a repeated post with the same status, e.g. when storing its id failed
+	sum := sha256.Sum256([]byte(fmt.Sprintf("announce|%s|%s|%s", collection, id, statusID)))
+	response, err := service.PostMastodonStatus(ctx, token, hex.EncodeToString(sum[:]), status)
+	metrics.AnnouncementsTotal.WithLabelValues(collection, "posted", metrics.Result(err)).Inc()
+	if err != nil {
+		return "", err
+	}
+	logger.Ctx(ctx).Info.Printf("[announce.publish] %v %v is announced as status %v\n", collection, id, response.ID)
+	return response.ID, nil
+}
+
+func uploadGallery(ctx context.Context, token, galleryID, description string) (string, error) {
+	data, contentType, err := service.GetDirectusAsset(ctx, galleryID)
+	if err != nil {
+		return "", err
+	}
+
+	fileName := galleryID
+	if extensions, _ := mime.ExtensionsByType(contentType); len(extensions) > 0 {
+		fileName += extensions[0]
+	}
+	media, err := service.UploadMastodonMedia(ctx, token, fileName, data, description)
+	if err != nil {
+		return "", err
+	}
+	return media.ID, nil
+}
+
+// composeStatus joins the parts of a status, the description is shortened and hashtags are dropped from the end
+// to fit in ANNOUNCER_MAX_CHARS
+func composeStatus(title, when, description, link string, hashtags []string) string {
+	maxChars := config.EnvVariable.AnnouncerMaxChars
+
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, hashtag := range hashtags {
+		tag := strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' {
+				return r
+			}
+			return -1
+		}, hashtag)
+		if len(tag) > 0 && !seen[strings.ToLower(tag)] {
+			seen[strings.ToLower(tag)] = true
+			tags = append(tags, "#"+tag)
+		}
+	}
+
+	join := func(description string, tags []string) string {
+		parts := []string{}
+		for _, part := range []string{title, when, description, link, strings.Join(tags, " ")} {
+			if len(part) > 0 {
+				parts = append(parts, part)
+			}
+		}
+		return strings.Join(parts, "\n\n")
+	}
+	for len(tags) > 0 && utf8.RuneCountInString(join("", tags)) > maxChars {
+		tags = tags[:len(tags)-1]
+	}
+
+	description = strings.Join(strings.Fields(html.UnescapeString(htmlTagRegexp.ReplaceAllString(description, " "))), " ")
+	if budget := maxChars - utf8.RuneCountInString(join("", tags)) - len("\n\n"); utf8.RuneCountInString(description) > budget {
+		description = truncate(description, budget)
+	}
+
+	return truncate(join(description, tags), maxChars)
+}
+
+// truncate shortens s to n runes ending with an ellipsis, nothing is left when n is too small to hold a word
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	if n < 10 {
+		return ""
+	}
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:n-1])) + "…"
+}
+
+// formatEventTime formats the period of an event in ANNOUNCER_TIME_ZONE, the end date is left out when it is the start date
+func formatEventTime(start, end time.Time) string {
+	start, end = start.In(config.AnnouncerLocation), end.In(config.AnnouncerLocation)
+	if start.Format("2006-01-02") == end.Format("2006-01-02") {
+		return fmt.Sprintf("%s – %s", start.Format("2006-01-02 15:04"), end.Format("15:04 MST"))
+	}
+	return fmt.Sprintf("%s – %s", start.Format("2006-01-02 15:04"), end.Format("2006-01-02 15:04 MST"))
+}
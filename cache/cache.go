@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"hubs-cms-go/config"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -11,9 +12,13 @@ var Store *cache.Cache
 var EventLikes *cache.Cache
 var RoomLikes *cache.Cache
 
+// Responses keeps directus responses of public reads
+var Responses *ResponseCache
+
 // Setup initialize the Cache object
 func Setup() {
 	Store = cache.New(86400*time.Second, 1800*time.Second)
 	EventLikes = cache.New(cache.NoExpiration, 1800*time.Second)
 	RoomLikes = cache.New(cache.NoExpiration, 1800*time.Second)
+	Responses = NewResponseCache(config.EnvVariable.ResponseCacheTTL, config.EnvVariable.ResponseCacheStale, config.EnvVariable.ResponseCacheMaxMB<<20)
 }
@@ -0,0 +1,154 @@
+package cache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ResponseCache keeps upstream response bodies in LRU order within a size bound.
+// Entries older than ttl are still returned as stale until ttl+stale, for the caller to revalidate
+type ResponseCache struct {
+	lock       sync.Mutex
+	ttl        time.Duration
+	stale      time.Duration
+	maxBytes   int
+	size       int
+	generation uint64
+	entries    map[string]*list.Element
+	lru        *list.List
+}
+
+type responseEntry struct {
+	key      string
+	body     []byte
+	storedAt time.Time
+}
+
+// NewResponseCache creates a ResponseCache, a non-positive ttl or maxBytes disables it
+func NewResponseCache(ttl, stale time.Duration, maxBytes int) *ResponseCache {
+	return &ResponseCache{
+		ttl:      ttl,
+		stale:    stale,
+		maxBytes: maxBytes,
+		entries:  map[string]*list.Element{},
+		lru:      list.New(),
+	}
+}
+
+// Enabled tells whether entries are kept at all
+func (c *ResponseCache) Enabled() bool {
+	return c != nil && c.ttl > 0 && c.maxBytes > 0
+}
+
+// Get returns the body of key and whether it is still within ttl
+func (c *ResponseCache) Get(key string) (body []byte, fresh bool, found bool) {
+	if !c.Enabled() {
+		return nil, false, false
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	element, found := c.entries[key]
+	if !found {
+		return nil, false, false
+	}
+
+	entry := element.Value.(*responseEntry)
+	age := time.Since(entry.storedAt)
+	if age >= c.ttl+c.stale {
+		c.remove(element)
+		return nil, false, false
+	}
+
+	c.lru.MoveToFront(element)
+	return entry.body, age < c.ttl, true
+}
+
+// Generation changes on every invalidation, pass it to Set to drop bodies fetched before an invalidation
+func (c *ResponseCache) Generation() uint64 {
+	if c == nil {
+		return 0
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.generation
+}
+
+// Set stores the body of key unless the cache was invalidated since generation, least recently used entries are evicted to fit
+func (c *ResponseCache) Set(key string, body []byte, generation uint64) {
+	if !c.Enabled() || len(body) > c.maxBytes {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	if element, found := c.entries[key]; found {
+		c.remove(element)
+	}
+	c.entries[key] = c.lru.PushFront(&responseEntry{key: key, body: body, storedAt: time.Now()})
+	c.size += len(body)
+
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// DeletePrefix removes the entries whose key starts with prefix
+func (c *ResponseCache) DeletePrefix(prefix string) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.generation++
+	for key, element := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(element)
+		}
+	}
+}
+
+// DeletePath removes the entries of the path, its sub paths and queries, but not of paths it only prefixes
+func (c *ResponseCache) DeletePath(path string) {
+	if c == nil {
+		return
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.generation++
+	for key, element := range c.entries {
+		if key == path || strings.HasPrefix(key, path+"/") || strings.HasPrefix(key, path+"?") {
+			c.remove(element)
+		}
+	}
+}
+
+// Len returns the number of entries
+func (c *ResponseCache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Len()
+}
+
+func (c *ResponseCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*responseEntry)
+	delete(c.entries, entry.key)
+	c.size -= len(entry.body)
+}
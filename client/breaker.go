@@ -0,0 +1,117 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"hubs-cms-go/config"
+	"hubs-cms-go/metrics"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the upstream while its circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitHalfOpen
+	circuitOpen
+)
+
+// circuitBreaker opens after consecutive failures and lets a single probe through once the open timeout passes
+type circuitBreaker struct {
+	upstream string
+	lock     sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var breakers = map[string]*circuitBreaker{
+	"directus": {upstream: "directus"},
+	"mastodon": {upstream: "mastodon"},
+}
+
+// breakerFor returns the circuit breaker of the upstream, nil for unknown upstreams
+func breakerFor(upstream string) *circuitBreaker {
+	return breakers[upstream]
+}
+
+// IsCircuitOpen reports whether calls to the upstream currently fail fast
+func IsCircuitOpen(upstream string) bool {
+	b := breakerFor(upstream)
+	if b == nil {
+		return false
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.state == circuitOpen && time.Since(b.openedAt) < config.EnvVariable.CircuitBreakerOpenTimeout
+}
+
+// ResetCircuitBreakers closes the circuit breakers of all upstreams
+func ResetCircuitBreakers() {
+	for _, b := range breakers {
+		b.lock.Lock()
+		b.setState(circuitClosed)
+		b.failures = 0
+		b.probing = false
+		b.lock.Unlock()
+	}
+}
+
+func (b *circuitBreaker) allow() error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < config.EnvVariable.CircuitBreakerOpenTimeout {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, b.upstream)
+		}
+		b.setState(circuitHalfOpen)
+		b.probing = true
+	case circuitHalfOpen:
+		if b.probing {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, b.upstream)
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+func (b *circuitBreaker) success() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.setState(circuitClosed)
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *circuitBreaker) failure() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.state == circuitHalfOpen || b.failures >= config.EnvVariable.CircuitBreakerThreshold {
+		b.setState(circuitOpen)
+		b.openedAt = time.Now()
+	}
+}
+
+// release gives up a probe without a verdict, e.g. when the caller cancelled it
+func (b *circuitBreaker) release() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.probing = false
+}
+
+func (b *circuitBreaker) setState(state circuitState) {
+	b.state = state
+	metrics.UpstreamCircuitState.WithLabelValues(b.upstream).Set(float64(state))
+}
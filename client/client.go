@@ -1,7 +1,9 @@
 package client
 
 import (
-	"fmt"
+	"context"
+	"encoding/json"
+	"hubs-cms-go/constant"
 	"hubs-cms-go/logger"
 
 	"github.com/go-resty/resty/v2"
@@ -10,25 +12,64 @@ import (
 // RestyClient the resty http client
 var RestyClient = resty.New()
 
-// NewHTTPRequest create http request
-func NewHTTPRequest() *resty.Request {
-	return RestyClient.R()
+// NewHTTPRequest create http request, the request is cancelled with ctx
+func NewHTTPRequest(ctx context.Context) *resty.Request {
+	return RestyClient.R().SetContext(ctx)
 }
 
 // Setup setup http client
 func Setup() {
+	RestyClient.OnBeforeRequest(requestID)
+	RestyClient.OnBeforeRequest(requestTimeout)
+	RestyClient.OnBeforeRequest(requestTracing)
 	RestyClient.OnAfterResponse(responseLogger)
+	RestyClient.OnAfterResponse(responseMetrics)
+	RestyClient.OnAfterResponse(responseTracing)
+	RestyClient.OnAfterResponse(responseTimeout)
+	RestyClient.OnError(errorMetrics)
+	RestyClient.OnError(errorTracing)
+	RestyClient.OnError(errorTimeout)
+}
+
+// requestID forwards the X-Request-ID of the incoming request to upstream
+func requestID(c *resty.Client, req *resty.Request) error {
+	if id := logger.RequestIDFromContext(req.Context()); len(id) > 0 {
+		req.Header.Set(constant.HeaderRequestID, id)
+	}
+	return nil
 }
 
 func responseLogger(c *resty.Client, resp *resty.Response) error {
-	method := fmt.Sprintf("[Method] %s", resp.Request.Method)
-	url := fmt.Sprintf("[URL] %s", resp.Request.URL)
-	reqBody := fmt.Sprintf("[Request Body] %v", resp.Request.Body)
-	status := fmt.Sprintf("[Status] %d", resp.StatusCode())
-	duration := fmt.Sprintf("[Duration] %v", resp.Time())
-	respBody := fmt.Sprintf("[Response Body] %s", resp.String())
-
-	log := fmt.Sprintf("%s %s %s %s %s %s", method, url, reqBody, status, duration, respBody)
-	logger.Debug.Println(log)
+	log := logger.Ctx(resp.Request.Context()).Debug
+	if !log.Enabled() {
+		return nil
+	}
+
+	log.With(logger.Fields{
+		"method":        resp.Request.Method,
+		"url":           resp.Request.URL,
+		"request_body":  requestBody(resp.Request.Body),
+		"status":        resp.StatusCode(),
+		"duration_ms":   resp.Time().Milliseconds(),
+		"response_body": resp.String(),
+	}).Print("[responseLogger] upstream response")
 	return nil
 }
+
+// requestBody encodes the body as JSON, so secrets in it are redacted by key
+func requestBody(body interface{}) string {
+	switch b := body.(type) {
+	case nil:
+		return ""
+	case string:
+		return b
+	case []byte:
+		return string(b)
+	}
+
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "<unencodable body>"
+	}
+	return string(b)
+}
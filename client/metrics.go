@@ -0,0 +1,63 @@
+package client
+
+import (
+	"hubs-cms-go/config"
+	"hubs-cms-go/metrics"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+var idPattern = regexp.MustCompile(`^([0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
+func responseMetrics(c *resty.Client, resp *resty.Response) error {
+	upstream, endpoint := upstreamEndpoint(resp.Request.URL)
+	status := strconv.Itoa(resp.StatusCode())
+
+	metrics.UpstreamRequestDuration.WithLabelValues(upstream, resp.Request.Method, endpoint, status).Observe(resp.Time().Seconds())
+	if !resp.IsSuccess() {
+		metrics.UpstreamErrorsTotal.WithLabelValues(upstream, resp.Request.Method, endpoint).Inc()
+	}
+	return nil
+}
+
+func errorMetrics(req *resty.Request, err error) {
+	upstream, endpoint := upstreamEndpoint(req.URL)
+
+	// response hooks are skipped when the response body cannot be parsed
+	if respErr, ok := err.(*resty.ResponseError); ok {
+		status := strconv.Itoa(respErr.Response.StatusCode())
+		metrics.UpstreamRequestDuration.WithLabelValues(upstream, req.Method, endpoint, status).Observe(respErr.Response.Time().Seconds())
+	} else if !req.Time.IsZero() {
+		metrics.UpstreamRequestDuration.WithLabelValues(upstream, req.Method, endpoint, "error").Observe(time.Since(req.Time).Seconds())
+	}
+	metrics.UpstreamErrorsTotal.WithLabelValues(upstream, req.Method, endpoint).Inc()
+}
+
+// upstreamEndpoint maps a request url to low cardinality labels, ids in path are replaced by ":id"
+func upstreamEndpoint(rawURL string) (upstream string, endpoint string) {
+	uri, err := url.Parse(rawURL)
+	if err != nil {
+		return "unknown", "unknown"
+	}
+
+	upstream = "unknown"
+	switch {
+	case strings.HasPrefix(rawURL, config.EnvVariable.DirectusBaseURI):
+		upstream = "directus"
+	case strings.HasPrefix(rawURL, config.EnvVariable.MastodonBaseURI):
+		upstream = "mastodon"
+	}
+
+	segments := strings.Split(uri.Path, "/")
+	for i, segment := range segments {
+		if idPattern.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return upstream, strings.Join(segments, "/")
+}
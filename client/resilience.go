@@ -0,0 +1,95 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"hubs-cms-go/config"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// Execute sends the request through the circuit breaker of its upstream,
+// idempotent requests are retried on network errors and 5xx with jittered exponential backoff
+func Execute(req *resty.Request, method, url string) (*resty.Response, error) {
+	upstream, _ := upstreamEndpoint(url)
+	breaker := breakerFor(upstream)
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		if breaker != nil {
+			if err := breaker.allow(); err != nil {
+				return nil, err
+			}
+		}
+
+		// hooks derive the context of each attempt from the caller's
+		req.SetContext(ctx)
+		resp, err := req.Execute(method, url)
+		req.SetContext(ctx)
+
+		failed := isUpstreamFailure(resp, err)
+		if breaker != nil {
+			switch {
+			case failed:
+				breaker.failure()
+			case ctx.Err() != nil:
+				breaker.release()
+			default:
+				breaker.success()
+			}
+		}
+
+		if !failed || !isIdempotent(method) || attempt >= config.EnvVariable.UpstreamRetryCount || ctx.Err() != nil {
+			return resp, err
+		}
+
+		wait := backoff(attempt)
+		logger.Ctx(ctx).Warn.Printf("[Execute] retry %v %v in %v, attempt=%v, err=%v\n", method, url, wait, attempt+1, upstreamError(resp, err))
+		metrics.UpstreamRetriesTotal.WithLabelValues(upstream).Inc()
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return resp, err
+		}
+	}
+}
+
+// isUpstreamFailure tells network errors and 5xx apart from errors caused by the caller going away
+func isUpstreamFailure(resp *resty.Response, err error) bool {
+	if resp != nil && resp.RawResponse != nil {
+		return resp.StatusCode() >= http.StatusInternalServerError
+	}
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	return true
+}
+
+func isIdempotent(method string) bool {
+	return method == resty.MethodGet || method == resty.MethodHead || method == resty.MethodOptions
+}
+
+// backoff returns a wait between half and the full exponential delay, capped by UPSTREAM_RETRY_MAX_WAIT
+func backoff(attempt int) time.Duration {
+	wait := config.EnvVariable.UpstreamRetryWait << uint(attempt)
+	if max := config.EnvVariable.UpstreamRetryMaxWait; wait > max || wait <= 0 {
+		wait = max
+	}
+	if wait <= 1 {
+		return wait
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)))
+}
+
+func upstreamError(resp *resty.Response, err error) interface{} {
+	if err != nil {
+		return err
+	}
+	return resp.Status()
+}
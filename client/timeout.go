@@ -0,0 +1,53 @@
+package client
+
+import (
+	"context"
+	"hubs-cms-go/config"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+type cancelKey struct{}
+
+// upstreamTimeout returns the budget of a single call to the upstream, zero means no limit
+func upstreamTimeout(upstream string) time.Duration {
+	switch upstream {
+	case "directus":
+		return config.EnvVariable.DirectusTimeout
+	case "mastodon":
+		return config.EnvVariable.MastodonTimeout
+	}
+	return 0
+}
+
+// requestTimeout bounds each upstream call by its own budget, the deadline of the caller still applies
+func requestTimeout(c *resty.Client, req *resty.Request) error {
+	upstream, _ := upstreamEndpoint(req.URL)
+	timeout := upstreamTimeout(upstream)
+	if timeout <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	ctx = context.WithValue(ctx, cancelKey{}, cancel)
+	req.SetContext(ctx)
+	req.RawRequest = req.RawRequest.WithContext(ctx)
+	return nil
+}
+
+// cancelTimeout releases the timer once the response body is read
+func cancelTimeout(req *resty.Request) {
+	if cancel, ok := req.Context().Value(cancelKey{}).(context.CancelFunc); ok {
+		cancel()
+	}
+}
+
+func responseTimeout(c *resty.Client, resp *resty.Response) error {
+	cancelTimeout(resp.Request)
+	return nil
+}
+
+func errorTimeout(req *resty.Request, err error) {
+	cancelTimeout(req)
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"hubs-cms-go/tracing"
+
+	"github.com/go-resty/resty/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type clientSpanKey struct{}
+
+// clientSpan returns the span started by requestTracing, the span of the caller should not be ended here
+func clientSpan(req *resty.Request) trace.Span {
+	if span, ok := req.Context().Value(clientSpanKey{}).(trace.Span); ok {
+		return span
+	}
+	return trace.SpanFromContext(context.Background())
+}
+
+// requestTracing starts a client span as a child of the request context and propagates it upstream
+func requestTracing(c *resty.Client, req *resty.Request) error {
+	upstream, endpoint := upstreamEndpoint(req.URL)
+
+	ctx, span := tracing.Tracer().Start(req.Context(), fmt.Sprintf("%s %s %s", upstream, req.Method, endpoint),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req.RawRequest)...),
+	)
+
+	// raw request shares the header map with resty request
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	ctx = context.WithValue(ctx, clientSpanKey{}, span)
+	req.SetContext(ctx)
+	req.RawRequest = req.RawRequest.WithContext(ctx)
+	return nil
+}
+
+func responseTracing(c *resty.Client, resp *resty.Response) error {
+	span := clientSpan(resp.Request)
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode())...)
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(resp.StatusCode()))
+	span.End()
+	return nil
+}
+
+func errorTracing(req *resty.Request, err error) {
+	span := clientSpan(req)
+
+	// response hooks are skipped when the response body cannot be parsed
+	if respErr, ok := err.(*resty.ResponseError); ok {
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(respErr.Response.StatusCode())...)
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.End()
+}
@@ -7,11 +7,11 @@ import (
 )
 
 func GetDirectusGetAccountURI(mastodonAccount string) string {
-	return fmt.Sprintf(`%s/items/account?fields=*,active_avatar.*,liked_rooms.id,liked_rooms.room_id,liked_events.id,liked_events.event_id&filter={"mastodon_account":{"_eq":"%s"}}`, EnvVariable.DirectusBaseURI, mastodonAccount)
+	return fmt.Sprintf(`%s/items/account?fields=*,active_avatar.*,liked_rooms.id,liked_rooms.room_id,liked_events.id,liked_events.event_id,favorite_avatars.id,favorite_avatars.avatar_id&filter={"mastodon_account":{"_eq":"%s"}}`, EnvVariable.DirectusBaseURI, mastodonAccount)
 }
 
 func GetDirectusPatchAccountURI(accountID string) string {
-	return fmt.Sprintf("%s/items/account/%s?fields=*,active_avatar.*,liked_rooms.id,liked_rooms.room_id,liked_events.id,liked_events.event_id", EnvVariable.DirectusBaseURI, accountID)
+	return fmt.Sprintf("%s/items/account/%s?fields=*,active_avatar.*,liked_rooms.id,liked_rooms.room_id,liked_events.id,liked_events.event_id,favorite_avatars.id,favorite_avatars.avatar_id", EnvVariable.DirectusBaseURI, accountID)
 }
 
 func GetDirectusCreateAccountURI() string {
@@ -60,3 +60,33 @@ func GetDirectusGetAccountsLikedStuffURI(Type string, offset, limit int64) strin
 
 	return uri.String()
 }
+
+// GetDirectusGetAccountsLikedEventURI lists the accounts which liked the event
+func GetDirectusGetAccountsLikedEventURI(eventID string, offset, limit int64) string {
+	uri, err := genAccountUrl("")
+	if err != nil {
+		return ""
+	}
+
+	q := url.Values{}
+	q.Add("meta", "filter_count")
+	q.Add("fields", "id,mastodon_account,display_name")
+	q.Add("filter[liked_events][event_id][_eq]", eventID)
+	q.Set("offset", fmt.Sprintf("%v", offset))
+	if limit > 0 {
+		q.Set("limit", fmt.Sprintf("%v", limit))
+	}
+
+	uri.RawQuery = q.Encode()
+	return uri.String()
+}
+
+// GetDirectusAccountsByActiveAvatarURI lists the ids of the accounts using the avatar
+func GetDirectusAccountsByActiveAvatarURI(avatarID string) string {
+	return fmt.Sprintf(`%s/items/account?fields=id&limit=-1&filter={"active_avatar":{"_eq":"%s"}}`, EnvVariable.DirectusBaseURI, avatarID)
+}
+
+// GetDirectusAccountsURI updates accounts in batch
+func GetDirectusAccountsURI() string {
+	return fmt.Sprintf("%s/items/account", EnvVariable.DirectusBaseURI)
+}
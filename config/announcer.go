@@ -0,0 +1,13 @@
+package config
+
+import "strings"
+
+// IsAnnouncerEnabled tells whether new events and public rooms are announced on mastodon
+func IsAnnouncerEnabled() bool {
+	return EnvVariable.MastodonAnnouncerToken != ""
+}
+
+// GetAnnouncerEventURL returns the page of the event linked in its announcement, empty when ANNOUNCER_EVENT_URL is not set
+func GetAnnouncerEventURL(eventID string) string {
+	return strings.ReplaceAll(EnvVariable.AnnouncerEventURL, "{id}", eventID)
+}
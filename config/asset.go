@@ -0,0 +1,98 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AssetProxyPath is the route of the asset proxy
+const AssetProxyPath = "/api/hubs-cms/v1/assets/"
+
+// IsAssetProxyEnabled tells whether asset links go through the signed asset proxy
+func IsAssetProxyEnabled() bool {
+	return len(EnvVariable.AssetSigningKey) > 0
+}
+
+// GetAssetURI returns the link clients fetch an asset from. Public assets are served from ASSET_CDN_BASE_URI when it is set,
+// other assets through the signed asset proxy. Without ASSET_SIGNING_KEY the directus asset is linked as is
+func GetAssetURI(assetID string, public bool) string {
+	return GetImageURI(assetID, public, "")
+}
+
+// GetAssetURIs maps the asset ids of thumbnails to their links, nil for items without thumbnails
+func GetAssetURIs(assetIDs map[string]string, public bool) map[string]string {
+	if len(assetIDs) == 0 {
+		return nil
+	}
+	urls := make(map[string]string, len(assetIDs))
+	for size, assetID := range assetIDs {
+		urls[size] = GetAssetURI(assetID, public)
+	}
+	return urls
+}
+
+// GetImageURI is GetAssetURI of the image transformed by the preset, the original for an empty preset
+func GetImageURI(assetID string, public bool, preset string) string {
+	query := GetImagePresetQuery(preset)
+	if public && len(EnvVariable.AssetCDNBaseURI) > 0 {
+		return withQuery(fmt.Sprintf("%s/%s", strings.TrimSuffix(EnvVariable.AssetCDNBaseURI, "/"), assetID), query)
+	}
+	if !IsAssetProxyEnabled() {
+		return GetDirectusGetImageURI(assetID, preset)
+	}
+	return GetSignedAssetURI(assetID, preset, time.Now())
+}
+
+// GetImageSrcset maps the ImagePresetNames to the links of the image transformed by them
+func GetImageSrcset(assetID string, public bool) map[string]string {
+	srcset := make(map[string]string, len(ImagePresetNames))
+	for _, preset := range ImagePresetNames {
+		srcset[preset] = GetImageURI(assetID, public, preset)
+	}
+	return srcset
+}
+
+func withQuery(uri, query string) string {
+	if len(query) == 0 {
+		return uri
+	}
+	return uri + "?" + query
+}
+
+// GetSignedAssetURI links the asset proxy with a signature expiring between ASSET_URL_TTL and twice of it from now.
+// Links issued within the same ASSET_URL_TTL window are the same, so clients and caches reuse them
+func GetSignedAssetURI(assetID, preset string, now time.Time) string {
+	ttl := int64(EnvVariable.AssetURLTTL / time.Second)
+	exp := (now.Unix()/ttl + 2) * ttl
+
+	q := url.Values{}
+	if len(preset) > 0 {
+		q.Set("preset", preset)
+	}
+	q.Set("exp", strconv.FormatInt(exp, 10))
+	q.Set("sig", SignAsset(assetID, preset, exp))
+	return fmt.Sprintf("%s%s%s?%s", strings.TrimSuffix(EnvVariable.AssetBaseURI, "/"), AssetProxyPath, url.PathEscape(assetID), q.Encode())
+}
+
+// SignAsset is the HMAC-SHA256 of the asset id, its preset and its expiry with ASSET_SIGNING_KEY
+func SignAsset(assetID, preset string, exp int64) string {
+	mac := hmac.New(sha256.New, []byte(EnvVariable.AssetSigningKey))
+	fmt.Fprintf(mac, "%s\n%s\n%d", assetID, preset, exp)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyAsset checks the signature of an asset link, the expiry is checked by the caller
+func VerifyAsset(assetID, preset string, exp int64, sig string) bool {
+	expected, err := hex.DecodeString(sig)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+	actual, _ := hex.DecodeString(SignAsset(assetID, preset, exp))
+	return hmac.Equal(actual, expected)
+}
@@ -7,3 +7,19 @@ import (
 func GetDirectusAccessTokenURI() string {
 	return fmt.Sprintf("%s/auth/login", EnvVariable.DirectusBaseURI)
 }
+
+func GetDirectusRefreshTokenURI() string {
+	return fmt.Sprintf("%s/auth/refresh", EnvVariable.DirectusBaseURI)
+}
+
+func GetDirectusUsersMeURI() string {
+	return fmt.Sprintf("%s/users/me?fields=role.admin_access", EnvVariable.DirectusBaseURI)
+}
+
+func GetDirectusPermissionsMeURI() string {
+	return fmt.Sprintf("%s/permissions/me?limit=-1", EnvVariable.DirectusBaseURI)
+}
+
+func GetDirectusUsersMeIDURI() string {
+	return fmt.Sprintf("%s/users/me?fields=id", EnvVariable.DirectusBaseURI)
+}
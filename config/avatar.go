@@ -2,7 +2,10 @@ package config
 
 import (
 	"fmt"
+	"hubs-cms-go/logger"
 	"hubs-cms-go/utils"
+	"net/url"
+	"strings"
 )
 
 func GetDirectusGetAvatarURI(avatarID string) string {
@@ -13,6 +16,82 @@ func GetDirectusGetPublicAvatarURI(start int64, limit int64) string {
 	return fmt.Sprintf(`%s/items/avatar?filter={"is_public":{"_eq":true}}&meta=*&%s`, EnvVariable.DirectusBaseURI, utils.GetPageParam(start, limit))
 }
 
+// GetDirectusSearchPublicAvatarURI lists the public avatars with all of the tags, the source and a title containing search.
+// sort is a field name or empty, a negative limit lists all of them
+func GetDirectusSearchPublicAvatarURI(tags []string, source, search, sort string, start int64, limit int64) string {
+	uri, err := url.Parse(EnvVariable.DirectusBaseURI)
+	if err != nil {
+		logger.Error.Printf("[GetDirectusSearchPublicAvatarURI] Parse %s error: %v\n", EnvVariable.DirectusBaseURI, err)
+		return ""
+	}
+
+	uri.Path = "/items/avatar"
+	q := url.Values{}
+	q.Set("filter[is_public][_eq]", "true")
+	if len(source) > 0 {
+		q.Set("filter[source][_eq]", source)
+	}
+	if len(search) > 0 {
+		q.Set("filter[title][_icontains]", search)
+	}
+	// tags is a json array, the quotes keep a tag from matching a longer one
+	for i, tag := range tags {
+		q.Set(fmt.Sprintf("filter[_and][%d][tags][_contains]", i), `"`+tag+`"`)
+	}
+	if len(sort) > 0 {
+		q.Set("sort", sort)
+	}
+	attachAvatarPaging(q, start, limit)
+	uri.RawQuery = q.Encode()
+	return uri.String()
+}
+
+// GetDirectusGetFavoriteAvatarURI lists the avatars among avatarIDs which are public or owned by the account
+func GetDirectusGetFavoriteAvatarURI(accountID string, avatarIDs []string, start int64, limit int64) string {
+	uri, err := url.Parse(EnvVariable.DirectusBaseURI)
+	if err != nil {
+		logger.Error.Printf("[GetDirectusGetFavoriteAvatarURI] Parse %s error: %v\n", EnvVariable.DirectusBaseURI, err)
+		return ""
+	}
+
+	uri.Path = "/items/avatar"
+	q := url.Values{}
+	q.Set("filter[id][_in]", strings.Join(avatarIDs, ","))
+	q.Set("filter[_or][0][is_public][_eq]", "true")
+	q.Set("filter[_or][1][owner][_eq]", accountID)
+	attachAvatarPaging(q, start, limit)
+	uri.RawQuery = q.Encode()
+	return uri.String()
+}
+
+// GetDirectusAvatarPopularityURI counts the accounts by active_avatar
+func GetDirectusAvatarPopularityURI() string {
+	uri, err := url.Parse(EnvVariable.DirectusBaseURI)
+	if err != nil {
+		logger.Error.Printf("[GetDirectusAvatarPopularityURI] Parse %s error: %v\n", EnvVariable.DirectusBaseURI, err)
+		return ""
+	}
+
+	uri.Path = "/items/account"
+	q := url.Values{}
+	q.Set("aggregate[count]", "id")
+	q.Set("groupBy[]", "active_avatar")
+	q.Set("filter[active_avatar][_nnull]", "true")
+	q.Set("limit", "-1")
+	uri.RawQuery = q.Encode()
+	return uri.String()
+}
+
+func attachAvatarPaging(q url.Values, start int64, limit int64) {
+	q.Set("meta", "filter_count")
+	if start > 0 {
+		q.Set("offset", fmt.Sprintf("%v", start))
+	}
+	if limit != 0 {
+		q.Set("limit", fmt.Sprintf("%v", limit))
+	}
+}
+
 func GetDirectusGetMyAvatarURI(accountID string, start int64, limit int64) string {
 	return fmt.Sprintf(`%s/items/avatar?filter={"owner":{"_eq":"%s"}}&meta=*&%s`, EnvVariable.DirectusBaseURI, accountID, utils.GetPageParam(start, limit))
 }
@@ -21,10 +100,20 @@ func GetDirectusGetAssetURI(assetID string) string {
 	return fmt.Sprintf("%s/assets/%s", EnvVariable.DirectusBaseURI, assetID)
 }
 
+// GetDirectusGetImageURI is the directus asset transformed by the image preset
+func GetDirectusGetImageURI(assetID, preset string) string {
+	return withQuery(GetDirectusGetAssetURI(assetID), GetImagePresetQuery(preset))
+}
+
 func GetDirectusUploadAssetURI() string {
 	return fmt.Sprintf("%s/files", EnvVariable.DirectusBaseURI)
 }
 
+// GetDirectusFileURI is the file replaced when the content of an asset is rewritten
+func GetDirectusFileURI(assetID string) string {
+	return fmt.Sprintf("%s/files/%s", EnvVariable.DirectusBaseURI, assetID)
+}
+
 func GetDirectusImportAssetURI() string {
 	return fmt.Sprintf("%s/files/import", EnvVariable.DirectusBaseURI)
 }
@@ -36,3 +125,37 @@ func GetDirectusCreateAvatarURI() string {
 func GetDirectusSingleAvatarURI(avatarID string) string {
 	return fmt.Sprintf("%s/items/avatar/%s", EnvVariable.DirectusBaseURI, avatarID)
 }
+
+// GetDirectusGetAvatarFilesURI lists the files of all of the avatars owned by the account
+func GetDirectusGetAvatarFilesURI(accountID string) string {
+	uri, err := url.Parse(EnvVariable.DirectusBaseURI)
+	if err != nil {
+		logger.Error.Printf("[GetDirectusGetAvatarFilesURI] Parse %s error: %v\n", EnvVariable.DirectusBaseURI, err)
+		return ""
+	}
+
+	uri.Path = "/items/avatar"
+	q := url.Values{}
+	q.Set("fields", "id,snapshot,snapshot_thumbnails,glb")
+	q.Set("filter[owner][_eq]", accountID)
+	q.Set("limit", "-1")
+	uri.RawQuery = q.Encode()
+	return uri.String()
+}
+
+// GetDirectusGetFileSizesURI reads the filesize of the files
+func GetDirectusGetFileSizesURI(fileIDs []string) string {
+	uri, err := url.Parse(EnvVariable.DirectusBaseURI)
+	if err != nil {
+		logger.Error.Printf("[GetDirectusGetFileSizesURI] Parse %s error: %v\n", EnvVariable.DirectusBaseURI, err)
+		return ""
+	}
+
+	uri.Path = "/files"
+	q := url.Values{}
+	q.Set("fields", "id,filesize")
+	q.Set("filter[id][_in]", strings.Join(fileIDs, ","))
+	q.Set("limit", "-1")
+	uri.RawQuery = q.Encode()
+	return uri.String()
+}
@@ -3,25 +3,96 @@ package config
 import (
 	"fmt"
 	"log"
+	"net"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 )
 
 type envVariable struct {
-	Version               string `env:"FULL_VERSION" envDefault:"1.0.0"`
-	Port                  string `env:"GO_HTTP_PORT,required"`
-	LogLevel              string `env:"LOG_LEVEL" envDefault:"ERROR"`
-	Environment           string `env:"ENVIRONMENT" envDefault:"DEVELOP"`
-	MastodonBaseURI       string `env:"MASTODON_BASE_URI,required"`
-	DirectusBaseURI       string `env:"DIRECTUS_BASE_URI,required"`
-	DirectusAdminEmail    string `env:"DIRECTUS_ADMIN_EMAIL,required"`
-	DirectusAdminPassword string `env:"DIRECTUS_ADMIN_PASSWORD,required"`
-	HubsBaseURI           string `env:"HUBS_BASE_URI,required"`
-	EventBackupInterval   string `env:"EVENT_BACKUP_INTERVAL" envDefault:"@daily"`
+	Version                   string        `env:"FULL_VERSION" envDefault:"1.0.0"`
+	Port                      string        `env:"GO_HTTP_PORT,required"`
+	LogLevel                  string        `env:"LOG_LEVEL" envDefault:"ERROR"`
+	Environment               string        `env:"ENVIRONMENT" envDefault:"DEVELOP"`
+	MastodonBaseURI           string        `env:"MASTODON_BASE_URI,required"`
+	DirectusBaseURI           string        `env:"DIRECTUS_BASE_URI,required"`
+	DirectusAdminEmail        string        `env:"DIRECTUS_ADMIN_EMAIL"`
+	DirectusAdminPassword     string        `env:"DIRECTUS_ADMIN_PASSWORD"`
+	DirectusStaticToken       string        `env:"DIRECTUS_STATIC_TOKEN"`
+	HubsBaseURI               string        `env:"HUBS_BASE_URI,required"`
+	EventBackupInterval       string        `env:"EVENT_BACKUP_INTERVAL" envDefault:"@daily"`
+	CORSAllowedOrigins        []string      `env:"CORS_ALLOWED_ORIGINS" envSeparator:","`
+	CORSAllowedHeaders        []string      `env:"CORS_ALLOWED_HEADERS" envSeparator:"," envDefault:"Content-Type,Content-Length,Accept-Encoding,X-CSRF-Token,Authorization,Accept,Origin,Cache-Control,X-Requested-With"`
+	CORSMaxAge                int           `env:"CORS_MAX_AGE" envDefault:"600"`
+	ShutdownDelay             time.Duration `env:"SHUTDOWN_DELAY" envDefault:"5s"`
+	ShutdownTimeout           time.Duration `env:"SHUTDOWN_TIMEOUT" envDefault:"30s"`
+	HealthCheckTimeout        time.Duration `env:"HEALTH_CHECK_TIMEOUT" envDefault:"3s"`
+	HealthCacheTTL            time.Duration `env:"HEALTH_CACHE_TTL" envDefault:"5s"`
+	TracingExporter           string        `env:"TRACING_EXPORTER" envDefault:"none"`
+	TracingSampleRatio        float64       `env:"TRACING_SAMPLE_RATIO" envDefault:"1"`
+	AdminToken                string        `env:"ADMIN_TOKEN"`
+	DirectusTimeout           time.Duration `env:"DIRECTUS_TIMEOUT" envDefault:"30s"`
+	MastodonTimeout           time.Duration `env:"MASTODON_TIMEOUT" envDefault:"10s"`
+	UpstreamRetryCount        int           `env:"UPSTREAM_RETRY_COUNT" envDefault:"2"`
+	UpstreamRetryWait         time.Duration `env:"UPSTREAM_RETRY_WAIT" envDefault:"100ms"`
+	UpstreamRetryMaxWait      time.Duration `env:"UPSTREAM_RETRY_MAX_WAIT" envDefault:"2s"`
+	CircuitBreakerThreshold   int           `env:"CIRCUIT_BREAKER_THRESHOLD" envDefault:"5"`
+	CircuitBreakerOpenTimeout time.Duration `env:"CIRCUIT_BREAKER_OPEN_TIMEOUT" envDefault:"30s"`
+	DirectusTokenRefreshAhead time.Duration `env:"DIRECTUS_TOKEN_REFRESH_AHEAD" envDefault:"1m"`
+	ResponseCacheTTL          time.Duration `env:"RESPONSE_CACHE_TTL" envDefault:"30s"`
+	ResponseCacheStale        time.Duration `env:"RESPONSE_CACHE_STALE" envDefault:"5m"`
+	ResponseCacheMaxMB        int           `env:"RESPONSE_CACHE_MAX_MB" envDefault:"32"`
+	DirectusWebhookSecret     string        `env:"DIRECTUS_WEBHOOK_SECRET"`
+	StreamHeartbeatInterval   time.Duration `env:"STREAM_HEARTBEAT_INTERVAL" envDefault:"15s"`
+	StreamMaxSubscriptions    int           `env:"STREAM_MAX_SUBSCRIPTIONS" envDefault:"50"`
+	StreamBufferSize          int           `env:"STREAM_BUFFER_SIZE" envDefault:"32"`
+	EventLifecycleInterval    string        `env:"EVENT_LIFECYCLE_INTERVAL" envDefault:"@every 5m"`
+	EventLifecycleLookahead   time.Duration `env:"EVENT_LIFECYCLE_LOOKAHEAD" envDefault:"24h"`
+	EventReminderLead         time.Duration `env:"EVENT_REMINDER_LEAD" envDefault:"15m"`
+	EventReminderMessage      string        `env:"EVENT_REMINDER_MESSAGE" envDefault:"{title} starts in {minutes} minutes"`
+	EventNotifier             string        `env:"EVENT_NOTIFIER" envDefault:"log"`
+	EventNotifierFile         string        `env:"EVENT_NOTIFIER_FILE"`
+	MastodonNotifierToken     string        `env:"MASTODON_NOTIFIER_TOKEN"`
+	MastodonAnnouncerToken    string        `env:"MASTODON_ANNOUNCER_TOKEN"`
+	AnnouncerLocale           string        `env:"ANNOUNCER_LOCALE"`
+	AnnouncerVisibility       string        `env:"ANNOUNCER_VISIBILITY" envDefault:"public"`
+	AnnouncerEventURL         string        `env:"ANNOUNCER_EVENT_URL"`
+	AnnouncerTimeZone         string        `env:"ANNOUNCER_TIME_ZONE" envDefault:"UTC"`
+	AnnouncerMaxChars         int           `env:"ANNOUNCER_MAX_CHARS" envDefault:"500"`
+	GLBMaxMB                  int           `env:"GLB_MAX_MB" envDefault:"20"`
+	GLBMaxTriangles           int           `env:"GLB_MAX_TRIANGLES" envDefault:"100000"`
+	GLBMaxTextures            int           `env:"GLB_MAX_TEXTURES" envDefault:"16"`
+	GLBMaxTextureSize         int           `env:"GLB_MAX_TEXTURE_SIZE" envDefault:"4096"`
+	GLBRequiredNodes          []string      `env:"GLB_REQUIRED_NODES" envSeparator:"," envDefault:"Head"`
+	ImportMode                string        `env:"IMPORT_MODE" envDefault:"fetch"`
+	ImportAllowedSchemes      []string      `env:"IMPORT_ALLOWED_SCHEMES" envSeparator:"," envDefault:"https"`
+	ImportAllowedHosts        []string      `env:"IMPORT_ALLOWED_HOSTS" envSeparator:","`
+	ImportAllowedCIDRs        []string      `env:"IMPORT_ALLOWED_CIDRS" envSeparator:","`
+	ImportAllowedContentTypes []string      `env:"IMPORT_ALLOWED_CONTENT_TYPES" envSeparator:"," envDefault:"model/gltf-binary,application/octet-stream"`
+	ImportMaxMB               int           `env:"IMPORT_MAX_MB" envDefault:"20"`
+	ImportTimeout             time.Duration `env:"IMPORT_TIMEOUT" envDefault:"30s"`
+	ImageMaxMB                int           `env:"IMAGE_MAX_MB" envDefault:"10"`
+	ImageMaxDimension         int           `env:"IMAGE_MAX_DIMENSION" envDefault:"4096"`
+	ImageThumbnailSizes       []int         `env:"IMAGE_THUMBNAIL_SIZES" envSeparator:"," envDefault:"128,256,512"`
+	AvatarQuotaCount          int64         `env:"AVATAR_QUOTA_COUNT" envDefault:"20"`
+	AvatarQuotaMB             int64         `env:"AVATAR_QUOTA_MB" envDefault:"200"`
+	AvatarPopularLimit        int64         `env:"AVATAR_POPULAR_LIMIT" envDefault:"1000"`
+	OrphanGCInterval          string        `env:"ORPHAN_GC_INTERVAL" envDefault:"@daily"`
+	OrphanGCGracePeriod       time.Duration `env:"ORPHAN_GC_GRACE_PERIOD" envDefault:"24h"`
+	OrphanGCDryRun            bool          `env:"ORPHAN_GC_DRY_RUN" envDefault:"true"`
+	OrphanGCScope             string        `env:"ORPHAN_GC_SCOPE" envDefault:"service"`
+	AssetSigningKey           string        `env:"ASSET_SIGNING_KEY"`
+	AssetURLTTL               time.Duration `env:"ASSET_URL_TTL" envDefault:"1h"`
+	AssetBaseURI              string        `env:"ASSET_BASE_URI"`
+	AssetCDNBaseURI           string        `env:"ASSET_CDN_BASE_URI"`
+	ImagePresetMode           string        `env:"IMAGE_PRESET_MODE" envDefault:"transform"`
+	ImagePresetQuality        int           `env:"IMAGE_PRESET_QUALITY" envDefault:"80"`
+	VideoHLSEnabled           bool          `env:"VIDEO_HLS_ENABLED" envDefault:"false"`
+	VideoCaptionsEnabled      bool          `env:"VIDEO_CAPTIONS_ENABLED" envDefault:"false"`
 }
 
 func (r envVariable) Validate() bool {
@@ -59,13 +130,180 @@ func (r envVariable) Validate() bool {
 		return false
 	}
 
-	if EnvVariable.DirectusAdminEmail == "" {
-		log.Fatalf("ERR: required environment variable \"DIRECTUS_ADMIN_EMAIL\" is not set")
+	if EnvVariable.DirectusStaticToken == "" && EnvVariable.DirectusAdminEmail == "" {
+		log.Fatalf("ERR: required environment variable \"DIRECTUS_ADMIN_EMAIL\" is not set, or set \"DIRECTUS_STATIC_TOKEN\" instead")
 		return false
 	}
 
-	if EnvVariable.DirectusAdminPassword == "" {
-		log.Fatalf("ERR: required environment variable \"DIRECTUS_ADMIN_PASSWORD\" is not set")
+	if EnvVariable.DirectusStaticToken == "" && EnvVariable.DirectusAdminPassword == "" {
+		log.Fatalf("ERR: required environment variable \"DIRECTUS_ADMIN_PASSWORD\" is not set, or set \"DIRECTUS_STATIC_TOKEN\" instead")
+		return false
+	}
+
+	if EnvVariable.CORSMaxAge < 0 {
+		log.Fatalf("ERR: environment variable \"CORS_MAX_AGE\" should not be negative")
+		return false
+	}
+
+	if d := strings.ToLower(EnvVariable.TracingExporter); d != "none" && d != "stdout" && d != "otlp" {
+		log.Fatalf("ERR: environment variable \"TRACING_EXPORTER\" should be \"NONE|STDOUT|OTLP\"")
+		return false
+	}
+
+	if EnvVariable.TracingSampleRatio < 0 || EnvVariable.TracingSampleRatio > 1 {
+		log.Fatalf("ERR: environment variable \"TRACING_SAMPLE_RATIO\" should be 0~1")
+		return false
+	}
+
+	if EnvVariable.DirectusTimeout <= 0 || EnvVariable.MastodonTimeout <= 0 {
+		log.Fatalf("ERR: environment variable \"DIRECTUS_TIMEOUT\" and \"MASTODON_TIMEOUT\" should be positive")
+		return false
+	}
+
+	if EnvVariable.UpstreamRetryCount < 0 || EnvVariable.UpstreamRetryWait <= 0 || EnvVariable.UpstreamRetryMaxWait < EnvVariable.UpstreamRetryWait {
+		log.Fatalf("ERR: environment variable \"UPSTREAM_RETRY_COUNT\" should not be negative and \"UPSTREAM_RETRY_MAX_WAIT\" should not be less than \"UPSTREAM_RETRY_WAIT\"")
+		return false
+	}
+
+	if EnvVariable.CircuitBreakerThreshold <= 0 || EnvVariable.CircuitBreakerOpenTimeout <= 0 {
+		log.Fatalf("ERR: environment variable \"CIRCUIT_BREAKER_THRESHOLD\" and \"CIRCUIT_BREAKER_OPEN_TIMEOUT\" should be positive")
+		return false
+	}
+
+	if EnvVariable.DirectusTokenRefreshAhead < 0 {
+		log.Fatalf("ERR: environment variable \"DIRECTUS_TOKEN_REFRESH_AHEAD\" should not be negative")
+		return false
+	}
+
+	if EnvVariable.ResponseCacheTTL < 0 || EnvVariable.ResponseCacheStale < 0 || EnvVariable.ResponseCacheMaxMB < 0 {
+		log.Fatalf("ERR: environment variable \"RESPONSE_CACHE_TTL\", \"RESPONSE_CACHE_STALE\" and \"RESPONSE_CACHE_MAX_MB\" should not be negative")
+		return false
+	}
+
+	if EnvVariable.StreamHeartbeatInterval <= 0 || EnvVariable.StreamMaxSubscriptions <= 0 || EnvVariable.StreamBufferSize <= 0 {
+		log.Fatalf("ERR: environment variable \"STREAM_HEARTBEAT_INTERVAL\", \"STREAM_MAX_SUBSCRIPTIONS\" and \"STREAM_BUFFER_SIZE\" should be positive")
+		return false
+	}
+
+	if EnvVariable.EventReminderLead < 0 || EnvVariable.EventLifecycleLookahead <= EnvVariable.EventReminderLead {
+		log.Fatalf("ERR: environment variable \"EVENT_REMINDER_LEAD\" should not be negative and \"EVENT_LIFECYCLE_LOOKAHEAD\" should be greater than it")
+		return false
+	}
+
+	if d := strings.ToLower(EnvVariable.EventNotifier); d != "none" && d != "log" && d != "mastodon" {
+		log.Fatalf("ERR: environment variable \"EVENT_NOTIFIER\" should be \"NONE|LOG|MASTODON\"")
+		return false
+	}
+
+	if strings.ToLower(EnvVariable.EventNotifier) == "mastodon" && EnvVariable.MastodonNotifierToken == "" {
+		log.Fatalf("ERR: environment variable \"MASTODON_NOTIFIER_TOKEN\" is required by \"EVENT_NOTIFIER=MASTODON\"")
+		return false
+	}
+
+	if d := strings.ToLower(EnvVariable.AnnouncerVisibility); d != "public" && d != "unlisted" && d != "private" {
+		log.Fatalf("ERR: environment variable \"ANNOUNCER_VISIBILITY\" should be \"PUBLIC|UNLISTED|PRIVATE\"")
+		return false
+	}
+
+	if AnnouncerLocation, err = time.LoadLocation(EnvVariable.AnnouncerTimeZone); err != nil {
+		log.Fatalf("ERR: environment variable \"ANNOUNCER_TIME_ZONE\" is invalid: %v", err)
+		return false
+	}
+
+	if EnvVariable.AnnouncerMaxChars < 100 {
+		log.Fatalf("ERR: environment variable \"ANNOUNCER_MAX_CHARS\" should not be less than 100")
+		return false
+	}
+
+	if EnvVariable.GLBMaxMB <= 0 {
+		log.Fatalf("ERR: environment variable \"GLB_MAX_MB\" should be positive")
+		return false
+	}
+
+	if EnvVariable.GLBMaxTriangles < 0 || EnvVariable.GLBMaxTextures < 0 || EnvVariable.GLBMaxTextureSize < 0 {
+		log.Fatalf("ERR: environment variables \"GLB_MAX_TRIANGLES\", \"GLB_MAX_TEXTURES\" and \"GLB_MAX_TEXTURE_SIZE\" should not be negative")
+		return false
+	}
+
+	if d := strings.ToLower(EnvVariable.ImportMode); d != "fetch" && d != "directus" {
+		log.Fatalf("ERR: environment variable \"IMPORT_MODE\" should be \"FETCH|DIRECTUS\"")
+		return false
+	}
+
+	if EnvVariable.ImportMaxMB <= 0 || EnvVariable.ImportTimeout <= 0 {
+		log.Fatalf("ERR: environment variables \"IMPORT_MAX_MB\" and \"IMPORT_TIMEOUT\" should be positive")
+		return false
+	}
+
+	ImportAllowedNets = nil
+	for _, cidr := range EnvVariable.ImportAllowedCIDRs {
+		_, n, err := net.ParseCIDR(strings.TrimSpace(cidr))
+		if err != nil {
+			log.Fatalf("ERR: environment variable \"IMPORT_ALLOWED_CIDRS\" is invalid: %v", err)
+			return false
+		}
+		ImportAllowedNets = append(ImportAllowedNets, n)
+	}
+
+	if EnvVariable.ImageMaxMB <= 0 || EnvVariable.ImageMaxDimension <= 0 {
+		log.Fatalf("ERR: environment variables \"IMAGE_MAX_MB\" and \"IMAGE_MAX_DIMENSION\" should be positive")
+		return false
+	}
+
+	for _, size := range EnvVariable.ImageThumbnailSizes {
+		if size <= 0 {
+			log.Fatalf("ERR: environment variable \"IMAGE_THUMBNAIL_SIZES\" should be positive sizes")
+			return false
+		}
+	}
+
+	if EnvVariable.AvatarQuotaCount < 0 || EnvVariable.AvatarQuotaMB < 0 {
+		log.Fatalf("ERR: environment variables \"AVATAR_QUOTA_COUNT\" and \"AVATAR_QUOTA_MB\" should not be negative")
+		return false
+	}
+
+	if EnvVariable.AvatarPopularLimit <= 0 {
+		log.Fatalf("ERR: environment variable \"AVATAR_POPULAR_LIMIT\" should be positive")
+		return false
+	}
+
+	if EnvVariable.OrphanGCGracePeriod < time.Hour {
+		log.Fatalf("ERR: environment variable \"ORPHAN_GC_GRACE_PERIOD\" should not be less than 1h")
+		return false
+	}
+
+	if d := strings.ToLower(EnvVariable.OrphanGCScope); d != "service" && d != "all" {
+		log.Fatalf("ERR: environment variable \"ORPHAN_GC_SCOPE\" should be \"SERVICE|ALL\"")
+		return false
+	}
+
+	if len(EnvVariable.AssetSigningKey) > 0 && len(EnvVariable.AssetSigningKey) < 32 {
+		log.Fatalf("ERR: environment variable \"ASSET_SIGNING_KEY\" should be at least 32 characters")
+		return false
+	}
+
+	if len(EnvVariable.AssetSigningKey) > 0 && len(EnvVariable.AssetBaseURI) == 0 {
+		log.Fatalf("ERR: environment variable \"ASSET_BASE_URI\" is required by \"ASSET_SIGNING_KEY\"")
+		return false
+	}
+
+	if EnvVariable.AssetURLTTL < time.Minute {
+		log.Fatalf("ERR: environment variable \"ASSET_URL_TTL\" should not be less than 1m")
+		return false
+	}
+
+	if d := strings.ToLower(EnvVariable.ImagePresetMode); d != "transform" && d != "key" {
+		log.Fatalf("ERR: environment variable \"IMAGE_PRESET_MODE\" should be \"TRANSFORM|KEY\"")
+		return false
+	}
+
+	if EnvVariable.ImagePresetQuality < 1 || EnvVariable.ImagePresetQuality > 100 {
+		log.Fatalf("ERR: environment variable \"IMAGE_PRESET_QUALITY\" should be 1~100")
+		return false
+	}
+
+	if EnvVariable.ShutdownDelay < 0 || EnvVariable.ShutdownTimeout <= 0 {
+		log.Fatalf("ERR: environment variable \"SHUTDOWN_DELAY\" should not be negative and \"SHUTDOWN_TIMEOUT\" should be positive")
 		return false
 	}
 
@@ -76,6 +314,12 @@ func (r envVariable) Validate() bool {
 var EnvVariable envVariable
 var DefaultMastodonAccountDomain string
 
+// AnnouncerLocation is the time zone of event times in announcements
+var AnnouncerLocation = time.UTC
+
+// ImportAllowedNets are the internal ranges of IMPORT_ALLOWED_CIDRS remote assets may be fetched from
+var ImportAllowedNets []*net.IPNet
+
 // Setup loads and validates config from environment variables
 func Setup() {
 
@@ -90,6 +334,11 @@ func Setup() {
 	}
 }
 
+// IsDirectusStaticToken tells whether directus is called with a static token instead of the admin login
+func IsDirectusStaticToken() bool {
+	return EnvVariable.DirectusStaticToken != ""
+}
+
 // IsDevEnv should enable gin debug mode for more logs
 func IsDevEnv() bool {
 	d := strings.ToLower(EnvVariable.Environment)
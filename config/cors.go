@@ -0,0 +1,81 @@
+package config
+
+import (
+	"net/url"
+	"strings"
+)
+
+// GetCORSAllowedOrigins returns the origins allowed to call this service.
+// When CORS_ALLOWED_ORIGINS is not set, develop environment allows any origin
+// and other environments only allow the origin of HUBS_BASE_URI.
+func GetCORSAllowedOrigins() []string {
+	if len(EnvVariable.CORSAllowedOrigins) > 0 {
+		return EnvVariable.CORSAllowedOrigins
+	}
+
+	if IsDevEnv() {
+		return []string{"*"}
+	}
+
+	uri, err := url.Parse(EnvVariable.HubsBaseURI)
+	if err != nil || len(uri.Host) == 0 {
+		return []string{}
+	}
+	return []string{uri.Scheme + "://" + uri.Host}
+}
+
+// IsCORSOriginAllowed checks the request origin against the allowlist.
+// A pattern could be "*", an exact origin or a wildcard subdomain like "https://*.viveport.com"
+func IsCORSOriginAllowed(origin string) bool {
+	if len(origin) == 0 {
+		return false
+	}
+
+	origin = strings.ToLower(origin)
+	for _, pattern := range GetCORSAllowedOrigins() {
+		if matchOrigin(strings.ToLower(strings.TrimSpace(pattern)), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCORSCredentialsAllowed tells whether the origin is listed by a pattern other than "*".
+// Origins only allowed by "*" are answered with a literal "*" and without credentials
+func IsCORSCredentialsAllowed(origin string) bool {
+	if len(origin) == 0 {
+		return false
+	}
+
+	origin = strings.ToLower(origin)
+	for _, pattern := range GetCORSAllowedOrigins() {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if strings.TrimSuffix(pattern, "/") != "*" && matchOrigin(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchOrigin(pattern, origin string) bool {
+	pattern = strings.TrimSuffix(pattern, "/")
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+
+	i := strings.Index(pattern, "*.")
+	if i < 0 {
+		return false
+	}
+
+	// "https://*.viveport.com" => prefix "https://", suffix ".viveport.com"
+	prefix, suffix := pattern[:i], pattern[i+1:]
+	if len(origin) <= len(prefix)+len(suffix) ||
+		!strings.HasPrefix(origin, prefix) ||
+		!strings.HasSuffix(origin, suffix) {
+		return false
+	}
+
+	subdomain := origin[len(prefix) : len(origin)-len(suffix)]
+	return !strings.ContainsAny(subdomain, "/:@")
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"hubs-cms-go/logger"
 	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -39,8 +41,18 @@ func genUrlValues(locale string) url.Values {
 		"hosted_accounts.account_id.is_admin,"+
 		"images.directus_files_id,"+
 		"videos.video_id.cover_image,"+
-		"videos.video_id.mp4,"+
-		"videos.video_id.webm,"+
+		"videos.video_id.mp4.id,"+
+		"videos.video_id.mp4.type,"+
+		"videos.video_id.mp4.filesize,"+
+		"videos.video_id.mp4.width,"+
+		"videos.video_id.mp4.height,"+
+		"videos.video_id.mp4.duration,"+
+		"videos.video_id.webm.id,"+
+		"videos.video_id.webm.type,"+
+		"videos.video_id.webm.filesize,"+
+		"videos.video_id.webm.width,"+
+		"videos.video_id.webm.height,"+
+		"videos.video_id.webm.duration,"+
 		"hosts.event_participate_id.id,"+
 		"hosts.event_participate_id.name,"+
 		"hosts.event_participate_id.description,"+
@@ -52,8 +64,25 @@ func genUrlValues(locale string) url.Values {
 		"rooms.room_id.title,"+
 		"rooms.room_id.description,"+
 		"rooms.room_id.gallery,"+
+		"rooms.room_id.is_public,"+
 		"rooms.room_id.hubs_id")
 
+	// directus refuses unknown fields, hls and captions are only read once the schema has them
+	if EnvVariable.VideoHLSEnabled {
+		q.Add("fields", "videos.video_id.hls.id,"+
+			"videos.video_id.hls.type,"+
+			"videos.video_id.hls.filesize,"+
+			"videos.video_id.hls.width,"+
+			"videos.video_id.hls.height,"+
+			"videos.video_id.hls.duration")
+	}
+	if EnvVariable.VideoCaptionsEnabled {
+		q.Add("fields", "videos.video_id.captions.languages_code,"+
+			"videos.video_id.captions.kind,"+
+			"videos.video_id.captions.label,"+
+			"videos.video_id.captions.file")
+	}
+
 	if locale != "" {
 		q.Add("fields", "translations.*")
 		q.Set("deep[translations][_filter][languages_code][_eq]", locale)
@@ -65,13 +94,18 @@ func genUrlValues(locale string) url.Values {
 		q.Set("deep[category][translations][_filter][languages_code][_eq]", locale)
 
 		q.Add("fields", "hosts.event_participate_id.translations.*")
-		q.Set("deep[hosts][event_participate_id][translations][_filter][languages_code]", locale)
+		q.Set("deep[hosts][event_participate_id][translations][_filter][languages_code][_eq]", locale)
 
 		q.Add("fields", "speakers.event_participate_id.translations.*")
-		q.Set("deep[speakers][event_participate_id][translations][_filter][languages_code]", locale)
+		q.Set("deep[speakers][event_participate_id][translations][_filter][languages_code][_eq]", locale)
 
 		q.Add("fields", "rooms.room_id.translations.*")
-		q.Set("deep[rooms][room_id][translations][_filter][languages_code]", locale)
+		q.Set("deep[rooms][room_id][translations][_filter][languages_code][_eq]", locale)
+
+		// captions in other locales are left out, players show the one of the request
+		if EnvVariable.VideoCaptionsEnabled {
+			q.Set("deep[videos][video_id][captions][_filter][languages_code][_eq]", locale)
+		}
 	} else {
 		q.Add("fields", "translations.id") // reduce payload
 	}
@@ -129,6 +163,32 @@ func GetDirectusGetEventURISimple(eventID string) string {
 	return uri.String()
 }
 
+// GetEventReminderMessage fills the {title} and {minutes} of EVENT_REMINDER_MESSAGE
+func GetEventReminderMessage(title string, minutes int) string {
+	return strings.NewReplacer("{title}", title, "{minutes}", strconv.Itoa(minutes)).Replace(EnvVariable.EventReminderMessage)
+}
+
+// GetDirectusGetLifecycleEventsURI lists the events not marked as ended that start before the given time
+func GetDirectusGetLifecycleEventsURI(before time.Time, offset, limit int64) string {
+	uri, err := genUrl("")
+	if err != nil {
+		return ""
+	}
+	q := url.Values{}
+	q.Set("fields", "id,title,start_time,end_time,reminded_at,started_at,ended_at")
+	q.Set("filter[start_time][_lte]", before.UTC().Format(time.RFC3339))
+	q.Set("filter[end_time][_nnull]", "true")
+	q.Set("filter[ended_at][_null]", "true")
+	q.Set("sort", "start_time")
+	q.Set("meta", "filter_count")
+	q.Set("offset", fmt.Sprintf("%v", offset))
+	if limit > 0 {
+		q.Set("limit", fmt.Sprintf("%v", limit))
+	}
+	uri.RawQuery = q.Encode()
+	return uri.String()
+}
+
 func GetDirectusGraphQLURI() string {
 	return fmt.Sprintf("%s/graphql", EnvVariable.DirectusBaseURI)
 }
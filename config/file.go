@@ -0,0 +1,55 @@
+package config
+
+import (
+	"fmt"
+	"hubs-cms-go/logger"
+	"net/url"
+	"time"
+)
+
+// GetDirectusFileReferencesURI lists the fields of a collection which refer to directus files
+func GetDirectusFileReferencesURI(collection, fields string, offset, limit int64) string {
+	uri, err := url.Parse(EnvVariable.DirectusBaseURI)
+	if err != nil {
+		logger.Error.Printf("[GetDirectusFileReferencesURI] Parse %s error: %v\n", EnvVariable.DirectusBaseURI, err)
+		return ""
+	}
+
+	uri.Path = "/items/" + collection
+	q := url.Values{}
+	q.Set("fields", fields)
+	q.Set("sort", "id")
+	q.Set("meta", "filter_count")
+	q.Set("offset", fmt.Sprintf("%v", offset))
+	q.Set("limit", fmt.Sprintf("%v", limit))
+	uri.RawQuery = q.Encode()
+	return uri.String()
+}
+
+// GetDirectusGetFilesBeforeURI lists the files uploaded before the given time, by the given user when it is not empty
+func GetDirectusGetFilesBeforeURI(before time.Time, uploadedBy string, offset, limit int64) string {
+	uri, err := url.Parse(EnvVariable.DirectusBaseURI)
+	if err != nil {
+		logger.Error.Printf("[GetDirectusGetFilesBeforeURI] Parse %s error: %v\n", EnvVariable.DirectusBaseURI, err)
+		return ""
+	}
+
+	uri.Path = "/files"
+	q := url.Values{}
+	q.Set("fields", "id,filename_download,filesize,uploaded_on")
+	q.Set("filter[uploaded_on][_lt]", before.UTC().Format(time.RFC3339))
+	if uploadedBy != "" {
+		q.Set("filter[uploaded_by][_eq]", uploadedBy)
+	}
+	q.Set("sort", "uploaded_on,id")
+	q.Set("meta", "filter_count")
+	q.Set("offset", fmt.Sprintf("%v", offset))
+	q.Set("limit", fmt.Sprintf("%v", limit))
+	uri.RawQuery = q.Encode()
+	return uri.String()
+}
+
+// GetDirectusDeleteFilesURI deletes the files whose ids are in the body
+func GetDirectusDeleteFilesURI() string {
+	return fmt.Sprintf("%s/files", EnvVariable.DirectusBaseURI)
+}
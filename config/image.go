@@ -0,0 +1,43 @@
+package config
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ImagePreset is a directus image transform, fit is cover, contain, inside or outside
+type ImagePreset struct {
+	Width  int
+	Height int
+	Fit    string
+}
+
+// ImagePresetNames lists the presets of images, smallest first
+var ImagePresetNames = []string{"thumb", "card", "hero"}
+
+// ImagePresets are the transforms of the presets in the transform IMAGE_PRESET_MODE
+var ImagePresets = map[string]ImagePreset{
+	"thumb": {Width: 256, Height: 256, Fit: "cover"},
+	"card":  {Width: 640, Height: 360, Fit: "cover"},
+	"hero":  {Width: 1920, Height: 1080, Fit: "inside"},
+}
+
+// GetImagePresetQuery returns the query of the directus asset transform of the preset, empty for the original.
+// IMAGE_PRESET_MODE=key refers to directus storage asset presets of the same names instead
+func GetImagePresetQuery(name string) string {
+	preset, found := ImagePresets[name]
+	if !found {
+		return ""
+	}
+	if strings.EqualFold(EnvVariable.ImagePresetMode, "key") {
+		return "key=" + name
+	}
+
+	// keys in the order of url.Values.Encode, so the query is the same wherever it is built
+	return "fit=" + preset.Fit +
+		"&format=webp" +
+		"&height=" + strconv.Itoa(preset.Height) +
+		"&quality=" + strconv.Itoa(EnvVariable.ImagePresetQuality) +
+		"&width=" + strconv.Itoa(preset.Width) +
+		"&withoutEnlargement=true"
+}
@@ -11,3 +11,23 @@ func GetMastodonVerifyCredentialsURI() string {
 func GetMastodonUpdateCredentialsURI() string {
 	return fmt.Sprintf("%s/api/v1/accounts/update_credentials", EnvVariable.MastodonBaseURI)
 }
+
+func GetMastodonInstanceURI() string {
+	return fmt.Sprintf("%s/api/v1/instance", EnvVariable.MastodonBaseURI)
+}
+
+func GetMastodonStatusesURI() string {
+	return fmt.Sprintf("%s/api/v1/statuses", EnvVariable.MastodonBaseURI)
+}
+
+func GetMastodonStatusURI(statusID string) string {
+	return fmt.Sprintf("%s/api/v1/statuses/%s", EnvVariable.MastodonBaseURI, statusID)
+}
+
+func GetMastodonUploadMediaURI() string {
+	return fmt.Sprintf("%s/api/v2/media", EnvVariable.MastodonBaseURI)
+}
+
+func GetMastodonMediaURI(mediaID string) string {
+	return fmt.Sprintf("%s/api/v1/media/%s", EnvVariable.MastodonBaseURI, mediaID)
+}
@@ -7,5 +7,10 @@ const HeaderMastodonDisplayName = "X-Mastodon-Display-Name"
 const HeaderMastodonUsername = "X-Mastodon-Username"
 const HeaderMastodonAvatar = "X-Mastodon-Avatar"
 const HeaderMastodonToken = "X-Mastodon-Token"
+const HeaderRequestID = "X-Request-Id"
 const HeaderMastodonHandlerStatus = "X-Mastodon-Handler-Status"
+const HeaderWebhookSecret = "X-Webhook-Secret"
+const HeaderWebhookSignature = "X-Webhook-Signature"
 const CacheKeyDirectusAccessToken = "CacheKeyDirectusAccessToken"
+const CacheKeyDirectusRefreshToken = "CacheKeyDirectusRefreshToken"
+const CacheKeyReadiness = "CacheKeyReadiness"
@@ -11,6 +11,8 @@ type DirectusAccount struct {
 	ActiveAvatar    *DirectusAvatar `json:"active_avatar"`
 	LikedRooms      []string        `json:"liked_rooms"`
 	LikedEvents     []string        `json:"liked_events"`
+	FavoriteAvatars []string        `json:"favorite_avatars"`
+	AvatarQuota
 }
 
 type DirectusCreateAccountRequest struct {
@@ -25,14 +27,22 @@ type DirectusUpsertAccountResponse struct {
 }
 
 type DirectusAccountResponseData struct {
-	ID              string                      `json:"id"`
-	MastodonAccount string                      `json:"mastodon_account"`
-	MastodonAvatar  string                      `json:"mastodon_avatar"`
-	DisplayName     string                      `json:"display_name"`
-	IsAdmin         bool                        `json:"is_admin"`
-	ActiveAvatar    DirectusAvatarResponseData  `json:"active_avatar"`
-	LikedRooms      []DirectusAccountLikedRoom  `json:"liked_rooms"`
-	LikedEvents     []DirectusAccountLikedEvent `json:"liked_events"`
+	ID              string                          `json:"id"`
+	MastodonAccount string                          `json:"mastodon_account"`
+	MastodonAvatar  string                          `json:"mastodon_avatar"`
+	DisplayName     string                          `json:"display_name"`
+	IsAdmin         bool                            `json:"is_admin"`
+	ActiveAvatar    DirectusAvatarResponseData      `json:"active_avatar"`
+	LikedRooms      []DirectusAccountLikedRoom      `json:"liked_rooms"`
+	LikedEvents     []DirectusAccountLikedEvent     `json:"liked_events"`
+	FavoriteAvatars []DirectusAccountFavoriteAvatar `json:"favorite_avatars"`
+	AvatarQuota
+}
+
+// AvatarQuota overrides AVATAR_QUOTA_COUNT and AVATAR_QUOTA_MB for an account, null keeps the default and 0 is unlimited
+type AvatarQuota struct {
+	AvatarQuotaCount *int64 `json:"avatar_quota_count" binding:"omitempty,min=0"`
+	AvatarQuotaMB    *int64 `json:"avatar_quota_mb" binding:"omitempty,min=0"`
 }
 
 type DirectusAccountLikedRoom struct {
@@ -45,6 +55,11 @@ type DirectusAccountLikedEvent struct {
 	EventID string      `json:"event_id"`
 }
 
+type DirectusAccountFavoriteAvatar struct {
+	ID       json.Number `json:"id"`
+	AvatarID string      `json:"avatar_id"`
+}
+
 func (r DirectusUpsertAccountResponse) Validate() bool {
 	return len(r.Data.MastodonAccount) > 0
 }
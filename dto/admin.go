@@ -0,0 +1,11 @@
+package dto
+
+// LogLevelRequest is the payload to change the log level at runtime
+type LogLevelRequest struct {
+	Level string `json:"level" binding:"required"`
+}
+
+// LogLevelResponse is the current log level
+type LogLevelResponse struct {
+	Level string `json:"level"`
+}
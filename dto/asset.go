@@ -0,0 +1,21 @@
+package dto
+
+import "hubs-cms-go/config"
+
+type AssetIDRequest struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+type AssetQuery struct {
+	Sig    string `form:"sig" binding:"required,hexadecimal"`
+	Exp    int64  `form:"exp" binding:"required,min=1"`
+	Preset string `form:"preset" binding:"omitempty,oneof=thumb card hero"`
+}
+
+// ImageLinks returns the link of the image in the preset. Without a preset it returns the original and the srcset of the presets
+func ImageLinks(assetID string, public bool, preset string) (string, map[string]string) {
+	if len(preset) > 0 {
+		return config.GetImageURI(assetID, public, preset), nil
+	}
+	return config.GetAssetURI(assetID, public), config.GetImageSrcset(assetID, public)
+}
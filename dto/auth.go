@@ -5,6 +5,11 @@ type DirectusAuthLoginRequest struct {
 	Password string `json:"password"`
 }
 
+type DirectusAuthRefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	Mode         string `json:"mode"`
+}
+
 type DirectusAuthLoginResponse struct {
 	Data DirectusAuthLoginResponseData `json:"data"`
 }
@@ -22,3 +27,28 @@ func (r DirectusAuthLoginResponse) Validate() bool {
 		len(r.Data.RefreshToken) > 0 &&
 		r.Data.Expires > 0
 }
+
+type DirectusGetUsersMeResponse struct {
+	Data struct {
+		Role *struct {
+			AdminAccess bool `json:"admin_access"`
+		} `json:"role"`
+	} `json:"data"`
+}
+
+type DirectusPermission struct {
+	Collection string   `json:"collection"`
+	Action     string   `json:"action"`
+	Fields     []string `json:"fields"`
+}
+
+type DirectusGetPermissionsResponse struct {
+	Data []DirectusPermission `json:"data"`
+}
+
+// DirectusPermissionReport compares the grants of the directus token with what this service needs
+type DirectusPermissionReport struct {
+	AdminAccess bool
+	Missing     []string
+	Excess      []string
+}
@@ -1,6 +1,12 @@
 package dto
 
-import "mime/multipart"
+import (
+	"encoding/json"
+	"mime/multipart"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
 
 type DirectusGetAvatarResponse struct {
 	Data DirectusAvatarResponseData `json:"data"`
@@ -11,13 +17,15 @@ func (r DirectusGetAvatarResponse) Validate() bool {
 }
 
 type DirectusAvatarResponseData struct {
-	ID       string `json:"id"`
-	Snapshot string `json:"snapshot"`
-	GLB      string `json:"glb"`
-	Owner    string `json:"owner"`
-	Source   string `json:"source"`
-	Title    string `json:"title"`
-	IsPublic bool   `json:"is_public"`
+	ID                 string            `json:"id"`
+	Snapshot           string            `json:"snapshot"`
+	SnapshotThumbnails map[string]string `json:"snapshot_thumbnails"`
+	GLB                string            `json:"glb"`
+	Owner              string            `json:"owner"`
+	Source             string            `json:"source"`
+	Title              string            `json:"title"`
+	IsPublic           bool              `json:"is_public"`
+	Tags               []string          `json:"tags"`
 }
 
 func (r DirectusAvatarResponseData) Validate() bool {
@@ -48,19 +56,101 @@ type GetAvatarsResponse struct {
 type DirectusAvatar struct {
 	ID       string `json:"id"`
 	Snapshot string `json:"snapshot_url"`
-	GLB      string `json:"glb_url"`
-	Owner    string `json:"owner"`
-	Source   string `json:"source"`
-	Title    string `json:"title"`
-	IsPublic bool   `json:"is_public"`
+	// SnapshotThumbnails maps thumbnail sizes to urls, null for snapshots uploaded before thumbnails were made
+	SnapshotThumbnails map[string]string `json:"snapshot_thumbnails"`
+	GLB                string            `json:"glb_url"`
+	Owner              string            `json:"owner"`
+	Source             string            `json:"source"`
+	Title              string            `json:"title"`
+	IsPublic           bool              `json:"is_public"`
+	// Tags is null for avatars created before tags were added
+	Tags []string `json:"tags"`
+	// Popularity is the number of accounts using the avatar, only set when avatars are sorted by popularity
+	Popularity *int64 `json:"popularity,omitempty"`
+}
+
+// AvatarQuery filters and sorts the public avatars, tags are separated by commas and an avatar must have all of them
+type AvatarQuery struct {
+	Tags   string `form:"tags"`
+	Source string `form:"source" binding:"omitempty,max=100"`
+	Search string `form:"search" binding:"omitempty,max=100"`
+	Sort   string `form:"sort" binding:"omitempty,oneof=popular title"`
+}
+
+func (q AvatarQuery) IsEmpty() bool {
+	return q == AvatarQuery{}
+}
+
+const (
+	// AvatarMaxTags bounds the tags of an avatar and of a query
+	AvatarMaxTags      = 10
+	avatarMaxTagLength = 32
+)
+
+var avatarTagPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]+$`)
+
+// NormalizeAvatarTags splits tags on commas, trims and lowercases them and drops duplicates. It fails on tags with other
+// characters than letters, digits, - and _, on tags longer than 32 characters and on more than AvatarMaxTags tags
+func NormalizeAvatarTags(tags []string) ([]string, bool) {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, value := range tags {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if len(tag) == 0 || seen[tag] {
+				continue
+			}
+			if utf8.RuneCountInString(tag) > avatarMaxTagLength || !avatarTagPattern.MatchString(tag) {
+				return nil, false
+			}
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, len(normalized) <= AvatarMaxTags
+}
+
+// AvatarUsage is the avatars owned by an account and the bytes of their files, a max of 0 is unlimited
+type AvatarUsage struct {
+	AvatarCount    int64 `json:"avatar_count"`
+	Bytes          int64 `json:"bytes"`
+	MaxAvatarCount int64 `json:"max_avatar_count"`
+	MaxBytes       int64 `json:"max_bytes"`
+}
+
+type DirectusGetFileSizesResponse struct {
+	Data []DirectusFileSize `json:"data"`
+}
+
+type DirectusFileSize struct {
+	ID       string      `json:"id"`
+	FileSize json.Number `json:"filesize"`
+}
+
+type AvatarFavoriteResponse struct {
+	IsFavorite bool `json:"is_favorite"`
+}
+
+type DirectusGetAvatarPopularityResponse struct {
+	Data []DirectusAvatarPopularity `json:"data"`
+}
+
+// DirectusAvatarPopularity is a group of accounts by active_avatar
+type DirectusAvatarPopularity struct {
+	ActiveAvatar string `json:"active_avatar"`
+	Count        struct {
+		ID json.Number `json:"id"`
+	} `json:"count"`
 }
 
 type UploadAvatarRequest struct {
 	Title    string                `form:"title" binding:"omitempty"`
-	GLB      string                `form:"glb" binding:"required,url"`
+	GLB      string                `form:"glb" binding:"required_without=GLBFile,omitempty,url"`
+	GLBFile  *multipart.FileHeader `form:"glb_file"`
 	Source   string                `form:"source" binding:"required"`
 	IsPublic *bool                 `form:"is_public" binding:"required"`
 	Snapshot *multipart.FileHeader `form:"snapshot" binding:"required"`
+	Tags     []string              `form:"tags"`
 }
 
 type DirectusUploadAssetResponse struct {
@@ -80,12 +170,50 @@ type DirectusImportAssetRequest struct {
 }
 
 type DirectusCreateAvatarRequest struct {
-	Snapshot string `json:"snapshot"`
-	GLB      string `json:"glb"`
-	Owner    string `json:"owner"`
-	Source   string `json:"source"`
-	Title    string `json:"title"`
-	IsPublic bool   `json:"is_public"`
+	Snapshot           string            `json:"snapshot"`
+	SnapshotThumbnails map[string]string `json:"snapshot_thumbnails"`
+	GLB                string            `json:"glb"`
+	Owner              string            `json:"owner"`
+	Source             string            `json:"source"`
+	Title              string            `json:"title"`
+	IsPublic           bool              `json:"is_public"`
+	Tags               []string          `json:"tags"`
+}
+
+type PatchAvatarRequest struct {
+	Title    *string               `form:"title" json:"title"`
+	Source   *string               `form:"source" json:"source" binding:"omitempty,min=1"`
+	IsPublic *bool                 `form:"is_public" json:"is_public"`
+	GLB      string                `form:"glb" json:"glb" binding:"omitempty,url"`
+	GLBFile  *multipart.FileHeader `form:"glb_file" json:"-"`
+	Snapshot *multipart.FileHeader `form:"snapshot" json:"-"`
+	// Tags replaces the tags, an empty list clears them
+	Tags []string `form:"tags" json:"tags"`
+}
+
+// Validate requires a change and at most one model
+func (r PatchAvatarRequest) Validate() bool {
+	changed := r.Title != nil || r.Source != nil || r.IsPublic != nil || len(r.GLB) > 0 || r.GLBFile != nil || r.Snapshot != nil || r.Tags != nil
+	return changed && !(len(r.GLB) > 0 && r.GLBFile != nil)
+}
+
+type DirectusPatchAvatarRequest struct {
+	Title              *string           `json:"title,omitempty"`
+	Source             *string           `json:"source,omitempty"`
+	IsPublic           *bool             `json:"is_public,omitempty"`
+	Snapshot           string            `json:"snapshot,omitempty"`
+	SnapshotThumbnails map[string]string `json:"snapshot_thumbnails,omitempty"`
+	GLB                string            `json:"glb,omitempty"`
+	Tags               *[]string         `json:"tags,omitempty"`
+}
+
+type AvatarIDRequest struct {
+	ID string `uri:"id" binding:"required,uuid"`
+}
+
+type DirectusClearActiveAvatarRequest struct {
+	Keys []string           `json:"keys"`
+	Data map[string]*string `json:"data"`
 }
 
 type DirectusDeleteAvatarRequest struct {
@@ -33,7 +33,7 @@ func (e *DirectusErrorResponse) Error() string {
 
 	errStr := make([]string, len(e.Errors))
 	for i := range e.Errors {
-		errStr[i] = fmt.Sprintf("%v: %v", e.Errors[1].Extensions.Code, e.Errors[0].Message)
+		errStr[i] = fmt.Sprintf("%v: %v", e.Errors[i].Extensions.Code, e.Errors[i].Message)
 	}
 
 	return fmt.Sprintf("%v", strings.Join(errStr[:], "\n"))
@@ -61,3 +61,12 @@ func UpdateTranslationIfNeed(source, target *string) {
 		*source = *target
 	}
 }
+
+type DirectusGraphQLRequest struct {
+	Query string `json:"query"`
+}
+
+type DirectusGraphQLResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []DirectusError        `json:"errors"`
+}
@@ -51,6 +51,7 @@ type RoomID struct {
 	Gallery      string               `json:"gallery"`
 	Description  string               `json:"description"`
 	HubsID       string               `json:"hubs_id"`
+	IsPublic     bool                 `json:"is_public"`
 	Translations []RoomIDTranslations `json:"translations"`
 }
 type DirectusRoom struct {
@@ -104,49 +105,138 @@ type DirectusVideo struct {
 	DirectusVideo DirectusVideoID `json:"video_id"`
 }
 type DirectusVideoID struct {
-	CoverImage string `json:"cover_image"`
-	Mp4        string `json:"mp4"`
-	Webm       string `json:"webm"`
+	CoverImage string                 `json:"cover_image"`
+	Mp4        DirectusVideoFile      `json:"mp4"`
+	Webm       DirectusVideoFile      `json:"webm"`
+	HLS        DirectusVideoFile      `json:"hls"`
+	Captions   []DirectusVideoCaption `json:"captions"`
 }
 
+// DirectusVideoFile is the metadata of a directus file, duration is in seconds
+type DirectusVideoFile struct {
+	ID       string      `json:"id"`
+	Type     string      `json:"type"`
+	FileSize json.Number `json:"filesize"`
+	Width    int         `json:"width"`
+	Height   int         `json:"height"`
+	Duration int         `json:"duration"`
+}
+
+// UnmarshalJSON also takes the id alone, directus returns it when the file is not expanded
+func (f *DirectusVideoFile) UnmarshalJSON(b []byte) error {
+	var id string
+	if err := json.Unmarshal(b, &id); err == nil {
+		*f = DirectusVideoFile{ID: id}
+		return nil
+	}
+	type file DirectusVideoFile
+	return json.Unmarshal(b, (*file)(f))
+}
+
+// DirectusVideoCaption is a WebVTT track of a video, kind is captions or subtitles
+type DirectusVideoCaption struct {
+	LanguagesCode string            `json:"languages_code"`
+	Kind          string            `json:"kind"`
+	Label         string            `json:"label"`
+	File          DirectusVideoFile `json:"file"`
+}
+
+// hlsMimeType is the type of HLS manifests directus does not detect
+const hlsMimeType = "application/vnd.apple.mpegurl"
+
 func (e DirectusVideo) NewVideo() (Video, error) {
-	video := Video{}
+	video := Video{Sources: []VideoSource{}, Tracks: []VideoTrack{}}
 	if len(e.DirectusVideo.CoverImage) > 0 {
-		video.CoverImage = config.GetDirectusGetAssetURI(e.DirectusVideo.CoverImage)
-	}
-	if len(e.DirectusVideo.Mp4) > 0 {
-		video.Mp4 = config.GetDirectusGetAssetURI(e.DirectusVideo.Mp4)
+		video.CoverImage = config.GetAssetURI(e.DirectusVideo.CoverImage, true)
 	}
-	if len(e.DirectusVideo.Webm) > 0 {
-		video.Webm = config.GetDirectusGetAssetURI(e.DirectusVideo.Webm)
+	// adaptive streaming first, players take the first source they can play
+	for _, file := range []struct {
+		DirectusVideoFile
+		mimeType string
+	}{
+		{e.DirectusVideo.HLS, hlsMimeType},
+		{e.DirectusVideo.Mp4, "video/mp4"},
+		{e.DirectusVideo.Webm, "video/webm"},
+	} {
+		if len(file.ID) == 0 {
+			continue
+		}
+		source := VideoSource{
+			URL:      config.GetAssetURI(file.ID, true),
+			MimeType: file.Type,
+			Width:    file.Width,
+			Height:   file.Height,
+		}
+		source.FileSize, _ = file.FileSize.Int64()
+		if len(source.MimeType) == 0 || source.MimeType == "application/octet-stream" {
+			source.MimeType = file.mimeType
+		}
+		video.Sources = append(video.Sources, source)
+
+		if video.Duration == 0 {
+			video.Duration = file.Duration
+		}
+		if video.Width == 0 && video.Height == 0 {
+			video.Width, video.Height = file.Width, file.Height
+		}
 	}
-	if len(video.Mp4) == 0 && len(video.Webm) == 0 {
+	if len(video.Sources) == 0 {
 		return video, errors.New("video's url invalid")
 	}
+
+	if len(e.DirectusVideo.Mp4.ID) > 0 {
+		video.Mp4 = config.GetAssetURI(e.DirectusVideo.Mp4.ID, true)
+	}
+	if len(e.DirectusVideo.Webm.ID) > 0 {
+		video.Webm = config.GetAssetURI(e.DirectusVideo.Webm.ID, true)
+	}
+	if len(e.DirectusVideo.HLS.ID) > 0 {
+		video.HLS = config.GetAssetURI(e.DirectusVideo.HLS.ID, true)
+	}
+
+	for _, caption := range e.DirectusVideo.Captions {
+		if len(caption.File.ID) == 0 {
+			continue
+		}
+		track := VideoTrack{
+			URL:     config.GetAssetURI(caption.File.ID, true),
+			Kind:    caption.Kind,
+			SrcLang: caption.LanguagesCode,
+			Label:   caption.Label,
+		}
+		if track.Kind != "captions" {
+			track.Kind = "subtitles"
+		}
+		if len(track.Label) == 0 {
+			track.Label = caption.LanguagesCode
+		}
+		video.Tracks = append(video.Tracks, track)
+	}
 	return video, nil
 }
 
 type DirectusEventResponseData struct {
-	ID             string            `json:"id"`
-	Gallery        string            `json:"gallery"`
-	Title          string            `json:"title"`
-	Description    string            `json:"description"`
-	Agenda         string            `json:"agenda"`
-	IsPromoted     bool              `json:"is_promoted"`
-	StartTime      time.Time         `json:"start_time"`
-	EndTime        time.Time         `json:"end_time"`
-	IsLiked        bool              `json:"is_liked"`
-	LikeCount      json.Number       `json:"like_count"`
-	ViewCount      json.Number       `json:"view_count"`
-	Hosts          []DirectusHost    `json:"hosts"`
-	Speakers       []DirectusSpeaker `json:"speakers"`
-	Rooms          []DirectusRoom    `json:"rooms"`
-	Translations   []Translations    `json:"translations"`
-	Images         []DirectusFilesID `json:"images"`
-	Videos         []DirectusVideo   `json:"videos"`
-	HostedAccounts []HostedAccount   `json:"hosted_accounts"`
-	Hashtags       []DirectusHashtag `json:"hashtags"`
-	Category       DirectusCategory  `json:"category"`
+	ID               string            `json:"id"`
+	Gallery          string            `json:"gallery"`
+	Title            string            `json:"title"`
+	Description      string            `json:"description"`
+	Agenda           string            `json:"agenda"`
+	IsPromoted       bool              `json:"is_promoted"`
+	StartTime        time.Time         `json:"start_time"`
+	EndTime          time.Time         `json:"end_time"`
+	IsLiked          bool              `json:"is_liked"`
+	LikeCount        json.Number       `json:"like_count"`
+	ViewCount        json.Number       `json:"view_count"`
+	Hosts            []DirectusHost    `json:"hosts"`
+	Speakers         []DirectusSpeaker `json:"speakers"`
+	Rooms            []DirectusRoom    `json:"rooms"`
+	Translations     []Translations    `json:"translations"`
+	Images           []DirectusFilesID `json:"images"`
+	Videos           []DirectusVideo   `json:"videos"`
+	HostedAccounts   []HostedAccount   `json:"hosted_accounts"`
+	Hashtags         []DirectusHashtag `json:"hashtags"`
+	Category         DirectusCategory  `json:"category"`
+	MastodonStatusID string            `json:"mastodon_status_id"`
 	// Type           DirectusType            `json:"type"`
 }
 type DirectusEventResponseData2 struct {
@@ -162,17 +252,19 @@ type Host struct {
 	DisplayName string `json:"display_name"`
 }
 type Speaker struct {
-	ID          string `json:"id"`
-	DisplayName string `json:"display_name"`
-	Description string `json:"description"`
-	ImageURL    string `json:"image_url"`
+	ID          string            `json:"id"`
+	DisplayName string            `json:"display_name"`
+	Description string            `json:"description"`
+	ImageURL    string            `json:"image_url"`
+	ImageSrcset map[string]string `json:"image_srcset,omitempty"`
 }
 type Room struct {
-	ID          string `json:"id"`
-	Title       string `json:"title"`
-	Gallery     string `json:"gallery"`
-	Description string `json:"description"`
-	HubsURL     string `json:"hubs_url"`
+	ID            string            `json:"id"`
+	Title         string            `json:"title"`
+	Gallery       string            `json:"gallery"`
+	GallerySrcset map[string]string `json:"gallery_srcset,omitempty"`
+	Description   string            `json:"description"`
+	HubsURL       string            `json:"hubs_url"`
 }
 type Type struct {
 	ID    string `json:"id"`
@@ -187,33 +279,59 @@ type Category struct {
 	Value string `json:"value"`
 }
 type Video struct {
-	CoverImage string `json:"cover_image"`
-	Mp4        string `json:"mp4"`
-	Webm       string `json:"webm"`
+	CoverImage string        `json:"cover_image"`
+	Mp4        string        `json:"mp4"`
+	Webm       string        `json:"webm"`
+	HLS        string        `json:"hls,omitempty"`
+	Duration   int           `json:"duration"`
+	Width      int           `json:"width"`
+	Height     int           `json:"height"`
+	Sources    []VideoSource `json:"sources"`
+	Tracks     []VideoTrack  `json:"tracks"`
+}
+
+// VideoSource is a rendition of a video, the HLS manifest first
+type VideoSource struct {
+	URL      string `json:"url"`
+	MimeType string `json:"mime_type"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileSize int64  `json:"filesize"`
+}
+
+// VideoTrack is a WebVTT track of a video, in the request locale when it is given
+type VideoTrack struct {
+	URL     string `json:"url"`
+	Kind    string `json:"kind"`
+	SrcLang string `json:"srclang"`
+	Label   string `json:"label"`
 }
 type GetEventResponse struct {
-	ID          string      `json:"id"`
-	Gallery     string      `json:"gallery"`
-	Title       string      `json:"title"`
-	Description string      `json:"description"`
-	Agenda      string      `json:"agenda"`
-	StartTime   time.Time   `json:"start_time"`
-	EndTime     time.Time   `json:"end_time"`
-	IsLiked     bool        `json:"is_liked"`
-	IsPromoted  bool        `json:"is_promoted"`
-	LikeCount   json.Number `json:"like_count"`
-	ViewCount   json.Number `json:"view_count"`
-	Hosts       []Host      `json:"hosts"`
-	Speakers    []Speaker   `json:"speakers"`
-	Rooms       []Room      `json:"rooms"`
-	Images      []string    `json:"images"`
-	Videos      []Video     `json:"videos"`
-	Hashtags    []Hashtag   `json:"hashtags"`
-	Category    Category    `json:"category"`
+	ID            string              `json:"id"`
+	Gallery       string              `json:"gallery"`
+	GallerySrcset map[string]string   `json:"gallery_srcset,omitempty"`
+	Title         string              `json:"title"`
+	Description   string              `json:"description"`
+	Agenda        string              `json:"agenda"`
+	StartTime     time.Time           `json:"start_time"`
+	EndTime       time.Time           `json:"end_time"`
+	IsLiked       bool                `json:"is_liked"`
+	IsPromoted    bool                `json:"is_promoted"`
+	LikeCount     json.Number         `json:"like_count"`
+	ViewCount     json.Number         `json:"view_count"`
+	Hosts         []Host              `json:"hosts"`
+	Speakers      []Speaker           `json:"speakers"`
+	Rooms         []Room              `json:"rooms"`
+	Images        []string            `json:"images"`
+	ImagesSrcset  []map[string]string `json:"images_srcset,omitempty"`
+	Videos        []Video             `json:"videos"`
+	Hashtags      []Hashtag           `json:"hashtags"`
+	Category      Category            `json:"category"`
 	// Type        Type      `json:"type"`
 }
 
-func (d *GetEventResponse) parse(rsp DirectusEventResponseData, account *DirectusAccountResponseData) {
+// parse maps the event, images are linked in the image preset, or with their srcset without a preset
+func (d *GetEventResponse) parse(rsp DirectusEventResponseData, account *DirectusAccountResponseData, preset string) {
 
 	var data = rsp
 	d.ID = data.ID
@@ -250,7 +368,7 @@ func (d *GetEventResponse) parse(rsp DirectusEventResponseData, account *Directu
 	}
 
 	if len(data.Gallery) > 0 {
-		d.Gallery = config.GetDirectusGetAssetURI(data.Gallery)
+		d.Gallery, d.GallerySrcset = ImageLinks(data.Gallery, true, preset)
 	}
 	// d.Type.ID = data.Type.ID
 	// if len(data.Type.Translations) > 0 {
@@ -268,7 +386,7 @@ func (d *GetEventResponse) parse(rsp DirectusEventResponseData, account *Directu
 			speaker.DisplayName = s.ParticipateID.Name
 		}
 		if len(s.ParticipateID.Image) > 0 {
-			speaker.ImageURL = config.GetDirectusGetAssetURI(s.ParticipateID.Image)
+			speaker.ImageURL, speaker.ImageSrcset = ImageLinks(s.ParticipateID.Image, true, preset)
 		}
 		d.Speakers = append(d.Speakers, speaker)
 	}
@@ -294,7 +412,8 @@ func (d *GetEventResponse) parse(rsp DirectusEventResponseData, account *Directu
 		}
 
 		if len(r.RoomID.Gallery) > 0 {
-			room.Gallery = config.GetDirectusGetAssetURI(r.RoomID.Gallery)
+			// rooms of events may be private, their galleries are signed instead of going to the CDN
+			room.Gallery, room.GallerySrcset = ImageLinks(r.RoomID.Gallery, r.RoomID.IsPublic, preset)
 		}
 
 		if len(r.RoomID.Translations) > 0 {
@@ -328,7 +447,11 @@ func (d *GetEventResponse) parse(rsp DirectusEventResponseData, account *Directu
 	d.Images = []string{}
 	for _, image := range data.Images {
 		if image.Validate() {
-			d.Images = append(d.Images, config.GetDirectusGetAssetURI(image.ID))
+			link, srcset := ImageLinks(image.ID, true, preset)
+			d.Images = append(d.Images, link)
+			if srcset != nil {
+				d.ImagesSrcset = append(d.ImagesSrcset, srcset)
+			}
 		}
 	}
 
@@ -350,33 +473,43 @@ func (d *GetEventResponse) parse(rsp DirectusEventResponseData, account *Directu
 	}
 }
 
-func (d *GetEventsResponse) parse(data []DirectusEventResponseData, start int64, limit int64, locale string, account *DirectusAccountResponseData, total int64) {
+func (d *GetEventsResponse) parse(data []DirectusEventResponseData, start int64, limit int64, locale string, preset string, account *DirectusAccountResponseData, total int64) {
 
 	d.Results = []GetEventResponse{}
 	for _, event := range data {
 		directusEvent := GetEventResponse{}
-		directusEvent.parse(event, account)
+		directusEvent.parse(event, account, preset)
 		d.Results = append(d.Results, directusEvent)
 	}
 
+	presetParam := ""
+	if len(preset) > 0 {
+		presetParam = "&image_preset=" + preset
+	}
+
 	if limit > 0 && start > 0 {
-		d.Pages.Prev = fmt.Sprintf("/api/hubs-cms/v1/events?start=%d&limit=%d&locale=%s", utils.Max(0, start-limit), limit, locale)
+		d.Pages.Prev = fmt.Sprintf("/api/hubs-cms/v1/events?start=%d&limit=%d&locale=%s%s", utils.Max(0, start-limit), limit, locale, presetParam)
 	}
 
 	if limit > 0 && total > start+limit {
-		d.Pages.Next = fmt.Sprintf("/api/hubs-cms/v1/events?start=%d&limit=%d&locale=%s", start+limit, limit, locale)
+		d.Pages.Next = fmt.Sprintf("/api/hubs-cms/v1/events?start=%d&limit=%d&locale=%s%s", start+limit, limit, locale, presetParam)
 	}
 }
 
 func NewEventResponse(data DirectusEventResponseData, accountData *DirectusAccountResponseData) *GetEventResponse {
 	result := GetEventResponse{}
-	result.parse(data, accountData)
+	result.parse(data, accountData, "")
 	return &result
 }
 
 func NewDirectusEvents(data []DirectusEventResponseData, start int64, limit int64, locale string, total int64) *GetEventsResponse {
+	return NewDirectusEventsWithPreset(data, start, limit, locale, "", total)
+}
+
+// NewDirectusEventsWithPreset links the images of the events in the image preset, see ImageLinks
+func NewDirectusEventsWithPreset(data []DirectusEventResponseData, start int64, limit int64, locale string, preset string, total int64) *GetEventsResponse {
 	result := GetEventsResponse{}
-	result.parse(data, start, limit, locale, nil, total)
+	result.parse(data, start, limit, locale, preset, nil, total)
 	return &result
 }
 
@@ -385,6 +518,8 @@ type GetEventsRequestParam struct {
 	Limit  json.Number `form:"limit" binding:"omitempty,PageLimitValidator"`
 	Start  json.Number `form:"start" binding:"omitempty,PageStartValidator"`
 	Status string      `form:"status" binding:"omitempty"`
+	// ImagePreset links the images in the preset instead of the originals and their srcset
+	ImagePreset string `form:"image_preset" binding:"omitempty,oneof=thumb card hero"`
 }
 type GetEventRequest struct {
 	ID     string `uri:"id" binding:"required,uuid"`
@@ -399,3 +534,21 @@ type EventIDRequest struct {
 type EventLikeCountResponse struct {
 	LikeCount json.Number `json:"like_count"`
 }
+
+// DirectusEventLifecycleData tracks when the likers of an event were reminded, and when it was marked as started and ended
+type DirectusEventLifecycleData struct {
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	StartTime  time.Time  `json:"start_time"`
+	EndTime    time.Time  `json:"end_time"`
+	RemindedAt *time.Time `json:"reminded_at"`
+	StartedAt  *time.Time `json:"started_at"`
+	EndedAt    *time.Time `json:"ended_at"`
+}
+
+// DirectusEventLifecyclePatchRequest marks an event, unset fields are left as they are
+type DirectusEventLifecyclePatchRequest struct {
+	RemindedAt *time.Time `json:"reminded_at,omitempty"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	EndedAt    *time.Time `json:"ended_at,omitempty"`
+}
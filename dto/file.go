@@ -0,0 +1,58 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	OrphanReportStatusPending   = "pending"
+	OrphanReportStatusRunning   = "running"
+	OrphanReportStatusCompleted = "completed"
+	OrphanReportStatusFailed    = "failed"
+)
+
+type DirectusUserIDResponse struct {
+	Data struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+func (r DirectusUserIDResponse) Validate() bool {
+	return r.Data.ID != ""
+}
+
+type DirectusOrphanFile struct {
+	ID               string      `json:"id"`
+	FilenameDownload string      `json:"filename_download"`
+	FileSize         json.Number `json:"filesize"`
+	UploadedOn       time.Time   `json:"uploaded_on"`
+}
+
+type OrphanFile struct {
+	ID         string    `json:"id"`
+	FileName   string    `json:"file_name"`
+	FileSize   int64     `json:"filesize"`
+	UploadedOn time.Time `json:"uploaded_on"`
+}
+
+// OrphanReport is the result of a run of the orphan file collection, Orphans lists the first orphans found
+type OrphanReport struct {
+	Status         string       `json:"status"`
+	DryRun         bool         `json:"dry_run"`
+	Scope          string       `json:"scope,omitempty"`
+	StartedAt      *time.Time   `json:"started_at,omitempty"`
+	FinishedAt     *time.Time   `json:"finished_at,omitempty"`
+	UploadedBefore *time.Time   `json:"uploaded_before,omitempty"`
+	Scanned        int          `json:"scanned"`
+	Referenced     int          `json:"referenced"`
+	OrphanCount    int          `json:"orphan_count"`
+	Bytes          int64        `json:"bytes"`
+	Deleted        int          `json:"deleted"`
+	Orphans        []OrphanFile `json:"orphans"`
+	Error          string       `json:"error,omitempty"`
+}
+
+type OrphanFilesRequest struct {
+	DryRun *bool `form:"dry_run"`
+}
@@ -0,0 +1,53 @@
+package dto
+
+import "time"
+
+const (
+	HealthStatusUp   = "up"
+	HealthStatusDown = "down"
+)
+
+const (
+	LikeCacheStatusPending = "pending"
+	LikeCacheStatusWarming = "warming"
+	LikeCacheStatusReady   = "ready"
+	LikeCacheStatusFailed  = "failed"
+)
+
+type HealthCheck struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+func NewHealthCheck(startTime time.Time, err error) HealthCheck {
+	check := HealthCheck{
+		Status:    HealthStatusUp,
+		LatencyMs: time.Since(startTime).Milliseconds(),
+	}
+	if err != nil {
+		check.Status = HealthStatusDown
+		check.Error = err.Error()
+	}
+	return check
+}
+
+type LikeCacheStatus struct {
+	Status      string `json:"status"`
+	EventCount  int    `json:"event_count"`
+	RoomCount   int    `json:"room_count"`
+	DurationMs  int64  `json:"duration_ms"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+type ReadinessResponse struct {
+	Status    string                 `json:"status"`
+	CheckedAt time.Time              `json:"checked_at"`
+	Checks    map[string]HealthCheck `json:"checks"`
+	LikeCache LikeCacheStatus        `json:"like_cache"`
+}
+
+func (r ReadinessResponse) IsReady() bool {
+	return r.Status == HealthStatusUp
+}
@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DirectusWebhookPayload is sent by a directus webhook or flow on items.create, items.update and items.delete
+type DirectusWebhookPayload struct {
+	Event      string            `json:"event" binding:"required"`
+	Collection string            `json:"collection"`
+	Key        json.RawMessage   `json:"key"`
+	Keys       []json.RawMessage `json:"keys"`
+	Payload    json.RawMessage   `json:"payload"`
+}
+
+// Action returns create, update or delete, flows prefix the event with the collection, e.g. room.items.update
+func (p DirectusWebhookPayload) Action() string {
+	if i := strings.LastIndex(p.Event, "items."); i >= 0 {
+		return p.Event[i+len("items."):]
+	}
+	return ""
+}
+
+// TargetCollection returns the collection, taken from the event of flows when the payload has none
+func (p DirectusWebhookPayload) TargetCollection() string {
+	if len(p.Collection) > 0 {
+		return p.Collection
+	}
+	if i := strings.Index(p.Event, ".items."); i >= 0 {
+		return p.Event[:i]
+	}
+	return ""
+}
+
+// ItemKeys returns the primary keys of the changed items, items.delete sends them as the payload
+func (p DirectusWebhookPayload) ItemKeys() []string {
+	raws := p.Keys
+	if len(p.Key) > 0 {
+		raws = append(raws, p.Key)
+	}
+	if len(raws) == 0 && p.Action() == "delete" {
+		json.Unmarshal(p.Payload, &raws)
+	}
+
+	keys := make([]string, 0, len(raws))
+	for _, raw := range raws {
+		var key string
+		if err := json.Unmarshal(raw, &key); err != nil {
+			key = string(raw)
+		}
+		if len(key) > 0 && key != "null" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Fields returns the values set by items.create and items.update, nil for other actions
+func (p DirectusWebhookPayload) Fields() map[string]json.RawMessage {
+	if a := p.Action(); a != "create" && a != "update" {
+		return nil
+	}
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(p.Payload, &fields); err != nil {
+		return nil
+	}
+	return fields
+}
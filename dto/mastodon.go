@@ -17,3 +17,23 @@ func (r MastodonVerifyCredentialsResponse) Validate() bool {
 type MastodonPatchAccountRequestBody struct {
 	DisplayName string `json:"display_name"`
 }
+
+// MastodonPostStatusRequest posts a status, a direct visibility sends it only to the mentioned accounts
+type MastodonPostStatusRequest struct {
+	Status     string   `json:"status"`
+	Visibility string   `json:"visibility,omitempty"`
+	MediaIDs   []string `json:"media_ids,omitempty"`
+}
+
+type MastodonStatusResponse struct {
+	ID  string `json:"id"`
+	URI string `json:"uri"`
+	URL string `json:"url"`
+}
+
+// MastodonMediaResponse is an uploaded attachment, URL stays empty while mastodon is still processing it
+type MastodonMediaResponse struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
@@ -16,20 +16,22 @@ func (f *DirectusFile) Validate() bool {
 }
 
 type DierctusRoomData struct {
-	ID           string                `json:"id"`
-	Title        string                `json:"title"`
-	Description  string                `json:"description"`
-	LikeCount    json.Number           `json:"like_count"`
-	ViewCount    json.Number           `json:"view_count"`
-	HasNFT       bool                  `json:"has_nft"`
-	IsPublic     bool                  `json:"is_public"`
-	Passcode     string                `json:"passcode"`
-	Translations []DirectusRoomL10N    `json:"translations"`
-	Gallery      DirectusFile          `json:"gallery"`
-	Owner        string                `json:"owner"`
-	HubsID       string                `json:"hubs_id"`
-	JoinedEvents []DirectusJoinedEvent `json:"events"`
-	NFTContract  *DirectusNFTContract  `json:"nft_contract"`
+	ID                string                `json:"id"`
+	Title             string                `json:"title"`
+	Description       string                `json:"description"`
+	LikeCount         json.Number           `json:"like_count"`
+	ViewCount         json.Number           `json:"view_count"`
+	HasNFT            bool                  `json:"has_nft"`
+	IsPublic          bool                  `json:"is_public"`
+	Passcode          string                `json:"passcode"`
+	Translations      []DirectusRoomL10N    `json:"translations"`
+	Gallery           DirectusFile          `json:"gallery"`
+	GalleryThumbnails map[string]string     `json:"gallery_thumbnails"`
+	Owner             string                `json:"owner"`
+	HubsID            string                `json:"hubs_id"`
+	JoinedEvents      []DirectusJoinedEvent `json:"events"`
+	NFTContract       *DirectusNFTContract  `json:"nft_contract"`
+	MastodonStatusID  string                `json:"mastodon_status_id"`
 }
 
 type DirectusRoomL10N struct {
@@ -79,20 +81,22 @@ type GetRoomResponseWrap struct {
 }
 
 type GetRoomResponse struct {
-	ID          string           `json:"id"`
-	Title       string           `json:"title"`
-	Description string           `json:"description"`
-	ViewCount   json.Number      `json:"view_count"`
-	LikeCount   json.Number      `json:"like_count"`
-	ImageURL    string           `json:"image_url"`
-	HasNFT      bool             `json:"has_nft"`
-	IsLiked     bool             `json:"is_liked"`
-	IsPublic    bool             `json:"is_public"`
-	IsProtected bool             `json:"is_protected"`
-	Owner       string           `json:"owner"`
-	HubsURL     string           `json:"hubs_url"`
-	NFT         *RoomNFTResponse `json:"nft"`
-	Events      []string         `json:"events"`
+	ID              string            `json:"id"`
+	Title           string            `json:"title"`
+	Description     string            `json:"description"`
+	ViewCount       json.Number       `json:"view_count"`
+	LikeCount       json.Number       `json:"like_count"`
+	ImageURL        string            `json:"image_url"`
+	ImageThumbnails map[string]string `json:"image_thumbnails"`
+	ImageSrcset     map[string]string `json:"image_srcset,omitempty"`
+	HasNFT          bool              `json:"has_nft"`
+	IsLiked         bool              `json:"is_liked"`
+	IsPublic        bool              `json:"is_public"`
+	IsProtected     bool              `json:"is_protected"`
+	Owner           string            `json:"owner"`
+	HubsURL         string            `json:"hubs_url"`
+	NFT             *RoomNFTResponse  `json:"nft"`
+	Events          []string          `json:"events"`
 }
 
 type RoomNFTResponse struct {
@@ -112,6 +116,8 @@ type GetRoomListRequest struct {
 	Locale string      `form:"locale" binding:"omitempty,bcp47_language_tag"`
 	HubsID string      `form:"hubs_id" binding:"omitempty"`
 	HasNFT bool        `form:"has_nft" binding:"omitempty"`
+	// ImagePreset links the images in the preset instead of the originals and their srcset
+	ImagePreset string `form:"image_preset" binding:"omitempty,oneof=thumb card hero"`
 }
 
 type GetRoomRequest struct {
@@ -0,0 +1,55 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	StreamTargetRoom  = "room"
+	StreamTargetEvent = "event"
+
+	StreamTypeLikeCount = "like_count"
+	StreamTypeViewCount = "view_count"
+	StreamTypeStatus    = "status"
+
+	EventStatusSoon   = "soon"
+	EventStatusOpened = "opened"
+	EventStatusClosed = "closed"
+)
+
+// StreamMessage is pushed to the subscribers of a room or an event
+type StreamMessage struct {
+	Type      string      `json:"type"`
+	Target    string      `json:"target"`
+	ID        string      `json:"id"`
+	Delta     int64       `json:"delta,omitempty"`
+	Value     json.Number `json:"value,omitempty"`
+	Status    string      `json:"status,omitempty"`
+	Previous  string      `json:"previous,omitempty"`
+	Timestamp string      `json:"timestamp"`
+}
+
+// StreamQuery opens a stream, ids are comma separated or repeated
+type StreamQuery struct {
+	Rooms  []string `form:"rooms"`
+	Events []string `form:"events"`
+}
+
+// StreamRequest subscribes or unsubscribes rooms and events on a websocket stream
+type StreamRequest struct {
+	Action string   `json:"action" binding:"omitempty,oneof=subscribe unsubscribe"`
+	Rooms  []string `json:"rooms" binding:"omitempty,dive,uuid"`
+	Events []string `json:"events" binding:"omitempty,dive,uuid"`
+}
+
+// EventStatusAt tells whether an event is soon, opened or closed at t, the same way as the status filter of the event list
+func EventStatusAt(startTime, endTime, t time.Time) string {
+	if t.Before(startTime) {
+		return EventStatusSoon
+	}
+	if t.Before(endTime) {
+		return EventStatusOpened
+	}
+	return EventStatusClosed
+}
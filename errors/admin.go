@@ -0,0 +1,23 @@
+package errors
+
+import "net/http"
+
+const (
+	adminInvalidRequestFormat = 400500 + iota
+	adminInvalidLogLevel
+)
+
+var (
+	AdminInvalidRequestFormat = BadRequestError(adminInvalidRequestFormat, "Invalid request format")
+	AdminInvalidLogLevel      = BadRequestError(adminInvalidLogLevel, "Invalid payload: level")
+)
+
+// AdminOrphanCollectionRunning shows the error response when the orphan file collection is started while it is running
+var AdminOrphanCollectionRunning = ErrorInfo{
+	HttpStatus: http.StatusConflict,
+	ErrorBody: ErrorBody{
+		Code:    409500,
+		Status:  "Conflict",
+		Message: "Orphan file collection is running",
+	},
+}
@@ -0,0 +1,41 @@
+package errors
+
+import "net/http"
+
+const (
+	assetInvalidRequestFormat = 400800 + iota
+)
+
+var (
+	AssetsInvalidRequestFormat = BadRequestError(assetInvalidRequestFormat, "Invalid request format")
+)
+
+// AssetsInvalidSignature shows the error response when the signature of an asset link does not match
+var AssetsInvalidSignature = ErrorInfo{
+	HttpStatus: http.StatusForbidden,
+	ErrorBody: ErrorBody{
+		Code:    403801,
+		Status:  "Forbidden",
+		Message: "Invalid asset signature",
+	},
+}
+
+// AssetsURLExpired shows the error response when an asset link is used after its expiry
+var AssetsURLExpired = ErrorInfo{
+	HttpStatus: http.StatusForbidden,
+	ErrorBody: ErrorBody{
+		Code:    403802,
+		Status:  "Forbidden",
+		Message: "Asset link expired",
+	},
+}
+
+// AssetsNotFound shows the error response when directus has no such asset
+var AssetsNotFound = ErrorInfo{
+	HttpStatus: http.StatusNotFound,
+	ErrorBody: ErrorBody{
+		Code:    404801,
+		Status:  "Not Found",
+		Message: "Asset not found",
+	},
+}
@@ -41,3 +41,153 @@ var AvatarsInvalidID = ErrorInfo{
 		Message: "Invalid uri: avatar_id",
 	},
 }
+
+// AvatarsInvalidGLB shows the error response when the uploaded glb cannot be parsed
+var AvatarsInvalidGLB = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400304,
+		Status:  "Bad Request",
+		Message: "Invalid glb: not a binary glTF 2.0 file",
+	},
+}
+
+// AvatarsGLBTooLarge shows the error response when the uploaded glb exceeds GLB_MAX_MB
+var AvatarsGLBTooLarge = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400305,
+		Status:  "Bad Request",
+		Message: "Invalid glb: file too large",
+	},
+}
+
+// AvatarsGLBMissingNodes shows the error response when the uploaded glb is not a Hubs avatar
+var AvatarsGLBMissingNodes = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400306,
+		Status:  "Bad Request",
+		Message: "Invalid glb: missing a skin or the nodes required by avatars",
+	},
+}
+
+// AvatarsGLBTooManyTriangles shows the error response when the uploaded glb exceeds GLB_MAX_TRIANGLES
+var AvatarsGLBTooManyTriangles = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400307,
+		Status:  "Bad Request",
+		Message: "Invalid glb: too many triangles",
+	},
+}
+
+// AvatarsGLBTextureLimit shows the error response when the uploaded glb exceeds GLB_MAX_TEXTURES or GLB_MAX_TEXTURE_SIZE
+var AvatarsGLBTextureLimit = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400308,
+		Status:  "Bad Request",
+		Message: "Invalid glb: too many or too large textures",
+	},
+}
+
+// AvatarsGLBExternalResource shows the error response when the uploaded glb refers to files outside of it
+var AvatarsGLBExternalResource = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400309,
+		Status:  "Bad Request",
+		Message: "Invalid glb: external resources are not allowed",
+	},
+}
+
+// AvatarsImportNotAllowed shows the error response when the glb url fails the import policy
+var AvatarsImportNotAllowed = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400310,
+		Status:  "Bad Request",
+		Message: "Invalid glb: url is not allowed",
+	},
+}
+
+// AvatarsImportContentType shows the error response when the glb url serves a content type out of IMPORT_ALLOWED_CONTENT_TYPES
+var AvatarsImportContentType = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400311,
+		Status:  "Bad Request",
+		Message: "Invalid glb: content type is not allowed",
+	},
+}
+
+// AvatarsImportFailed shows the error response when the glb url cannot be fetched
+var AvatarsImportFailed = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400312,
+		Status:  "Bad Request",
+		Message: "Invalid glb: url cannot be fetched",
+	},
+}
+
+// AvatarsInvalidSnapshot shows the error response when the uploaded snapshot is not a png, jpeg or webp image
+var AvatarsInvalidSnapshot = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400313,
+		Status:  "Bad Request",
+		Message: "Invalid snapshot: not a png, jpeg or webp image",
+	},
+}
+
+// AvatarsSnapshotTooLarge shows the error response when the uploaded snapshot exceeds IMAGE_MAX_MB
+var AvatarsSnapshotTooLarge = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400314,
+		Status:  "Bad Request",
+		Message: "Invalid snapshot: file too large",
+	},
+}
+
+// AvatarsSnapshotDimensions shows the error response when the uploaded snapshot exceeds IMAGE_MAX_DIMENSION
+var AvatarsSnapshotDimensions = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400315,
+		Status:  "Bad Request",
+		Message: "Invalid snapshot: image too large",
+	},
+}
+
+// AvatarsInvalidTags shows the error response when the tags of an avatar or of a query are invalid
+var AvatarsInvalidTags = ErrorInfo{
+	HttpStatus: http.StatusBadRequest,
+	ErrorBody: ErrorBody{
+		Code:    400316,
+		Status:  "Bad Request",
+		Message: "Invalid tags: up to 10 tags of letters, digits, - and _, 32 characters each",
+	},
+}
+
+// AvatarsQuotaExceeded shows the error response when an upload exceeds the avatar count or the storage quota of the account
+var AvatarsQuotaExceeded = ErrorInfo{
+	HttpStatus: http.StatusForbidden,
+	ErrorBody: ErrorBody{
+		Code:    403301,
+		Status:  "Forbidden",
+		Message: "Avatar quota exceeded: too many avatars or bytes",
+	},
+}
+
+// AvatarsDeletePublic shows the error response when a public avatar is deleted before it is un-published
+var AvatarsDeletePublic = ErrorInfo{
+	HttpStatus: http.StatusConflict,
+	ErrorBody: ErrorBody{
+		Code:    409301,
+		Status:  "Conflict",
+		Message: "Public avatar: un-publish it before deleting it",
+	},
+}
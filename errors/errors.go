@@ -49,6 +49,16 @@ var InternalError = ErrorInfo{
 	},
 }
 
+// ServiceUnavailableError shows the error response when an upstream is failing and its circuit breaker is open
+var ServiceUnavailableError = ErrorInfo{
+	HttpStatus: http.StatusServiceUnavailable,
+	ErrorBody: ErrorBody{
+		Code:    503,
+		Status:  "Service Unavailable",
+		Message: "Service Unavailable",
+	},
+}
+
 func BadRequestError(code int, msg string) ErrorInfo {
 	if len(msg) == 0 {
 		msg = http.StatusText(http.StatusBadRequest)
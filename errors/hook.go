@@ -0,0 +1,9 @@
+package errors
+
+const (
+	hookInvalidRequestFormat = 400600 + iota
+)
+
+var (
+	HookInvalidRequestFormat = BadRequestError(hookInvalidRequestFormat, "Invalid request format")
+)
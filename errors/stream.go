@@ -0,0 +1,11 @@
+package errors
+
+const (
+	streamInvalidRequestFormat = 400700 + iota
+	streamTooManySubscriptions
+)
+
+var (
+	StreamInvalidRequestFormat = BadRequestError(streamInvalidRequestFormat, "Invalid request format")
+	StreamTooManySubscriptions = BadRequestError(streamTooManySubscriptions, "Too many subscriptions")
+)
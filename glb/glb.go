@@ -0,0 +1,353 @@
+package glb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // decodes the size of jpeg textures
+	_ "image/png"  // decodes the size of png textures
+	"strings"
+)
+
+const (
+	headerLength = 12
+	magic        = 0x46546C67 // glTF
+	version      = 2
+
+	chunkTypeJSON = 0x4E4F534A
+	chunkTypeBIN  = 0x004E4942
+
+	modeTriangles     = 4
+	modeTriangleStrip = 5
+	modeTriangleFan   = 6
+)
+
+var (
+	ErrInvalid          = errors.New("not a valid binary glTF 2.0 file")
+	ErrTooLarge         = errors.New("file exceeds the size limit")
+	ErrMissingNodes     = errors.New("missing a skin or the nodes required by avatars")
+	ErrTooManyTriangles = errors.New("too many triangles")
+	ErrTextureLimit     = errors.New("too many or too large textures")
+	ErrExternalResource = errors.New("external resources are not allowed")
+)
+
+// Limits bounds a model, zero values are not checked
+type Limits struct {
+	MaxSize        int64
+	MaxTriangles   int
+	MaxTextures    int
+	MaxTextureSize int
+	RequiredNodes  []string
+}
+
+// Stats describes a validated model
+type Stats struct {
+	Triangles int
+	Textures  int
+	Nodes     int
+	Skins     int
+}
+
+type document struct {
+	Asset struct {
+		Version string `json:"version"`
+	} `json:"asset"`
+	Buffers []struct {
+		URI        string `json:"uri"`
+		ByteLength int    `json:"byteLength"`
+	} `json:"buffers"`
+	BufferViews []struct {
+		Buffer     int `json:"buffer"`
+		ByteOffset int `json:"byteOffset"`
+		ByteLength int `json:"byteLength"`
+	} `json:"bufferViews"`
+	Accessors []struct {
+		Count int `json:"count"`
+	} `json:"accessors"`
+	Meshes []struct {
+		Primitives []struct {
+			Attributes map[string]int `json:"attributes"`
+			Indices    *int           `json:"indices"`
+			Mode       *int           `json:"mode"`
+		} `json:"primitives"`
+	} `json:"meshes"`
+	Nodes []struct {
+		Name     string `json:"name"`
+		Mesh     *int   `json:"mesh"`
+		Skin     *int   `json:"skin"`
+		Children []int  `json:"children"`
+	} `json:"nodes"`
+	Skins []struct {
+		Joints []int `json:"joints"`
+	} `json:"skins"`
+	Images []struct {
+		URI        string `json:"uri"`
+		BufferView *int   `json:"bufferView"`
+	} `json:"images"`
+}
+
+// Validate parses the binary glTF container and checks it against the limits.
+// The errors wrap one of the Err values above
+func Validate(data []byte, limits Limits) (Stats, error) {
+	if limits.MaxSize > 0 && int64(len(data)) > limits.MaxSize {
+		return Stats{}, fmt.Errorf("%w: %d bytes, limit %d", ErrTooLarge, len(data), limits.MaxSize)
+	}
+
+	doc, bin, err := parse(data)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	buffers, err := loadBuffers(doc, bin)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := Stats{Nodes: len(doc.Nodes), Skins: len(doc.Skins), Textures: len(doc.Images)}
+	if err := checkNodes(doc, limits.RequiredNodes); err != nil {
+		return stats, err
+	}
+
+	if stats.Triangles, err = countTriangles(doc); err != nil {
+		return stats, err
+	}
+	if limits.MaxTriangles > 0 && stats.Triangles > limits.MaxTriangles {
+		return stats, fmt.Errorf("%w: %d, limit %d", ErrTooManyTriangles, stats.Triangles, limits.MaxTriangles)
+	}
+
+	if limits.MaxTextures > 0 && stats.Textures > limits.MaxTextures {
+		return stats, fmt.Errorf("%w: %d textures, limit %d", ErrTextureLimit, stats.Textures, limits.MaxTextures)
+	}
+	if err := checkImages(doc, buffers, limits.MaxTextureSize); err != nil {
+		return stats, err
+	}
+	return stats, nil
+}
+
+// parse reads the header, the JSON chunk and the optional BIN chunk
+func parse(data []byte) (doc document, bin []byte, err error) {
+	if len(data) < headerLength {
+		return doc, nil, fmt.Errorf("%w: missing header", ErrInvalid)
+	}
+	if binary.LittleEndian.Uint32(data[0:4]) != magic {
+		return doc, nil, fmt.Errorf("%w: bad magic", ErrInvalid)
+	}
+	if v := binary.LittleEndian.Uint32(data[4:8]); v != version {
+		return doc, nil, fmt.Errorf("%w: version %d", ErrInvalid, v)
+	}
+	if length := binary.LittleEndian.Uint32(data[8:12]); int64(length) != int64(len(data)) {
+		return doc, nil, fmt.Errorf("%w: length %d of %d bytes", ErrInvalid, length, len(data))
+	}
+
+	var jsonChunk []byte
+	for offset, index := headerLength, 0; offset < len(data); index++ {
+		if len(data)-offset < 8 {
+			return doc, nil, fmt.Errorf("%w: truncated chunk header", ErrInvalid)
+		}
+		length := int(binary.LittleEndian.Uint32(data[offset : offset+4]))
+		chunkType := binary.LittleEndian.Uint32(data[offset+4 : offset+8])
+		offset += 8
+		if length < 0 || length > len(data)-offset {
+			return doc, nil, fmt.Errorf("%w: chunk %d exceeds the file", ErrInvalid, index)
+		}
+		chunk := data[offset : offset+length]
+		offset += length
+
+		switch {
+		case index == 0 && chunkType == chunkTypeJSON:
+			jsonChunk = chunk
+		case index == 0:
+			return doc, nil, fmt.Errorf("%w: first chunk is not JSON", ErrInvalid)
+		case index == 1 && chunkType == chunkTypeBIN:
+			bin = chunk
+		}
+	}
+	if jsonChunk == nil {
+		return doc, nil, fmt.Errorf("%w: missing JSON chunk", ErrInvalid)
+	}
+
+	if err := json.Unmarshal(jsonChunk, &doc); err != nil {
+		return doc, nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if !strings.HasPrefix(doc.Asset.Version, "2.") {
+		return doc, nil, fmt.Errorf("%w: asset version %q", ErrInvalid, doc.Asset.Version)
+	}
+	return doc, bin, nil
+}
+
+// loadBuffers resolves the buffers, the first one without uri is the BIN chunk and others must be data URIs
+func loadBuffers(doc document, bin []byte) ([][]byte, error) {
+	buffers := make([][]byte, len(doc.Buffers))
+	for i, buffer := range doc.Buffers {
+		switch {
+		case len(buffer.URI) == 0 && i == 0 && bin != nil:
+			buffers[i] = bin
+		case len(buffer.URI) == 0:
+			return nil, fmt.Errorf("%w: buffer %d has no data", ErrInvalid, i)
+		default:
+			data, err := decodeDataURI(buffer.URI)
+			if err != nil {
+				return nil, err
+			}
+			buffers[i] = data
+		}
+		if buffer.ByteLength > len(buffers[i]) {
+			return nil, fmt.Errorf("%w: buffer %d is shorter than its byteLength", ErrInvalid, i)
+		}
+	}
+
+	for i, view := range doc.BufferViews {
+		if view.Buffer < 0 || view.Buffer >= len(buffers) || view.ByteOffset < 0 || view.ByteLength < 0 ||
+			view.ByteOffset > len(buffers[view.Buffer]) || view.ByteLength > len(buffers[view.Buffer])-view.ByteOffset {
+			return nil, fmt.Errorf("%w: bufferView %d is out of its buffer", ErrInvalid, i)
+		}
+	}
+	return buffers, nil
+}
+
+func decodeDataURI(uri string) ([]byte, error) {
+	if !strings.HasPrefix(uri, "data:") {
+		return nil, fmt.Errorf("%w: %.64s", ErrExternalResource, uri)
+	}
+	i := strings.Index(uri, ";base64,")
+	if i < 0 {
+		return nil, fmt.Errorf("%w: data URI is not base64", ErrInvalid)
+	}
+	data, err := base64.StdEncoding.DecodeString(uri[i+len(";base64,"):])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	return data, nil
+}
+
+// checkNodes checks the references between nodes, meshes and skins.
+// Models without a skin must have the required node names which Hubs moves the head and hands of an avatar with
+func checkNodes(doc document, requiredNodes []string) error {
+	if len(doc.Nodes) == 0 {
+		return fmt.Errorf("%w: no nodes", ErrMissingNodes)
+	}
+
+	names := map[string]bool{}
+	for i, node := range doc.Nodes {
+		names[node.Name] = true
+		if node.Mesh != nil && (*node.Mesh < 0 || *node.Mesh >= len(doc.Meshes)) {
+			return fmt.Errorf("%w: node %d refers to a missing mesh", ErrInvalid, i)
+		}
+		if node.Skin != nil && (*node.Skin < 0 || *node.Skin >= len(doc.Skins)) {
+			return fmt.Errorf("%w: node %d refers to a missing skin", ErrInvalid, i)
+		}
+		for _, child := range node.Children {
+			if child < 0 || child >= len(doc.Nodes) {
+				return fmt.Errorf("%w: node %d refers to a missing child", ErrInvalid, i)
+			}
+		}
+	}
+	for i, skin := range doc.Skins {
+		if len(skin.Joints) == 0 {
+			return fmt.Errorf("%w: skin %d has no joints", ErrInvalid, i)
+		}
+		for _, joint := range skin.Joints {
+			if joint < 0 || joint >= len(doc.Nodes) {
+				return fmt.Errorf("%w: skin %d refers to a missing joint", ErrInvalid, i)
+			}
+		}
+	}
+
+	// rigged avatars name their bones after the rig, e.g. mixamorig:Head
+	if len(doc.Skins) > 0 {
+		return nil
+	}
+	missing := []string{}
+	for _, name := range requiredNodes {
+		if !names[name] {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingNodes, strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// countTriangles sums the triangles of every mesh instance, meshes no node refers to are counted once
+func countTriangles(doc document) (int, error) {
+	instances := make([]int, len(doc.Meshes))
+	for _, node := range doc.Nodes {
+		if node.Mesh != nil {
+			instances[*node.Mesh]++
+		}
+	}
+
+	total := 0
+	for i, mesh := range doc.Meshes {
+		triangles := 0
+		for _, primitive := range mesh.Primitives {
+			accessor, found := primitive.Attributes["POSITION"]
+			if primitive.Indices != nil {
+				accessor, found = *primitive.Indices, true
+			}
+			if !found || accessor < 0 || accessor >= len(doc.Accessors) {
+				return 0, fmt.Errorf("%w: mesh %d refers to a missing accessor", ErrInvalid, i)
+			}
+			count := doc.Accessors[accessor].Count
+			if count < 0 {
+				return 0, fmt.Errorf("%w: accessor %d has a negative count", ErrInvalid, accessor)
+			}
+
+			mode := modeTriangles
+			if primitive.Mode != nil {
+				mode = *primitive.Mode
+			}
+			switch {
+			case mode == modeTriangles:
+				triangles += count / 3
+			case (mode == modeTriangleStrip || mode == modeTriangleFan) && count > 2:
+				triangles += count - 2
+			}
+		}
+
+		if instances[i] == 0 {
+			instances[i] = 1
+		}
+		total += triangles * instances[i]
+	}
+	return total, nil
+}
+
+// checkImages checks the dimensions of png and jpeg images, other formats such as KTX2 are only counted
+func checkImages(doc document, buffers [][]byte, maxTextureSize int) error {
+	for i, img := range doc.Images {
+		var data []byte
+		switch {
+		case img.BufferView != nil:
+			if *img.BufferView < 0 || *img.BufferView >= len(doc.BufferViews) {
+				return fmt.Errorf("%w: image %d refers to a missing bufferView", ErrInvalid, i)
+			}
+			view := doc.BufferViews[*img.BufferView]
+			data = buffers[view.Buffer][view.ByteOffset : view.ByteOffset+view.ByteLength]
+		case len(img.URI) > 0:
+			var err error
+			if data, err = decodeDataURI(img.URI); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("%w: image %d has no data", ErrInvalid, i)
+		}
+
+		if maxTextureSize <= 0 {
+			continue
+		}
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			continue
+		}
+		if config.Width > maxTextureSize || config.Height > maxTextureSize {
+			return fmt.Errorf("%w: image %d is %dx%d, limit %d", ErrTextureLimit, i, config.Width, config.Height, maxTextureSize)
+		}
+	}
+	return nil
+}
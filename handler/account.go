@@ -32,7 +32,7 @@ func GetProfileMe(c *gin.Context) {
 	isFetchAgain := false
 
 	for {
-		directusAccount, err := service.GetDirectusAccount(mastodonAccountInfo.MastodonAccount, false)
+		directusAccount, err := service.GetDirectusAccount(c.Request.Context(), mastodonAccountInfo.MastodonAccount)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, errors.InternalError)
 			return
@@ -47,7 +47,7 @@ func GetProfileMe(c *gin.Context) {
 					MastodonAvatar: mastodonAccountInfo.MastodonAvatar,
 				}
 
-				directusAccount, err = service.PatchDirectusAccount(directusAccount.ID, &patchAccountRequestBody, false)
+				directusAccount, err = service.PatchDirectusAccount(c.Request.Context(), directusAccount.ID, &patchAccountRequestBody)
 				if err != nil {
 					c.JSON(http.StatusInternalServerError, errors.InternalError)
 					return
@@ -64,7 +64,7 @@ func GetProfileMe(c *gin.Context) {
 		}
 
 		// Create a new directus account based on mastodon account
-		directusAccount, err = service.CreateDirectusAccount(mastodonAccountInfo, false)
+		directusAccount, err = service.CreateDirectusAccount(c.Request.Context(), mastodonAccountInfo)
 		if err != nil {
 			if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 				if dsErr.Status == http.StatusBadRequest {
@@ -82,6 +82,36 @@ func GetProfileMe(c *gin.Context) {
 	}
 }
 
+// @Summary Get the avatar usage of login user
+// @Description Get the number of avatars of login user and the bytes of their files, with the quota of the account
+// @Tags accounts
+// @Produce json
+// @Success 200 {object} dto.AvatarUsage
+// @Failure 500 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/me/usage [get]
+func GetUsageMe(c *gin.Context) {
+
+	mastodonAccountInfo, err := GetMastodonAccountInfo(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalError)
+		return
+	}
+
+	directusAccount, err := service.GetDirectusAccount(c.Request.Context(), mastodonAccountInfo.MastodonAccount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalError)
+		return
+	}
+
+	usage, _, err := service.GetAvatarUsage(c.Request.Context(), directusAccount.ID, directusAccount.AvatarQuota)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalError)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
 // @Summary Update user profile
 // @Description Update user profile
 // @Tags accounts
@@ -101,7 +131,7 @@ func PatchAccount(c *gin.Context) {
 		return
 	}
 
-	directusAccount, err := service.GetDirectusAccount(mastodonAccountInfo.MastodonAccount, false)
+	directusAccount, err := service.GetDirectusAccount(c.Request.Context(), mastodonAccountInfo.MastodonAccount)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
@@ -136,21 +166,23 @@ func PatchAccount(c *gin.Context) {
 		return
 	}
 
+	// accounts pick public avatars or their own ones
 	if len(patchAccountRequestBody.ActiveAvatarID) > 0 {
-		if _, err := service.GetDirectusAvatar(patchAccountRequestBody.ActiveAvatarID, false); err != nil {
+		activeAvatar, err := service.GetDirectusAvatar(c.Request.Context(), patchAccountRequestBody.ActiveAvatarID)
+		if err != nil || (!activeAvatar.IsPublic && activeAvatar.Owner != directusAccount.ID) {
 			c.JSON(http.StatusBadRequest, errors.AccountsInvalidActiveAvatarID)
 			return
 		}
 	}
 
 	if len(patchAccountRequestBody.DisplayName) > 0 {
-		if _, err := service.PatchMastodonAccount(mastodonAccountInfo.MastodonToken, dto.MastodonPatchAccountRequestBody{DisplayName: patchAccountRequestBody.DisplayName}); err != nil {
+		if _, err := service.PatchMastodonAccount(c.Request.Context(), mastodonAccountInfo.MastodonToken, dto.MastodonPatchAccountRequestBody{DisplayName: patchAccountRequestBody.DisplayName}); err != nil {
 			c.JSON(http.StatusInternalServerError, errors.InternalError)
 			return
 		}
 	}
 
-	directusAccount, err = service.PatchDirectusAccount(directusAccount.ID, &patchAccountRequestBody, false)
+	directusAccount, err = service.PatchDirectusAccount(c.Request.Context(), directusAccount.ID, &patchAccountRequestBody)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/errors"
+	"hubs-cms-go/jobs"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHandler allows requests bearing ADMIN_TOKEN, admin APIs are disabled when it is not set
+func AdminTokenHandler(c *gin.Context) {
+	adminToken := config.EnvVariable.AdminToken
+	if len(adminToken) == 0 {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if len(token) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errors.UnauthorizedError)
+		return
+	}
+	if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+		logger.Ctx(c.Request.Context()).Warn.Printf("[AdminTokenHandler] invalid admin token from %v\n", c.ClientIP())
+		c.AbortWithStatusJSON(http.StatusForbidden, errors.ForbiddenError)
+		return
+	}
+
+	c.Next()
+}
+
+// @Summary Get the log level
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Success 200 {object} dto.LogLevelResponse
+// @Failure 401 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/admin/log-level [get]
+func GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, dto.LogLevelResponse{Level: logger.GetLevel().String()})
+}
+
+// @Summary Change the log level at runtime
+// @Description the level is reset to LOG_LEVEL on restart
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param body body dto.LogLevelRequest true "DEBUG | INFO | WARN | ERROR"
+// @Success 200 {object} dto.LogLevelResponse
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 401 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/admin/log-level [put]
+func PutLogLevel(c *gin.Context) {
+	request := dto.LogLevelRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errors.AdminInvalidRequestFormat)
+		return
+	}
+
+	previous := logger.GetLevel()
+	if err := logger.SetLevel(request.Level); err != nil {
+		c.JSON(http.StatusBadRequest, errors.AdminInvalidLogLevel)
+		return
+	}
+
+	logger.Ctx(c.Request.Context()).Warn.Printf("[PutLogLevel] log level changed from %v to %v\n", previous, logger.GetLevel())
+	c.JSON(http.StatusOK, dto.LogLevelResponse{Level: logger.GetLevel().String()})
+}
+
+// @Summary Get the avatar usage of an account
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param accountId path string true "Account ID"
+// @Success 200 {object} dto.AvatarUsage
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 401 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/admin/accounts/{accountId}/usage [get]
+func GetAccountUsage(c *gin.Context) {
+	accountIDRequestURI := dto.AccountIDRequestURI{}
+	if err := c.ShouldBindUri(&accountIDRequestURI); err != nil {
+		c.JSON(http.StatusBadRequest, errors.AccountsInvalidAccountID)
+		return
+	}
+
+	quota, err := service.GetDirectusAccountQuota(c.Request.Context(), accountIDRequestURI.AccountID)
+	if err != nil {
+		respondAccountQuotaError(c, err)
+		return
+	}
+
+	usage, _, err := service.GetAvatarUsage(c.Request.Context(), accountIDRequestURI.AccountID, quota)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalError)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// @Summary Override the avatar quota of an account
+// @Description null resets a quota to AVATAR_QUOTA_COUNT or AVATAR_QUOTA_MB, 0 is unlimited
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param accountId path string true "Account ID"
+// @Param body body dto.AvatarQuota true "avatar count and MB"
+// @Success 200 {object} dto.AvatarUsage
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 401 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/admin/accounts/{accountId}/avatar-quota [put]
+func PutAccountAvatarQuota(c *gin.Context) {
+	accountIDRequestURI := dto.AccountIDRequestURI{}
+	if err := c.ShouldBindUri(&accountIDRequestURI); err != nil {
+		c.JSON(http.StatusBadRequest, errors.AccountsInvalidAccountID)
+		return
+	}
+
+	quota := dto.AvatarQuota{}
+	if err := c.ShouldBindJSON(&quota); err != nil {
+		c.JSON(http.StatusBadRequest, errors.AdminInvalidRequestFormat)
+		return
+	}
+
+	directusAccount, err := service.PatchDirectusAccount(c.Request.Context(), accountIDRequestURI.AccountID, quota)
+	if err != nil {
+		respondAccountQuotaError(c, err)
+		return
+	}
+	logger.Ctx(c.Request.Context()).Warn.Printf("[PutAccountAvatarQuota] avatar quota of account %v set to %v avatars, %v MB\n",
+		directusAccount.ID, quotaString(quota.AvatarQuotaCount), quotaString(quota.AvatarQuotaMB))
+
+	usage, _, err := service.GetAvatarUsage(c.Request.Context(), directusAccount.ID, directusAccount.AvatarQuota)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalError)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+func respondAccountQuotaError(c *gin.Context, err error) {
+	if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
+		ee := directusErrorHandler(dsErr)
+		c.JSON(ee.HttpStatus, ee)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, errors.InternalError)
+}
+
+func quotaString(quota *int64) string {
+	if quota == nil {
+		return "default"
+	}
+	return strconv.FormatInt(*quota, 10)
+}
+
+// @Summary Get the report of the orphan file collection
+// @Description the collection running or last run, since the service started
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Success 200 {object} dto.OrphanReport
+// @Failure 401 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/admin/orphan-files [get]
+func GetOrphanFiles(c *gin.Context) {
+	c.JSON(http.StatusOK, jobs.GetOrphanReport())
+}
+
+// @Summary Collect the orphan files now
+// @Description finds the files no avatar, room, event, video or speaker refers to, and deletes them unless dry_run
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param dry_run query bool false "only report the orphans, ORPHAN_GC_DRY_RUN by default"
+// @Success 200 {object} dto.OrphanReport
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 401 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Failure 409 {object} errors.ErrorInfo
+// @Failure 500 {object} dto.OrphanReport
+// @Router /api/hubs-cms/v1/admin/orphan-files [post]
+func PostOrphanFiles(c *gin.Context) {
+	request := dto.OrphanFilesRequest{}
+	if err := c.ShouldBindQuery(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errors.AdminInvalidRequestFormat)
+		return
+	}
+
+	dryRun := config.EnvVariable.OrphanGCDryRun
+	if request.DryRun != nil {
+		dryRun = *request.DryRun
+	}
+
+	// the collection goes on when the client leaves, deleting half of a batch is not undone
+	report, err := jobs.CollectOrphanFiles(service.Detach(c.Request.Context()), dryRun)
+	if err == jobs.ErrOrphanCollectionRunning {
+		c.JSON(http.StatusConflict, errors.AdminOrphanCollectionRunning)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, report)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
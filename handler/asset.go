@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"fmt"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/errors"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/service"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// assetResponseHeaders are the headers of directus passed on to the client
+var assetResponseHeaders = []string{"Content-Type", "Content-Length", "Content-Range", "Content-Disposition", "Accept-Ranges", "ETag", "Last-Modified"}
+
+// @Summary Get an asset through a signed link
+// @Description streams a directus asset linked with a signature and expiry issued by the service, with Range and conditional requests.
+// @Description Disabled without ASSET_SIGNING_KEY
+// @Tags assets
+// @Produce octet-stream
+// @Param id path string true "Asset ID"
+// @Param sig query string true "HMAC-SHA256 of the asset id, preset and exp"
+// @Param exp query int true "unix time the link expires at"
+// @Param preset query string false "thumb, card or hero image transform"
+// @Param Range header string false "byte range"
+// @Success 200 {file} binary
+// @Success 206 {file} binary
+// @Success 304
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Failure 404 {object} errors.ErrorInfo
+// @Failure 500 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/assets/{id} [get]
+func GetAsset(c *gin.Context) {
+	if !config.IsAssetProxyEnabled() {
+		c.JSON(http.StatusNotFound, errors.AssetsNotFound)
+		return
+	}
+
+	assetIDRequest := dto.AssetIDRequest{}
+	assetQuery := dto.AssetQuery{}
+	if c.ShouldBindUri(&assetIDRequest) != nil || c.ShouldBindQuery(&assetQuery) != nil {
+		c.JSON(http.StatusBadRequest, errors.AssetsInvalidRequestFormat)
+		return
+	}
+
+	if !config.VerifyAsset(assetIDRequest.ID, assetQuery.Preset, assetQuery.Exp, assetQuery.Sig) {
+		logger.Ctx(c.Request.Context()).Warn.Printf("[GetAsset] invalid signature of asset %v from %v\n", assetIDRequest.ID, c.ClientIP())
+		c.JSON(http.StatusForbidden, errors.AssetsInvalidSignature)
+		return
+	}
+	maxAge := assetQuery.Exp - time.Now().Unix()
+	if maxAge <= 0 {
+		c.JSON(http.StatusForbidden, errors.AssetsURLExpired)
+		return
+	}
+
+	response, err := service.OpenDirectusAsset(c.Request.Context(), assetIDRequest.ID, assetQuery.Preset, c.Request.Header)
+	if err != nil {
+		// directus answers 403 for files which do not exist
+		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok && (dsErr.Status == http.StatusNotFound || dsErr.Status == http.StatusForbidden) {
+			c.JSON(http.StatusNotFound, errors.AssetsNotFound)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, errors.InternalError)
+		return
+	}
+	defer response.Body.Close()
+
+	for _, key := range assetResponseHeaders {
+		if value := response.Header.Get(key); len(value) > 0 {
+			c.Header(key, value)
+		}
+	}
+	// the link is only valid until exp, a cached copy must not outlive it
+	c.Header("Cache-Control", fmt.Sprintf("private, max-age=%d", maxAge))
+	c.Status(response.StatusCode)
+
+	if _, err := io.Copy(c.Writer, response.Body); err != nil {
+		logger.Ctx(c.Request.Context()).Debug.Printf("[GetAsset] stream asset %v error: %v\n", assetIDRequest.ID, err)
+	}
+}
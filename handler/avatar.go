@@ -1,23 +1,39 @@
 package handler
 
 import (
+	"context"
+	goErrors "errors"
+	"hubs-cms-go/config"
 	"hubs-cms-go/dto"
 	"hubs-cms-go/errors"
+	"hubs-cms-go/glb"
+	"hubs-cms-go/imaging"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/remote"
 	"hubs-cms-go/service"
+	"hubs-cms-go/snapshot"
 	"hubs-cms-go/validators"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gin-gonic/gin/binding"
 )
 
 // @Summary Get all avatars
-// @Description Get all avatars
+// @Description Get all public avatars, filtered by tags, source and title, sorted by title or by the number of accounts using them
 // @Tags avatars
 // @Accept  json
 // @Produce json
 // @param start path int true "0" Format(int64)
 // @param limit path int true "10" Format(int64)
+// @param tags query string false "comma separated tags, an avatar must have all of them"
+// @param source query string false "source"
+// @param search query string false "part of the title, case insensitive"
+// @param sort query string false "popular or title"
 // @Success 200 {object} dto.GetAvatarsResponse
 // @Failure 400 {object} errors.ErrorInfo
 // @Failure 500 {object} errors.ErrorInfo
@@ -38,10 +54,28 @@ func GetPublicAvatars(c *gin.Context) {
 		return
 	}
 
+	avatarQuery := dto.AvatarQuery{}
+	if err := c.ShouldBindWith(&avatarQuery, binding.Form); err != nil {
+		c.JSON(http.StatusBadRequest, errors.AvatarsInvalidRequestFormat)
+		return
+	}
+
+	tags, ok := dto.NormalizeAvatarTags([]string{avatarQuery.Tags})
+	if !ok {
+		c.JSON(http.StatusBadRequest, errors.AvatarsInvalidTags)
+		return
+	}
+
 	start, _ := pageRequestParam.Start.Int64()
 	limit, _ := pageRequestParam.Limit.Int64()
 
-	directusAvatars, errorInfo := service.GetPublicAvatars(start, limit, false)
+	var directusAvatars dto.GetAvatarsResponse
+	var errorInfo errors.ErrorInfo
+	if avatarQuery.IsEmpty() {
+		directusAvatars, errorInfo = service.GetPublicAvatars(c.Request.Context(), start, limit)
+	} else {
+		directusAvatars, errorInfo = service.SearchPublicAvatars(c.Request.Context(), avatarQuery, tags, start, limit)
+	}
 	if errorInfo != (errors.ErrorInfo{}) {
 		c.JSON(http.StatusInternalServerError, errorInfo)
 		return
@@ -50,6 +84,163 @@ func GetPublicAvatars(c *gin.Context) {
 	c.JSON(http.StatusOK, directusAvatars)
 }
 
+// @Summary Get the favorite avatars of login user
+// @Description Get the favorite avatars of login user which are still public or owned by the user
+// @Tags avatars
+// @Accept  json
+// @Produce json
+// @param start path int true "0" Format(int64)
+// @param limit path int true "10" Format(int64)
+// @Success 200 {object} dto.GetAvatarsResponse
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 401 {object} errors.ErrorInfo
+// @Failure 500 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/my-favorite-avatars [get]
+func GetFavoriteAvatars(c *gin.Context) {
+
+	pageRequestParam := dto.PageRequestParam{}
+	if err := c.ShouldBindWith(&pageRequestParam, binding.Form); err != nil {
+		if validators.IsInvalid("PageRequestParam.Limit", err) {
+			c.JSON(http.StatusBadRequest, errors.AvatarsInvalidLimit)
+			return
+		}
+		if validators.IsInvalid("PageRequestParam.Start", err) {
+			c.JSON(http.StatusBadRequest, errors.AvatarsInvalidStart)
+			return
+		}
+		c.JSON(http.StatusBadRequest, errors.AvatarsInvalidRequestFormat)
+		return
+	}
+
+	pDirectusAccount := getDirectusAccountDataByHeaderInfo(c)
+	if pDirectusAccount == nil {
+		c.JSON(http.StatusUnauthorized, errors.UnauthorizedError)
+		return
+	}
+
+	avatarIDs := make([]string, len(pDirectusAccount.FavoriteAvatars))
+	for i := range pDirectusAccount.FavoriteAvatars {
+		avatarIDs[i] = pDirectusAccount.FavoriteAvatars[i].AvatarID
+	}
+
+	start, _ := pageRequestParam.Start.Int64()
+	limit, _ := pageRequestParam.Limit.Int64()
+
+	directusAvatars, errorInfo := service.GetFavoriteAvatars(c.Request.Context(), pDirectusAccount.ID, avatarIDs, start, limit)
+	if errorInfo != (errors.ErrorInfo{}) {
+		c.JSON(errorInfo.HttpStatus, errorInfo)
+		return
+	}
+
+	c.JSON(http.StatusOK, directusAvatars)
+}
+
+// @Summary Add an avatar to the favorites of login user
+// @Description Add a public avatar, or an avatar of login user, to the favorites of login user
+// @Tags avatars
+// @Accept  json
+// @Produce json
+// @Param id path string true "Avatar ID"
+// @Success 200 {object} dto.AvatarFavoriteResponse
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 401 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Failure 500 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/avatars/{id}/favorited [post]
+func PostFavoriteAvatar(c *gin.Context) {
+	toggleAvatarFavorite(c, true)
+}
+
+// @Summary Remove an avatar from the favorites of login user
+// @Description Remove an avatar from the favorites of login user
+// @Tags avatars
+// @Accept  json
+// @Produce json
+// @Param id path string true "Avatar ID"
+// @Success 200 {object} dto.AvatarFavoriteResponse
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 401 {object} errors.ErrorInfo
+// @Failure 500 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/avatars/{id}/unfavorited [post]
+func PostUnfavoriteAvatar(c *gin.Context) {
+	toggleAvatarFavorite(c, false)
+}
+
+func toggleAvatarFavorite(c *gin.Context, isFavorite bool) {
+	param := dto.AvatarIDRequest{}
+	if err := c.ShouldBindUri(&param); err != nil {
+		c.JSON(http.StatusBadRequest, errors.AvatarsInvalidID)
+		return
+	}
+
+	pDirectusAccount := getDirectusAccountDataByHeaderInfo(c)
+	if pDirectusAccount == nil {
+		logger.Ctx(c.Request.Context()).Warn.Println("[toggleAvatarFavorite] Cannot find account")
+		c.JSON(http.StatusUnauthorized, errors.UnauthorizedError)
+		return
+	}
+
+	indexOfAvatar := -1
+	for i := range pDirectusAccount.FavoriteAvatars {
+		if param.ID == pDirectusAccount.FavoriteAvatars[i].AvatarID {
+			indexOfAvatar = i
+			break
+		}
+	}
+
+	// already favorite / not favorite
+	if isFavorite == (indexOfAvatar >= 0) {
+		c.JSON(http.StatusOK, dto.AvatarFavoriteResponse{IsFavorite: isFavorite})
+		return
+	}
+
+	ctx := c.Request.Context()
+	m2mPatchBody := struct {
+		FavoriteAvatars *dto.DirectusM2MPatchRequest `json:"favorite_avatars"`
+	}{}
+	if isFavorite {
+		// an avatar may be left in the favorites after it is un-published, it cannot be added then
+		avatarResponse, err := service.GetAvatar(ctx, param.ID)
+		if err != nil || (!avatarResponse.Data.IsPublic && avatarResponse.Data.Owner != pDirectusAccount.ID) {
+			c.JSON(http.StatusForbidden, errors.ForbiddenError)
+			return
+		}
+
+		createBody := struct {
+			AvatarID  string `json:"avatar_id"`
+			AccountID string `json:"account_id"`
+		}{
+			AvatarID:  param.ID,
+			AccountID: pDirectusAccount.ID,
+		}
+		m2mPatchBody.FavoriteAvatars = &dto.DirectusM2MPatchRequest{
+			Create: []interface{}{createBody},
+		}
+	} else {
+		recordID, err := pDirectusAccount.FavoriteAvatars[indexOfAvatar].ID.Int64()
+		if err != nil {
+			logger.Ctx(ctx).Error.Printf("[toggleAvatarFavorite] Parse id error: %v\n", err)
+			c.JSON(http.StatusInternalServerError, errors.InternalError)
+			return
+		}
+		m2mPatchBody.FavoriteAvatars = &dto.DirectusM2MPatchRequest{
+			Delete: []int64{recordID},
+		}
+	}
+
+	if _, err := service.PatchDirectusAccount(ctx, pDirectusAccount.ID, &m2mPatchBody); err != nil {
+		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
+			ee := directusErrorHandler(dsErr)
+			c.JSON(ee.HttpStatus, ee)
+		} else {
+			c.JSON(http.StatusInternalServerError, errors.InternalError)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.AvatarFavoriteResponse{IsFavorite: isFavorite})
+}
+
 // @Summary Get all avatars of login user
 // @Description Get all avatars of login user
 // @Tags avatars
@@ -86,7 +277,7 @@ func GetMyAvatars(c *gin.Context) {
 	start, _ := pageRequestParam.Start.Int64()
 	limit, _ := pageRequestParam.Limit.Int64()
 
-	directusAccount, err := service.GetDirectusAccount(mastodonAccountInfo.MastodonAccount, false)
+	directusAccount, err := service.GetDirectusAccount(c.Request.Context(), mastodonAccountInfo.MastodonAccount)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
@@ -97,7 +288,7 @@ func GetMyAvatars(c *gin.Context) {
 		return
 	}
 
-	directusAvatars, errorInfo := service.GetMyAvatars(directusAccount.ID, start, limit, false)
+	directusAvatars, errorInfo := service.GetMyAvatars(c.Request.Context(), directusAccount.ID, start, limit)
 	if errorInfo != (errors.ErrorInfo{}) {
 		c.JSON(http.StatusInternalServerError, errorInfo)
 		return
@@ -113,9 +304,13 @@ func GetMyAvatars(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Account ID"
 // @Param body body dto.UploadAvatarRequest.ID true "Create avatar"
-// @Param file formData file true "snapshot"
+// @Param file formData file true "png, jpeg or webp snapshot, re-encoded without its metadata under the IMAGE_* limits"
+// @Param glb formData string false "url of the glb imported under the IMPORT_* policy, required without glb_file"
+// @Param glb_file formData file false "binary glTF 2.0 avatar, validated before it is uploaded"
+// @Param tags formData string false "comma separated tags"
 // @Success 200 {object} dto.DirectusAvatar
 // @Failure 400 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
 // @Failure 500 {object} errors.ErrorInfo
 // @Router /api/hubs-cms/v1/avatars/{id} [post]
 func CreateAvatar(c *gin.Context) {
@@ -126,7 +321,7 @@ func CreateAvatar(c *gin.Context) {
 		return
 	}
 
-	directusAccount, err := service.GetDirectusAccount(mastodonAccountInfo.MastodonAccount, false)
+	directusAccount, err := service.GetDirectusAccount(c.Request.Context(), mastodonAccountInfo.MastodonAccount)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
@@ -138,29 +333,58 @@ func CreateAvatar(c *gin.Context) {
 		return
 	}
 
-	snapshotID, err := service.UploadAsset(uploadAvatarRequest.Snapshot, false)
+	tags, ok := dto.NormalizeAvatarTags(uploadAvatarRequest.Tags)
+	if !ok {
+		c.JSON(http.StatusBadRequest, errors.AvatarsInvalidTags)
+		return
+	}
+
+	// reject an invalid snapshot or model before anything is uploaded to directus
+	ctx := c.Request.Context()
+	snapshotImage, errorInfo := processAvatarSnapshot(ctx, uploadAvatarRequest.Snapshot)
+	if !errorInfo.IsNil() {
+		c.JSON(errorInfo.HttpStatus, errorInfo)
+		return
+	}
+
+	model, errorInfo := prepareAvatarGLB(ctx, uploadAvatarRequest.GLBFile, uploadAvatarRequest.GLB)
+	if !errorInfo.IsNil() {
+		c.JSON(errorInfo.HttpStatus, errorInfo)
+		return
+	}
+
+	if errorInfo := checkAvatarQuota(ctx, directusAccount, 1, imageBytes(snapshotImage)+int64(len(model.data))); !errorInfo.IsNil() {
+		c.JSON(errorInfo.HttpStatus, errorInfo)
+		return
+	}
+
+	snapshotID, snapshotThumbnails, err := snapshot.Upload(ctx, uploadAvatarRequest.Snapshot.Filename, snapshotImage)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
 	}
 
-	glbID, err := service.ImportAsset(uploadAvatarRequest.GLB, false)
+	glbID, err := uploadAvatarGLB(ctx, model)
 	if err != nil {
+		removeAvatarUploads(ctx, snapshotID, snapshotThumbnails, "")
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
 	}
 
 	directusCreateAvatarRequest := dto.DirectusCreateAvatarRequest{
-		Title:    uploadAvatarRequest.Title,
-		Source:   uploadAvatarRequest.Source,
-		IsPublic: *uploadAvatarRequest.IsPublic,
-		GLB:      glbID,
-		Snapshot: snapshotID,
-		Owner:    directusAccount.ID,
+		Title:              uploadAvatarRequest.Title,
+		Source:             uploadAvatarRequest.Source,
+		IsPublic:           *uploadAvatarRequest.IsPublic,
+		GLB:                glbID,
+		Snapshot:           snapshotID,
+		SnapshotThumbnails: snapshotThumbnails,
+		Owner:              directusAccount.ID,
+		Tags:               tags,
 	}
 
-	createdAvatar, err := service.CreateAvatar(directusCreateAvatarRequest, false)
+	createdAvatar, err := service.CreateAvatar(c.Request.Context(), directusCreateAvatarRequest)
 	if err != nil {
+		removeAvatarUploads(ctx, snapshotID, snapshotThumbnails, glbID)
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
 	}
@@ -168,12 +392,332 @@ func CreateAvatar(c *gin.Context) {
 	c.JSON(http.StatusOK, createdAvatar)
 }
 
+// @Summary Update an avatar of login user
+// @Description Change the title, source or visibility of an avatar, or replace its snapshot or glb. Un-publishing an avatar clears it on the other accounts using it
+// @Tags avatars
+// @Accept  multipart/form-data
+// @Accept  json
+// @Produce json
+// @Param id path string true "Avatar ID"
+// @Param title formData string false "title"
+// @Param source formData string false "source"
+// @Param is_public formData bool false "false un-publishes the avatar"
+// @Param snapshot formData file false "png, jpeg or webp snapshot replacing the current one"
+// @Param glb formData string false "url of a glb replacing the current one, imported under the IMPORT_* policy"
+// @Param glb_file formData file false "binary glTF 2.0 avatar replacing the current one"
+// @Param tags formData string false "comma separated tags replacing the current ones, empty clears them"
+// @Success 200 {object} dto.DirectusAvatar
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Failure 500 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/avatars/{id} [patch]
+func PatchAvatar(c *gin.Context) {
+
+	mastodonAccountInfo, err := GetMastodonAccountInfo(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalError)
+		return
+	}
+
+	directusAccount, err := service.GetDirectusAccount(c.Request.Context(), mastodonAccountInfo.MastodonAccount)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalError)
+		return
+	}
+
+	avatarIDRequest := dto.AvatarIDRequest{}
+	if err := c.ShouldBindUri(&avatarIDRequest); err != nil {
+		c.JSON(http.StatusBadRequest, errors.AvatarsInvalidID)
+		return
+	}
+
+	patchAvatarRequest := dto.PatchAvatarRequest{}
+	if err := c.ShouldBind(&patchAvatarRequest); err != nil || !patchAvatarRequest.Validate() {
+		c.JSON(http.StatusBadRequest, errors.AvatarsInvalidRequestFormat)
+		return
+	}
+
+	var tags *[]string
+	if patchAvatarRequest.Tags != nil {
+		normalized, ok := dto.NormalizeAvatarTags(patchAvatarRequest.Tags)
+		if !ok {
+			c.JSON(http.StatusBadRequest, errors.AvatarsInvalidTags)
+			return
+		}
+		tags = &normalized
+	}
+
+	ctx := c.Request.Context()
+	avatarResponse, err := service.GetAvatar(ctx, avatarIDRequest.ID)
+	if err != nil || avatarResponse.Data.Owner != directusAccount.ID {
+		c.JSON(http.StatusForbidden, errors.ForbiddenError)
+		return
+	}
+
+	// reject an invalid snapshot or model before anything is uploaded to directus
+	var snapshotImage imaging.Result
+	errorInfo := errors.ErrorInfo{}
+	if patchAvatarRequest.Snapshot != nil {
+		snapshotImage, errorInfo = processAvatarSnapshot(ctx, patchAvatarRequest.Snapshot)
+	}
+	var model avatarGLB
+	replaceGLB := patchAvatarRequest.GLBFile != nil || len(patchAvatarRequest.GLB) > 0
+	if errorInfo.IsNil() && replaceGLB {
+		model, errorInfo = prepareAvatarGLB(ctx, patchAvatarRequest.GLBFile, patchAvatarRequest.GLB)
+	}
+	if errorInfo.IsNil() && (patchAvatarRequest.Snapshot != nil || replaceGLB) {
+		var newBytes int64
+		replaced := []string{}
+		if patchAvatarRequest.Snapshot != nil {
+			newBytes += imageBytes(snapshotImage)
+			replaced = append(replaced, avatarResponse.Data.Snapshot)
+			for _, id := range avatarResponse.Data.SnapshotThumbnails {
+				replaced = append(replaced, id)
+			}
+		}
+		if replaceGLB {
+			newBytes += int64(len(model.data))
+			replaced = append(replaced, avatarResponse.Data.GLB)
+		}
+		errorInfo = checkAvatarQuota(ctx, directusAccount, 0, newBytes, replaced...)
+	}
+	if !errorInfo.IsNil() {
+		c.JSON(errorInfo.HttpStatus, errorInfo)
+		return
+	}
+
+	directusPatchAvatarRequest := dto.DirectusPatchAvatarRequest{
+		Title:    patchAvatarRequest.Title,
+		Source:   patchAvatarRequest.Source,
+		IsPublic: patchAvatarRequest.IsPublic,
+		Tags:     tags,
+	}
+	if patchAvatarRequest.Snapshot != nil {
+		directusPatchAvatarRequest.Snapshot, directusPatchAvatarRequest.SnapshotThumbnails, err = snapshot.Upload(ctx, patchAvatarRequest.Snapshot.Filename, snapshotImage)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, errors.InternalError)
+			return
+		}
+	}
+	if replaceGLB {
+		if directusPatchAvatarRequest.GLB, err = uploadAvatarGLB(ctx, model); err != nil {
+			removeAvatarUploads(ctx, directusPatchAvatarRequest.Snapshot, directusPatchAvatarRequest.SnapshotThumbnails, "")
+			c.JSON(http.StatusInternalServerError, errors.InternalError)
+			return
+		}
+	}
+
+	patchedAvatar, err := service.PatchAvatar(ctx, avatarIDRequest.ID, directusPatchAvatarRequest)
+	if err != nil {
+		removeAvatarUploads(ctx, directusPatchAvatarRequest.Snapshot, directusPatchAvatarRequest.SnapshotThumbnails, directusPatchAvatarRequest.GLB)
+		c.JSON(http.StatusInternalServerError, errors.InternalError)
+		return
+	}
+
+	// other accounts may no longer use an avatar which is not public, the owner keeps it.
+	// The clear runs on every patch of a private avatar, so a retry finishes a failed clear
+	if !patchedAvatar.IsPublic {
+		if _, err := service.ClearActiveAvatar(ctx, avatarIDRequest.ID, directusAccount.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, errors.InternalError)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, patchedAvatar)
+}
+
+// avatarGLB is a model checked before it is uploaded, data is nil for a url imported by directus
+type avatarGLB struct {
+	url         string
+	name        string
+	contentType string
+	data        []byte
+}
+
+// prepareAvatarGLB reads the uploaded model or fetches its url, and validates it. In the directus import mode
+// the url is only probed since directus fetches it again
+func prepareAvatarGLB(ctx context.Context, glbFile *multipart.FileHeader, glbURL string) (avatarGLB, errors.ErrorInfo) {
+	model := avatarGLB{url: glbURL, contentType: "model/gltf-binary"}
+	var errorInfo errors.ErrorInfo
+	switch {
+	case glbFile != nil:
+		model.name = glbFile.Filename
+		model.data, errorInfo = readAvatarFile(ctx, glbFile, int64(config.EnvVariable.GLBMaxMB)<<20, errors.AvatarsGLBTooLarge)
+	case strings.EqualFold(config.EnvVariable.ImportMode, "fetch"):
+		model.data, model.contentType, model.name, errorInfo = fetchAvatarGLB(ctx, glbURL)
+	default:
+		errorInfo = remoteErrorInfo(ctx, glbURL, remote.Probe(ctx, glbURL))
+	}
+	if errorInfo.IsNil() && model.data != nil {
+		errorInfo = validateAvatarGLB(ctx, model.name, model.data)
+	}
+	return model, errorInfo
+}
+
+// uploadAvatarGLB stores a model prepared by prepareAvatarGLB and returns its asset id
+func uploadAvatarGLB(ctx context.Context, model avatarGLB) (string, error) {
+	if model.data != nil {
+		return service.UploadAssetData(ctx, model.name, model.contentType, model.data)
+	}
+	return service.ImportAsset(ctx, model.url)
+}
+
+// removeAvatarUploads deletes the files uploaded for an avatar which was not created or patched
+func removeAvatarUploads(ctx context.Context, snapshotID string, snapshotThumbnails map[string]string, glbID string) {
+	fileIDs := []string{snapshotID, glbID}
+	for _, id := range snapshotThumbnails {
+		fileIDs = append(fileIDs, id)
+	}
+	service.RemoveFiles(ctx, fileIDs...)
+}
+
+// checkAvatarQuota rejects uploads which take the account over its quota. newAvatars and newBytes are added to the usage
+// and the replaced files are taken out of it. Models imported by directus are only counted once they are stored
+func checkAvatarQuota(ctx context.Context, account dto.DirectusAccount, newAvatars, newBytes int64, replaced ...string) errors.ErrorInfo {
+	usage, fileSizes, err := service.GetAvatarUsage(ctx, account.ID, account.AvatarQuota)
+	if err != nil {
+		return errors.InternalError
+	}
+
+	replacedFiles := map[string]bool{}
+	for _, id := range replaced {
+		if !replacedFiles[id] {
+			replacedFiles[id] = true
+			usage.Bytes -= fileSizes[id]
+		}
+	}
+
+	if (usage.MaxAvatarCount > 0 && usage.AvatarCount+newAvatars > usage.MaxAvatarCount) ||
+		(usage.MaxBytes > 0 && usage.Bytes+newBytes > usage.MaxBytes) {
+		logger.Ctx(ctx).Warn.Printf("[checkAvatarQuota] account %v over quota: %d+%d avatars, %d+%d bytes, usage %+v\n", account.ID, usage.AvatarCount, newAvatars, usage.Bytes, newBytes, usage)
+		return errors.AvatarsQuotaExceeded
+	}
+	return errors.ErrorInfo{}
+}
+
+// imageBytes is the size of a processed image with its thumbnails
+func imageBytes(result imaging.Result) int64 {
+	size := int64(len(result.Data))
+	for _, thumbnail := range result.Thumbnails {
+		size += int64(len(thumbnail.Data))
+	}
+	return size
+}
+
+// readAvatarFile reads an uploaded file up to maxSize, tooLarge is returned for larger files
+func readAvatarFile(ctx context.Context, file *multipart.FileHeader, maxSize int64, tooLarge errors.ErrorInfo) ([]byte, errors.ErrorInfo) {
+	if file.Size > maxSize {
+		return nil, tooLarge
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		logger.Ctx(ctx).Error.Printf("[readAvatarFile] open %v error: %v\n", file.Filename, err)
+		return nil, errors.InternalError
+	}
+	defer f.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		logger.Ctx(ctx).Error.Printf("[readAvatarFile] read %v error: %v\n", file.Filename, err)
+		return nil, errors.InternalError
+	}
+	if int64(len(data)) > maxSize {
+		return nil, tooLarge
+	}
+	return data, errors.ErrorInfo{}
+}
+
+// processAvatarSnapshot re-encodes the snapshot without its metadata under the IMAGE_* limits
+func processAvatarSnapshot(ctx context.Context, file *multipart.FileHeader) (imaging.Result, errors.ErrorInfo) {
+	data, errorInfo := readAvatarFile(ctx, file, int64(config.EnvVariable.ImageMaxMB)<<20, errors.AvatarsSnapshotTooLarge)
+	if !errorInfo.IsNil() {
+		return imaging.Result{}, errorInfo
+	}
+
+	result, err := snapshot.Process(data)
+	if err != nil {
+		logger.Ctx(ctx).Warn.Printf("[processAvatarSnapshot] reject %v: %v\n", file.Filename, err)
+		switch {
+		case goErrors.Is(err, imaging.ErrTooLarge):
+			return imaging.Result{}, errors.AvatarsSnapshotTooLarge
+		case goErrors.Is(err, imaging.ErrDimensions):
+			return imaging.Result{}, errors.AvatarsSnapshotDimensions
+		default:
+			return imaging.Result{}, errors.AvatarsInvalidSnapshot
+		}
+	}
+	return result, errors.ErrorInfo{}
+}
+
+// fetchAvatarGLB downloads the model under the IMPORT_* policy
+func fetchAvatarGLB(ctx context.Context, glbURL string) (data []byte, contentType, fileName string, errorInfo errors.ErrorInfo) {
+	data, contentType, fileName, err := remote.Fetch(ctx, glbURL)
+	if err != nil {
+		return nil, "", "", remoteErrorInfo(ctx, glbURL, err)
+	}
+	return data, contentType, fileName, errors.ErrorInfo{}
+}
+
+func remoteErrorInfo(ctx context.Context, glbURL string, err error) errors.ErrorInfo {
+	if err == nil {
+		return errors.ErrorInfo{}
+	}
+
+	logger.Ctx(ctx).Warn.Printf("[remoteErrorInfo] reject %.128s: %v\n", glbURL, err)
+	switch {
+	case goErrors.Is(err, remote.ErrNotAllowed):
+		return errors.AvatarsImportNotAllowed
+	case goErrors.Is(err, remote.ErrTooLarge):
+		return errors.AvatarsGLBTooLarge
+	case goErrors.Is(err, remote.ErrContentType):
+		return errors.AvatarsImportContentType
+	default:
+		return errors.AvatarsImportFailed
+	}
+}
+
+// validateAvatarGLB parses the model and checks it against the GLB_* limits
+func validateAvatarGLB(ctx context.Context, fileName string, data []byte) errors.ErrorInfo {
+	limits := glb.Limits{
+		MaxSize:        int64(config.EnvVariable.GLBMaxMB) << 20,
+		MaxTriangles:   config.EnvVariable.GLBMaxTriangles,
+		MaxTextures:    config.EnvVariable.GLBMaxTextures,
+		MaxTextureSize: config.EnvVariable.GLBMaxTextureSize,
+		RequiredNodes:  config.EnvVariable.GLBRequiredNodes,
+	}
+
+	stats, err := glb.Validate(data, limits)
+	if err != nil {
+		logger.Ctx(ctx).Warn.Printf("[validateAvatarGLB] reject %v: %v\n", fileName, err)
+		switch {
+		case goErrors.Is(err, glb.ErrTooLarge):
+			return errors.AvatarsGLBTooLarge
+		case goErrors.Is(err, glb.ErrMissingNodes):
+			return errors.AvatarsGLBMissingNodes
+		case goErrors.Is(err, glb.ErrTooManyTriangles):
+			return errors.AvatarsGLBTooManyTriangles
+		case goErrors.Is(err, glb.ErrTextureLimit):
+			return errors.AvatarsGLBTextureLimit
+		case goErrors.Is(err, glb.ErrExternalResource):
+			return errors.AvatarsGLBExternalResource
+		default:
+			return errors.AvatarsInvalidGLB
+		}
+	}
+
+	logger.Ctx(ctx).Debug.Printf("[validateAvatarGLB] %v: %+v\n", fileName, stats)
+	return errors.ErrorInfo{}
+}
+
 // @Summary delete an avatar by id
-// @Description delete an avatar by id
+// @Description delete a private avatar by id and clear it on the accounts using it, public avatars are un-published first
 // @Tags avatars
 // @Param id path string true "Account ID"
 // @Success 200 {string} ok
 // @Failure 400 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Failure 409 {object} errors.ErrorInfo
 // @Failure 500 {object} errors.ErrorInfo
 // @Router /api/hubs-cms/v1/avatars/{id} [delete]
 func DeleteAvatar(c *gin.Context) {
@@ -184,7 +728,7 @@ func DeleteAvatar(c *gin.Context) {
 		return
 	}
 
-	directusAccount, err := service.GetDirectusAccount(mastodonAccountInfo.MastodonAccount, false)
+	directusAccount, err := service.GetDirectusAccount(c.Request.Context(), mastodonAccountInfo.MastodonAccount)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
@@ -196,18 +740,29 @@ func DeleteAvatar(c *gin.Context) {
 		return
 	}
 
-	avatarResponse, err := service.GetAvatar(deleteAvatarRequest.ID, false)
+	avatarResponse, err := service.GetAvatar(c.Request.Context(), deleteAvatarRequest.ID)
 	if err != nil {
 		c.JSON(http.StatusForbidden, errors.ForbiddenError)
 		return
 	}
 
-	if avatarResponse.Data.Owner != directusAccount.ID || avatarResponse.Data.IsPublic {
+	if avatarResponse.Data.Owner != directusAccount.ID {
 		c.JSON(http.StatusForbidden, errors.ForbiddenError)
 		return
 	}
 
-	if err := service.DeleteAvatar(deleteAvatarRequest.ID, false); err != nil {
+	// a public avatar is un-published first with PATCH, so that it is not deleted while others pick it
+	if avatarResponse.Data.IsPublic {
+		c.JSON(http.StatusConflict, errors.AvatarsDeletePublic)
+		return
+	}
+
+	if _, err := service.ClearActiveAvatar(c.Request.Context(), deleteAvatarRequest.ID, ""); err != nil {
+		c.JSON(http.StatusInternalServerError, errors.InternalError)
+		return
+	}
+
+	if err := service.DeleteAvatar(c.Request.Context(), deleteAvatarRequest.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
 	}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"hubs-cms-go/cache"
@@ -23,6 +24,7 @@ import (
 // @param start path int false "0" Format(int64)
 // @param limit path int false "10" Format(int64)
 // @param locale path string false "en-US"
+// @param image_preset query string false "thumb, card or hero"
 // @Success 200 {object} dto.GetEventsResponse
 // @Failure 400 {object} errors.ErrorInfo
 // @Failure 500 {object} errors.ErrorInfo
@@ -49,7 +51,7 @@ func GetEvents(c *gin.Context) {
 	limit, _ := param.Limit.Int64()
 	status := param.Status
 
-	directusEvents, total, err := service.GetDirectusEvents(locale, status, start, limit)
+	directusEvents, total, err := service.GetDirectusEvents(c.Request.Context(), locale, status, start, limit)
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -64,7 +66,7 @@ func GetEvents(c *gin.Context) {
 		return
 	}
 
-	res := dto.NewDirectusEvents(directusEvents, start, limit, locale, total)
+	res := dto.NewDirectusEventsWithPreset(directusEvents, start, limit, locale, param.ImagePreset, total)
 
 	c.JSON(http.StatusOK, res)
 }
@@ -91,7 +93,7 @@ func GetEvent(c *gin.Context) {
 		return
 	}
 
-	directusEvent, err := service.GetDirectusEvent(param.ID, param.Locale)
+	directusEvent, err := service.GetDirectusEvent(c.Request.Context(), param.ID, param.Locale)
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -147,12 +149,12 @@ func prepareToggleEventLike(c *gin.Context, isDoLike bool) {
 
 	pDirectusAccount := getDirectusAccountDataByHeaderInfo(c)
 	if pDirectusAccount == nil {
-		logger.Warn.Println("[prepareToggleEventLike] Cannot find account")
+		logger.Ctx(c.Request.Context()).Warn.Println("[prepareToggleEventLike] Cannot find account")
 		c.JSON(http.StatusUnauthorized, errors.UnauthorizedError)
 		return
 	}
 
-	directusEvent, err := service.GetDirectusEvent(param.ID, "")
+	directusEvent, err := service.GetDirectusEvent(c.Request.Context(), param.ID, "")
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -163,7 +165,7 @@ func prepareToggleEventLike(c *gin.Context, isDoLike bool) {
 		return
 	}
 
-	likeCount, err := toggleEventLike(pDirectusAccount, &directusEvent, isDoLike)
+	likeCount, err := toggleEventLike(c.Request.Context(), pDirectusAccount, &directusEvent, isDoLike)
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -177,7 +179,7 @@ func prepareToggleEventLike(c *gin.Context, isDoLike bool) {
 	c.JSON(http.StatusOK, &dto.EventLikeCountResponse{LikeCount: json.Number(fmt.Sprintf("%v", likeCount))})
 }
 
-func toggleEventLike(pDirectusAccount *dto.DirectusAccountResponseData, pDirectusEvent *dto.DirectusEventResponseData, isDoLike bool) (likeCount int64, err error) {
+func toggleEventLike(ctx context.Context, pDirectusAccount *dto.DirectusAccountResponseData, pDirectusEvent *dto.DirectusEventResponseData, isDoLike bool) (likeCount int64, err error) {
 	if pDirectusAccount == nil || pDirectusEvent == nil {
 		return
 	}
@@ -193,10 +195,10 @@ func toggleEventLike(pDirectusAccount *dto.DirectusAccountResponseData, pDirectu
 	// already liked / not like
 	alreadyLiked := indexOfEvent >= 0
 
-	likeCount = processEventLikes(pDirectusEvent.ID, isDoLike, alreadyLiked)
+	likeCount = processEventLikes(ctx, pDirectusEvent.ID, isDoLike, alreadyLiked)
 
 	if isDoLike == alreadyLiked {
-		logger.Debug.Printf("[toggleEventLike] Ignore [%+v] action\n", isDoLike)
+		logger.Ctx(ctx).Debug.Printf("[toggleEventLike] Ignore [%+v] action\n", isDoLike)
 		return
 	}
 
@@ -221,36 +223,36 @@ func toggleEventLike(pDirectusAccount *dto.DirectusAccountResponseData, pDirectu
 
 		var recordID int64
 		if recordID, err = pDirectusAccount.LikedEvents[indexOfEvent].ID.Int64(); err != nil {
-			logger.Warn.Printf("[toggleEventLike] Parse id error: %v\n", err)
+			logger.Ctx(ctx).Warn.Printf("[toggleEventLike] Parse id error: %v\n", err)
 			err = nil // ignore the err
 		} else {
 			m2mPatchBody.LikedEvents.DirectusM2MPatchRequest = &dto.DirectusM2MPatchRequest{
 				Delete: []int64{recordID},
 			}
 		}
-		logger.Debug.Printf("[toggleEventLike] like count: %v, index: %v, id: %v\n", likeCount, indexOfEvent, recordID)
+		logger.Ctx(ctx).Debug.Printf("[toggleEventLike] like count: %v, index: %v, id: %v\n", likeCount, indexOfEvent, recordID)
 	}
 
-	if _, err = service.PatchDirectusAccount(pDirectusAccount.ID, &m2mPatchBody, false); err != nil {
+	if _, err = service.PatchDirectusAccount(ctx, pDirectusAccount.ID, &m2mPatchBody); err != nil {
 		return
 	}
 
 	return
 }
 
-func processEventLikes(id string, isDoLike, alreadyLiked bool) (likes int64) {
+func processEventLikes(ctx context.Context, id string, isDoLike, alreadyLiked bool) (likes int64) {
 
-	logger.Debug.Printf("[processEventLikes] id=%v, isDoLike=%v, alreadyLiked=%v\n", id, isDoLike, alreadyLiked)
+	logger.Ctx(ctx).Debug.Printf("[processEventLikes] id=%v, isDoLike=%v, alreadyLiked=%v\n", id, isDoLike, alreadyLiked)
 	if isDoLike {
 		//like +1
 		if alreadyLiked {
 			_likes, found := cache.EventLikes.Get(id)
 			if !found {
 				//id not found, it should be new event, so create it
-				logger.Debug.Printf("[processEventLikes] likes+1: id not found, so create it.")
+				logger.Ctx(ctx).Debug.Printf("[processEventLikes] likes+1: id not found, so create it.")
 				err := cache.EventLikes.Add(id, int64(1), goCache.NoExpiration)
 				if err != nil {
-					logger.Error.Printf("[processEventLikes] likes+1 [%+v]\n", err)
+					logger.Ctx(ctx).Error.Printf("[processEventLikes] likes+1 [%+v]\n", err)
 				}
 			}
 			likes = _likes.(int64)
@@ -258,13 +260,13 @@ func processEventLikes(id string, isDoLike, alreadyLiked bool) (likes int64) {
 			_likes, err := cache.EventLikes.IncrementInt64(id, 1)
 			if err != nil {
 				//new event, so create it
-				logger.Debug.Printf("[processEventLikes] likes+1 [%+v]\n", err)
+				logger.Ctx(ctx).Debug.Printf("[processEventLikes] likes+1 [%+v]\n", err)
 				err = cache.EventLikes.Add(id, int64(1), goCache.NoExpiration)
 				if err != nil {
-					logger.Debug.Printf("[processEventLikes] likes+1 [%+v]\n", err)
+					logger.Ctx(ctx).Debug.Printf("[processEventLikes] likes+1 [%+v]\n", err)
 					_likes, err = cache.EventLikes.IncrementInt64(id, 1)
 					if err != nil {
-						logger.Error.Printf("[processEventLikes] likes+1 [%+v]\n", err)
+						logger.Ctx(ctx).Error.Printf("[processEventLikes] likes+1 [%+v]\n", err)
 					}
 				} else {
 					_likes = 1
@@ -277,7 +279,7 @@ func processEventLikes(id string, isDoLike, alreadyLiked bool) (likes int64) {
 		if alreadyLiked {
 			_likes, err := cache.EventLikes.DecrementInt64(id, 1)
 			if err != nil {
-				logger.Debug.Printf("[processEventLikes] likes-1 [%+v]\n", err)
+				logger.Ctx(ctx).Debug.Printf("[processEventLikes] likes-1 [%+v]\n", err)
 			}
 			likes = _likes
 		} else {
@@ -288,7 +290,8 @@ func processEventLikes(id string, isDoLike, alreadyLiked bool) (likes int64) {
 		}
 	}
 
-	logger.Debug.Printf("[processEventLikes] likes=%v", likes)
+	logger.Ctx(ctx).Debug.Printf("[processEventLikes] likes=%v", likes)
+	publishLikeCount(dto.StreamTargetEvent, id, isDoLike, alreadyLiked, likes)
 	return
 }
 
@@ -314,7 +317,7 @@ func EventViewCountHandler(c *gin.Context) {
 		return
 	}
 
-	getDirectusEvent, err := service.GetDirectusEvent(param.ID, param.Locale)
+	getDirectusEvent, err := service.GetDirectusEvent(service.NoCache(c.Request.Context()), param.ID, param.Locale)
 
 	if err != nil {
 
@@ -329,11 +332,12 @@ func EventViewCountHandler(c *gin.Context) {
 
 	pDirectusAccount := getDirectusAccountDataByHeaderInfo(c)
 
-	addEventViewCount, addViewCountErrorInfo := service.PostDirectusEventViewCount(getDirectusEvent, param.Locale, false)
+	addEventViewCount, addViewCountErrorInfo := service.PostDirectusEventViewCount(c.Request.Context(), getDirectusEvent, param.Locale)
 	if addViewCountErrorInfo != (errors.ErrorInfo{}) {
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
 	}
+	publishViewCount(dto.StreamTargetEvent, getDirectusEvent.ID, addEventViewCount.ViewCount)
 
 	if pDirectusAccount != nil {
 		for i := range pDirectusAccount.LikedEvents {
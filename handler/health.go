@@ -1,14 +1,129 @@
 package handler
 
 import (
+	"context"
+	"hubs-cms-go/cache"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/jobs"
+	"hubs-cms-go/service"
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+var isDraining int32
+
+// SetDraining marks the service as draining, health check fails until the process exits
+func SetDraining(draining bool) {
+	var v int32
+	if draining {
+		v = 1
+	}
+	atomic.StoreInt32(&isDraining, v)
+}
+
+// IsDraining reports whether the service is shutting down
+func IsDraining() bool {
+	return atomic.LoadInt32(&isDraining) == 1
+}
+
 // HealthHandler is health checker API
 // @Success 200 {string} string "ok"
+// @Failure 503 {string} string "draining"
 // @Router /health [get]
 func HealthHandler(c *gin.Context) {
+	if IsDraining() {
+		c.String(http.StatusServiceUnavailable, "draining")
+		return
+	}
 	c.String(http.StatusOK, "ok")
 }
+
+// LivenessHandler reports the process is alive, it stays ok while draining
+// @Success 200 {string} string "ok"
+// @Router /health/live [get]
+func LivenessHandler(c *gin.Context) {
+	c.String(http.StatusOK, "ok")
+}
+
+// ReadinessHandler checks directus, mastodon and the like cache warmup
+// @Success 200 {object} dto.ReadinessResponse
+// @Failure 503 {object} dto.ReadinessResponse
+// @Router /health/ready [get]
+func ReadinessHandler(c *gin.Context) {
+	if IsDraining() {
+		c.JSON(http.StatusServiceUnavailable, dto.ReadinessResponse{
+			Status:    dto.HealthStatusDown,
+			CheckedAt: time.Now().UTC(),
+			Checks:    map[string]dto.HealthCheck{},
+			LikeCache: jobs.GetLikeCacheStatus(),
+		})
+		return
+	}
+
+	var readiness dto.ReadinessResponse
+	if item, found := cache.Store.Get(constant.CacheKeyReadiness); found {
+		readiness = item.(dto.ReadinessResponse)
+	} else {
+		// cache the result to avoid hammering upstreams
+		readiness = checkReadiness()
+		cache.Store.Set(constant.CacheKeyReadiness, readiness, config.EnvVariable.HealthCacheTTL)
+	}
+
+	if !readiness.IsReady() {
+		c.JSON(http.StatusServiceUnavailable, readiness)
+		return
+	}
+	c.JSON(http.StatusOK, readiness)
+}
+
+func checkReadiness() dto.ReadinessResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), config.EnvVariable.HealthCheckTimeout)
+	defer cancel()
+
+	checkers := map[string]func() error{
+		"directus_token":   func() error { return service.CheckDirectusAccessToken(ctx) },
+		"directus_graphql": func() error { return service.CheckDirectusGraphQL(ctx) },
+		"mastodon":         func() error { return service.CheckMastodon(ctx) },
+	}
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	checks := make(map[string]dto.HealthCheck, len(checkers))
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker func() error) {
+			defer wg.Done()
+			startTime := time.Now()
+			check := dto.NewHealthCheck(startTime, checker())
+
+			lock.Lock()
+			checks[name] = check
+			lock.Unlock()
+		}(name, checker)
+	}
+	wg.Wait()
+
+	readiness := dto.ReadinessResponse{
+		Status:    dto.HealthStatusUp,
+		CheckedAt: time.Now().UTC(),
+		Checks:    checks,
+		LikeCache: jobs.GetLikeCacheStatus(),
+	}
+
+	for _, check := range checks {
+		if check.Status != dto.HealthStatusUp {
+			readiness.Status = dto.HealthStatusDown
+		}
+	}
+	if readiness.LikeCache.Status != dto.LikeCacheStatusReady {
+		readiness.Status = dto.HealthStatusDown
+	}
+
+	return readiness
+}
@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"hubs-cms-go/announce"
+	"hubs-cms-go/cache"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/errors"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/service"
+	"hubs-cms-go/snapshot"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DirectusWebhookAuthHandler allows requests bearing DIRECTUS_WEBHOOK_SECRET or an HMAC-SHA256 signature of the body made with it,
+// the webhook is disabled when it is not set
+func DirectusWebhookAuthHandler(c *gin.Context) {
+	secret := config.EnvVariable.DirectusWebhookSecret
+	if len(secret) == 0 {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	body, err := ioutil.ReadAll(c.Request.Body)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, errors.HookInvalidRequestFormat)
+		return
+	}
+	c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	sharedSecret := c.GetHeader(constant.HeaderWebhookSecret)
+	signature := strings.TrimPrefix(c.GetHeader(constant.HeaderWebhookSignature), "sha256=")
+	if len(sharedSecret) == 0 && len(signature) == 0 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, errors.UnauthorizedError)
+		return
+	}
+
+	if !verifyWebhookSecret(secret, sharedSecret) && !verifyWebhookSignature(secret, signature, body) {
+		logger.Ctx(c.Request.Context()).Warn.Printf("[DirectusWebhookAuthHandler] invalid webhook secret or signature from %v\n", c.ClientIP())
+		c.AbortWithStatusJSON(http.StatusForbidden, errors.ForbiddenError)
+		return
+	}
+
+	c.Next()
+}
+
+func verifyWebhookSecret(secret, sharedSecret string) bool {
+	return len(sharedSecret) > 0 && subtle.ConstantTimeCompare([]byte(sharedSecret), []byte(secret)) == 1
+}
+
+func verifyWebhookSignature(secret, signature string, body []byte) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// @Summary Receive item changes made in directus
+// @Description evicts the local caches of changed rooms, events and avatars, processes new room galleries, and announces new events and public rooms on mastodon
+// @Tags hooks
+// @Accept json
+// @Param X-Webhook-Secret header string false "DIRECTUS_WEBHOOK_SECRET"
+// @Param X-Webhook-Signature header string false "sha256=HMAC-SHA256 of the body with DIRECTUS_WEBHOOK_SECRET"
+// @Param body body dto.DirectusWebhookPayload true "directus webhook or flow payload"
+// @Success 204
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 401 {object} errors.ErrorInfo
+// @Failure 403 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/hooks/directus [post]
+func DirectusWebhookHandler(c *gin.Context) {
+	payload := dto.DirectusWebhookPayload{}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, errors.HookInvalidRequestFormat)
+		return
+	}
+
+	ctx := c.Request.Context()
+	collection, action, keys := payload.TargetCollection(), payload.Action(), payload.ItemKeys()
+	logger.Ctx(ctx).Info.Printf("[DirectusWebhookHandler] %v %v %v\n", collection, action, keys)
+
+	if action != "create" && action != "update" && action != "delete" {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	switch collection {
+	case "room":
+		processGalleries(ctx, action, keys, payload.Fields())
+		announceItems(ctx, collection, action, keys, payload.Fields())
+		// lists may hold the item, drop the whole collection
+		service.InvalidateDirectusCache(ctx, "room", "")
+		if action == "delete" {
+			for _, key := range keys {
+				cache.RoomLikes.Delete(key)
+			}
+		}
+	case "event":
+		announceItems(ctx, collection, action, keys, payload.Fields())
+		service.InvalidateDirectusCache(ctx, "event", "")
+		for _, key := range keys {
+			if action == "delete" {
+				cache.EventLikes.Delete(key)
+			} else {
+				// start_time or end_time may have moved
+				rewatchStreamEvent(ctx, key)
+			}
+		}
+	case "video", "video_caption":
+		// events expand their videos and captions
+		service.InvalidateDirectusCache(ctx, "event", "")
+	case "avatar":
+		service.InvalidateDirectusCache(ctx, "avatar", "")
+	case "account":
+		// accounts are read from directus on every request, liked items of deleted accounts are recounted on restart.
+		// The popularity of avatars is counted on the accounts
+		service.InvalidateDirectusCache(ctx, "account", "")
+	default:
+		logger.Ctx(ctx).Debug.Printf("[DirectusWebhookHandler] ignore collection %v\n", collection)
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// serviceFields are written by this service, updates of them alone are not announced
+var serviceFields = map[string]bool{
+	"like_count":         true,
+	"view_count":         true,
+	"reminded_at":        true,
+	"started_at":         true,
+	"ended_at":           true,
+	"mastodon_status_id": true,
+	"gallery_thumbnails": true,
+}
+
+// processGalleries rewrites the galleries set in directus and makes their thumbnails
+func processGalleries(ctx context.Context, action string, keys []string, fields map[string]json.RawMessage) {
+	if _, found := fields["gallery"]; !found || action == "delete" {
+		return
+	}
+	for _, key := range keys {
+		snapshot.Start(ctx, key)
+	}
+}
+
+// announceItems posts created items and items updated to is_promoted or is_public, and edits the statuses of other updated items
+func announceItems(ctx context.Context, collection, action string, keys []string, fields map[string]json.RawMessage) {
+	if !config.IsAnnouncerEnabled() || action == "delete" {
+		return
+	}
+
+	edited := action == "create"
+	for field := range fields {
+		edited = edited || !serviceFields[field]
+	}
+	if !edited {
+		return
+	}
+
+	flag := "is_promoted"
+	if collection == "room" {
+		flag = "is_public"
+	}
+	var flagged bool
+	json.Unmarshal(fields[flag], &flagged)
+
+	for _, key := range keys {
+		announce.Start(ctx, collection, key, action == "create" || flagged)
+	}
+}
@@ -1,36 +1,297 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	goErrors "errors"
 	"fmt"
+	"hubs-cms-go/client"
 	"hubs-cms-go/config"
 	"hubs-cms-go/constant"
 	"hubs-cms-go/dto"
 	"hubs-cms-go/errors"
 	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
 	"hubs-cms-go/service"
+	"hubs-cms-go/tracing"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// RequestIDMiddleware propagates X-Request-ID from the caller or generates one, so every log line of the request can be correlated
+func RequestIDMiddleware() gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(constant.HeaderRequestID)
+		if !isValidRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		c.Writer.Header().Set(constant.HeaderRequestID, requestID)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), requestID))
+		c.Next()
+	}
+}
+
+func isValidRequestID(requestID string) bool {
+	if len(requestID) == 0 || len(requestID) > 128 {
+		return false
+	}
+	for _, r := range requestID {
+		if r < '!' || r > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// AccessLogMiddleware writes a structured log line for each request, 5xx as error and 4xx as warn
+func AccessLogMiddleware(skipPaths ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		startTime := time.Now()
+		c.Next()
+
+		if skip[c.Request.URL.Path] {
+			return
+		}
+
+		status := c.Writer.Status()
+		entry := logger.Ctx(c.Request.Context())
+		log := entry.Info
+		if status >= http.StatusInternalServerError {
+			log = entry.Error
+		} else if status >= http.StatusBadRequest {
+			log = entry.Warn
+		}
+		if !log.Enabled() {
+			return
+		}
+
+		log.With(logger.Fields{
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"route":      c.FullPath(),
+			"status":     status,
+			"latency_ms": time.Since(startTime).Milliseconds(),
+			"client_ip":  c.ClientIP(),
+			"user_agent": c.Request.UserAgent(),
+			"size":       c.Writer.Size(),
+		}).Print("[AccessLogMiddleware] request completed")
+	}
+}
+
+// CORSMiddleware echoes the request origin with credentials when it matches CORS_ALLOWED_ORIGINS,
+// origins only allowed by "*" get a literal "*" without credentials
 func CORSMiddleware() gin.HandlerFunc {
 
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+		origin := c.GetHeader("Origin")
+		isPreflight := c.Request.Method == http.MethodOptions
+
+		// response differs by origin, shared caches should not mix them up
+		c.Writer.Header().Add("Vary", "Origin")
+		if isPreflight {
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Method")
+			c.Writer.Header().Add("Vary", "Access-Control-Request-Headers")
+		}
+
+		if len(origin) > 0 {
+			if !config.IsCORSOriginAllowed(origin) {
+				logger.Ctx(c.Request.Context()).Warn.Printf("[CORSMiddleware] origin not allowed: %v\n", origin)
+				if isPreflight {
+					c.AbortWithStatus(http.StatusForbidden)
+					return
+				}
+				c.Next()
+				return
+			}
+
+			if config.IsCORSCredentialsAllowed(origin) {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+				c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+			} else {
+				c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+			}
+			c.Writer.Header().Set("Access-Control-Allow-Headers", strings.Join(config.EnvVariable.CORSAllowedHeaders, ", "))
+			c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+		}
+
+		if isPreflight {
+			if len(origin) > 0 && config.EnvVariable.CORSMaxAge > 0 {
+				c.Writer.Header().Set("Access-Control-Max-Age", strconv.Itoa(config.EnvVariable.CORSMaxAge))
+			}
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// MetricsMiddleware records request count and latency by gin route
+func MetricsMiddleware() gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		startTime := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if len(route) == 0 {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		metrics.HTTPRequestsTotal.WithLabelValues(c.Request.Method, route, status).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(startTime).Seconds())
+	}
+}
+
+// TracingMiddleware starts a server span for each request, continuing the trace of the caller
+func TracingMiddleware(skipPaths ...string) gin.HandlerFunc {
+	skip := make(map[string]bool, len(skipPaths))
+	for _, path := range skipPaths {
+		skip[path] = true
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		if len(route) == 0 {
+			route = "unmatched"
+		}
+
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracing.Tracer().Start(ctx, fmt.Sprintf("%s %s", c.Request.Method, route),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPServerAttributesFromHTTPRequest("hubs-cms-go", route, c.Request)...),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(status)...)
+		span.SetStatus(semconv.SpanStatusFromHTTPStatusCode(status))
+		if err := c.Errors.Last(); err != nil {
+			span.RecordError(err)
+		}
+	}
+}
+
+// CircuitBreakerMiddleware fails fast with 503 on APIs backed by directus while its circuit breaker is open
+func CircuitBreakerMiddleware(pathPrefix string, skipPrefixes ...string) gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if !strings.HasPrefix(path, pathPrefix) {
+			c.Next()
+			return
+		}
+		for _, prefix := range skipPrefixes {
+			if strings.HasPrefix(path, prefix) {
+				c.Next()
+				return
+			}
+		}
+
+		if client.IsCircuitOpen("directus") {
+			logger.Ctx(c.Request.Context()).Warn.Printf("[CircuitBreakerMiddleware] directus circuit is open, reject %v %v\n", c.Request.Method, path)
+			c.Header("Retry-After", strconv.Itoa(int(config.EnvVariable.CircuitBreakerOpenTimeout.Seconds())))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, errors.ServiceUnavailableError)
+			return
+		}
+		c.Next()
+	}
+}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
+// etagWriter holds back the body so that ETagMiddleware can hash it before anything is sent
+type etagWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *etagWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *etagWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// ETagMiddleware tags successful GET responses with a hash of the body and answers 304 when it matches If-None-Match
+func ETagMiddleware() gin.HandlerFunc {
+
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet {
+			c.Next()
 			return
 		}
 
+		writer := &etagWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
 		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.Status() != http.StatusOK {
+			c.Writer.Write(writer.body.Bytes())
+			return
+		}
+
+		sum := sha256.Sum256(writer.body.Bytes())
+		etag := fmt.Sprintf("\"%s\"", hex.EncodeToString(sum[:16]))
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "no-cache")
+		// keep the Vary values of CORSMiddleware
+		c.Writer.Header().Add("Vary", constant.HeaderAuthorization)
+
+		if etagMatch(c.GetHeader("If-None-Match"), etag) {
+			c.Writer.WriteHeader(http.StatusNotModified)
+			c.Writer.WriteHeaderNow()
+			return
+		}
+		c.Writer.Write(writer.body.Bytes())
 	}
 }
 
+// etagMatch compares with the weak comparison of If-None-Match
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
 func ErrorMiddleware() gin.HandlerFunc {
 
 	return func(c *gin.Context) {
@@ -39,7 +300,7 @@ func ErrorMiddleware() gin.HandlerFunc {
 		if err == nil {
 			return
 		}
-		logger.Error.Printf("[ErrorMiddleware] error: %v\n", err)
+		logger.Ctx(c.Request.Context()).Error.Printf("[ErrorMiddleware] error: %v\n", err)
 	}
 }
 
@@ -52,7 +313,11 @@ func MastodonTokenHandler(c *gin.Context) {
 	}
 
 	// check token by /api/v1/accounts/verify_credentials
-	verifyCredentialsResponse, err := service.GetMastodonVerifyCredentials(bearerTokenMiddlewareRequest.Token)
+	verifyCredentialsResponse, err := service.GetMastodonVerifyCredentials(c.Request.Context(), bearerTokenMiddlewareRequest.Token)
+	if goErrors.Is(err, client.ErrCircuitOpen) {
+		c.Set(constant.HeaderMastodonHandlerStatus, http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		c.Set(constant.HeaderMastodonHandlerStatus, http.StatusForbidden)
 		return
@@ -84,6 +349,8 @@ func MastodonTokenStatusHandler(c *gin.Context) {
 			c.JSON(http.StatusForbidden, errors.ForbiddenError)
 		case http.StatusUnauthorized:
 			c.JSON(http.StatusUnauthorized, errors.UnauthorizedError)
+		case http.StatusServiceUnavailable:
+			c.JSON(http.StatusServiceUnavailable, errors.ServiceUnavailableError)
 		default:
 			c.JSON(http.StatusInternalServerError, errors.InternalError)
 		}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"hubs-cms-go/cache"
@@ -8,6 +9,7 @@ import (
 	"hubs-cms-go/dto"
 	"hubs-cms-go/errors"
 	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
 	"hubs-cms-go/service"
 	"hubs-cms-go/validators"
 	"net/http"
@@ -43,7 +45,8 @@ func CheckHubsPasscode(c *gin.Context) {
 	}
 
 	hubsID := param.HubsID
-	directusRoomList, total, err := service.GetDirectusRoomList(nil, hubsID, "", 0, 0)
+	// a changed passcode applies at once
+	directusRoomList, total, err := service.GetDirectusRoomList(service.NoCache(c.Request.Context()), nil, hubsID, "", 0, 0)
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -60,12 +63,13 @@ func CheckHubsPasscode(c *gin.Context) {
 			for i := int64(0); i < total; i++ {
 				rooms[i] = directusRoomList[i].ID
 			}
-			logger.Error.Printf("[CheckHubsPasscode] Same HubsID(%v) for %v rooms: [%v]\n", hubsID, total, strings.Join(rooms, ","))
+			logger.Ctx(c.Request.Context()).Error.Printf("[CheckHubsPasscode] Same HubsID(%v) for %v rooms: [%v]\n", hubsID, total, strings.Join(rooms, ","))
 			c.JSON(http.StatusInternalServerError, errors.InternalError)
 			return
 		}
 
 		if passcode := directusRoomList[0].Passcode; len(passcode) > 0 && passcode != param.Passcode {
+			metrics.PasscodeFailuresTotal.Inc()
 			c.JSON(http.StatusForbidden, errors.ForbiddenError)
 			return
 		}
@@ -110,12 +114,12 @@ func prepareToggleRoomLike(c *gin.Context, isDoLike bool) {
 
 	pDirectusAccount := getDirectusAccountDataByHeaderInfo(c)
 	if pDirectusAccount == nil {
-		logger.Warn.Println("[prepareToggleRoomLike] Cannot find account")
+		logger.Ctx(c.Request.Context()).Warn.Println("[prepareToggleRoomLike] Cannot find account")
 		c.JSON(http.StatusUnauthorized, errors.UnauthorizedError)
 		return
 	}
 
-	directusRoom, err := service.GetDirectusRoom(param.ID, "")
+	directusRoom, err := service.GetDirectusRoom(c.Request.Context(), param.ID, "")
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -132,7 +136,7 @@ func prepareToggleRoomLike(c *gin.Context, isDoLike bool) {
 		// 	return
 		// }
 
-		logger.Debug.Println("[prepareToggleRoomLike] owner:", directusRoom.Owner, "account:", pDirectusAccount.ID)
+		logger.Ctx(c.Request.Context()).Debug.Println("[prepareToggleRoomLike] owner:", directusRoom.Owner, "account:", pDirectusAccount.ID)
 		if directusRoom.Owner != pDirectusAccount.ID {
 			c.JSON(http.StatusUnauthorized, errors.UnauthorizedError)
 			return
@@ -140,7 +144,7 @@ func prepareToggleRoomLike(c *gin.Context, isDoLike bool) {
 	}
 
 	var likeCount int64
-	likeCount, err = toggleRoomLike(pDirectusAccount, &directusRoom, isDoLike)
+	likeCount, err = toggleRoomLike(c.Request.Context(), pDirectusAccount, &directusRoom, isDoLike)
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -154,7 +158,7 @@ func prepareToggleRoomLike(c *gin.Context, isDoLike bool) {
 	c.JSON(http.StatusOK, &dto.RoomLikeCountResponse{LikeCount: json.Number(fmt.Sprintf("%v", likeCount))})
 }
 
-func toggleRoomLike(pDirectusAccount *dto.DirectusAccountResponseData, pDirectusRoom *dto.DierctusRoomData, isDoLike bool) (likeCount int64, err error) {
+func toggleRoomLike(ctx context.Context, pDirectusAccount *dto.DirectusAccountResponseData, pDirectusRoom *dto.DierctusRoomData, isDoLike bool) (likeCount int64, err error) {
 	if pDirectusAccount == nil || pDirectusRoom == nil {
 		return
 	}
@@ -170,10 +174,10 @@ func toggleRoomLike(pDirectusAccount *dto.DirectusAccountResponseData, pDirectus
 	// already liked / not like
 	alreadyLiked := indexOfRoom >= 0
 
-	likeCount = processRoomLikes(pDirectusRoom.ID, isDoLike, alreadyLiked)
+	likeCount = processRoomLikes(ctx, pDirectusRoom.ID, isDoLike, alreadyLiked)
 
 	if isDoLike == alreadyLiked {
-		logger.Debug.Printf("[toggleRoomLike] Ignore [%+v] action\n", isDoLike)
+		logger.Ctx(ctx).Debug.Printf("[toggleRoomLike] Ignore [%+v] action\n", isDoLike)
 		return
 	}
 
@@ -198,36 +202,36 @@ func toggleRoomLike(pDirectusAccount *dto.DirectusAccountResponseData, pDirectus
 
 		var recordID int64
 		if recordID, err = pDirectusAccount.LikedRooms[indexOfRoom].ID.Int64(); err != nil {
-			logger.Warn.Printf("[toggleRoomLike] Parse id error: %v\n", err)
+			logger.Ctx(ctx).Warn.Printf("[toggleRoomLike] Parse id error: %v\n", err)
 			err = nil // ignore the err
 		} else {
 			m2mPatchBody.LikedRooms.DirectusM2MPatchRequest = &dto.DirectusM2MPatchRequest{
 				Delete: []int64{recordID},
 			}
 		}
-		logger.Debug.Printf("[toggleRoomLike] like count: %v, index: %v, id: %v\n", likeCount, indexOfRoom, recordID)
+		logger.Ctx(ctx).Debug.Printf("[toggleRoomLike] like count: %v, index: %v, id: %v\n", likeCount, indexOfRoom, recordID)
 	}
 
-	if _, err = service.PatchDirectusAccount(pDirectusAccount.ID, &m2mPatchBody, false); err != nil {
+	if _, err = service.PatchDirectusAccount(ctx, pDirectusAccount.ID, &m2mPatchBody); err != nil {
 		return
 	}
 
 	return
 }
 
-func processRoomLikes(id string, isDoLike, alreadyLiked bool) (likes int64) {
+func processRoomLikes(ctx context.Context, id string, isDoLike, alreadyLiked bool) (likes int64) {
 
-	logger.Debug.Printf("[processRoomLikes] id=%v, isDoLike=%v, alreadyLiked=%v\n", id, isDoLike, alreadyLiked)
+	logger.Ctx(ctx).Debug.Printf("[processRoomLikes] id=%v, isDoLike=%v, alreadyLiked=%v\n", id, isDoLike, alreadyLiked)
 	if isDoLike {
 		//like +1
 		if alreadyLiked {
 			_likes, found := cache.RoomLikes.Get(id)
 			if !found {
 				//id not found, it should be new event, so create it
-				logger.Debug.Printf("[processRoomLikes] likes+1: id not found, so create it.")
+				logger.Ctx(ctx).Debug.Printf("[processRoomLikes] likes+1: id not found, so create it.")
 				err := cache.RoomLikes.Add(id, int64(1), goCache.NoExpiration)
 				if err != nil {
-					logger.Error.Printf("[processRoomLikes] likes+1 [%+v]\n", err)
+					logger.Ctx(ctx).Error.Printf("[processRoomLikes] likes+1 [%+v]\n", err)
 				}
 			}
 			likes = _likes.(int64)
@@ -235,13 +239,13 @@ func processRoomLikes(id string, isDoLike, alreadyLiked bool) (likes int64) {
 			_likes, err := cache.RoomLikes.IncrementInt64(id, 1)
 			if err != nil {
 				//new event, so create it
-				logger.Debug.Printf("[processRoomLikes] likes+1 [%+v]\n", err)
+				logger.Ctx(ctx).Debug.Printf("[processRoomLikes] likes+1 [%+v]\n", err)
 				err = cache.RoomLikes.Add(id, int64(1), goCache.NoExpiration)
 				if err != nil {
-					logger.Debug.Printf("[processRoomLikes] likes+1 [%+v]\n", err)
+					logger.Ctx(ctx).Debug.Printf("[processRoomLikes] likes+1 [%+v]\n", err)
 					_likes, err = cache.RoomLikes.IncrementInt64(id, 1)
 					if err != nil {
-						logger.Error.Printf("[processRoomLikes] likes+1 [%+v]\n", err)
+						logger.Ctx(ctx).Error.Printf("[processRoomLikes] likes+1 [%+v]\n", err)
 					}
 				} else {
 					_likes = 1
@@ -254,7 +258,7 @@ func processRoomLikes(id string, isDoLike, alreadyLiked bool) (likes int64) {
 		if alreadyLiked {
 			_likes, err := cache.RoomLikes.DecrementInt64(id, 1)
 			if err != nil {
-				logger.Debug.Printf("[processRoomLikes] likes-1 [%+v]\n", err)
+				logger.Ctx(ctx).Debug.Printf("[processRoomLikes] likes-1 [%+v]\n", err)
 			}
 			likes = _likes
 		} else {
@@ -265,7 +269,8 @@ func processRoomLikes(id string, isDoLike, alreadyLiked bool) (likes int64) {
 		}
 	}
 
-	logger.Debug.Printf("[processRoomLikes] likes=%v", likes)
+	logger.Ctx(ctx).Debug.Printf("[processRoomLikes] likes=%v", likes)
+	publishLikeCount(dto.StreamTargetRoom, id, isDoLike, alreadyLiked, likes)
 	return
 }
 
@@ -277,6 +282,7 @@ func processRoomLikes(id string, isDoLike, alreadyLiked bool) (likes int64) {
 // @param locale query string false "en-US"
 // @param start query int false "0" Format(int64)
 // @param limit query int false "10" Format(int64)
+// @param image_preset query string false "thumb, card or hero"
 // @Success 200 {object} dto.GetRoomListResponse
 // @Failure 400 {object} errors.ErrorInfo
 // @Failure 500 {object} errors.ErrorInfo
@@ -307,12 +313,12 @@ func GetMyRooms(c *gin.Context) {
 
 	pDirectusAccount := getDirectusAccountDataByHeaderInfo(c)
 	if pDirectusAccount == nil {
-		logger.Warn.Println("[GetMyRooms] cannot find account")
+		logger.Ctx(c.Request.Context()).Warn.Println("[GetMyRooms] cannot find account")
 		c.JSON(http.StatusInternalServerError, errors.InternalError)
 		return
 	}
 
-	directusRoomList, total, err := service.GetDirectusMyRoomList(pDirectusAccount.ID, locale, start, limit)
+	directusRoomList, total, err := service.GetDirectusMyRoomList(c.Request.Context(), pDirectusAccount.ID, locale, start, limit)
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -336,7 +342,7 @@ func GetMyRooms(c *gin.Context) {
 
 	results := make([]dto.GetRoomResponseWrap, len(directusRoomList))
 	for i := range results {
-		room := generateResponse(&directusRoomList[i], pDirectusAccount)
+		room := generateResponse(&directusRoomList[i], pDirectusAccount, param.ImagePreset)
 		results[i] = dto.GetRoomResponseWrap{
 			GetRoomResponse: room,
 		}
@@ -359,6 +365,7 @@ func GetMyRooms(c *gin.Context) {
 // @param locale query string false "en-US"
 // @param start query int false "0" Format(int64)
 // @param limit query int false "10" Format(int64)
+// @param image_preset query string false "thumb, card or hero"
 // @Success 200 {object} dto.GetRoomListResponse
 // @Failure 400 {object} errors.ErrorInfo
 // @Failure 500 {object} errors.ErrorInfo
@@ -392,7 +399,7 @@ func GetRoomList(c *gin.Context) {
 		pHasNFT = &param.HasNFT
 	}
 
-	directusRoomList, total, err := service.GetDirectusRoomList(pHasNFT, hubsID, locale, start, limit)
+	directusRoomList, total, err := service.GetDirectusRoomList(c.Request.Context(), pHasNFT, hubsID, locale, start, limit)
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -418,7 +425,7 @@ func GetRoomList(c *gin.Context) {
 
 	results := make([]dto.GetRoomResponseWrap, len(directusRoomList))
 	for i := range results {
-		room := generateResponse(&directusRoomList[i], pDirectusAccount)
+		room := generateResponse(&directusRoomList[i], pDirectusAccount, param.ImagePreset)
 		results[i] = dto.GetRoomResponseWrap{
 			GetRoomResponse: room,
 		}
@@ -459,7 +466,7 @@ func GetRoom(c *gin.Context) {
 	roomId := param.ID
 	locale := param.Locale
 
-	directusRoom, err := service.GetDirectusRoom(roomId, locale)
+	directusRoom, err := service.GetDirectusRoom(c.Request.Context(), roomId, locale)
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -478,14 +485,14 @@ func GetRoom(c *gin.Context) {
 			return
 		}
 
-		logger.Debug.Println("[GetRoom] owner: ", directusRoom.Owner, "account: ", pDirectusAccount.ID)
+		logger.Ctx(c.Request.Context()).Debug.Println("[GetRoom] owner: ", directusRoom.Owner, "account: ", pDirectusAccount.ID)
 		if directusRoom.Owner != pDirectusAccount.ID {
 			c.JSON(http.StatusUnauthorized, errors.UnauthorizedError)
 			return
 		}
 	}
 
-	c.JSON(http.StatusOK, generateResponse(&directusRoom, pDirectusAccount))
+	c.JSON(http.StatusOK, generateResponse(&directusRoom, pDirectusAccount, ""))
 }
 
 func generatePagingResponse(uri string, start, limit, total int64) *dto.Page {
@@ -514,7 +521,8 @@ func generatePagingResponse(uri string, start, limit, total int64) *dto.Page {
 	return &paging
 }
 
-func generateResponse(pDirectusRoom *dto.DierctusRoomData, pDirectusAccount *dto.DirectusAccountResponseData) *dto.GetRoomResponse {
+// generateResponse links the gallery in the image preset, or with its srcset without a preset
+func generateResponse(pDirectusRoom *dto.DierctusRoomData, pDirectusAccount *dto.DirectusAccountResponseData, preset string) *dto.GetRoomResponse {
 	ret := dto.GetRoomResponse{
 		ID:          pDirectusRoom.ID,
 		Title:       pDirectusRoom.Title,
@@ -535,7 +543,8 @@ func generateResponse(pDirectusRoom *dto.DierctusRoomData, pDirectusAccount *dto
 	}
 
 	if pDirectusRoom.Gallery.Validate() {
-		ret.ImageURL = config.GetDirectusGetAssetURI(pDirectusRoom.Gallery.ID)
+		ret.ImageURL, ret.ImageSrcset = dto.ImageLinks(pDirectusRoom.Gallery.ID, pDirectusRoom.IsPublic, preset)
+		ret.ImageThumbnails = config.GetAssetURIs(pDirectusRoom.GalleryThumbnails, pDirectusRoom.IsPublic)
 	}
 
 	if len(pDirectusRoom.HubsID) > 0 {
@@ -632,7 +641,7 @@ func RoomViewCountHandler(c *gin.Context) {
 	roomId := param.ID
 	locale := param.Locale
 
-	directusRoom, err := service.GetDirectusRoom(roomId, locale)
+	directusRoom, err := service.GetDirectusRoom(service.NoCache(c.Request.Context()), roomId, locale)
 	if err != nil {
 		if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
 			ee := directusErrorHandler(dsErr)
@@ -651,18 +660,19 @@ func RoomViewCountHandler(c *gin.Context) {
 			return
 		}
 
-		logger.Debug.Println("[RoomViewCountHandler] owner: ", directusRoom.Owner, "account: ", pDirectusAccount.ID)
+		logger.Ctx(c.Request.Context()).Debug.Println("[RoomViewCountHandler] owner: ", directusRoom.Owner, "account: ", pDirectusAccount.ID)
 		if directusRoom.Owner != pDirectusAccount.ID {
 			c.JSON(http.StatusUnauthorized, errors.UnauthorizedError)
 			return
 		}
 	}
 
-	addViewCount, errInfo := service.PostRoomViewCount(directusRoom, locale, false)
+	addViewCount, errInfo := service.PostRoomViewCount(c.Request.Context(), directusRoom, locale)
 	if errInfo != (errors.ErrorInfo{}) {
 		c.JSON(errInfo.HttpStatus, errInfo)
 		return
 	}
+	publishViewCount(dto.StreamTargetRoom, directusRoom.ID, addViewCount.ViewCount)
 
-	c.JSON(http.StatusOK, generateResponse(&addViewCount, pDirectusAccount))
+	c.JSON(http.StatusOK, generateResponse(&addViewCount, pDirectusAccount, ""))
 }
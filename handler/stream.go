@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/errors"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
+	"hubs-cms-go/service"
+	"hubs-cms-go/stream"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gorilla/websocket"
+)
+
+var streamUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	CheckOrigin: func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		return len(origin) == 0 || config.IsCORSOriginAllowed(origin)
+	},
+}
+
+// @Summary Stream like counts, view counts and event status of rooms and events
+// @Description pushes like_count and view_count deltas and soon/opened/closed transitions as server-sent events,
+// @Description or as websocket text messages when upgraded, websocket clients may send {"action":"subscribe|unsubscribe","rooms":[],"events":[]} later
+// @Tags stream
+// @Produce text/event-stream
+// @Param rooms query string false "comma separated room ids"
+// @Param events query string false "comma separated event ids"
+// @Success 200 {object} dto.StreamMessage
+// @Failure 400 {object} errors.ErrorInfo
+// @Failure 401 {object} errors.ErrorInfo
+// @Router /api/hubs-cms/v1/stream [get]
+func StreamHandler(c *gin.Context) {
+	query := dto.StreamQuery{}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, errors.StreamInvalidRequestFormat)
+		return
+	}
+	request := dto.StreamRequest{Rooms: splitStreamIDs(query.Rooms), Events: splitStreamIDs(query.Events)}
+	if err := binding.Validator.ValidateStruct(&request); err != nil {
+		c.JSON(http.StatusBadRequest, errors.StreamInvalidRequestFormat)
+		return
+	}
+
+	isWebSocket := websocket.IsWebSocketUpgrade(c.Request)
+	if !isWebSocket && len(request.Rooms) == 0 && len(request.Events) == 0 {
+		// server-sent events cannot subscribe later
+		c.JSON(http.StatusBadRequest, errors.StreamInvalidRequestFormat)
+		return
+	}
+
+	subscriber := stream.NewSubscriber()
+	defer subscriber.Close()
+
+	pDirectusAccount := getDirectusAccountDataByHeaderInfo(c)
+	if errInfo := subscribeStream(c.Request.Context(), subscriber, pDirectusAccount, request); errInfo != nil {
+		c.JSON(errInfo.HttpStatus, errInfo)
+		return
+	}
+
+	if isWebSocket {
+		serveWebSocketStream(c, subscriber, pDirectusAccount)
+		return
+	}
+	serveSSEStream(c, subscriber)
+}
+
+func splitStreamIDs(values []string) (ids []string) {
+	for _, value := range values {
+		for _, id := range strings.Split(value, ",") {
+			if id = strings.TrimSpace(id); len(id) > 0 {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return
+}
+
+// subscribeStream subscribes the rooms and events the account is allowed to read, and watches the status of the events
+func subscribeStream(ctx context.Context, subscriber *stream.Subscriber, pDirectusAccount *dto.DirectusAccountResponseData, request dto.StreamRequest) *errors.ErrorInfo {
+	for _, id := range request.Rooms {
+		directusRoom, err := service.GetDirectusRoom(ctx, id, "")
+		if err != nil {
+			return streamDirectusError(err)
+		}
+		if !directusRoom.IsPublic && (pDirectusAccount == nil || directusRoom.Owner != pDirectusAccount.ID) {
+			return &errors.UnauthorizedError
+		}
+		if err := subscriber.Subscribe(dto.StreamTargetRoom, id); err != nil {
+			return &errors.StreamTooManySubscriptions
+		}
+	}
+
+	for _, id := range request.Events {
+		directusEvent, err := service.GetDirectusEvent(ctx, id, "")
+		if err != nil {
+			return streamDirectusError(err)
+		}
+		if err := subscriber.Subscribe(dto.StreamTargetEvent, id); err != nil {
+			return &errors.StreamTooManySubscriptions
+		}
+		stream.WatchEvent(id, directusEvent.StartTime, directusEvent.EndTime)
+	}
+	return nil
+}
+
+func streamDirectusError(err error) *errors.ErrorInfo {
+	if dsErr, ok := err.(*dto.DirectusErrorResponse); ok {
+		return directusErrorHandler(dsErr)
+	}
+	return &errors.InternalError
+}
+
+func unsubscribeStream(subscriber *stream.Subscriber, request dto.StreamRequest) {
+	for _, id := range request.Rooms {
+		subscriber.Unsubscribe(dto.StreamTargetRoom, id)
+	}
+	for _, id := range request.Events {
+		subscriber.Unsubscribe(dto.StreamTargetEvent, id)
+	}
+}
+
+func serveSSEStream(c *gin.Context, subscriber *stream.Subscriber) {
+	metrics.StreamConnections.WithLabelValues("sse").Inc()
+	defer metrics.StreamConnections.WithLabelValues("sse").Dec()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// keep reverse proxies from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(config.EnvVariable.StreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-stream.Done():
+			return
+		case msg := <-subscriber.C:
+			c.SSEvent(msg.Type, msg)
+		case <-heartbeat.C:
+			io.WriteString(c.Writer, ": ping\n\n")
+		}
+		c.Writer.Flush()
+	}
+}
+
+func serveWebSocketStream(c *gin.Context, subscriber *stream.Subscriber, pDirectusAccount *dto.DirectusAccountResponseData) {
+	conn, err := streamUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// the upgrader has replied with the error
+		logger.Ctx(c.Request.Context()).Warn.Printf("[serveWebSocketStream] upgrade error: %v\n", err)
+		return
+	}
+	defer conn.Close()
+
+	metrics.StreamConnections.WithLabelValues("websocket").Inc()
+	defer metrics.StreamConnections.WithLabelValues("websocket").Dec()
+
+	// the reader handles subscriptions and ends the stream when the client goes away
+	ctx := c.Request.Context()
+	closed, done := make(chan struct{}), make(chan struct{})
+	defer close(done)
+	replies := make(chan interface{}, 1)
+	wait := 2 * config.EnvVariable.StreamHeartbeatInterval
+	conn.SetReadLimit(4096)
+	conn.SetReadDeadline(time.Now().Add(wait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wait))
+	})
+
+	go func() {
+		defer close(closed)
+		for {
+			request := dto.StreamRequest{}
+			if err := conn.ReadJSON(&request); err != nil {
+				if _, ok := err.(*websocket.CloseError); !ok {
+					logger.Ctx(ctx).Debug.Printf("[serveWebSocketStream] read error: %v\n", err)
+				}
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(wait))
+
+			var errInfo *errors.ErrorInfo
+			if err := binding.Validator.ValidateStruct(&request); err != nil {
+				errInfo = &errors.StreamInvalidRequestFormat
+			} else if request.Action == "unsubscribe" {
+				unsubscribeStream(subscriber, request)
+			} else {
+				errInfo = subscribeStream(ctx, subscriber, pDirectusAccount, request)
+			}
+			if errInfo != nil {
+				select {
+				case replies <- errInfo:
+				case <-done:
+					return
+				}
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(config.EnvVariable.StreamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		conn.SetWriteDeadline(time.Now().Add(wait))
+
+		var err error
+		select {
+		case <-closed:
+			return
+		case <-stream.Done():
+			conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
+			return
+		case msg := <-subscriber.C:
+			err = conn.WriteJSON(msg)
+		case reply := <-replies:
+			err = conn.WriteJSON(reply)
+		case <-heartbeat.C:
+			err = conn.WriteMessage(websocket.PingMessage, nil)
+		}
+		if err != nil {
+			logger.Ctx(ctx).Debug.Printf("[serveWebSocketStream] write error: %v\n", err)
+			return
+		}
+	}
+}
+
+// publishLikeCount pushes the like count of a room or an event to the stream when a like is added or removed
+func publishLikeCount(target, id string, isDoLike, alreadyLiked bool, likes int64) {
+	if isDoLike == alreadyLiked {
+		return
+	}
+
+	delta := int64(-1)
+	if isDoLike {
+		delta = 1
+	}
+	stream.PublishLikeCount(target, id, delta, likes)
+}
+
+// publishViewCount pushes the view count of a room or an event to the stream
+func publishViewCount(target, id string, views json.Number) {
+	count, err := views.Int64()
+	if err != nil {
+		logger.Warn.Printf("[publishViewCount] %v %v view count %q: %v\n", target, id, views, err)
+		return
+	}
+	stream.PublishViewCount(target, id, 1, count)
+}
+
+// rewatchStreamEvent reschedules the status transitions of a watched event whose times may have changed in directus
+func rewatchStreamEvent(ctx context.Context, id string) {
+	if !stream.IsWatchingEvent(id) {
+		return
+	}
+
+	directusEvent, err := service.GetDirectusEvent(service.NoCache(ctx), id, "")
+	if err != nil {
+		logger.Ctx(ctx).Warn.Printf("[rewatchStreamEvent] get event %v error: %v\n", id, err)
+		return
+	}
+	stream.WatchEvent(id, directusEvent.StartTime, directusEvent.EndTime)
+}
@@ -16,12 +16,12 @@ func getDirectusAccountDataByHeaderInfo(c *gin.Context) (pDirectusAccountData *d
 	}
 
 	if mastodonAccountInfo, err := GetMastodonAccountInfo(c); err == nil {
-		directusAccountData, err := service.GetDirectusAccountData(mastodonAccountInfo.MastodonAccount)
+		directusAccountData, err := service.GetDirectusAccountData(c.Request.Context(), mastodonAccountInfo.MastodonAccount)
 		if err == nil && len(directusAccountData.ID) > 0 {
 			pDirectusAccountData = &directusAccountData
 		}
 
-		logger.Debug.Println("[getDirectusAccountDataByHeaderInfo] MastodonAccount: ", mastodonAccountInfo.MastodonAccount, " id: ", directusAccountData.ID, " err: ", err)
+		logger.Ctx(c.Request.Context()).Debug.Println("[getDirectusAccountDataByHeaderInfo] MastodonAccount: ", mastodonAccountInfo.MastodonAccount, " id: ", directusAccountData.ID, " err: ", err)
 	}
 
 	return
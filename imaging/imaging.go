@@ -0,0 +1,240 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	"golang.org/x/image/webp"
+)
+
+const jpegQuality = 90
+
+var (
+	ErrNotImage   = errors.New("not a png, jpeg or webp image")
+	ErrInvalid    = errors.New("image cannot be decoded")
+	ErrTooLarge   = errors.New("file exceeds the size limit")
+	ErrDimensions = errors.New("image exceeds the dimension limit")
+)
+
+// Limits bounds an image, zero values are not checked
+type Limits struct {
+	MaxSize      int64
+	MaxDimension int
+}
+
+// Image is an encoded image
+type Image struct {
+	Data        []byte
+	ContentType string
+	Width       int
+	Height      int
+}
+
+// Ext returns the file extension of the content type
+func (i Image) Ext() string {
+	if i.ContentType == "image/jpeg" {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+// Result is a processed image with its thumbnails
+type Result struct {
+	Image
+	// Thumbnails holds an image fitting size x size for each size smaller than the image
+	Thumbnails map[int]Image
+}
+
+// Process sniffs the type of data, checks it against limits and re-encodes it without its metadata, EXIF and GPS included.
+// The orientation of a jpeg is applied to the pixels before it is dropped. Jpeg stays jpeg, png and webp become png
+func Process(data []byte, limits Limits, thumbnailSizes []int) (Result, error) {
+	if limits.MaxSize > 0 && int64(len(data)) > limits.MaxSize {
+		return Result{}, fmt.Errorf("%w: %d bytes, limit %d", ErrTooLarge, len(data), limits.MaxSize)
+	}
+
+	contentType := http.DetectContentType(data)
+	var decodeConfig func([]byte) (image.Config, error)
+	var decode func([]byte) (image.Image, error)
+	switch contentType {
+	case "image/png":
+		decodeConfig = func(b []byte) (image.Config, error) { return png.DecodeConfig(bytes.NewReader(b)) }
+		decode = func(b []byte) (image.Image, error) { return png.Decode(bytes.NewReader(b)) }
+	case "image/jpeg":
+		decodeConfig = func(b []byte) (image.Config, error) { return jpeg.DecodeConfig(bytes.NewReader(b)) }
+		decode = func(b []byte) (image.Image, error) { return jpeg.Decode(bytes.NewReader(b)) }
+	case "image/webp":
+		decodeConfig = func(b []byte) (image.Config, error) { return webp.DecodeConfig(bytes.NewReader(b)) }
+		decode = func(b []byte) (image.Image, error) { return webp.Decode(bytes.NewReader(b)) }
+	default:
+		return Result{}, fmt.Errorf("%w: %v", ErrNotImage, contentType)
+	}
+
+	// the header is checked first so that a small file cannot make us allocate a huge image
+	cfg, err := decodeConfig(data)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return Result{}, fmt.Errorf("%w: %dx%d", ErrInvalid, cfg.Width, cfg.Height)
+	}
+	if limits.MaxDimension > 0 && (cfg.Width > limits.MaxDimension || cfg.Height > limits.MaxDimension) {
+		return Result{}, fmt.Errorf("%w: %dx%d, limit %d", ErrDimensions, cfg.Width, cfg.Height, limits.MaxDimension)
+	}
+
+	img, err := decode(data)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if contentType == "image/jpeg" {
+		img = orient(img, jpegOrientation(data))
+	} else {
+		contentType = "image/png"
+	}
+
+	result := Result{Thumbnails: map[int]Image{}}
+	if result.Image, err = encode(img, contentType); err != nil {
+		return Result{}, err
+	}
+
+	for _, size := range thumbnailSizes {
+		if size <= 0 || (result.Width <= size && result.Height <= size) {
+			continue
+		}
+		if result.Thumbnails[size], err = encode(fit(img, size), contentType); err != nil {
+			return Result{}, err
+		}
+	}
+	return result, nil
+}
+
+func encode(img image.Image, contentType string) (Image, error) {
+	b := &bytes.Buffer{}
+	var err error
+	if contentType == "image/jpeg" {
+		err = jpeg.Encode(b, img, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(b, img)
+	}
+	if err != nil {
+		return Image{}, fmt.Errorf("encode %v: %w", contentType, err)
+	}
+	size := img.Bounds().Size()
+	return Image{Data: b.Bytes(), ContentType: contentType, Width: size.X, Height: size.Y}, nil
+}
+
+// fit scales img down to fit size x size, keeping its aspect ratio
+func fit(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	w, h := size, size
+	if bounds.Dx() > bounds.Dy() {
+		h = max(1, bounds.Dy()*size/bounds.Dx())
+	} else {
+		w = max(1, bounds.Dx()*size/bounds.Dy())
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Src, nil)
+	return dst
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// jpegOrientation reads the orientation tag of the EXIF segment, 1 when there is none
+func jpegOrientation(data []byte) int {
+	// walk the markers up to the start of scan
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2 : i+4]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			break
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return exifOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// exifOrientation reads tag 0x0112 in the first IFD of a TIFF structure
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	offset := int64(order.Uint32(tiff[4:8]))
+	if offset+2 > int64(len(tiff)) {
+		return 1
+	}
+	count := int64(order.Uint16(tiff[offset:]))
+	for entry := offset + 2; entry+12 <= int64(len(tiff)) && count > 0; entry, count = entry+12, count-1 {
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orient transforms img so that it is displayed upright without the EXIF orientation o
+func orient(img image.Image, o int) image.Image {
+	if o <= 1 || o > 8 {
+		return img
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dw, dh := w, h
+	if o >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := x, y
+			switch o {
+			case 2:
+				dx = w - 1 - x
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dy = h - 1 - y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
@@ -1,9 +1,12 @@
 package jobs
 
 import (
+	"context"
 	"hubs-cms-go/cache"
 	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
 	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
 	"hubs-cms-go/service"
 	"sync"
 	"time"
@@ -12,49 +15,249 @@ import (
 	"github.com/robfig/cron"
 )
 
+var scheduler *cron.Cron
+var backupLock sync.Mutex
+
+// running tracks the scheduled jobs in progress, cron.Stop does not wait for them
+var running struct {
+	sync.Mutex
+	jobs    sync.WaitGroup
+	stopped bool
+}
+
+var likeCacheStatus = dto.LikeCacheStatus{Status: dto.LikeCacheStatusPending}
+var likeCacheStatusLock sync.RWMutex
+
+const (
+	likeCacheRetryMin = 10 * time.Second
+	likeCacheRetryMax = 5 * time.Minute
+)
+
+// likeCacheRetry retries a failed warmup with exponential backoff until it succeeds or the jobs shut down
+var likeCacheRetry struct {
+	sync.Mutex
+	timer   *time.Timer
+	attempt int
+}
+
 func Setup() {
+	running.Lock()
+	running.stopped = false
+	running.Unlock()
+
 	initialCache()
+	refreshEventLifecycle()
 	startCron()
 }
 
+// Shutdown stops the scheduler, waits for the running jobs until ctx is done and flushes the like counts to directus
+func Shutdown(ctx context.Context) {
+	running.Lock()
+	running.stopped = true
+	running.Unlock()
+
+	if scheduler != nil {
+		scheduler.Stop()
+	}
+	stopLikeCacheRetry()
+	stopEventLifecycle()
+
+	done := make(chan struct{})
+	go func() {
+		running.jobs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		logger.Warn.Printf("[Shutdown] running jobs are not done: %v\n", ctx.Err())
+	}
+
+	startTime := time.Now()
+	count, likes, _ := backupLikeCount(context.Background())
+	logger.Info.Printf("[Shutdown] Flush %v items(event+room) %v likes, duration=%v", count, likes, time.Since(startTime))
+}
+
+// GetLikeCacheStatus reports the warmup progress of the like caches
+func GetLikeCacheStatus() dto.LikeCacheStatus {
+	likeCacheStatusLock.RLock()
+	defer likeCacheStatusLock.RUnlock()
+	return likeCacheStatus
+}
+
+func setLikeCacheStatus(status dto.LikeCacheStatus) {
+	likeCacheStatusLock.Lock()
+	defer likeCacheStatusLock.Unlock()
+	likeCacheStatus = status
+}
+
 func initialCache() {
+	setLikeCacheStatus(dto.LikeCacheStatus{Status: dto.LikeCacheStatusWarming})
+	initialTime := time.Now()
+
+	// counts of a failed attempt are partial, they are restored from scratch
+	cache.EventLikes.Flush()
+	cache.RoomLikes.Flush()
+
 	var wg sync.WaitGroup
+	var likedEventCount, likedRoomCount int
+	var eventErr, roomErr error
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
 		startTime := time.Now()
-		likedEventCount, totalLikes, _ := RestoreEventLikeCount()
-		logger.Debug.Printf("[initialCache] %v liked events has been restored, total likes=%v, duration=%v", likedEventCount, totalLikes, time.Since(startTime))
+		var totalLikes int
+		likedEventCount, totalLikes, eventErr = RestoreEventLikeCount(context.Background())
+		logger.Debug.Printf("[initialCache] %v liked events has been restored, total likes=%v, duration=%v, err=%v", likedEventCount, totalLikes, time.Since(startTime), eventErr)
 	}()
 	go func() {
 		defer wg.Done()
 		startTime := time.Now()
-		likedRoomCount, totalLikes, _ := RestoreRoomLikeCount()
-		logger.Debug.Printf("[initialCache] %v liked rooms has been restored, total likes=%v, duration=%v", likedRoomCount, totalLikes, time.Since(startTime))
+		var totalLikes int
+		likedRoomCount, totalLikes, roomErr = RestoreRoomLikeCount(context.Background())
+		logger.Debug.Printf("[initialCache] %v liked rooms has been restored, total likes=%v, duration=%v, err=%v", likedRoomCount, totalLikes, time.Since(startTime), roomErr)
 	}()
 	wg.Wait()
+
+	// a partial cache would serve and back up wrong like counts, it is dropped and readiness fails until a retry succeeds
+	for _, err := range []error{eventErr, roomErr} {
+		if err != nil {
+			cache.EventLikes.Flush()
+			cache.RoomLikes.Flush()
+			setLikeCacheStatus(dto.LikeCacheStatus{
+				Status:     dto.LikeCacheStatusFailed,
+				EventCount: likedEventCount,
+				RoomCount:  likedRoomCount,
+				DurationMs: time.Since(initialTime).Milliseconds(),
+				Error:      err.Error(),
+			})
+			retryInitialCache(err)
+			return
+		}
+	}
+
+	likeCacheRetry.Lock()
+	likeCacheRetry.attempt = 0
+	likeCacheRetry.Unlock()
+
+	setLikeCacheStatus(dto.LikeCacheStatus{
+		Status:      dto.LikeCacheStatusReady,
+		EventCount:  likedEventCount,
+		RoomCount:   likedRoomCount,
+		DurationMs:  time.Since(initialTime).Milliseconds(),
+		CompletedAt: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// retryInitialCache schedules the next warmup, waiting twice as long after each failure up to likeCacheRetryMax
+func retryInitialCache(err error) {
+	likeCacheRetry.Lock()
+	defer likeCacheRetry.Unlock()
+
+	delay := likeCacheRetryMin << uint(likeCacheRetry.attempt)
+	if delay <= 0 || delay > likeCacheRetryMax {
+		delay = likeCacheRetryMax
+	} else {
+		likeCacheRetry.attempt++
+	}
+	logger.Error.Printf("[initialCache] unable to restore like counts, retry in %v: %v\n", delay, err)
+
+	if likeCacheRetry.timer != nil {
+		likeCacheRetry.timer.Stop()
+	}
+	likeCacheRetry.timer = time.AfterFunc(delay, track(initialCache))
+}
+
+func stopLikeCacheRetry() {
+	likeCacheRetry.Lock()
+	defer likeCacheRetry.Unlock()
+
+	if likeCacheRetry.timer != nil {
+		likeCacheRetry.timer.Stop()
+	}
+	likeCacheRetry.attempt = 0
+}
+
+// track runs job as a running job, jobs which start after Shutdown are skipped
+func track(job func()) func() {
+	return func() {
+		running.Lock()
+		if running.stopped {
+			running.Unlock()
+			return
+		}
+		running.jobs.Add(1)
+		running.Unlock()
+		defer running.jobs.Done()
+
+		job()
+	}
 }
 
 func startCron() {
 
-	c := cron.New()
+	scheduler = cron.New()
 
-	c.AddFunc(config.EnvVariable.EventBackupInterval, func() {
+	scheduler.AddFunc(config.EnvVariable.EventBackupInterval, track(func() {
 		startTime := time.Now()
-		count, likes := service.BackupLikeCount("event", cache.EventLikes.Items(), "room", cache.RoomLikes.Items())
+		count, likes, _ := backupLikeCount(context.Background())
 		logger.Debug.Printf("[startCron] Backup %v items(event+room) %v likes, duration=%v", count, likes, time.Since(startTime))
-	})
+	}))
+
+	if err := scheduler.AddFunc(config.EnvVariable.EventLifecycleInterval, track(refreshEventLifecycle)); err != nil {
+		logger.Error.Printf("[startCron] EVENT_LIFECYCLE_INTERVAL %v error: %v\n", config.EnvVariable.EventLifecycleInterval, err)
+	}
 
-	c.Start()
+	if err := scheduler.AddFunc(config.EnvVariable.OrphanGCInterval, track(collectOrphanFiles)); err != nil {
+		logger.Error.Printf("[startCron] ORPHAN_GC_INTERVAL %v error: %v\n", config.EnvVariable.OrphanGCInterval, err)
+	}
+
+	scheduler.Start()
+}
+
+func refreshEventLifecycle() {
+	startTime := time.Now()
+	err := RefreshEventLifecycle(context.Background())
+	logger.Debug.Printf("[refreshEventLifecycle] err=%v, duration=%v", err, time.Since(startTime))
+}
+
+// backupLikeCount prevents the final flush from racing with a running cron backup.
+// Counts are written as absolute values, so nothing is written unless the like caches are warm
+func backupLikeCount(ctx context.Context) (count, likes int64, err error) {
+	backupLock.Lock()
+	defer backupLock.Unlock()
+
+	if status := GetLikeCacheStatus().Status; status != dto.LikeCacheStatusReady {
+		logger.Ctx(ctx).Warn.Printf("[backupLikeCount] like cache is %v, skip the backup\n", status)
+		return
+	}
+
+	eventItems, roomItems := cache.EventLikes.Items(), cache.RoomLikes.Items()
+	if len(eventItems) == 0 && len(roomItems) == 0 {
+		return
+	}
+
+	startTime := time.Now()
+	count, likes, err = service.BackupLikeCount(ctx, "event", eventItems, "room", roomItems)
+
+	metrics.BackupJobDuration.Observe(time.Since(startTime).Seconds())
+	metrics.BackupJobTotal.WithLabelValues(metrics.Result(err)).Inc()
+	if err == nil {
+		metrics.BackupJobLastSuccess.SetToCurrentTime()
+	}
+	return
 }
 
-func RestoreEventLikeCount() (eventCount, totalLikes int, err error) {
+func RestoreEventLikeCount(ctx context.Context) (eventCount, totalLikes int, err error) {
 
 	var offset = int64(0)
 	var pageSize = int64(100)
 
 	for {
-		accounts, filterCount, _ := service.GetAccountsLikedStuff("event", offset, pageSize)
+		accounts, filterCount, err := service.GetAccountsLikedStuff(ctx, "event", offset, pageSize)
+		if err != nil {
+			return eventCount, totalLikes, err
+		}
 		if len(accounts) > 0 {
 			for _, account := range accounts {
 				for _, event := range account.LikedEvents {
@@ -62,7 +265,7 @@ func RestoreEventLikeCount() (eventCount, totalLikes int, err error) {
 						//id does not exist, create it
 						if err = cache.EventLikes.Add(event.EventID, int64(1), goCache.NoExpiration); err != nil {
 							//already exists, should not happen
-							logger.Error.Printf("[RestoreEventLikeCount] like+1 [%+v]\n", err)
+							logger.Ctx(ctx).Error.Printf("[RestoreEventLikeCount] like+1 [%+v]\n", err)
 							continue
 						}
 						eventCount++
@@ -79,13 +282,16 @@ func RestoreEventLikeCount() (eventCount, totalLikes int, err error) {
 	return
 }
 
-func RestoreRoomLikeCount() (eventCount, totalLikes int, err error) {
+func RestoreRoomLikeCount(ctx context.Context) (eventCount, totalLikes int, err error) {
 
 	var offset = int64(0)
 	var pageSize = int64(100)
 
 	for {
-		accounts, filterCount, _ := service.GetAccountsLikedStuff("room", offset, pageSize)
+		accounts, filterCount, err := service.GetAccountsLikedStuff(ctx, "room", offset, pageSize)
+		if err != nil {
+			return eventCount, totalLikes, err
+		}
 		if len(accounts) > 0 {
 			for _, account := range accounts {
 				for _, event := range account.LikedRooms {
@@ -93,7 +299,7 @@ func RestoreRoomLikeCount() (eventCount, totalLikes int, err error) {
 						//id does not exist, create it
 						if err = cache.RoomLikes.Add(event.RoomID, int64(1), goCache.NoExpiration); err != nil {
 							//already exists, should not happen
-							logger.Error.Printf("[RestoreRoomLikeCount] like+1 [%+v]\n", err)
+							logger.Ctx(ctx).Error.Printf("[RestoreRoomLikeCount] like+1 [%+v]\n", err)
 							continue
 						}
 						eventCount++
@@ -0,0 +1,208 @@
+package jobs
+
+import (
+	"context"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
+	"hubs-cms-go/notify"
+	"hubs-cms-go/service"
+	"sync"
+	"time"
+)
+
+const lifecyclePageSize = int64(100)
+
+// eventLifecycle keeps the events about to be reminded, started or ended, and a timer to the earliest of their boundaries
+var eventLifecycle = struct {
+	sync.Mutex
+	events map[string]*dto.DirectusEventLifecycleData
+	timer  *time.Timer
+}{events: map[string]*dto.DirectusEventLifecycleData{}}
+
+// eventLifecycleRun serializes refreshes and timer runs, eventLifecycle is only locked around its state
+// so that directus and mastodon are not called with it held
+var eventLifecycleRun sync.Mutex
+
+// RefreshEventLifecycle reloads the events starting within EVENT_LIFECYCLE_LOOKAHEAD which are not marked as ended,
+// handles the boundaries already passed and waits for the next one.
+// A boundary whose handling failed is retried on the next refresh
+func RefreshEventLifecycle(ctx context.Context) error {
+	eventLifecycleRun.Lock()
+	defer eventLifecycleRun.Unlock()
+
+	var events []dto.DirectusEventLifecycleData
+	before := time.Now().Add(config.EnvVariable.EventLifecycleLookahead)
+	for offset := int64(0); ; offset += lifecyclePageSize {
+		page, filterCount, err := service.GetDirectusLifecycleEvents(ctx, before, offset, lifecyclePageSize)
+		if err != nil {
+			return err
+		}
+		events = append(events, page...)
+		if offset+lifecyclePageSize >= filterCount {
+			break
+		}
+	}
+
+	eventLifecycle.Lock()
+	eventLifecycle.events = make(map[string]*dto.DirectusEventLifecycleData, len(events))
+	for i := range events {
+		eventLifecycle.events[events[i].ID] = &events[i]
+	}
+	eventLifecycle.Unlock()
+
+	processEventLifecycle(ctx, time.Now())
+	return nil
+}
+
+// stopEventLifecycle waits for a running refresh and stops the timer
+func stopEventLifecycle() {
+	eventLifecycleRun.Lock()
+	defer eventLifecycleRun.Unlock()
+
+	eventLifecycle.Lock()
+	defer eventLifecycle.Unlock()
+
+	if eventLifecycle.timer != nil {
+		eventLifecycle.timer.Stop()
+	}
+}
+
+func fireEventLifecycle() {
+	eventLifecycleRun.Lock()
+	defer eventLifecycleRun.Unlock()
+
+	processEventLifecycle(context.Background(), time.Now())
+}
+
+// lifecycleDue tells which boundaries of the event have passed, late reminders are skipped since the event has started already
+func lifecycleDue(event dto.DirectusEventLifecycleData, now time.Time) (remind, start, end bool) {
+	remind = event.RemindedAt == nil && event.StartedAt == nil && !now.Before(event.StartTime.Add(-config.EnvVariable.EventReminderLead)) && now.Before(event.StartTime)
+	start = event.StartedAt == nil && !now.Before(event.StartTime)
+	end = event.EndedAt == nil && !now.Before(event.EndTime)
+	return
+}
+
+// processEventLifecycle reminds the likers of events starting within EVENT_REMINDER_LEAD,
+// and marks the events whose start_time or end_time has passed. The caller holds eventLifecycleRun
+func processEventLifecycle(ctx context.Context, now time.Time) {
+	// copies of the due events, the boundaries are handled without holding the lock
+	eventLifecycle.Lock()
+	due := []dto.DirectusEventLifecycleData{}
+	for _, event := range eventLifecycle.events {
+		if remind, start, end := lifecycleDue(*event, now); remind || start || end {
+			due = append(due, *event)
+		}
+	}
+	eventLifecycle.Unlock()
+
+	for _, event := range due {
+		remind, start, end := lifecycleDue(event, now)
+		patch := dto.DirectusEventLifecyclePatchRequest{}
+		actions := []string{}
+
+		// a failed reminder is retried on the next refresh, the other boundaries are marked anyway
+		if remind {
+			count, err := remindEventLikers(ctx, event)
+			logger.Ctx(ctx).Info.Printf("[processEventLifecycle] remind %v likers of event %v, err=%v\n", count, event.ID, err)
+			if err != nil {
+				metrics.EventLifecycleErrorsTotal.WithLabelValues("reminded").Inc()
+			} else {
+				patch.RemindedAt, actions = &now, append(actions, "reminded")
+			}
+		}
+		if start {
+			patch.StartedAt, actions = &now, append(actions, "started")
+		}
+		if end {
+			patch.EndedAt, actions = &now, append(actions, "ended")
+		}
+		if len(actions) == 0 {
+			continue
+		}
+
+		if err := service.PatchDirectusEvent(ctx, event.ID, patch); err != nil {
+			logger.Ctx(ctx).Error.Printf("[processEventLifecycle] mark event %v %v error: %v\n", event.ID, actions, err)
+			for _, action := range actions {
+				metrics.EventLifecycleErrorsTotal.WithLabelValues(action).Inc()
+			}
+			continue
+		}
+		for _, action := range actions {
+			metrics.EventLifecycleTotal.WithLabelValues(action).Inc()
+		}
+
+		eventLifecycle.Lock()
+		if current, found := eventLifecycle.events[event.ID]; found {
+			if patch.RemindedAt != nil {
+				current.RemindedAt = patch.RemindedAt
+			}
+			if patch.StartedAt != nil {
+				current.StartedAt = patch.StartedAt
+			}
+			if patch.EndedAt != nil {
+				delete(eventLifecycle.events, event.ID)
+			}
+		}
+		eventLifecycle.Unlock()
+	}
+
+	eventLifecycle.Lock()
+	defer eventLifecycle.Unlock()
+	armEventLifecycle(now)
+}
+
+// armEventLifecycle sets the timer to the earliest boundary after now, boundaries passed while handling the others fire at once
+func armEventLifecycle(now time.Time) {
+	var next time.Time
+	later := func(t time.Time) {
+		if t.After(now) && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	for _, event := range eventLifecycle.events {
+		if event.RemindedAt == nil && event.StartedAt == nil {
+			later(event.StartTime.Add(-config.EnvVariable.EventReminderLead))
+		}
+		if event.StartedAt == nil {
+			later(event.StartTime)
+		}
+		later(event.EndTime)
+	}
+
+	if eventLifecycle.timer != nil {
+		eventLifecycle.timer.Stop()
+	}
+	if next.IsZero() {
+		return
+	}
+	eventLifecycle.timer = time.AfterFunc(time.Until(next), fireEventLifecycle)
+}
+
+// remindEventLikers notifies the accounts which liked the event, failures of single accounts are logged and skipped
+func remindEventLikers(ctx context.Context, event dto.DirectusEventLifecycleData) (count int, err error) {
+	minutes := int(time.Until(event.StartTime).Round(time.Minute).Minutes())
+	message := config.GetEventReminderMessage(event.Title, minutes)
+
+	for offset := int64(0); ; offset += lifecyclePageSize {
+		accounts, filterCount, err := service.GetAccountsLikedEvent(ctx, event.ID, offset, lifecyclePageSize)
+		if err != nil {
+			return count, err
+		}
+
+		for _, account := range accounts {
+			err := notify.Default.Notify(ctx, notify.Notification{Key: "event-reminder:" + event.ID, Account: account, Message: message})
+			metrics.NotificationsTotal.WithLabelValues(metrics.Result(err)).Inc()
+			if err != nil {
+				logger.Ctx(ctx).Warn.Printf("[remindEventLikers] notify account %v of event %v error: %v\n", account.ID, event.ID, err)
+				continue
+			}
+			count++
+		}
+
+		if offset+lifecyclePageSize >= filterCount {
+			return count, nil
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
+	"hubs-cms-go/service"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	orphanPageSize   = int64(500)
+	orphanDeleteSize = 100
+	// orphanReportSize bounds the orphans listed in the report, the counts cover all of them
+	orphanReportSize = 100
+)
+
+// ErrOrphanCollectionRunning is returned when a collection is started while another one is running
+var ErrOrphanCollectionRunning = errors.New("orphan file collection is running")
+
+var orphanRunning int32
+
+var orphanReport = dto.OrphanReport{Status: dto.OrphanReportStatusPending, Orphans: []dto.OrphanFile{}}
+var orphanReportLock sync.RWMutex
+
+// GetOrphanReport reports the orphan file collection running or last run
+func GetOrphanReport() dto.OrphanReport {
+	orphanReportLock.RLock()
+	defer orphanReportLock.RUnlock()
+	return orphanReport
+}
+
+func setOrphanReport(report dto.OrphanReport) {
+	orphanReportLock.Lock()
+	defer orphanReportLock.Unlock()
+	orphanReport = report
+}
+
+func collectOrphanFiles() {
+	startTime := time.Now()
+	report, err := CollectOrphanFiles(context.Background(), config.EnvVariable.OrphanGCDryRun)
+	logger.Debug.Printf("[collectOrphanFiles] %v orphans %v bytes, %v deleted, err=%v, duration=%v", report.OrphanCount, report.Bytes, report.Deleted, err, time.Since(startTime))
+}
+
+// CollectOrphanFiles finds the files uploaded more than ORPHAN_GC_GRACE_PERIOD ago which no avatar, room, event,
+// video or speaker refers to, and deletes them unless dryRun. Under ORPHAN_GC_SCOPE=service only the files
+// uploaded by the directus user of this service are considered
+func CollectOrphanFiles(ctx context.Context, dryRun bool) (dto.OrphanReport, error) {
+	if !atomic.CompareAndSwapInt32(&orphanRunning, 0, 1) {
+		return GetOrphanReport(), ErrOrphanCollectionRunning
+	}
+	defer atomic.StoreInt32(&orphanRunning, 0)
+
+	startedAt := time.Now()
+	before := startedAt.Add(-config.EnvVariable.OrphanGCGracePeriod)
+	report := dto.OrphanReport{
+		Status:         dto.OrphanReportStatusRunning,
+		DryRun:         dryRun,
+		Scope:          strings.ToLower(config.EnvVariable.OrphanGCScope),
+		StartedAt:      &startedAt,
+		UploadedBefore: &before,
+		Orphans:        []dto.OrphanFile{},
+	}
+	setOrphanReport(report)
+
+	err := collectOrphanFilesBefore(ctx, before, &report)
+
+	finishedAt := time.Now()
+	report.FinishedAt = &finishedAt
+	report.Status = dto.OrphanReportStatusCompleted
+	if err != nil {
+		report.Status, report.Error = dto.OrphanReportStatusFailed, err.Error()
+	}
+	setOrphanReport(report)
+
+	metrics.OrphanJobTotal.WithLabelValues(metrics.Result(err)).Inc()
+	logger.Ctx(ctx).Info.Printf("[CollectOrphanFiles] dry_run=%v scanned=%v orphans=%v bytes=%v deleted=%v err=%v\n",
+		dryRun, report.Scanned, report.OrphanCount, report.Bytes, report.Deleted, err)
+	return report, err
+}
+
+func collectOrphanFilesBefore(ctx context.Context, before time.Time, report *dto.OrphanReport) error {
+	uploadedBy := ""
+	if report.Scope == "service" {
+		userID, err := service.GetDirectusUserID(ctx)
+		if err != nil {
+			return err
+		}
+		uploadedBy = userID
+	}
+
+	var files []dto.DirectusOrphanFile
+	for offset := int64(0); ; offset += orphanPageSize {
+		page, filterCount, err := service.GetDirectusFilesBefore(ctx, before, uploadedBy, offset, orphanPageSize)
+		if err != nil {
+			return err
+		}
+		files = append(files, page...)
+		if offset+orphanPageSize >= filterCount {
+			break
+		}
+	}
+	report.Scanned = len(files)
+	if len(files) == 0 {
+		return nil
+	}
+
+	// the references are read after the files, so a file attached meanwhile is still seen as referenced
+	referenced, err := service.GetReferencedFiles(ctx)
+	if err != nil {
+		return err
+	}
+	report.Referenced = len(referenced)
+
+	orphans := []string{}
+	for _, file := range files {
+		if referenced[file.ID] {
+			continue
+		}
+		size, _ := file.FileSize.Int64()
+		orphans = append(orphans, file.ID)
+		report.OrphanCount++
+		report.Bytes += size
+		if len(report.Orphans) < orphanReportSize {
+			report.Orphans = append(report.Orphans, dto.OrphanFile{ID: file.ID, FileName: file.FilenameDownload, FileSize: size, UploadedOn: file.UploadedOn})
+		}
+	}
+	if report.DryRun {
+		return nil
+	}
+
+	for start := 0; start < len(orphans); start += orphanDeleteSize {
+		end := start + orphanDeleteSize
+		if end > len(orphans) {
+			end = len(orphans)
+		}
+		if err := service.DeleteFiles(ctx, orphans[start:end]); err != nil {
+			return fmt.Errorf("delete %v of %v orphans: %w", end-start, len(orphans)-start, err)
+		}
+		report.Deleted += end - start
+		metrics.OrphanFilesDeletedTotal.Add(float64(end - start))
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+// Entry holds the leveled loggers bound to a request
+type Entry struct {
+	Error *Logger
+	Warn  *Logger
+	Info  *Logger
+	Debug *Logger
+}
+
+// WithRequestID stores the request id for the loggers and upstream calls made with ctx
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext returns the request id stored by WithRequestID
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Ctx returns the loggers carrying the request id and trace id of ctx
+func Ctx(ctx context.Context) *Entry {
+	fields := Fields{}
+	if requestID := RequestIDFromContext(ctx); len(requestID) > 0 {
+		fields["request_id"] = requestID
+	}
+	if ctx != nil {
+		if spanContext := trace.SpanContextFromContext(ctx); spanContext.IsValid() {
+			fields["trace_id"] = spanContext.TraceID().String()
+		}
+	}
+	if len(fields) == 0 {
+		return &Entry{Error: Error, Warn: Warn, Info: Info, Debug: Debug}
+	}
+
+	return &Entry{
+		Error: Error.With(fields),
+		Warn:  Warn.With(fields),
+		Info:  Info.With(fields),
+		Debug: Debug.With(fields),
+	}
+}
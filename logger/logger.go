@@ -1,47 +1,167 @@
 package logger
 
 import (
-	"io/ioutil"
-	"log"
+	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Level is the severity of a log line, lower levels are more verbose
+type Level int32
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	return levelNames[l]
+}
+
+// ParseLevel converts DEBUG|INFO|WARN|ERROR to a Level
+func ParseLevel(level string) (Level, error) {
+	for l, name := range levelNames {
+		if strings.ToLower(level) == name {
+			return l, nil
+		}
+	}
+	return LevelError, fmt.Errorf("unknown log level: %q", level)
+}
+
+// Fields are the structured key values attached to a log line
+type Fields map[string]interface{}
+
+// Logger writes a JSON line per log at a fixed level
+type Logger struct {
+	level  Level
+	fields Fields
+}
+
 // Info writes info logs
-var Info *log.Logger
+var Info = &Logger{level: LevelInfo}
 
 // Warn writes warn logs
-var Warn *log.Logger
+var Warn = &Logger{level: LevelWarn}
 
 // Debug writes debug logs
-var Debug *log.Logger
+var Debug = &Logger{level: LevelDebug}
 
 // Error writes error logs
-var Error *log.Logger
+var Error = &Logger{level: LevelError}
+
+var minLevel = int32(LevelError)
+
+var (
+	outputLock sync.Mutex
+	stdout     io.Writer = os.Stdout
+	stderr     io.Writer = os.Stderr
+)
 
 // Setup setup logger functions
 func Setup(logLevel string) {
+	level, err := ParseLevel(logLevel)
+	if err != nil {
+		level = LevelError
+	}
+	atomic.StoreInt32(&minLevel, int32(level))
+}
+
+// SetLevel changes the minimum level at runtime
+func SetLevel(logLevel string) error {
+	level, err := ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	atomic.StoreInt32(&minLevel, int32(level))
+	return nil
+}
 
-	logLevel = strings.ToLower(logLevel)
-	infoHandle := ioutil.Discard
-	warnHandle := ioutil.Discard
-	debugHandle := ioutil.Discard
-	errorHandle := os.Stderr
+// GetLevel returns the current minimum level
+func GetLevel() Level {
+	return Level(atomic.LoadInt32(&minLevel))
+}
+
+// SetOutput redirects logs, error logs go to errWriter
+func SetOutput(writer, errWriter io.Writer) {
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	stdout, stderr = writer, errWriter
+}
 
-	if logLevel == "info" {
-		infoHandle = os.Stdout
+// With returns a logger of the same level with extra fields
+func (l *Logger) With(fields Fields) *Logger {
+	merged := make(Fields, len(l.fields)+len(fields))
+	for k, v := range l.fields {
+		merged[k] = v
 	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return &Logger{level: l.level, fields: merged}
+}
 
-	if logLevel == "info" || logLevel == "warn" {
-		warnHandle = os.Stdout
+// Enabled reports whether logs of this level are written
+func (l *Logger) Enabled() bool {
+	return l.level >= GetLevel()
+}
+
+// Print writes a log line in the manner of fmt.Print
+func (l *Logger) Print(v ...interface{}) {
+	if l.Enabled() {
+		l.output(fmt.Sprint(v...))
 	}
+}
 
-	if logLevel == "info" || logLevel == "warn" || logLevel == "debug" {
-		debugHandle = os.Stdout
+// Printf writes a log line in the manner of fmt.Printf
+func (l *Logger) Printf(format string, v ...interface{}) {
+	if l.Enabled() {
+		l.output(fmt.Sprintf(format, v...))
 	}
+}
 
-	Info = log.New(infoHandle, "INF: ", log.Ldate|log.Lmicroseconds|log.LUTC)
-	Warn = log.New(warnHandle, "WRN: ", log.Ldate|log.Lmicroseconds|log.LUTC)
-	Debug = log.New(debugHandle, "DEB: ", log.Ldate|log.Lmicroseconds|log.LUTC)
-	Error = log.New(errorHandle, "ERR: ", log.Ldate|log.Lmicroseconds|log.LUTC)
+// Println writes a log line in the manner of fmt.Println
+func (l *Logger) Println(v ...interface{}) {
+	if l.Enabled() {
+		l.output(fmt.Sprintln(v...))
+	}
+}
+
+func (l *Logger) output(msg string) {
+	line := make(map[string]interface{}, len(l.fields)+3)
+	for k, v := range l.fields {
+		if s, ok := v.(string); ok {
+			v = RedactField(k, s)
+		}
+		line[k] = v
+	}
+	line["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	line["level"] = l.level.String()
+	line["msg"] = Redact(strings.TrimRight(msg, "\n"))
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		b, _ = json.Marshal(map[string]interface{}{"time": line["time"], "level": line["level"], "msg": line["msg"]})
+	}
+
+	outputLock.Lock()
+	defer outputLock.Unlock()
+	writer := stdout
+	if l.level == LevelError {
+		writer = stderr
+	}
+	_, _ = writer.Write(append(b, '\n'))
 }
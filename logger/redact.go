@@ -0,0 +1,39 @@
+package logger
+
+import (
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys are never written to logs, compared case-insensitively with "-" and "_" removed
+var secretKeys = []string{"password", "passcode", "authorization", "accesstoken", "refreshtoken", "token", "secret"}
+
+var (
+	// "password": "value" in JSON bodies
+	jsonSecretPattern = regexp.MustCompile(`(?i)"((?:[a-z]+[_-])*(?:password|passcode|authorization|access[_-]?token|refresh[_-]?token|token|secret))"(\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	// Password:value in structs printed by %+v, access_token=value in queries and Authorization: Bearer value in headers
+	textSecretPattern = regexp.MustCompile(`(?i)\b((?:[a-z]+[_-]?)?(?:password|passcode|authorization|access[_-]?token|refresh[_-]?token|secret))([:=]\s*)(?:bearer\s+)?[^\s&,;}\]"]+`)
+	// bearer tokens without a key
+	bearerPattern = regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`)
+)
+
+// Redact masks the secrets found in a log message
+func Redact(msg string) string {
+	msg = jsonSecretPattern.ReplaceAllString(msg, `"$1"$2"`+redacted+`"`)
+	msg = textSecretPattern.ReplaceAllString(msg, "$1$2"+redacted)
+	msg = bearerPattern.ReplaceAllString(msg, "Bearer "+redacted)
+	return msg
+}
+
+// RedactField masks the value when the key names a secret
+func RedactField(key, value string) string {
+	normalized := strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(key))
+	for _, secret := range secretKeys {
+		if strings.HasSuffix(normalized, secret) {
+			return redacted
+		}
+	}
+	return Redact(value)
+}
@@ -1,15 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"hubs-cms-go/announce"
 	"hubs-cms-go/cache"
 	"hubs-cms-go/client"
 	"hubs-cms-go/config"
+	"hubs-cms-go/handler"
 	"hubs-cms-go/jobs"
 	"hubs-cms-go/logger"
+	"hubs-cms-go/notify"
+	"hubs-cms-go/remote"
 	"hubs-cms-go/router"
+	"hubs-cms-go/service"
+	"hubs-cms-go/snapshot"
+	"hubs-cms-go/stream"
+	"hubs-cms-go/tracing"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -17,10 +29,38 @@ import (
 func Setup() {
 	config.Setup()
 	logger.Setup(config.EnvVariable.LogLevel)
+	tracing.Setup()
 	cache.Setup()
 	client.Setup()
+	remote.Setup()
 	router.Setup()
+	notify.Setup()
 	jobs.Setup()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.EnvVariable.DirectusTimeout)
+	defer cancel()
+	service.ReportDirectusPermissions(ctx)
+}
+
+// Shutdown drains the http server then stops the background jobs
+func Shutdown(s *http.Server) {
+	// let the load balancer see the failing health check before closing the listener
+	handler.SetDraining(true)
+	time.Sleep(config.EnvVariable.ShutdownDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.EnvVariable.ShutdownTimeout)
+	defer cancel()
+
+	stream.Shutdown()
+
+	if err := s.Shutdown(ctx); err != nil {
+		logger.Error.Printf("[Shutdown] http server shutdown error: %v\n", err)
+	}
+
+	announce.Wait(ctx)
+	snapshot.Wait(ctx)
+	jobs.Shutdown(ctx)
+	tracing.Shutdown(ctx)
 }
 
 // @title Package Management Service Swagger
@@ -40,8 +80,18 @@ func main() {
 		ReadTimeout:  30 * time.Minute,
 		WriteTimeout: 30 * time.Minute,
 	}
-	if err := s.ListenAndServe(); err != nil {
-		log.Panic(err)
-	}
 
+	go func() {
+		if err := s.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Panic(err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-quit
+
+	logger.Info.Printf("[main] receive signal %v, shutting down\n", sig)
+	Shutdown(s)
+	logger.Info.Printf("[main] shutdown completed\n")
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"hubs-cms-go/cache"
@@ -8,6 +9,7 @@ import (
 	"hubs-cms-go/config"
 	"hubs-cms-go/constant"
 	"hubs-cms-go/dto"
+	"hubs-cms-go/handler"
 	"hubs-cms-go/router"
 	"hubs-cms-go/service"
 	"net/http"
@@ -59,6 +61,17 @@ func TestHealth(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 }
 
+func TestHealthDraining(t *testing.T) {
+	handler.SetDraining(true)
+	defer handler.SetDraining(false)
+
+	r := router.SetupRouter()
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/health", nil)
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
+
 func TestVersion(t *testing.T) {
 	r := router.SetupRouter()
 	w := httptest.NewRecorder()
@@ -86,7 +99,7 @@ func TestGetDirectusAccessToken(t *testing.T) {
 			config.GetDirectusAccessTokenURI(),
 			getDirectusAccessTokenJsonResponder)
 
-		directusAccessToken, err := service.GetDirectusAccessToken(false)
+		directusAccessToken, err := service.GetDirectusAccessToken(context.Background(), false)
 		assert.Nil(t, err)
 		assert.Equal(t, "Bearer xyz", directusAccessToken)
 
@@ -111,7 +124,7 @@ func TestGetDirectusAccessTokenError(t *testing.T) {
 			config.GetDirectusAccessTokenURI(),
 			testErrorResponder)
 
-		emptyResult, responseErr := service.GetDirectusAccessToken(true)
+		emptyResult, responseErr := service.GetDirectusAccessToken(context.Background(), true)
 		assert.Equal(t, "", emptyResult)
 		assert.True(t, strings.Contains(responseErr.Error(), expectResult))
 	})
@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"hubs-cms-go/cache"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "hubs_cms"
+
+// HTTPRequestsTotal counts requests served by gin routes
+var HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "http_requests_total",
+	Help:      "Total number of HTTP requests by route and status.",
+}, []string{"method", "route", "status"})
+
+// HTTPRequestDuration observes the latency of gin routes
+var HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "http_request_duration_seconds",
+	Help:      "HTTP request latency by route and status.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"method", "route", "status"})
+
+// UpstreamRequestDuration observes the latency of directus and mastodon calls
+var UpstreamRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "upstream_request_duration_seconds",
+	Help:      "Upstream request latency by upstream, endpoint and status.",
+	Buckets:   prometheus.DefBuckets,
+}, []string{"upstream", "method", "endpoint", "status"})
+
+// UpstreamErrorsTotal counts upstream calls failed with network error or non-2xx status
+var UpstreamErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "upstream_errors_total",
+	Help:      "Total number of failed upstream requests by upstream and endpoint.",
+}, []string{"upstream", "method", "endpoint"})
+
+// UpstreamRetriesTotal counts retried upstream calls
+var UpstreamRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "upstream_retries_total",
+	Help:      "Total number of retried upstream requests by upstream.",
+}, []string{"upstream"})
+
+// UpstreamCircuitState is the circuit breaker state of an upstream, 0 closed, 1 half-open, 2 open
+var UpstreamCircuitState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "upstream_circuit_state",
+	Help:      "Circuit breaker state by upstream, 0 closed, 1 half-open, 2 open.",
+}, []string{"upstream"})
+
+// DirectusTokenRefreshTotal counts directus admin token refreshes
+var DirectusTokenRefreshTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "directus_token_refresh_total",
+	Help:      "Total number of directus access token refreshes by result.",
+}, []string{"result"})
+
+// ResponseCacheTotal counts directus reads served by the response cache by result
+var ResponseCacheTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "response_cache_total",
+	Help:      "Total number of cached directus reads by result, hit, stale or miss.",
+}, []string{"result"})
+
+// StreamConnections is the number of open SSE and websocket streams
+var StreamConnections = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "stream_connections",
+	Help:      "Number of open streams by transport, sse or websocket.",
+}, []string{"transport"})
+
+// StreamDroppedMessagesTotal counts messages dropped for subscribers too slow to receive them
+var StreamDroppedMessagesTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "stream_dropped_messages_total",
+	Help:      "Total number of stream messages dropped for slow subscribers.",
+})
+
+// BackupJobDuration observes the duration of like count backups
+var BackupJobDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+	Namespace: namespace,
+	Name:      "backup_job_duration_seconds",
+	Help:      "Duration of the like count backup job.",
+	Buckets:   prometheus.DefBuckets,
+})
+
+// BackupJobTotal counts like count backups by result
+var BackupJobTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "backup_job_total",
+	Help:      "Total number of like count backup runs by result.",
+}, []string{"result"})
+
+// BackupJobLastSuccess is the unix time of the last successful backup
+var BackupJobLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Name:      "backup_job_last_success_timestamp_seconds",
+	Help:      "Unix time of the last successful like count backup.",
+})
+
+// EventLifecycleTotal counts events marked by the lifecycle job by action, reminded, started or ended
+var EventLifecycleTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "event_lifecycle_total",
+	Help:      "Total number of events marked by the lifecycle job by action.",
+}, []string{"action"})
+
+// EventLifecycleErrorsTotal counts boundaries the lifecycle job failed to handle by action, they are retried on the next refresh
+var EventLifecycleErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "event_lifecycle_errors_total",
+	Help:      "Total number of event boundaries the lifecycle job failed to handle by action.",
+}, []string{"action"})
+
+// NotificationsTotal counts notifications sent to accounts by result
+var NotificationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "notifications_total",
+	Help:      "Total number of notifications sent to accounts by result.",
+}, []string{"result"})
+
+// AnnouncementsTotal counts statuses posted or edited by the announcer by collection, action and result
+var AnnouncementsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "announcements_total",
+	Help:      "Total number of statuses posted or edited by the announcer by collection, action and result.",
+}, []string{"collection", "action", "result"})
+
+// OrphanJobTotal counts orphan file collection runs by result
+var OrphanJobTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "orphan_job_total",
+	Help:      "Total number of orphan file collection runs by result.",
+}, []string{"result"})
+
+// OrphanFilesDeletedTotal counts orphan files deleted from directus
+var OrphanFilesDeletedTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "orphan_files_deleted_total",
+	Help:      "Total number of orphan files deleted from directus.",
+})
+
+// PasscodeFailuresTotal counts rejected room passcodes
+var PasscodeFailuresTotal = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "passcode_failures_total",
+	Help:      "Total number of rejected room passcode checks.",
+})
+
+var _ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Namespace:   namespace,
+	Name:        "like_cache_items",
+	Help:        "Number of items in the like cache.",
+	ConstLabels: prometheus.Labels{"type": "event"},
+}, func() float64 {
+	if cache.EventLikes == nil {
+		return 0
+	}
+	return float64(cache.EventLikes.ItemCount())
+})
+
+var _ = promauto.NewGaugeFunc(prometheus.GaugeOpts{
+	Namespace:   namespace,
+	Name:        "like_cache_items",
+	Help:        "Number of items in the like cache.",
+	ConstLabels: prometheus.Labels{"type": "room"},
+}, func() float64 {
+	if cache.RoomLikes == nil {
+		return 0
+	}
+	return float64(cache.RoomLikes.ItemCount())
+})
+
+// Result returns the label value of an operation result
+func Result(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
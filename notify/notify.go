@@ -0,0 +1,113 @@
+package notify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/service"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notification is a message to an account, Key identifies it so that a notifier can drop a repeated one
+type Notification struct {
+	Key     string
+	Account dto.DirectusAccountResponseData
+	Message string
+}
+
+// Notifier delivers notifications to accounts
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// Default is the notifier chosen by EVENT_NOTIFIER
+var Default Notifier = NoneNotifier{}
+
+// Setup creates Default from EVENT_NOTIFIER
+func Setup() {
+	switch strings.ToLower(config.EnvVariable.EventNotifier) {
+	case "mastodon":
+		Default = &MastodonNotifier{Token: config.EnvVariable.MastodonNotifierToken}
+	case "log":
+		notifier := &LogNotifier{}
+		if path := config.EnvVariable.EventNotifierFile; len(path) > 0 {
+			file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+			if err != nil {
+				logger.Error.Printf("[notify.Setup] open %v error: %v, notifications are logged instead\n", path, err)
+			} else {
+				notifier.Writer = file
+			}
+		}
+		Default = notifier
+	default:
+		Default = NoneNotifier{}
+	}
+}
+
+// NoneNotifier drops notifications
+type NoneNotifier struct{}
+
+func (NoneNotifier) Notify(ctx context.Context, notification Notification) error {
+	return nil
+}
+
+// LogNotifier writes notifications as JSON lines to Writer, or to the info log when Writer is nil
+type LogNotifier struct {
+	Writer io.Writer
+	lock   sync.Mutex
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	line, err := json.Marshal(map[string]string{
+		"time":             time.Now().UTC().Format(time.RFC3339),
+		"key":              notification.Key,
+		"account":          notification.Account.ID,
+		"mastodon_account": notification.Account.MastodonAccount,
+		"message":          notification.Message,
+	})
+	if err != nil {
+		return err
+	}
+
+	if n.Writer == nil {
+		logger.Ctx(ctx).Info.Printf("[LogNotifier] %s\n", line)
+		return nil
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	_, err = n.Writer.Write(append(line, '\n'))
+	return err
+}
+
+// MastodonNotifier sends notifications as direct statuses from the service account of Token
+type MastodonNotifier struct {
+	Token string
+}
+
+func (n *MastodonNotifier) Notify(ctx context.Context, notification Notification) error {
+	if len(notification.Account.MastodonAccount) == 0 {
+		return fmt.Errorf("[MastodonNotifier] account %v has no mastodon account", notification.Account.ID)
+	}
+
+	var idempotencyKey string
+	if len(notification.Key) > 0 {
+		sum := sha256.Sum256([]byte(notification.Key + "|" + notification.Account.ID))
+		idempotencyKey = hex.EncodeToString(sum[:])
+	}
+
+	_, err := service.PostMastodonStatus(ctx, n.Token, idempotencyKey, dto.MastodonPostStatusRequest{
+		Status:     fmt.Sprintf("@%s %s", notification.Account.MastodonAccount, notification.Message),
+		Visibility: "direct",
+	})
+	return err
+}
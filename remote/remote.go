@@ -0,0 +1,240 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hubs-cms-go/config"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const maxRedirects = 3
+
+var (
+	ErrNotAllowed  = errors.New("url is not allowed")
+	ErrTooLarge    = errors.New("content exceeds the size limit")
+	ErrContentType = errors.New("content type is not allowed")
+	ErrFetch       = errors.New("content cannot be fetched")
+)
+
+// blockedNets are the ranges a remote asset never comes from: private, loopback, link-local including
+// the cloud metadata addresses, carrier-grade NAT, documentation, benchmarking and multicast
+var blockedNets = parseCIDRs(
+	"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16", "172.16.0.0/12",
+	"192.0.0.0/24", "192.0.2.0/24", "192.168.0.0/16", "198.18.0.0/15", "198.51.100.0/24", "203.0.113.0/24",
+	"224.0.0.0/4", "240.0.0.0/4",
+	"::/128", "::1/128", "64:ff9b::/96", "100::/64", "2001:db8::/32", "fc00::/7", "fe80::/10", "ff00::/8",
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// IsAllowedIP tells whether assets may be fetched from ip, IMPORT_ALLOWED_CIDRS take precedence over the blocked ranges
+func IsAllowedIP(ip net.IP) bool {
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	for _, n := range config.ImportAllowedNets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	for _, n := range blockedNets {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL checks the scheme and host of rawURL against IMPORT_ALLOWED_SCHEMES and IMPORT_ALLOWED_HOSTS,
+// and the addresses the host resolves to with IsAllowedIP
+func CheckURL(ctx context.Context, rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() || len(u.Hostname()) == 0 {
+		return nil, fmt.Errorf("%w: %.128s is not an absolute url", ErrNotAllowed, rawURL)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("%w: credentials in url", ErrNotAllowed)
+	}
+	if !contains(config.EnvVariable.ImportAllowedSchemes, strings.ToLower(u.Scheme)) {
+		return nil, fmt.Errorf("%w: scheme %v", ErrNotAllowed, u.Scheme)
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if hosts := config.EnvVariable.ImportAllowedHosts; len(hosts) > 0 && !matchHost(hosts, host) {
+		return nil, fmt.Errorf("%w: host %v", ErrNotAllowed, host)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("%w: resolve %v: %v", ErrFetch, host, err)
+	}
+	for _, addr := range addrs {
+		if !IsAllowedIP(addr.IP) {
+			return nil, fmt.Errorf("%w: %v resolves to %v", ErrNotAllowed, host, addr.IP)
+		}
+	}
+	return u, nil
+}
+
+// matchHost matches exact hosts and wildcards such as *.example.com, which do not match example.com itself
+func matchHost(patterns []string, host string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == host || (strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(strings.TrimSpace(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// newClient returns a client whose every connection, redirects included, is checked with IsAllowedIP after DNS
+// resolution, so that a host cannot resolve to another address between CheckURL and the request
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !IsAllowedIP(ip) {
+				return fmt.Errorf("%w: connect to %v", ErrNotAllowed, host)
+			}
+			return nil
+		},
+	}
+
+	return &http.Client{
+		Timeout: config.EnvVariable.ImportTimeout,
+		Transport: &http.Transport{
+			// proxies from the environment would connect on our behalf
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: config.EnvVariable.ImportTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("%w: too many redirects", ErrNotAllowed)
+			}
+			_, err := CheckURL(req.Context(), req.URL.String())
+			return err
+		},
+	}
+}
+
+var client = newClient()
+
+// Setup rebuilds the client from IMPORT_TIMEOUT
+func Setup() {
+	client = newClient()
+}
+
+// Fetch downloads rawURL after CheckURL, bounded by IMPORT_MAX_MB and IMPORT_ALLOWED_CONTENT_TYPES.
+// It returns the content, its type and a file name taken from the url path
+func Fetch(ctx context.Context, rawURL string) (data []byte, contentType, fileName string, err error) {
+	response, err := request(ctx, http.MethodGet, rawURL)
+	if err != nil {
+		return nil, "", "", err
+	}
+	defer response.Body.Close()
+
+	maxSize := int64(config.EnvVariable.ImportMaxMB) << 20
+	if data, err = ioutil.ReadAll(io.LimitReader(response.Body, maxSize+1)); err != nil {
+		return nil, "", "", fmt.Errorf("%w: %v", ErrFetch, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, "", "", fmt.Errorf("%w: limit %d bytes", ErrTooLarge, maxSize)
+	}
+
+	fileName = path.Base(response.Request.URL.Path)
+	if fileName == "/" || fileName == "." {
+		fileName = "asset"
+	}
+	return data, mediaType(response), fileName, nil
+}
+
+// Probe checks rawURL like Fetch with a HEAD request without reading the content,
+// for imports left to directus which fetches the url again
+func Probe(ctx context.Context, rawURL string) error {
+	response, err := request(ctx, http.MethodHead, rawURL)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+// request sends the request and checks the status, the announced length and the content type of the response
+func request(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	u, err := CheckURL(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFetch, err)
+	}
+	response, err := client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrNotAllowed) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrFetch, err)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: status %v", ErrFetch, response.StatusCode)
+	}
+	if maxSize := int64(config.EnvVariable.ImportMaxMB) << 20; response.ContentLength > maxSize {
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrTooLarge, response.ContentLength, maxSize)
+	}
+	if types := config.EnvVariable.ImportAllowedContentTypes; len(types) > 0 && !contains(types, mediaType(response)) {
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: %v", ErrContentType, mediaType(response))
+	}
+	return response, nil
+}
+
+// mediaType returns the media type of the response without parameters, a missing one is application/octet-stream
+func mediaType(response *http.Response) string {
+	mediaType, _, err := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if err != nil || len(mediaType) == 0 {
+		return "application/octet-stream"
+	}
+	return mediaType
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	ginSwagger "github.com/swaggo/gin-swagger"
 	"github.com/swaggo/gin-swagger/swaggerFiles"
 )
@@ -29,10 +30,14 @@ func SetupRouter() *gin.Engine {
 	router := gin.New()
 
 	// setup middlewares
-	router.Use(gin.LoggerWithWriter(gin.DefaultWriter, "/health", "/version"))
+	router.Use(handler.RequestIDMiddleware())
+	router.Use(handler.AccessLogMiddleware("/health", "/health/live", "/health/ready", "/metrics", "/version"))
 	router.Use(gin.Recovery())
+	router.Use(handler.MetricsMiddleware())
+	router.Use(handler.TracingMiddleware("/health", "/health/live", "/health/ready", "/metrics", "/version"))
 	router.Use(handler.ErrorMiddleware())
 	router.Use(handler.CORSMiddleware())
+	router.Use(handler.CircuitBreakerMiddleware("/api/hubs-cms/v1/", "/api/hubs-cms/v1/admin/", "/api/hubs-cms/v1/hooks/"))
 
 	// setup validators
 	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
@@ -46,20 +51,39 @@ func SetupRouter() *gin.Engine {
 	// checker api
 	router.GET("/version", handler.VersionHandler)
 	router.GET("/health", handler.HealthHandler)
+	router.GET("/health/live", handler.LivenessHandler)
+	router.GET("/health/ready", handler.ReadinessHandler)
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// admin api
+	router.GET("/api/hubs-cms/v1/admin/log-level", handler.AdminTokenHandler, handler.GetLogLevel)
+	router.PUT("/api/hubs-cms/v1/admin/log-level", handler.AdminTokenHandler, handler.PutLogLevel)
+	router.GET("/api/hubs-cms/v1/admin/accounts/:accountId/usage", handler.AdminTokenHandler, handler.GetAccountUsage)
+	router.PUT("/api/hubs-cms/v1/admin/accounts/:accountId/avatar-quota", handler.AdminTokenHandler, handler.PutAccountAvatarQuota)
+	router.GET("/api/hubs-cms/v1/admin/orphan-files", handler.AdminTokenHandler, handler.GetOrphanFiles)
+	router.POST("/api/hubs-cms/v1/admin/orphan-files", handler.AdminTokenHandler, handler.PostOrphanFiles)
+
+	// hook api
+	router.POST("/api/hubs-cms/v1/hooks/directus", handler.DirectusWebhookAuthHandler, handler.DirectusWebhookHandler)
 
 	// account api
 	router.GET("/api/hubs-cms/v1/me", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.GetProfileMe)
+	router.GET("/api/hubs-cms/v1/me/usage", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.GetUsageMe)
 	router.PATCH("/api/hubs-cms/v1/accounts/:accountId", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.PatchAccount)
 
 	// avatar api
-	router.GET("/api/hubs-cms/v1/avatars", handler.GetPublicAvatars)
+	router.GET("/api/hubs-cms/v1/avatars", handler.ETagMiddleware(), handler.GetPublicAvatars)
 	router.GET("/api/hubs-cms/v1/my-avatars", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.GetMyAvatars)
 	router.POST("/api/hubs-cms/v1/avatars", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.CreateAvatar)
+	router.PATCH("/api/hubs-cms/v1/avatars/:id", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.PatchAvatar)
 	router.DELETE("/api/hubs-cms/v1/avatars/:id", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.DeleteAvatar)
+	router.POST("/api/hubs-cms/v1/avatars/:id/favorited", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.PostFavoriteAvatar)
+	router.POST("/api/hubs-cms/v1/avatars/:id/unfavorited", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.PostUnfavoriteAvatar)
+	router.GET("/api/hubs-cms/v1/my-favorite-avatars", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.GetFavoriteAvatars)
 
 	// room api
-	router.GET("/api/hubs-cms/v1/rooms/:id", handler.MastodonTokenHandler, handler.GetRoom)
-	router.GET("/api/hubs-cms/v1/rooms", handler.MastodonTokenHandler, handler.GetRoomList)
+	router.GET("/api/hubs-cms/v1/rooms/:id", handler.ETagMiddleware(), handler.MastodonTokenHandler, handler.GetRoom)
+	router.GET("/api/hubs-cms/v1/rooms", handler.ETagMiddleware(), handler.MastodonTokenHandler, handler.GetRoomList)
 	router.GET("/api/hubs-cms/v1/my-rooms", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.GetMyRooms)
 	router.POST("/api/hubs-cms/v1/rooms/:id/viewed", handler.MastodonTokenHandler, handler.RoomViewCountHandler)
 	router.POST("/api/hubs-cms/v1/rooms/:id/liked", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.PostLikeRoom)
@@ -67,11 +91,17 @@ func SetupRouter() *gin.Engine {
 	router.POST("/api/hubs-cms/v1/passcode/:hubsid", handler.CheckHubsPasscode)
 
 	// event api
-	router.GET("/api/hubs-cms/v1/events", handler.GetEvents)
-	router.GET("/api/hubs-cms/v1/events/:id", handler.MastodonTokenHandler, handler.GetEvent)
+	router.GET("/api/hubs-cms/v1/events", handler.ETagMiddleware(), handler.GetEvents)
+	router.GET("/api/hubs-cms/v1/events/:id", handler.ETagMiddleware(), handler.MastodonTokenHandler, handler.GetEvent)
 	router.POST("/api/hubs-cms/v1/events/:id/liked", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.PostLikeEvent)
 	router.POST("/api/hubs-cms/v1/events/:id/unliked", handler.MastodonTokenHandler, handler.MastodonTokenStatusHandler, handler.PostUnlikeEvent)
 	router.POST("/api/hubs-cms/v1/events/:id/viewed", handler.MastodonTokenHandler, handler.EventViewCountHandler)
+
+	// asset api
+	router.GET("/api/hubs-cms/v1/assets/:id", handler.GetAsset)
+
+	// stream api
+	router.GET("/api/hubs-cms/v1/stream", handler.MastodonTokenHandler, handler.StreamHandler)
 	if mode := gin.Mode(); mode == gin.DebugMode {
 		router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	}
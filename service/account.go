@@ -1,33 +1,32 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"hubs-cms-go/client"
 	"hubs-cms-go/config"
-	"hubs-cms-go/constant"
 	"hubs-cms-go/dto"
 	"hubs-cms-go/logger"
-	"net/http"
 
 	"github.com/go-resty/resty/v2"
 )
 
-func GetDirectusAccountData(mastodonAccount string) (ret dto.DirectusAccountResponseData, err error) {
+func GetDirectusAccountData(ctx context.Context, mastodonAccount string) (ret dto.DirectusAccountResponseData, err error) {
 	var data []dto.DirectusAccountResponseData
 
-	request := client.NewHTTPRequest().
+	request := client.NewHTTPRequest(ctx).
 		SetResult(&dto.DirectusGetResponse{Data: &data})
 	request.Method = resty.MethodGet
 	request.URL = config.GetDirectusGetAccountURI(mastodonAccount)
 
 	if _, err = directusRequestHandler(&request); err != nil {
-		logger.Error.Printf("[GetDirectusAccountData] get room data error: %v\n", err)
+		logger.Ctx(ctx).Error.Printf("[GetDirectusAccountData] get room data error: %v\n", err)
 		return
 	}
 
 	if l := len(data); l > 0 {
 		if l > 1 {
-			logger.Warn.Printf("{GetDirectusAccountData} find %v accounts\n", l)
+			logger.Ctx(ctx).Warn.Printf("{GetDirectusAccountData} find %v accounts\n", l)
 		}
 		ret = data[0]
 	}
@@ -35,43 +34,27 @@ func GetDirectusAccountData(mastodonAccount string) (ret dto.DirectusAccountResp
 	return
 }
 
-func GetDirectusAccount(mastodonAccount string, forceFetchDirectusAccessToken bool) (dto.DirectusAccount, error) {
-
-	directusAccessToken, err := GetDirectusAccessToken(forceFetchDirectusAccessToken)
-	if err != nil {
-		logger.Error.Printf("[GetDirectusAccount] unable to get directus access token error: %v\n", err)
-		return dto.DirectusAccount{}, err
-	}
+func GetDirectusAccount(ctx context.Context, mastodonAccount string) (dto.DirectusAccount, error) {
 
 	directusGetAccountResponse := dto.DirectusGetAccountResponse{}
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
-		SetResult(&directusGetAccountResponse).
-		Get(config.GetDirectusGetAccountURI(mastodonAccount))
-	if err != nil {
-		logger.Error.Printf("[GetDirectusAccount] %s error: %v\n", config.GetDirectusGetAccountURI(mastodonAccount), err)
-		return dto.DirectusAccount{}, err
-	}
-
-	if !response.IsSuccess() && !forceFetchDirectusAccessToken {
-		// Try again with new directus access token
-		return GetDirectusAccount(mastodonAccount, true)
-	}
+	request := client.NewHTTPRequest(ctx).SetResult(&directusGetAccountResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusGetAccountURI(mastodonAccount)
 
-	if !response.IsSuccess() {
-		logger.Error.Printf("[GetDirectusAccount] server response error status: %v\n", response.StatusCode())
-		return dto.DirectusAccount{}, fmt.Errorf("[GetDirectusAccount] server response error status: %v", response.StatusCode())
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetDirectusAccount] %s error: %v\n", config.GetDirectusGetAccountURI(mastodonAccount), err)
+		return dto.DirectusAccount{}, err
 	}
 
 	if len(directusGetAccountResponse.Data) == 0 {
 		// No record found, return empty result with nil error to hint the caller to create a new directus account
-		logger.Error.Printf("[GetDirectusAccount] server response payload length incorrect\n")
+		logger.Ctx(ctx).Error.Printf("[GetDirectusAccount] server response payload length incorrect\n")
 		return dto.DirectusAccount{}, nil
 	}
 
 	if !directusGetAccountResponse.Validate() {
-		logger.Error.Printf("[GetDirectusAccount] server response invalid payload: %v\n", directusGetAccountResponse)
+		logger.Ctx(ctx).Error.Printf("[GetDirectusAccount] server response invalid payload: %v\n", directusGetAccountResponse)
 		return dto.DirectusAccount{}, fmt.Errorf("[GetDirectusAccount] server response invalid payload: %v", directusGetAccountResponse)
 	}
 
@@ -81,17 +64,20 @@ func GetDirectusAccount(mastodonAccount string, forceFetchDirectusAccessToken bo
 		MastodonAvatar:  directusGetAccountResponse.Data[0].MastodonAvatar,
 		DisplayName:     directusGetAccountResponse.Data[0].DisplayName,
 		IsAdmin:         directusGetAccountResponse.Data[0].IsAdmin,
+		AvatarQuota:     directusGetAccountResponse.Data[0].AvatarQuota,
 	}
 
 	if len(directusGetAccountResponse.Data[0].ActiveAvatar.ID) > 0 {
 		directusAccount.ActiveAvatar = &dto.DirectusAvatar{
-			ID:       directusGetAccountResponse.Data[0].ActiveAvatar.ID,
-			Snapshot: config.GetDirectusGetAssetURI(directusGetAccountResponse.Data[0].ActiveAvatar.Snapshot),
-			GLB:      config.GetDirectusGetAssetURI(directusGetAccountResponse.Data[0].ActiveAvatar.GLB),
-			Owner:    directusGetAccountResponse.Data[0].ActiveAvatar.Owner,
-			Source:   directusGetAccountResponse.Data[0].ActiveAvatar.Source,
-			Title:    directusGetAccountResponse.Data[0].ActiveAvatar.Title,
-			IsPublic: directusGetAccountResponse.Data[0].ActiveAvatar.IsPublic,
+			ID:                 directusGetAccountResponse.Data[0].ActiveAvatar.ID,
+			Snapshot:           config.GetAssetURI(directusGetAccountResponse.Data[0].ActiveAvatar.Snapshot, directusGetAccountResponse.Data[0].ActiveAvatar.IsPublic),
+			SnapshotThumbnails: config.GetAssetURIs(directusGetAccountResponse.Data[0].ActiveAvatar.SnapshotThumbnails, directusGetAccountResponse.Data[0].ActiveAvatar.IsPublic),
+			GLB:                config.GetAssetURI(directusGetAccountResponse.Data[0].ActiveAvatar.GLB, directusGetAccountResponse.Data[0].ActiveAvatar.IsPublic),
+			Owner:              directusGetAccountResponse.Data[0].ActiveAvatar.Owner,
+			Source:             directusGetAccountResponse.Data[0].ActiveAvatar.Source,
+			Title:              directusGetAccountResponse.Data[0].ActiveAvatar.Title,
+			IsPublic:           directusGetAccountResponse.Data[0].ActiveAvatar.IsPublic,
+			Tags:               directusGetAccountResponse.Data[0].ActiveAvatar.Tags,
 		}
 	}
 
@@ -105,58 +91,36 @@ func GetDirectusAccount(mastodonAccount string, forceFetchDirectusAccessToken bo
 		directusAccount.LikedEvents[i] = directusGetAccountResponse.Data[0].LikedEvents[i].EventID
 	}
 
+	directusAccount.FavoriteAvatars = make([]string, len(directusGetAccountResponse.Data[0].FavoriteAvatars))
+	for i := range directusGetAccountResponse.Data[0].FavoriteAvatars {
+		directusAccount.FavoriteAvatars[i] = directusGetAccountResponse.Data[0].FavoriteAvatars[i].AvatarID
+	}
+
 	return directusAccount, nil
 }
 
-func CreateDirectusAccount(mastodonAccountInfo dto.MastodonVerifyCredentialsResponse, forceFetchDirectusAccessToken bool) (dto.DirectusAccount, error) {
-
-	directusAccessToken, err := GetDirectusAccessToken(false)
-	if err != nil {
-		logger.Error.Printf("[CreateDirectusAccount] unable to get directus access token error: %v\n", err)
-		return dto.DirectusAccount{}, err
-	}
+func CreateDirectusAccount(ctx context.Context, mastodonAccountInfo dto.MastodonVerifyCredentialsResponse) (dto.DirectusAccount, error) {
 
 	directusUpsertAccountResponse := dto.DirectusUpsertAccountResponse{}
-	directusErr := dto.DirectusErrorResponse{}
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
+	request := client.NewHTTPRequest(ctx).
 		SetBody(dto.DirectusCreateAccountRequest{
 			MastodonAccount: mastodonAccountInfo.MastodonAccount,
 			MastodonAvatar:  mastodonAccountInfo.MastodonAvatar,
 			DisplayName:     mastodonAccountInfo.DisplayName,
 			IsAdmin:         false,
 		}).
-		SetResult(&directusUpsertAccountResponse).
-		SetError(&directusErr).
-		Post(config.GetDirectusCreateAccountURI())
-	if err != nil {
-		logger.Error.Printf("[CreateDirectusAccount] request error: %v\n", err)
-		return dto.DirectusAccount{}, err
-	}
+		SetResult(&directusUpsertAccountResponse)
+	request.Method = resty.MethodPost
+	request.URL = config.GetDirectusCreateAccountURI()
 
-	if !response.IsSuccess() {
-		switch response.StatusCode() {
-		case http.StatusUnauthorized:
-			{
-				if !forceFetchDirectusAccessToken {
-					// Try again with new directus access token
-					return CreateDirectusAccount(mastodonAccountInfo, true)
-				}
-			}
-		case http.StatusBadRequest:
-			{
-				directusErr.Status = http.StatusBadRequest
-				return dto.DirectusAccount{}, &directusErr
-			}
-		}
-
-		logger.Error.Printf("[CreateDirectusAccount] server response error status: %v\n", response.StatusCode())
-		return dto.DirectusAccount{}, fmt.Errorf("[CreateDirectusAccount] server response error status: %v", response.StatusCode())
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[CreateDirectusAccount] request error: %v\n", err)
+		return dto.DirectusAccount{}, err
 	}
 
 	if !directusUpsertAccountResponse.Validate() {
-		logger.Error.Printf("[CreateDirectusAccount] server response invalid payload: %v\n", directusUpsertAccountResponse)
+		logger.Ctx(ctx).Error.Printf("[CreateDirectusAccount] server response invalid payload: %v\n", directusUpsertAccountResponse)
 		return dto.DirectusAccount{}, fmt.Errorf("[CreateDirectusAccount] server response invalid payload: %v", directusUpsertAccountResponse)
 	}
 
@@ -166,44 +130,31 @@ func CreateDirectusAccount(mastodonAccountInfo dto.MastodonVerifyCredentialsResp
 		MastodonAvatar:  directusUpsertAccountResponse.Data.MastodonAvatar,
 		DisplayName:     directusUpsertAccountResponse.Data.DisplayName,
 		IsAdmin:         directusUpsertAccountResponse.Data.IsAdmin,
+		AvatarQuota:     directusUpsertAccountResponse.Data.AvatarQuota,
 	}
 
 	return directusAccount, nil
 }
 
-func PatchDirectusAccount(accountID string, patchBody interface{}, forceFetchDirectusAccessToken bool) (dto.DirectusAccount, error) {
-
-	directusAccessToken, err := GetDirectusAccessToken(false)
-	if err != nil {
-		logger.Error.Printf("[PatchDirectusAccount] unable to get directus access token error: %v\n", err)
-		return dto.DirectusAccount{}, err
-	}
+func PatchDirectusAccount(ctx context.Context, accountID string, patchBody interface{}) (dto.DirectusAccount, error) {
 
 	directusUpsertAccountResponse := dto.DirectusUpsertAccountResponse{}
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
+	request := client.NewHTTPRequest(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetBody(patchBody).
-		SetResult(&directusUpsertAccountResponse).
-		Patch(config.GetDirectusPatchAccountURI(accountID))
-	if err != nil {
-		logger.Error.Printf("[PatchDirectusAccount] request error: %v\n", err)
-		return dto.DirectusAccount{}, err
-	}
+		SetResult(&directusUpsertAccountResponse)
+	request.Method = resty.MethodPatch
+	request.URL = config.GetDirectusPatchAccountURI(accountID)
 
-	if !response.IsSuccess() && !forceFetchDirectusAccessToken {
-		// Try again with new directus access token
-		return PatchDirectusAccount(accountID, patchBody, true)
-	}
-
-	if !response.IsSuccess() {
-		logger.Error.Printf("[PatchDirectusAccount] server response error status: %v\n", response.StatusCode())
-		return dto.DirectusAccount{}, fmt.Errorf("[PatchDirectusAccount] server response error status: %v", response.StatusCode())
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[PatchDirectusAccount] request error: %v\n", err)
+		return dto.DirectusAccount{}, err
 	}
+	InvalidateDirectusCache(ctx, "account", "")
 
 	if !directusUpsertAccountResponse.Validate() {
-		logger.Error.Printf("[PatchDirectusAccount] server response invalid payload: %v\n", directusUpsertAccountResponse)
+		logger.Ctx(ctx).Error.Printf("[PatchDirectusAccount] server response invalid payload: %v\n", directusUpsertAccountResponse)
 		return dto.DirectusAccount{}, fmt.Errorf("[PatchDirectusAccount] server response invalid payload: %v", directusUpsertAccountResponse)
 	}
 
@@ -213,39 +164,99 @@ func PatchDirectusAccount(accountID string, patchBody interface{}, forceFetchDir
 		MastodonAvatar:  directusUpsertAccountResponse.Data.MastodonAvatar,
 		DisplayName:     directusUpsertAccountResponse.Data.DisplayName,
 		IsAdmin:         directusUpsertAccountResponse.Data.IsAdmin,
+		AvatarQuota:     directusUpsertAccountResponse.Data.AvatarQuota,
 	}
 
 	if len(directusUpsertAccountResponse.Data.ActiveAvatar.ID) > 0 {
 		directusAccount.ActiveAvatar = &dto.DirectusAvatar{
-			ID:       directusUpsertAccountResponse.Data.ActiveAvatar.ID,
-			Snapshot: config.GetDirectusGetAssetURI(directusUpsertAccountResponse.Data.ActiveAvatar.Snapshot),
-			GLB:      config.GetDirectusGetAssetURI(directusUpsertAccountResponse.Data.ActiveAvatar.GLB),
-			Owner:    directusUpsertAccountResponse.Data.ActiveAvatar.Owner,
-			Source:   directusUpsertAccountResponse.Data.ActiveAvatar.Source,
-			Title:    directusUpsertAccountResponse.Data.ActiveAvatar.Title,
-			IsPublic: directusUpsertAccountResponse.Data.ActiveAvatar.IsPublic,
+			ID:                 directusUpsertAccountResponse.Data.ActiveAvatar.ID,
+			Snapshot:           config.GetAssetURI(directusUpsertAccountResponse.Data.ActiveAvatar.Snapshot, directusUpsertAccountResponse.Data.ActiveAvatar.IsPublic),
+			SnapshotThumbnails: config.GetAssetURIs(directusUpsertAccountResponse.Data.ActiveAvatar.SnapshotThumbnails, directusUpsertAccountResponse.Data.ActiveAvatar.IsPublic),
+			GLB:                config.GetAssetURI(directusUpsertAccountResponse.Data.ActiveAvatar.GLB, directusUpsertAccountResponse.Data.ActiveAvatar.IsPublic),
+			Owner:              directusUpsertAccountResponse.Data.ActiveAvatar.Owner,
+			Source:             directusUpsertAccountResponse.Data.ActiveAvatar.Source,
+			Title:              directusUpsertAccountResponse.Data.ActiveAvatar.Title,
+			IsPublic:           directusUpsertAccountResponse.Data.ActiveAvatar.IsPublic,
+			Tags:               directusUpsertAccountResponse.Data.ActiveAvatar.Tags,
 		}
 	}
 
 	return directusAccount, nil
 }
 
-func GetAccountsLikedStuff(Type string, offset, limit int64) (ret []dto.DirectusGetAccountLikesResponseData, filterCount int64, err error) {
+func GetAccountsLikedStuff(ctx context.Context, Type string, offset, limit int64) (ret []dto.DirectusGetAccountLikesResponseData, filterCount int64, err error) {
 
-	logger.Debug.Printf("[GetAccountsLiked%vs] offset=%v, limit=%v\n", Type, offset, limit)
+	logger.Ctx(ctx).Debug.Printf("[GetAccountsLiked%vs] offset=%v, limit=%v\n", Type, offset, limit)
 
 	directusResponse := dto.DirectusGetResponse{Data: &ret}
 
-	request := client.NewHTTPRequest().SetResult(&directusResponse)
+	request := client.NewHTTPRequest(ctx).SetResult(&directusResponse)
 	request.Method = resty.MethodGet
 
 	request.URL = config.GetDirectusGetAccountsLikedStuffURI(Type, offset, limit)
 
 	if _, err = directusRequestHandler(&request); err != nil {
-		logger.Error.Printf("[GetAccountsLiked%vs] %v\n", Type, err)
+		logger.Ctx(ctx).Error.Printf("[GetAccountsLiked%vs] %v\n", Type, err)
 	}
 
 	filterCount = directusResponse.Meta.FilterCount
 
 	return
 }
+
+// GetAccountsLikedEvent lists the accounts which liked the event
+func GetAccountsLikedEvent(ctx context.Context, eventID string, offset, limit int64) (ret []dto.DirectusAccountResponseData, filterCount int64, err error) {
+
+	directusResponse := dto.DirectusGetResponse{Data: &ret}
+
+	request := client.NewHTTPRequest(ctx).SetResult(&directusResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusGetAccountsLikedEventURI(eventID, offset, limit)
+
+	if _, err = directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetAccountsLikedEvent] %v\n", err)
+	}
+
+	filterCount = directusResponse.Meta.FilterCount
+	return
+}
+
+// ClearActiveAvatar unsets the avatar on the accounts using it, except on keepAccountID when it is not empty.
+// It returns the number of accounts changed
+func ClearActiveAvatar(ctx context.Context, avatarID, keepAccountID string) (int, error) {
+
+	accounts := []dto.DirectusAccountResponseData{}
+	request := client.NewHTTPRequest(ctx).SetResult(&dto.DirectusGetResponse{Data: &accounts})
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusAccountsByActiveAvatarURI(avatarID)
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[ClearActiveAvatar] %s error: %v\n", config.GetDirectusAccountsByActiveAvatarURI(avatarID), err)
+		return 0, err
+	}
+
+	clearRequest := dto.DirectusClearActiveAvatarRequest{Data: map[string]*string{"active_avatar": nil}}
+	for _, account := range accounts {
+		if account.ID != keepAccountID {
+			clearRequest.Keys = append(clearRequest.Keys, account.ID)
+		}
+	}
+	if len(clearRequest.Keys) == 0 {
+		return 0, nil
+	}
+
+	request = client.NewHTTPRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(clearRequest)
+	request.Method = resty.MethodPatch
+	request.URL = config.GetDirectusAccountsURI()
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[ClearActiveAvatar] %s error: %v\n", config.GetDirectusAccountsURI(), err)
+		return 0, err
+	}
+	InvalidateDirectusCache(ctx, "account", "")
+
+	logger.Ctx(ctx).Info.Printf("[ClearActiveAvatar] avatar %v cleared on %d accounts\n", avatarID, len(clearRequest.Keys))
+	return len(clearRequest.Keys), nil
+}
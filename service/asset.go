@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/logger"
+	"net/http"
+)
+
+// assetRequestHeaders are the headers of the client forwarded to directus, so that ranges and revalidation are answered by directus
+var assetRequestHeaders = []string{"Range", "If-Range", "If-None-Match", "If-Modified-Since"}
+
+// OpenDirectusAsset requests an asset from directus, transformed by the image preset unless it is empty,
+// without reading its body, the caller closes it. The response is streamed, so DIRECTUS_TIMEOUT and retries do not apply
+func OpenDirectusAsset(ctx context.Context, assetID, preset string, header http.Header) (*http.Response, error) {
+	var directusAccessToken string
+	var err error
+	for renew := false; ; renew = true {
+		if renew {
+			directusAccessToken, err = renewDirectusAccessToken(ctx, directusAccessToken)
+		} else {
+			directusAccessToken, err = GetDirectusAccessToken(ctx, false)
+		}
+		if err != nil {
+			logger.Ctx(ctx).Error.Printf("[OpenDirectusAsset] unable to get directus access token error: %v\n", err)
+			return nil, err
+		}
+
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, config.GetDirectusGetImageURI(assetID, preset), nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range assetRequestHeaders {
+			if value := header.Get(key); len(value) > 0 {
+				request.Header.Set(key, value)
+			}
+		}
+		request.Header.Set(constant.HeaderAuthorization, directusAccessToken)
+		if id := logger.RequestIDFromContext(ctx); len(id) > 0 {
+			request.Header.Set(constant.HeaderRequestID, id)
+		}
+
+		response, err := client.RestyClient.GetClient().Do(request)
+		if err != nil {
+			logger.Ctx(ctx).Error.Printf("[OpenDirectusAsset] %s error: %v\n", request.URL, err)
+			return nil, err
+		}
+
+		if response.StatusCode == http.StatusUnauthorized && !renew {
+			response.Body.Close()
+			continue
+		}
+		// 304 and 416 are answers to the forwarded headers
+		if response.StatusCode >= http.StatusBadRequest && response.StatusCode != http.StatusRequestedRangeNotSatisfiable {
+			response.Body.Close()
+			logger.Ctx(ctx).Warn.Printf("[OpenDirectusAsset] %s response status: %v\n", request.URL, response.StatusCode)
+			return nil, dto.DirectusErrorResponseFromHttpStstus(response.StatusCode)
+		}
+		return response, nil
+	}
+}
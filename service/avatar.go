@@ -1,100 +1,98 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"hubs-cms-go/client"
 	"hubs-cms-go/config"
-	"hubs-cms-go/constant"
 	"hubs-cms-go/dto"
 	"hubs-cms-go/errors"
 	"hubs-cms-go/logger"
 	"hubs-cms-go/utils"
 	"mime/multipart"
-)
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
-func GetDirectusAvatar(avatarID string, forceFetchDirectusAccessToken bool) (dto.DirectusAvatar, error) {
+	"github.com/go-resty/resty/v2"
+)
 
-	directusAccessToken, err := GetDirectusAccessToken(forceFetchDirectusAccessToken)
-	if err != nil {
-		logger.Error.Printf("[GetDirectusAvatar] unable to get directus access token error: %v\n", err)
-		return dto.DirectusAvatar{}, err
-	}
+func GetDirectusAvatar(ctx context.Context, avatarID string) (dto.DirectusAvatar, error) {
 
 	directusGetAvatarResponse := dto.DirectusGetAvatarResponse{}
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
-		SetResult(&directusGetAvatarResponse).
-		Get(config.GetDirectusGetAvatarURI(avatarID))
-	if err != nil {
-		logger.Error.Printf("[GetDirectusAvatar] %s error: %v\n", config.GetDirectusGetAvatarURI(avatarID), err)
-		return dto.DirectusAvatar{}, err
-	}
+	request := client.NewHTTPRequest(ctx).SetResult(&directusGetAvatarResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusGetAvatarURI(avatarID)
 
-	if !response.IsSuccess() && !forceFetchDirectusAccessToken {
-		// Try again with new directus access token
-		return GetDirectusAvatar(avatarID, true)
-	}
-
-	if !response.IsSuccess() {
-		logger.Error.Printf("[GetDirectusAvatar] server response error status: %v", response.StatusCode())
-		return dto.DirectusAvatar{}, fmt.Errorf("[GetDirectusAvatar] server response error status: %v", response.StatusCode())
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetDirectusAvatar] %s error: %v\n", config.GetDirectusGetAvatarURI(avatarID), err)
+		return dto.DirectusAvatar{}, err
 	}
 
 	if !directusGetAvatarResponse.Validate() {
-		logger.Error.Printf("[GetDirectusAvatar] server response invalid payload: %v", directusGetAvatarResponse)
+		logger.Ctx(ctx).Error.Printf("[GetDirectusAvatar] server response invalid payload: %v", directusGetAvatarResponse)
 		return dto.DirectusAvatar{}, fmt.Errorf("[GetDirectusAvatar] server response invalid payload: %v", directusGetAvatarResponse)
 	}
 
 	directusAvatar := dto.DirectusAvatar{
-		ID:       directusGetAvatarResponse.Data.ID,
-		Snapshot: config.GetDirectusGetAssetURI(directusGetAvatarResponse.Data.Snapshot),
-		GLB:      config.GetDirectusGetAssetURI(directusGetAvatarResponse.Data.GLB),
-		Owner:    directusGetAvatarResponse.Data.Owner,
-		Source:   directusGetAvatarResponse.Data.Source,
-		Title:    directusGetAvatarResponse.Data.Title,
-		IsPublic: directusGetAvatarResponse.Data.IsPublic,
+		ID:                 directusGetAvatarResponse.Data.ID,
+		Snapshot:           config.GetAssetURI(directusGetAvatarResponse.Data.Snapshot, directusGetAvatarResponse.Data.IsPublic),
+		SnapshotThumbnails: config.GetAssetURIs(directusGetAvatarResponse.Data.SnapshotThumbnails, directusGetAvatarResponse.Data.IsPublic),
+		GLB:                config.GetAssetURI(directusGetAvatarResponse.Data.GLB, directusGetAvatarResponse.Data.IsPublic),
+		Owner:              directusGetAvatarResponse.Data.Owner,
+		Source:             directusGetAvatarResponse.Data.Source,
+		Title:              directusGetAvatarResponse.Data.Title,
+		IsPublic:           directusGetAvatarResponse.Data.IsPublic,
+		Tags:               directusGetAvatarResponse.Data.Tags,
 	}
 
 	return directusAvatar, nil
 }
 
-func GetPublicAvatars(start int64, limit int64, forceFetchDirectusAccessToken bool) (dto.GetAvatarsResponse, errors.ErrorInfo) {
+func GetPublicAvatars(ctx context.Context, start int64, limit int64) (dto.GetAvatarsResponse, errors.ErrorInfo) {
 
-	directusAccessToken, err := GetDirectusAccessToken(forceFetchDirectusAccessToken)
-	if err != nil {
-		logger.Error.Printf("[GetPublicAvatars] unable to get directus access token error: %v\n", err)
+	directusGetAvatarsResponse := dto.DirectusGetAvatarsResponse{}
+
+	if err := cachedDirectusGet(ctx, config.GetDirectusGetPublicAvatarURI(start, limit), &directusGetAvatarsResponse); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetPublicAvatars] %s error: %v\n", config.GetDirectusGetPublicAvatarURI(start, limit), err)
 		return dto.GetAvatarsResponse{}, errors.InternalError
 	}
 
-	directusGetAvatarsResponse := dto.DirectusGetAvatarsResponse{}
-
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
-		SetResult(&directusGetAvatarsResponse).
-		Get(config.GetDirectusGetPublicAvatarURI(start, limit))
-	if err != nil {
-		logger.Error.Printf("[GetPublicAvatars] %s error: %v\n", config.GetDirectusGetPublicAvatarURI(start, limit), err)
+	if !directusGetAvatarsResponse.Validate() {
+		logger.Ctx(ctx).Error.Printf("[GetPublicAvatars] server response invalid payload: %v", directusGetAvatarsResponse)
 		return dto.GetAvatarsResponse{}, errors.InternalError
 	}
 
-	if !response.IsSuccess() && !forceFetchDirectusAccessToken {
-		// Try again with new directus access token
-		return GetPublicAvatars(start, limit, true)
+	if directusGetAvatarsResponse.Meta.FilterCount == 0 { // no content
+		return dto.GetAvatarsResponse{}, errors.ErrorInfo{}
 	}
 
-	if !response.IsSuccess() {
-		logger.Error.Printf("[GetPublicAvatars] server response error status: %v", response.StatusCode())
-		return dto.GetAvatarsResponse{}, errors.InternalError
+	if directusGetAvatarsResponse.Meta.FilterCount > 0 && start >= directusGetAvatarsResponse.Meta.FilterCount {
+		return dto.GetAvatarsResponse{}, errors.AvatarsInvalidStart
 	}
 
-	if !directusGetAvatarsResponse.Validate() {
-		logger.Error.Printf("[GetPublicAvatars] server response invalid payload: %v", directusGetAvatarsResponse)
+	return parseDirectusGetAvatarsResponse(directusGetAvatarsResponse, start, limit), errors.ErrorInfo{}
+}
+
+func GetMyAvatars(ctx context.Context, accountID string, start int64, limit int64) (dto.GetAvatarsResponse, errors.ErrorInfo) {
+
+	directusGetAvatarsResponse := dto.DirectusGetAvatarsResponse{}
+
+	request := client.NewHTTPRequest(ctx).SetResult(&directusGetAvatarsResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusGetMyAvatarURI(accountID, start, limit)
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetMyAvatars] %s error: %v\n", config.GetDirectusGetMyAvatarURI(accountID, start, limit), err)
 		return dto.GetAvatarsResponse{}, errors.InternalError
 	}
 
-	if directusGetAvatarsResponse.Meta.FilterCount == 0 { // no content
-		return dto.GetAvatarsResponse{}, errors.ErrorInfo{}
+	if !directusGetAvatarsResponse.Validate() {
+		logger.Ctx(ctx).Error.Printf("[GetMyAvatars] server response invalid payload: %v", directusGetAvatarsResponse)
+		return dto.GetAvatarsResponse{}, errors.InternalError
 	}
 
 	if directusGetAvatarsResponse.Meta.FilterCount > 0 && start >= directusGetAvatarsResponse.Meta.FilterCount {
@@ -104,37 +102,118 @@ func GetPublicAvatars(start int64, limit int64, forceFetchDirectusAccessToken bo
 	return parseDirectusGetAvatarsResponse(directusGetAvatarsResponse, start, limit), errors.ErrorInfo{}
 }
 
-func GetMyAvatars(accountID string, start int64, limit int64, forceFetchDirectusAccessToken bool) (dto.GetAvatarsResponse, errors.ErrorInfo) {
+// SearchPublicAvatars lists the public avatars matching the query and the normalized tags. Avatars sorted by popularity
+// are sorted by the number of accounts using them, then by title, before they are paged. Only the first
+// AVATAR_POPULAR_LIMIT avatars by title are read and ranked
+func SearchPublicAvatars(ctx context.Context, query dto.AvatarQuery, tags []string, start int64, limit int64) (dto.GetAvatarsResponse, errors.ErrorInfo) {
 
-	directusAccessToken, err := GetDirectusAccessToken(forceFetchDirectusAccessToken)
-	if err != nil {
-		logger.Error.Printf("[GetMyAvatars] unable to get directus access token error: %v\n", err)
-		return dto.GetAvatarsResponse{}, errors.InternalError
+	popular := query.Sort == "popular"
+	uri := config.GetDirectusSearchPublicAvatarURI(tags, query.Source, query.Search, query.Sort, start, limit)
+	if popular {
+		uri = config.GetDirectusSearchPublicAvatarURI(tags, query.Source, query.Search, "title", 0, config.EnvVariable.AvatarPopularLimit)
 	}
 
 	directusGetAvatarsResponse := dto.DirectusGetAvatarsResponse{}
+	if err := cachedDirectusGet(ctx, uri, &directusGetAvatarsResponse); err != nil {
+		logger.Ctx(ctx).Error.Printf("[SearchPublicAvatars] %s error: %v\n", uri, err)
+		return dto.GetAvatarsResponse{}, errors.InternalError
+	}
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
-		SetResult(&directusGetAvatarsResponse).
-		Get(config.GetDirectusGetMyAvatarURI(accountID, start, limit))
-	if err != nil {
-		logger.Error.Printf("[GetMyAvatars] %s error: %v\n", config.GetDirectusGetMyAvatarURI(accountID, start, limit), err)
+	if !directusGetAvatarsResponse.Validate() {
+		logger.Ctx(ctx).Error.Printf("[SearchPublicAvatars] server response invalid payload: %v", directusGetAvatarsResponse)
 		return dto.GetAvatarsResponse{}, errors.InternalError
 	}
 
-	if !response.IsSuccess() && !forceFetchDirectusAccessToken {
-		// Try again with new directus access token
-		return GetMyAvatars(accountID, start, limit, true)
+	var popularity map[string]int64
+	if popular {
+		var err error
+		if popularity, err = GetAvatarPopularity(ctx); err != nil {
+			return dto.GetAvatarsResponse{}, errors.InternalError
+		}
+		avatars := directusGetAvatarsResponse.Data
+		sort.SliceStable(avatars, func(i, j int) bool {
+			return popularity[avatars[i].ID] > popularity[avatars[j].ID]
+		})
+		directusGetAvatarsResponse.Meta.FilterCount = int64(len(avatars))
+	}
+
+	total := directusGetAvatarsResponse.Meta.FilterCount
+	if total > 0 && start >= total {
+		return dto.GetAvatarsResponse{}, errors.AvatarsInvalidStart
+	}
+
+	if popular {
+		end := total
+		if limit > 0 {
+			end = utils.Min(total, start+limit)
+		}
+		directusGetAvatarsResponse.Data = directusGetAvatarsResponse.Data[utils.Min(start, total):end]
+	}
+
+	result := parseDirectusGetAvatarsResponse(directusGetAvatarsResponse, start, limit)
+	if popular {
+		for i := range result.Results {
+			count := popularity[result.Results[i].ID]
+			result.Results[i].Popularity = &count
+		}
+	}
+
+	values := url.Values{}
+	if len(tags) > 0 {
+		values.Set("tags", strings.Join(tags, ","))
 	}
+	for key, value := range map[string]string{"source": query.Source, "search": query.Search, "sort": query.Sort} {
+		if len(value) > 0 {
+			values.Set(key, value)
+		}
+	}
+	result.Pages = avatarPages("/api/hubs-cms/v1/avatars", values, total, start, limit)
+
+	return result, errors.ErrorInfo{}
+}
 
-	if !response.IsSuccess() {
-		logger.Error.Printf("[GetMyAvatars] server response error status: %v", response.StatusCode())
+// GetAvatarPopularity counts the accounts using each avatar as active_avatar
+func GetAvatarPopularity(ctx context.Context) (map[string]int64, error) {
+
+	directusGetAvatarPopularityResponse := dto.DirectusGetAvatarPopularityResponse{}
+	if err := cachedDirectusGet(ctx, config.GetDirectusAvatarPopularityURI(), &directusGetAvatarPopularityResponse); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetAvatarPopularity] %s error: %v\n", config.GetDirectusAvatarPopularityURI(), err)
+		return nil, err
+	}
+
+	popularity := make(map[string]int64, len(directusGetAvatarPopularityResponse.Data))
+	for _, group := range directusGetAvatarPopularityResponse.Data {
+		count, err := group.Count.ID.Int64()
+		if err != nil {
+			logger.Ctx(ctx).Warn.Printf("[GetAvatarPopularity] avatar %v count %v error: %v\n", group.ActiveAvatar, group.Count.ID, err)
+			continue
+		}
+		popularity[group.ActiveAvatar] = count
+	}
+
+	return popularity, nil
+}
+
+// GetFavoriteAvatars lists the favorite avatars of the account which are still public or owned by it
+func GetFavoriteAvatars(ctx context.Context, accountID string, avatarIDs []string, start int64, limit int64) (dto.GetAvatarsResponse, errors.ErrorInfo) {
+
+	if len(avatarIDs) == 0 {
+		return dto.GetAvatarsResponse{Results: []dto.DirectusAvatar{}}, errors.ErrorInfo{}
+	}
+
+	directusGetAvatarsResponse := dto.DirectusGetAvatarsResponse{}
+
+	request := client.NewHTTPRequest(ctx).SetResult(&directusGetAvatarsResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusGetFavoriteAvatarURI(accountID, avatarIDs, start, limit)
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetFavoriteAvatars] %s error: %v\n", request.URL, err)
 		return dto.GetAvatarsResponse{}, errors.InternalError
 	}
 
 	if !directusGetAvatarsResponse.Validate() {
-		logger.Error.Printf("[GetMyAvatars] server response invalid payload: %v", directusGetAvatarsResponse)
+		logger.Ctx(ctx).Error.Printf("[GetFavoriteAvatars] server response invalid payload: %v", directusGetAvatarsResponse)
 		return dto.GetAvatarsResponse{}, errors.InternalError
 	}
 
@@ -142,156 +221,227 @@ func GetMyAvatars(accountID string, start int64, limit int64, forceFetchDirectus
 		return dto.GetAvatarsResponse{}, errors.AvatarsInvalidStart
 	}
 
-	return parseDirectusGetAvatarsResponse(directusGetAvatarsResponse, start, limit), errors.ErrorInfo{}
+	result := parseDirectusGetAvatarsResponse(directusGetAvatarsResponse, start, limit)
+	result.Pages = avatarPages("/api/hubs-cms/v1/my-favorite-avatars", url.Values{}, directusGetAvatarsResponse.Meta.FilterCount, start, limit)
+
+	return result, errors.ErrorInfo{}
 }
 
-func UploadAsset(asset *multipart.FileHeader, forceFetchDirectusAccessToken bool) (string, error) {
+// avatarPages links the previous and next pages of path, values holds the other parameters of the pages
+func avatarPages(path string, values url.Values, total int64, start int64, limit int64) dto.Page {
 
-	directusAccessToken, err := GetDirectusAccessToken(forceFetchDirectusAccessToken)
-	if err != nil {
-		logger.Error.Printf("[UploadAsset] unable to get directus access token error: %v\n", err)
-		return "", fmt.Errorf("[UploadAsset] unable to get directus access token error: %v", err)
+	page := dto.Page{}
+	if limit <= 0 {
+		return page
 	}
 
-	multipartFile, err := asset.Open()
-	if err != nil {
-		return "", err
+	link := func(start int64) string {
+		values.Set("start", strconv.FormatInt(start, 10))
+		values.Set("limit", strconv.FormatInt(limit, 10))
+		return path + "?" + values.Encode()
+	}
+	if start > 0 {
+		page.Prev = link(utils.Max(0, start-limit))
+	}
+	if total > start+limit {
+		page.Next = link(start + limit)
 	}
 
+	return page
+}
+
+func UploadAsset(ctx context.Context, asset *multipart.FileHeader) (string, error) {
+
 	directusUploadAssetResponse := dto.DirectusUploadAssetResponse{}
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
-		SetFileReader("unused", asset.Filename, multipartFile).
-		SetResult(&directusUploadAssetResponse).
-		Post(config.GetDirectusUploadAssetURI())
-	if err != nil {
-		logger.Error.Printf("[UploadAsset] %s error: %v\n", config.GetDirectusUploadAssetURI(), err)
-		return "", fmt.Errorf("[UploadAsset] %s error: %v", config.GetDirectusUploadAssetURI(), err)
-	}
+	request := client.NewHTTPRequest(ctx).SetResult(&directusUploadAssetResponse)
+	request.Method = resty.MethodPost
+	request.URL = config.GetDirectusUploadAssetURI()
 
-	if !response.IsSuccess() && !forceFetchDirectusAccessToken {
-		// Try again with new directus access token
-		return UploadAsset(asset, true)
+	// the file is read by every attempt, reopen it from the start each time
+	var files []multipart.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+	attachAsset := func(r *resty.Request) error {
+		multipartFile, err := asset.Open()
+		if err != nil {
+			return err
+		}
+		files = append(files, multipartFile)
+		r.SetFileReader("unused", asset.Filename, multipartFile)
+		return nil
 	}
 
-	if !response.IsSuccess() {
-		logger.Error.Printf("[UploadAsset] server response error status: %v\n", response.StatusCode())
-		return "", fmt.Errorf("[UploadAsset] server response error status: %v", response.StatusCode())
+	if _, err := directusRequestHandler(&request, attachAsset); err != nil {
+		logger.Ctx(ctx).Error.Printf("[UploadAsset] %s error: %v\n", config.GetDirectusUploadAssetURI(), err)
+		return "", fmt.Errorf("[UploadAsset] %s error: %w", config.GetDirectusUploadAssetURI(), err)
 	}
 
 	if !directusUploadAssetResponse.Validate() {
-		logger.Error.Printf("[UploadAsset] server response invalid payload: %v", directusUploadAssetResponse)
+		logger.Ctx(ctx).Error.Printf("[UploadAsset] server response invalid payload: %v", directusUploadAssetResponse)
 		return "", fmt.Errorf("[UploadAsset] server response invalid payload: %v\n", directusUploadAssetResponse)
 	}
 
 	return directusUploadAssetResponse.Data.ID, nil
 }
 
-func ImportAsset(assetURL string, forceFetchDirectusAccessToken bool) (string, error) {
+// UploadAssetData uploads content fetched by the service, e.g. a remote model checked before it is stored
+func UploadAssetData(ctx context.Context, fileName, contentType string, data []byte) (string, error) {
 
-	directusAccessToken, err := GetDirectusAccessToken(forceFetchDirectusAccessToken)
-	if err != nil {
-		logger.Error.Printf("[ImportAsset] unable to get directus access token error: %v\n", err)
-		return "", fmt.Errorf("[ImportAsset] unable to get directus access token error: %v", err)
+	directusUploadAssetResponse := dto.DirectusUploadAssetResponse{}
+
+	request := client.NewHTTPRequest(ctx).SetResult(&directusUploadAssetResponse)
+	request.Method = resty.MethodPost
+	request.URL = config.GetDirectusUploadAssetURI()
+
+	attachAsset := func(r *resty.Request) error {
+		r.SetMultipartField("unused", fileName, contentType, bytes.NewReader(data))
+		return nil
 	}
 
-	directusUploadAssetResponse := dto.DirectusUploadAssetResponse{}
+	if _, err := directusRequestHandler(&request, attachAsset); err != nil {
+		logger.Ctx(ctx).Error.Printf("[UploadAssetData] %s error: %v\n", config.GetDirectusUploadAssetURI(), err)
+		return "", fmt.Errorf("[UploadAssetData] %s error: %w", config.GetDirectusUploadAssetURI(), err)
+	}
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
-		SetBody(dto.DirectusImportAssetRequest{URL: assetURL}).
-		SetResult(&directusUploadAssetResponse).
-		Post(config.GetDirectusImportAssetURI())
-	if err != nil {
-		logger.Error.Printf("[ImportAsset] %s error: %v\n", config.GetDirectusImportAssetURI(), err)
-		return "", fmt.Errorf("[ImportAsset] %s error: %v", config.GetDirectusImportAssetURI(), err)
+	if !directusUploadAssetResponse.Validate() {
+		logger.Ctx(ctx).Error.Printf("[UploadAssetData] server response invalid payload: %v", directusUploadAssetResponse)
+		return "", fmt.Errorf("[UploadAssetData] server response invalid payload: %v\n", directusUploadAssetResponse)
+	}
+
+	return directusUploadAssetResponse.Data.ID, nil
+}
+
+// ReplaceAssetData rewrites the content of an asset in place, its id and the items referring to it are kept
+func ReplaceAssetData(ctx context.Context, assetID, fileName, contentType string, data []byte) error {
+
+	request := client.NewHTTPRequest(ctx)
+	request.Method = resty.MethodPatch
+	request.URL = config.GetDirectusFileURI(assetID)
+
+	attachAsset := func(r *resty.Request) error {
+		r.SetMultipartField("unused", fileName, contentType, bytes.NewReader(data))
+		return nil
 	}
 
-	if !response.IsSuccess() && !forceFetchDirectusAccessToken {
-		// Try again with new directus access token
-		return ImportAsset(assetURL, true)
+	if _, err := directusRequestHandler(&request, attachAsset); err != nil {
+		logger.Ctx(ctx).Error.Printf("[ReplaceAssetData] %s error: %v\n", config.GetDirectusFileURI(assetID), err)
+		return fmt.Errorf("[ReplaceAssetData] %s error: %w", config.GetDirectusFileURI(assetID), err)
 	}
 
-	if !response.IsSuccess() {
-		logger.Error.Printf("[ImportAsset] server response error status: %v\n", response.StatusCode())
-		return "", fmt.Errorf("[ImportAsset] server response error status: %v", response.StatusCode())
+	return nil
+}
+
+func ImportAsset(ctx context.Context, assetURL string) (string, error) {
+
+	directusUploadAssetResponse := dto.DirectusUploadAssetResponse{}
+
+	request := client.NewHTTPRequest(ctx).
+		SetBody(dto.DirectusImportAssetRequest{URL: assetURL}).
+		SetResult(&directusUploadAssetResponse)
+	request.Method = resty.MethodPost
+	request.URL = config.GetDirectusImportAssetURI()
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[ImportAsset] %s error: %v\n", config.GetDirectusImportAssetURI(), err)
+		return "", fmt.Errorf("[ImportAsset] %s error: %w", config.GetDirectusImportAssetURI(), err)
 	}
 
 	if !directusUploadAssetResponse.Validate() {
-		logger.Error.Printf("[ImportAsset] server response invalid payload: %v", directusUploadAssetResponse)
+		logger.Ctx(ctx).Error.Printf("[ImportAsset] server response invalid payload: %v", directusUploadAssetResponse)
 		return "", fmt.Errorf("[ImportAsset] server response invalid payload: %v\n", directusUploadAssetResponse)
 	}
 
 	return directusUploadAssetResponse.Data.ID, nil
 }
 
-func CreateAvatar(createAvatarRequest dto.DirectusCreateAvatarRequest, forceFetchDirectusAccessToken bool) (dto.DirectusAvatar, error) {
-
-	directusAccessToken, err := GetDirectusAccessToken(forceFetchDirectusAccessToken)
-	if err != nil {
-		logger.Error.Printf("[CreateAvatar] unable to get directus access token error: %v\n", err)
-		return dto.DirectusAvatar{}, fmt.Errorf("[CreateAvatar] unable to get directus access token error: %v", err)
-	}
+func CreateAvatar(ctx context.Context, createAvatarRequest dto.DirectusCreateAvatarRequest) (dto.DirectusAvatar, error) {
 
 	directusGetAvatarResponse := dto.DirectusGetAvatarResponse{}
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
+	request := client.NewHTTPRequest(ctx).
 		SetBody(createAvatarRequest).
-		SetResult(&directusGetAvatarResponse).
-		Post(config.GetDirectusCreateAvatarURI())
-	if err != nil {
-		logger.Error.Printf("[CreateAvatar] %s error: %v\n", config.GetDirectusImportAssetURI(), err)
-		return dto.DirectusAvatar{}, fmt.Errorf("[CreateAvatar] %s error: %v", config.GetDirectusImportAssetURI(), err)
-	}
+		SetResult(&directusGetAvatarResponse)
+	request.Method = resty.MethodPost
+	request.URL = config.GetDirectusCreateAvatarURI()
 
-	if !response.IsSuccess() && !forceFetchDirectusAccessToken {
-		// Try again with new directus access token
-		return CreateAvatar(createAvatarRequest, true)
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[CreateAvatar] %s error: %v\n", config.GetDirectusCreateAvatarURI(), err)
+		return dto.DirectusAvatar{}, fmt.Errorf("[CreateAvatar] %s error: %w", config.GetDirectusCreateAvatarURI(), err)
 	}
+	InvalidateDirectusCache(ctx, "avatar", "")
 
 	if !directusGetAvatarResponse.Validate() {
-		logger.Error.Printf("[CreateAvatar] server response invalid payload: %v", directusGetAvatarResponse)
+		logger.Ctx(ctx).Error.Printf("[CreateAvatar] server response invalid payload: %v", directusGetAvatarResponse)
 		return dto.DirectusAvatar{}, fmt.Errorf("[CreateAvatar] server response invalid payload: %v", directusGetAvatarResponse)
 	}
 
 	directusAvatar := dto.DirectusAvatar{
-		ID:       directusGetAvatarResponse.Data.ID,
-		Snapshot: config.GetDirectusGetAssetURI(directusGetAvatarResponse.Data.Snapshot),
-		GLB:      config.GetDirectusGetAssetURI(directusGetAvatarResponse.Data.GLB),
-		Owner:    directusGetAvatarResponse.Data.Owner,
-		Source:   directusGetAvatarResponse.Data.Source,
-		Title:    directusGetAvatarResponse.Data.Title,
-		IsPublic: directusGetAvatarResponse.Data.IsPublic,
+		ID:                 directusGetAvatarResponse.Data.ID,
+		Snapshot:           config.GetAssetURI(directusGetAvatarResponse.Data.Snapshot, directusGetAvatarResponse.Data.IsPublic),
+		SnapshotThumbnails: config.GetAssetURIs(directusGetAvatarResponse.Data.SnapshotThumbnails, directusGetAvatarResponse.Data.IsPublic),
+		GLB:                config.GetAssetURI(directusGetAvatarResponse.Data.GLB, directusGetAvatarResponse.Data.IsPublic),
+		Owner:              directusGetAvatarResponse.Data.Owner,
+		Source:             directusGetAvatarResponse.Data.Source,
+		Title:              directusGetAvatarResponse.Data.Title,
+		IsPublic:           directusGetAvatarResponse.Data.IsPublic,
+		Tags:               directusGetAvatarResponse.Data.Tags,
 	}
 
 	return directusAvatar, nil
 }
 
-func GetAvatar(avatarID string, forceFetchDirectusAccessToken bool) (dto.DirectusGetAvatarResponse, error) {
+func PatchAvatar(ctx context.Context, avatarID string, patchAvatarRequest dto.DirectusPatchAvatarRequest) (dto.DirectusAvatar, error) {
 
-	directusAccessToken, err := GetDirectusAccessToken(forceFetchDirectusAccessToken)
-	if err != nil {
-		logger.Error.Printf("[GetAvatar] unable to get directus access token error: %v\n", err)
-		return dto.DirectusGetAvatarResponse{}, fmt.Errorf("[GetAvatar] unable to get directus access token error: %v", err)
-	}
+	directusGetAvatarResponse := dto.DirectusGetAvatarResponse{}
 
-	avatarResponse := dto.DirectusGetAvatarResponse{}
+	request := client.NewHTTPRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(patchAvatarRequest).
+		SetResult(&directusGetAvatarResponse)
+	request.Method = resty.MethodPatch
+	request.URL = config.GetDirectusSingleAvatarURI(avatarID)
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
-		SetResult(&avatarResponse).
-		Get(config.GetDirectusSingleAvatarURI(avatarID))
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[PatchAvatar] %s error: %v\n", config.GetDirectusSingleAvatarURI(avatarID), err)
+		return dto.DirectusAvatar{}, fmt.Errorf("[PatchAvatar] %s error: %w", config.GetDirectusSingleAvatarURI(avatarID), err)
+	}
+	InvalidateDirectusCache(ctx, "avatar", "")
 
-	if err != nil {
-		logger.Error.Printf("[GetAvatar] %s error: %v\n", config.GetDirectusSingleAvatarURI(avatarID), err)
-		return dto.DirectusGetAvatarResponse{}, fmt.Errorf("[GetAvatar] %s error: %v", config.GetDirectusSingleAvatarURI(avatarID), err)
+	if !directusGetAvatarResponse.Validate() {
+		logger.Ctx(ctx).Error.Printf("[PatchAvatar] server response invalid payload: %v", directusGetAvatarResponse)
+		return dto.DirectusAvatar{}, fmt.Errorf("[PatchAvatar] server response invalid payload: %v", directusGetAvatarResponse)
+	}
+
+	directusAvatar := dto.DirectusAvatar{
+		ID:                 directusGetAvatarResponse.Data.ID,
+		Snapshot:           config.GetAssetURI(directusGetAvatarResponse.Data.Snapshot, directusGetAvatarResponse.Data.IsPublic),
+		SnapshotThumbnails: config.GetAssetURIs(directusGetAvatarResponse.Data.SnapshotThumbnails, directusGetAvatarResponse.Data.IsPublic),
+		GLB:                config.GetAssetURI(directusGetAvatarResponse.Data.GLB, directusGetAvatarResponse.Data.IsPublic),
+		Owner:              directusGetAvatarResponse.Data.Owner,
+		Source:             directusGetAvatarResponse.Data.Source,
+		Title:              directusGetAvatarResponse.Data.Title,
+		IsPublic:           directusGetAvatarResponse.Data.IsPublic,
+		Tags:               directusGetAvatarResponse.Data.Tags,
 	}
 
-	if !response.IsSuccess() && !forceFetchDirectusAccessToken {
-		// Try again with new directus access token
-		return GetAvatar(avatarID, true)
+	return directusAvatar, nil
+}
+
+func GetAvatar(ctx context.Context, avatarID string) (dto.DirectusGetAvatarResponse, error) {
+
+	avatarResponse := dto.DirectusGetAvatarResponse{}
+
+	request := client.NewHTTPRequest(ctx).SetResult(&avatarResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusSingleAvatarURI(avatarID)
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetAvatar] %s error: %v\n", config.GetDirectusSingleAvatarURI(avatarID), err)
+		return dto.DirectusGetAvatarResponse{}, fmt.Errorf("[GetAvatar] %s error: %w", config.GetDirectusSingleAvatarURI(avatarID), err)
 	}
 
 	if !avatarResponse.Validate() {
@@ -301,27 +451,17 @@ func GetAvatar(avatarID string, forceFetchDirectusAccessToken bool) (dto.Directu
 	return avatarResponse, nil
 }
 
-func DeleteAvatar(avatarID string, forceFetchDirectusAccessToken bool) error {
+func DeleteAvatar(ctx context.Context, avatarID string) error {
 
-	directusAccessToken, err := GetDirectusAccessToken(forceFetchDirectusAccessToken)
-	if err != nil {
-		logger.Error.Printf("[DeleteAvatar] unable to get directus access token error: %v\n", err)
-		return fmt.Errorf("[DeleteAvatar] unable to get directus access token error: %v", err)
-	}
+	request := client.NewHTTPRequest(ctx)
+	request.Method = resty.MethodDelete
+	request.URL = config.GetDirectusSingleAvatarURI(avatarID)
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
-		Delete(config.GetDirectusSingleAvatarURI(avatarID))
-
-	if err != nil {
-		logger.Error.Printf("[DeleteAvatar] %s error: %v\n", config.GetDirectusSingleAvatarURI(avatarID), err)
-		return fmt.Errorf("[DeleteAvatar] %s error: %v", config.GetDirectusSingleAvatarURI(avatarID), err)
-	}
-
-	if !response.IsSuccess() && !forceFetchDirectusAccessToken {
-		// Try again with new directus access token
-		return DeleteAvatar(avatarID, true)
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[DeleteAvatar] %s error: %v\n", config.GetDirectusSingleAvatarURI(avatarID), err)
+		return fmt.Errorf("[DeleteAvatar] %s error: %w", config.GetDirectusSingleAvatarURI(avatarID), err)
 	}
+	InvalidateDirectusCache(ctx, "avatar", "")
 
 	return nil
 }
@@ -333,13 +473,15 @@ func parseDirectusGetAvatarsResponse(origin dto.DirectusGetAvatarsResponse, star
 
 	for _, d := range origin.Data {
 		result.Results = append(result.Results, dto.DirectusAvatar{
-			ID:       d.ID,
-			Snapshot: config.GetDirectusGetAssetURI(d.Snapshot),
-			GLB:      config.GetDirectusGetAssetURI(d.GLB),
-			Owner:    d.Owner,
-			Source:   d.Source,
-			Title:    d.Title,
-			IsPublic: d.IsPublic,
+			ID:                 d.ID,
+			Snapshot:           config.GetAssetURI(d.Snapshot, d.IsPublic),
+			SnapshotThumbnails: config.GetAssetURIs(d.SnapshotThumbnails, d.IsPublic),
+			GLB:                config.GetAssetURI(d.GLB, d.IsPublic),
+			Owner:              d.Owner,
+			Source:             d.Source,
+			Title:              d.Title,
+			IsPublic:           d.IsPublic,
+			Tags:               d.Tags,
 		})
 	}
 
@@ -353,3 +495,18 @@ func parseDirectusGetAvatarsResponse(origin dto.DirectusGetAvatarsResponse, star
 
 	return result
 }
+
+// GetDirectusAsset downloads the file of a directus asset with its content type
+func GetDirectusAsset(ctx context.Context, assetID string) ([]byte, string, error) {
+
+	request := client.NewHTTPRequest(ctx)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusGetAssetURI(assetID)
+
+	response, err := directusRequestHandler(&request)
+	if err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetDirectusAsset] %s error: %v\n", request.URL, err)
+		return nil, "", fmt.Errorf("[GetDirectusAsset] %s error: %w", request.URL, err)
+	}
+	return response.Body(), response.Header().Get("Content-Type"), nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"hubs-cms-go/cache"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
+
+	"github.com/go-resty/resty/v2"
+	"golang.org/x/sync/singleflight"
+)
+
+// directusCacheGroup de-duplicates concurrent reads of the same uncached url
+var directusCacheGroup singleflight.Group
+
+type noCacheKey struct{}
+
+// NoCache makes the directus reads with the returned context skip the response cache, e.g. before a read-modify-write
+func NoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// cachedDirectusGet decodes the response of url into result from the response cache when possible.
+// A stale response is returned at once and revalidated in the background
+func cachedDirectusGet(ctx context.Context, url string, result interface{}) error {
+
+	if !cache.Responses.Enabled() || ctx.Value(noCacheKey{}) != nil {
+		request := client.NewHTTPRequest(ctx).SetResult(result)
+		request.Method = resty.MethodGet
+		request.URL = url
+		_, err := directusRequestHandler(&request)
+		return err
+	}
+
+	body, fresh, found := cache.Responses.Get(url)
+	if found {
+		if fresh {
+			metrics.ResponseCacheTotal.WithLabelValues("hit").Inc()
+		} else {
+			metrics.ResponseCacheTotal.WithLabelValues("stale").Inc()
+			directusCacheGroup.DoChan(url, func() (interface{}, error) {
+				return fetchDirectusBody(detachedContext{ctx}, url)
+			})
+		}
+		return json.Unmarshal(body, result)
+	}
+
+	metrics.ResponseCacheTotal.WithLabelValues("miss").Inc()
+	resultCh := directusCacheGroup.DoChan(url, func() (interface{}, error) {
+		return fetchDirectusBody(detachedContext{ctx}, url)
+	})
+
+	select {
+	case r := <-resultCh:
+		if r.Err != nil {
+			return r.Err
+		}
+		return json.Unmarshal(r.Val.([]byte), result)
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func fetchDirectusBody(ctx context.Context, url string) (interface{}, error) {
+	generation := cache.Responses.Generation()
+
+	request := client.NewHTTPRequest(ctx)
+	request.Method = resty.MethodGet
+	request.URL = url
+
+	response, err := directusRequestHandler(&request)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.Responses.Set(url, response.Body(), generation)
+	return response.Body(), nil
+}
+
+// InvalidateDirectusCache drops the cached responses of a collection, an empty id drops the lists and all items.
+// An id drops the item and the lists, which embed it
+func InvalidateDirectusCache(ctx context.Context, collection, id string) {
+	path := config.EnvVariable.DirectusBaseURI + "/items/" + collection
+	if len(id) > 0 {
+		logger.Ctx(ctx).Debug.Printf("[InvalidateDirectusCache] %v/%v\n", path, id)
+		cache.Responses.DeletePath(path + "/" + id)
+		cache.Responses.DeletePrefix(path + "?")
+		return
+	}
+	logger.Ctx(ctx).Debug.Printf("[InvalidateDirectusCache] %v\n", path)
+	cache.Responses.DeletePath(path)
+}
@@ -2,42 +2,37 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"hubs-cms-go/client"
 	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
 	"hubs-cms-go/logger"
 
-	"github.com/machinebox/graphql"
+	"github.com/go-resty/resty/v2"
 )
 
-//
-// It uses graphQL lib not using resty
-// , and force refresh token everytime
-//
-func SendDirectusGraphQLCmd(mutations string) (result map[string]interface{}, err error) {
+// SendDirectusGraphQLCmd runs the mutations on the graphql endpoint of directus
+func SendDirectusGraphQLCmd(ctx context.Context, mutations string) (result map[string]interface{}, err error) {
 
-	directusAccessToken := ""
-	directusAccessToken, err = GetDirectusAccessToken(true)
-	if err != nil {
-		logger.Error.Printf("[SendDirectusGraphQLCmd] unable to get directus access token error: %v\n", err)
-		return
-	}
-
-	// create graphql client
-	client := graphql.NewClient(config.GetDirectusGraphQLURI())
-	client.Log = func(msg string) {
-		logger.Debug.Printf("[SendDirectusGraphQLCmd] %+v", msg)
-	}
+	graphQLResponse := dto.DirectusGraphQLResponse{}
 
-	// make a request
-	req := graphql.NewRequest(mutations)
-	req.Header.Set("Authorization", directusAccessToken)
+	request := client.NewHTTPRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(dto.DirectusGraphQLRequest{Query: mutations}).
+		SetResult(&graphQLResponse)
+	request.Method = resty.MethodPost
+	request.URL = config.GetDirectusGraphQLURI()
 
-	// define a Context for the request
-	ctx := context.Background()
+	if _, err = directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[SendDirectusGraphQLCmd] %+v", err)
+		return
+	}
 
-	// run it and capture the response
 	// result map[string]interface{} or map[string]map[string]float64
-	if err = client.Run(ctx, req, &result); err != nil {
-		logger.Error.Printf("[SendDirectusGraphQLCmd] %+v", err)
+	result = graphQLResponse.Data
+	if len(graphQLResponse.Errors) > 0 {
+		err = fmt.Errorf("graphql: %s", graphQLResponse.Errors[0].Message)
+		logger.Ctx(ctx).Error.Printf("[SendDirectusGraphQLCmd] %+v", err)
 	}
 	return
 }
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"fmt"
 	"hubs-cms-go/cache"
 	"hubs-cms-go/client"
@@ -8,39 +9,115 @@ import (
 	"hubs-cms-go/constant"
 	"hubs-cms-go/dto"
 	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
 	"time"
+
+	"github.com/go-resty/resty/v2"
+	goCache "github.com/patrickmn/go-cache"
+	"golang.org/x/sync/singleflight"
 )
 
-func getDirectusAccessTokenFromCache() (string, error) {
-	accessToken, found := cache.Store.Get(constant.CacheKeyDirectusAccessToken)
+// directusTokenGroup de-duplicates concurrent refreshes of the admin token
+var directusTokenGroup singleflight.Group
+
+func getDirectusAccessTokenFromCache() (string, time.Time, error) {
+	accessToken, expiresAt, found := cache.Store.GetWithExpiration(constant.CacheKeyDirectusAccessToken)
 	if found {
-		return accessToken.(string), nil
+		return accessToken.(string), expiresAt, nil
 	}
-	return "", fmt.Errorf("no cached access token found")
+	return "", time.Time{}, fmt.Errorf("no cached access token found")
 }
 
 func setDirectusAccessTokenToCache(accessToken string, duration time.Duration) {
 	cache.Store.Set(constant.CacheKeyDirectusAccessToken, accessToken, duration)
 }
 
-func GetDirectusAccessToken(forceFetchFromServer bool) (string, error) {
+// GetDirectusAccessToken returns the cached admin token, a token about to expire is refreshed in the background.
+// forceFetchFromServer skips the cache, concurrent callers share a single refresh
+func GetDirectusAccessToken(ctx context.Context, forceFetchFromServer bool) (string, error) {
+
+	if config.IsDirectusStaticToken() {
+		return fmt.Sprintf("Bearer %s", config.EnvVariable.DirectusStaticToken), nil
+	}
 
 	if !forceFetchFromServer {
-		cachedAccessToken, err := getDirectusAccessTokenFromCache()
+		cachedAccessToken, expiresAt, err := getDirectusAccessTokenFromCache()
 		if err == nil {
+			if time.Until(expiresAt) < config.EnvVariable.DirectusTokenRefreshAhead {
+				directusTokenGroup.DoChan(constant.CacheKeyDirectusAccessToken, func() (interface{}, error) {
+					return refreshDirectusAccessToken(detachedContext{ctx})
+				})
+			}
 			return cachedAccessToken, nil
 		}
 	}
 
+	return waitDirectusAccessToken(ctx)
+}
+
+// renewDirectusAccessToken replaces a token rejected by directus, unless another caller already did
+func renewDirectusAccessToken(ctx context.Context, rejectedToken string) (string, error) {
+	if config.IsDirectusStaticToken() {
+		return "", fmt.Errorf("[renewDirectusAccessToken] static token is rejected")
+	}
+
+	cachedAccessToken, _, err := getDirectusAccessTokenFromCache()
+	if err == nil && cachedAccessToken != rejectedToken {
+		return cachedAccessToken, nil
+	}
+	return waitDirectusAccessToken(ctx)
+}
+
+// waitDirectusAccessToken joins the ongoing refresh or starts one, the refresh goes on when ctx is done
+func waitDirectusAccessToken(ctx context.Context) (string, error) {
+	resultCh := directusTokenGroup.DoChan(constant.CacheKeyDirectusAccessToken, func() (interface{}, error) {
+		return refreshDirectusAccessToken(detachedContext{ctx})
+	})
+
+	select {
+	case result := <-resultCh:
+		if result.Err != nil {
+			return "", result.Err
+		}
+		return result.Val.(string), nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// refreshDirectusAccessToken uses the refresh token when there is one and logs in with the admin password otherwise
+func refreshDirectusAccessToken(ctx context.Context) (bearerToken string, err error) {
+	defer func() {
+		metrics.DirectusTokenRefreshTotal.WithLabelValues(metrics.Result(err)).Inc()
+	}()
+
+	if refreshToken, found := cache.Store.Get(constant.CacheKeyDirectusRefreshToken); found {
+		bearerToken, err = fetchDirectusAccessToken(ctx, config.GetDirectusRefreshTokenURI(), dto.DirectusAuthRefreshRequest{
+			RefreshToken: refreshToken.(string),
+			Mode:         "json",
+		})
+		if err == nil {
+			return
+		}
+		logger.Ctx(ctx).Warn.Printf("[refreshDirectusAccessToken] refresh token rejected, log in again: %v\n", err)
+		cache.Store.Delete(constant.CacheKeyDirectusRefreshToken)
+	}
+
+	return fetchDirectusAccessToken(ctx, config.GetDirectusAccessTokenURI(), dto.DirectusAuthLoginRequest{
+		Email:    config.EnvVariable.DirectusAdminEmail,
+		Password: config.EnvVariable.DirectusAdminPassword,
+	})
+}
+
+func fetchDirectusAccessToken(ctx context.Context, url string, body interface{}) (string, error) {
+
 	directusAuthLoginResponse := dto.DirectusAuthLoginResponse{}
 
-	response, err := client.NewHTTPRequest().
-		SetBody(dto.DirectusAuthLoginRequest{
-			Email:    config.EnvVariable.DirectusAdminEmail,
-			Password: config.EnvVariable.DirectusAdminPassword,
-		}).
-		SetResult(&directusAuthLoginResponse).
-		Post(config.GetDirectusAccessTokenURI())
+	request := client.NewHTTPRequest(ctx).
+		SetBody(body).
+		SetResult(&directusAuthLoginResponse)
+
+	response, err := client.Execute(request, resty.MethodPost, url)
 	if err != nil {
 		return "", err
 	}
@@ -49,14 +126,32 @@ func GetDirectusAccessToken(forceFetchFromServer bool) (string, error) {
 		return "", fmt.Errorf("[GetDirectusAccessToken] server response error code: %v", response.StatusCode())
 	}
 	if !directusAuthLoginResponse.Validate() {
-		logger.Error.Printf("[GetDirectusAccessToken] token invalid\n")
-		return "", err
+		logger.Ctx(ctx).Error.Printf("[GetDirectusAccessToken] token invalid\n")
+		return "", fmt.Errorf("[GetDirectusAccessToken] token invalid")
 	}
 
 	bearerToken := fmt.Sprintf("Bearer %s", directusAuthLoginResponse.Data.AccessToken)
 
-	// Save directus access token back to cache store
+	// Save directus access and refresh tokens back to cache store
 	setDirectusAccessTokenToCache(bearerToken, time.Duration(directusAuthLoginResponse.Data.Expires)*time.Millisecond)
+	cache.Store.Set(constant.CacheKeyDirectusRefreshToken, directusAuthLoginResponse.Data.RefreshToken, goCache.NoExpiration)
 
 	return bearerToken, nil
 }
+
+// detachedContext keeps the values of its parent, e.g. the request ID, without its deadline and cancellation
+type detachedContext struct {
+	parent context.Context
+}
+
+// Detach returns a context for work outliving the request of ctx
+func Detach(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
@@ -1,103 +1,99 @@
 package service
 
 import (
+	"context"
 	"hubs-cms-go/client"
 	"hubs-cms-go/config"
-	"hubs-cms-go/constant"
 	"hubs-cms-go/dto"
 	"hubs-cms-go/errors"
 	"hubs-cms-go/logger"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 	goCache "github.com/patrickmn/go-cache"
 )
 
-func GetDirectusEvents(locale, status string, start, limit int64) (ret []dto.DirectusEventResponseData, total int64, err error) {
+func GetDirectusEvents(ctx context.Context, locale, status string, start, limit int64) (ret []dto.DirectusEventResponseData, total int64, err error) {
 	directusResponse := dto.DirectusGetResponse{Data: &ret}
-	request := client.NewHTTPRequest().SetResult(&directusResponse)
-	request.Method = resty.MethodGet
-	request.URL = config.GetDirectusGetEventsURI(locale, status, start, limit)
-
-	if _, err = directusRequestHandler(&request); err != nil {
+	if err = cachedDirectusGet(ctx, config.GetDirectusGetEventsURI(locale, status, start, limit), &directusResponse); err != nil {
 		return
 	}
 	total = directusResponse.Meta.FilterCount
 	return
 }
 
-func GetDirectusEvent(eventID, locale string) (ret dto.DirectusEventResponseData, err error) {
-	request := client.NewHTTPRequest().SetResult(&dto.DirectusGetResponse{Data: &ret})
-	request.Method = resty.MethodGet
-	request.URL = config.GetDirectusGetEventURI(eventID, locale)
-
-	if _, err = directusRequestHandler(&request); err != nil {
-		return
-	}
+func GetDirectusEvent(ctx context.Context, eventID, locale string) (ret dto.DirectusEventResponseData, err error) {
+	err = cachedDirectusGet(ctx, config.GetDirectusGetEventURI(eventID, locale), &dto.DirectusGetResponse{Data: &ret})
 	return
 }
 
-func PatchDirectusEvent(eventID string, patchBody interface{}) (err error) {
-	request := client.NewHTTPRequest().
+func PatchDirectusEvent(ctx context.Context, eventID string, patchBody interface{}) (err error) {
+	request := client.NewHTTPRequest(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetBody(patchBody)
 	request.Method = resty.MethodPatch
 	request.URL = config.GetDirectusGetEventURISimple(eventID)
 
-	_, err = directusRequestHandler(&request)
+	if _, err = directusRequestHandler(&request); err == nil {
+		InvalidateDirectusCache(ctx, "event", "")
+	}
 	return
 }
 
-func PostDirectusEventViewCount(eventInfo dto.DirectusEventResponseData, locale string, isRetried bool) (dto.DirectusEventResponseData, errors.ErrorInfo) {
+// GetDirectusLifecycleEvents lists the events not marked as ended that start before the given time
+func GetDirectusLifecycleEvents(ctx context.Context, before time.Time, offset, limit int64) (ret []dto.DirectusEventLifecycleData, filterCount int64, err error) {
 
-	ret := dto.DirectusEventResponseData{}
+	directusResponse := dto.DirectusGetResponse{Data: &ret}
 
-	directusAccessToken, tokenErr := GetDirectusAccessToken(isRetried)
-	if tokenErr != nil {
-		logger.Error.Printf("[PostDirectusEventViewCount] fail to get token. Turn on retry flag: %v\n", isRetried)
-		if !isRetried {
-			return PostDirectusEventViewCount(eventInfo, locale, true)
-		}
-		logger.Error.Printf("[PostDirectusEventViewCount] unable to get directus access token error: %v\n", tokenErr)
-		return dto.DirectusEventResponseData{}, errors.InternalError
+	request := client.NewHTTPRequest(ctx).SetResult(&directusResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusGetLifecycleEventsURI(before, offset, limit)
+
+	if _, err = directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetDirectusLifecycleEvents] %v\n", err)
 	}
 
+	filterCount = directusResponse.Meta.FilterCount
+	return
+}
+
+func PostDirectusEventViewCount(ctx context.Context, eventInfo dto.DirectusEventResponseData, locale string) (dto.DirectusEventResponseData, errors.ErrorInfo) {
+	ret := dto.DirectusEventResponseData{}
+
 	addNumber, convertNumberErr := eventInfo.ViewCount.Int64()
 	newViewCount := addNumber + 1
-
 	if convertNumberErr != nil {
-		logger.Error.Printf("[PostDirectusEventViewCount] unable convert to int64 error: %v\n", convertNumberErr)
+		logger.Ctx(ctx).Error.Printf("[PostDirectusEventViewCount] unable to convert number error: %v\n", convertNumberErr)
 		return dto.DirectusEventResponseData{}, errors.InternalError
 	}
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
+	request := client.NewHTTPRequest(ctx).
 		SetBody(dto.RoomDataIncreaseViewCountRequest{
 			ViewCount: newViewCount,
 		}).
-		SetResult(&dto.DirectusGetResponse{Data: &ret}).
-		Patch(config.GetDirectusGetEventURI(eventInfo.ID, locale))
+		SetResult(&dto.DirectusGetResponse{Data: &ret})
+	request.Method = resty.MethodPatch
+	request.URL = config.GetDirectusGetEventURI(eventInfo.ID, locale)
 
-	if err != nil {
-		logger.Error.Printf("[PostDirectusEventViewCount] request update room count error: %v\n", err)
-		return dto.DirectusEventResponseData{}, errors.InternalError
-	}
-
-	if !response.IsSuccess() {
-		logger.Error.Printf("[PostDirectusEventViewCount] fail to update view count. Turn on retry flag: %v\n", isRetried)
-		if !isRetried {
-			return PostDirectusEventViewCount(eventInfo, locale, true)
-		}
-		logger.Error.Printf("[PostDirectusEventViewCount] error: %v\n", err)
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[PostDirectusEventViewCount] request update view count error: %v\n", err)
 		return dto.DirectusEventResponseData{}, errors.InternalError
 	}
+	InvalidateDirectusCache(ctx, "event", eventInfo.ID)
 
 	return ret, errors.ErrorInfo{}
 }
 
-func BackupLikeCount(Type string, items map[string]goCache.Item, Type2 string, items2 map[string]goCache.Item) (eventCount, totalLikes int64) {
+func BackupLikeCount(ctx context.Context, Type string, items map[string]goCache.Item, Type2 string, items2 map[string]goCache.Item) (eventCount, totalLikes int64, err error) {
 
 	_, cmd := getGraphQLCmd(Type, items, Type2, items2)
-	result, _ := SendDirectusGraphQLCmd(cmd)
+	result, err := SendDirectusGraphQLCmd(ctx, cmd)
+	if len(items) > 0 {
+		InvalidateDirectusCache(ctx, Type, "")
+	}
+	if len(items2) > 0 {
+		InvalidateDirectusCache(ctx, Type2, "")
+	}
 
 	//
 	// check server response string
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/logger"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const fileReferencePageSize = int64(500)
+
+type fileReference struct {
+	collection string
+	fields     string
+}
+
+// fileReferences are the fields of the collections which refer to directus files,
+// the hls and captions of videos only when the schema has them
+func fileReferences() []fileReference {
+	videoFields := "cover_image,mp4,webm"
+	if config.EnvVariable.VideoHLSEnabled {
+		videoFields += ",hls"
+	}
+	if config.EnvVariable.VideoCaptionsEnabled {
+		videoFields += ",captions.file"
+	}
+
+	return []fileReference{
+		{"avatar", "snapshot,snapshot_thumbnails,glb"},
+		{"room", "gallery,gallery_thumbnails"},
+		{"event", "gallery,images.directus_files_id"},
+		{"video", videoFields},
+		{"event_participate", "image"},
+	}
+}
+
+// GetReferencedFiles reads the ids of the files referred to by avatars, rooms, events, videos and speakers.
+// Any failed read fails the whole, a partial set would make referenced files look orphaned
+func GetReferencedFiles(ctx context.Context) (map[string]bool, error) {
+	fileIDs := map[string]bool{}
+	for _, reference := range fileReferences() {
+		for offset := int64(0); ; offset += fileReferencePageSize {
+			items := []interface{}{}
+			directusResponse := dto.DirectusGetResponse{Data: &items}
+
+			request := client.NewHTTPRequest(ctx).SetResult(&directusResponse)
+			request.Method = resty.MethodGet
+			request.URL = config.GetDirectusFileReferencesURI(reference.collection, reference.fields, offset, fileReferencePageSize)
+
+			if _, err := directusRequestHandler(&request); err != nil {
+				logger.Ctx(ctx).Error.Printf("[GetReferencedFiles] %s error: %v\n", request.URL, err)
+				return nil, fmt.Errorf("[GetReferencedFiles] %s error: %w", request.URL, err)
+			}
+
+			for _, item := range items {
+				collectFileIDs(item, fileIDs)
+			}
+			if offset+fileReferencePageSize >= directusResponse.Meta.FilterCount {
+				break
+			}
+		}
+	}
+	delete(fileIDs, "")
+	return fileIDs, nil
+}
+
+// collectFileIDs adds the string leaves of a decoded item, only the fields referring to files are requested
+func collectFileIDs(value interface{}, fileIDs map[string]bool) {
+	switch v := value.(type) {
+	case string:
+		fileIDs[v] = true
+	case map[string]interface{}:
+		for _, field := range v {
+			collectFileIDs(field, fileIDs)
+		}
+	case []interface{}:
+		for _, element := range v {
+			collectFileIDs(element, fileIDs)
+		}
+	}
+}
+
+// GetDirectusUserID returns the id of the directus user the service logs in as
+func GetDirectusUserID(ctx context.Context) (string, error) {
+
+	directusUserIDResponse := dto.DirectusUserIDResponse{}
+	request := client.NewHTTPRequest(ctx).SetResult(&directusUserIDResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusUsersMeIDURI()
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetDirectusUserID] %s error: %v\n", request.URL, err)
+		return "", fmt.Errorf("[GetDirectusUserID] %s error: %w", request.URL, err)
+	}
+
+	if !directusUserIDResponse.Validate() {
+		return "", fmt.Errorf("[GetDirectusUserID] server response invalid payload: %v", directusUserIDResponse)
+	}
+
+	return directusUserIDResponse.Data.ID, nil
+}
+
+// GetDirectusFilesBefore lists the files uploaded before the given time, by the given user when it is not empty
+func GetDirectusFilesBefore(ctx context.Context, before time.Time, uploadedBy string, offset, limit int64) (ret []dto.DirectusOrphanFile, filterCount int64, err error) {
+
+	directusResponse := dto.DirectusGetResponse{Data: &ret}
+
+	request := client.NewHTTPRequest(ctx).SetResult(&directusResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusGetFilesBeforeURI(before, uploadedBy, offset, limit)
+
+	if _, err = directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetDirectusFilesBefore] %s error: %v\n", request.URL, err)
+		return nil, 0, fmt.Errorf("[GetDirectusFilesBefore] %s error: %w", request.URL, err)
+	}
+
+	return ret, directusResponse.Meta.FilterCount, nil
+}
+
+// DeleteFiles deletes the files and their content from directus
+func DeleteFiles(ctx context.Context, fileIDs []string) error {
+	if len(fileIDs) == 0 {
+		return nil
+	}
+
+	request := client.NewHTTPRequest(ctx).SetBody(fileIDs)
+	request.Method = resty.MethodDelete
+	request.URL = config.GetDirectusDeleteFilesURI()
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[DeleteFiles] %s %v error: %v\n", request.URL, fileIDs, err)
+		return fmt.Errorf("[DeleteFiles] %s error: %w", request.URL, err)
+	}
+
+	return nil
+}
+
+// RemoveFiles deletes files uploaded by a request which failed afterwards, even when the request is canceled.
+// A failure is only logged, the orphan file collection removes the files later
+func RemoveFiles(ctx context.Context, fileIDs ...string) {
+	unique := map[string]bool{"": true}
+	ids := []string{}
+	for _, id := range fileIDs {
+		if !unique[id] {
+			unique[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	if err := DeleteFiles(Detach(ctx), ids); err != nil {
+		logger.Ctx(ctx).Warn.Printf("[RemoveFiles] files %v are left to the orphan file collection: %v\n", ids, err)
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/logger"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// CheckDirectusAccessToken verifies directus accepts the token, a cached token is renewed when directus refuses it
+func CheckDirectusAccessToken(ctx context.Context) error {
+	if _, err := GetDirectusUserID(ctx); err != nil {
+		logger.Ctx(ctx).Error.Printf("[CheckDirectusAccessToken] %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// CheckDirectusGraphQL verifies the graphql endpoint answers a trivial query
+func CheckDirectusGraphQL(ctx context.Context) error {
+	request := client.NewHTTPRequest(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]string{"query": "{ __typename }"})
+	request.Method = resty.MethodPost
+	request.URL = config.GetDirectusGraphQLURI()
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[CheckDirectusGraphQL] %v\n", err)
+		return err
+	}
+	return nil
+}
+
+// CheckMastodon verifies the mastodon instance is reachable
+func CheckMastodon(ctx context.Context) error {
+	response, err := client.Execute(client.NewHTTPRequest(ctx), resty.MethodGet, config.GetMastodonInstanceURI())
+	if err != nil {
+		logger.Ctx(ctx).Error.Printf("[CheckMastodon] %v\n", err)
+		return err
+	}
+
+	if !response.IsSuccess() {
+		logger.Ctx(ctx).Error.Printf("[CheckMastodon] server response error status: %v\n", response.StatusCode())
+		return fmt.Errorf("[CheckMastodon] server response error status: %v", response.StatusCode())
+	}
+	return nil
+}
@@ -1,22 +1,34 @@
 package service
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"hubs-cms-go/client"
 	"hubs-cms-go/config"
 	"hubs-cms-go/constant"
 	"hubs-cms-go/dto"
 	"hubs-cms-go/logger"
+	"net/http"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	mastodonMediaPollWait    = 500 * time.Millisecond
+	mastodonMediaPollMaxWait = 8 * time.Second
 )
 
-func GetMastodonVerifyCredentials(token string) (dto.MastodonVerifyCredentialsResponse, error) {
+func GetMastodonVerifyCredentials(ctx context.Context, token string) (dto.MastodonVerifyCredentialsResponse, error) {
 
 	verifyCredentialsResponse := dto.MastodonVerifyCredentialsResponse{}
 
-	response, err := client.NewHTTPRequest().
+	request := client.NewHTTPRequest(ctx).
 		SetHeader(constant.HeaderAuthorization, token).
-		SetResult(&verifyCredentialsResponse).
-		Get(config.GetMastodonVerifyCredentialsURI())
+		SetResult(&verifyCredentialsResponse)
+
+	response, err := client.Execute(request, resty.MethodGet, config.GetMastodonVerifyCredentialsURI())
 	if err != nil {
 		return dto.MastodonVerifyCredentialsResponse{}, err
 	}
@@ -25,42 +37,145 @@ func GetMastodonVerifyCredentials(token string) (dto.MastodonVerifyCredentialsRe
 	}
 
 	if !verifyCredentialsResponse.Validate() {
-		logger.Error.Printf("[GetMastodonVerifyCredentials] %v\n", err)
+		logger.Ctx(ctx).Error.Printf("[GetMastodonVerifyCredentials] %v\n", err)
 		return dto.MastodonVerifyCredentialsResponse{}, fmt.Errorf("[GetMastodonVerifyCredentials] verifyCredentialsResponse.Validate failed")
 	}
 	return verifyCredentialsResponse, nil
 }
 
-func PatchMastodonAccount(mastodonToken string, patchRequest dto.MastodonPatchAccountRequestBody) (dto.MastodonVerifyCredentialsResponse, error) {
+func PatchMastodonAccount(ctx context.Context, mastodonToken string, patchRequest dto.MastodonPatchAccountRequestBody) (dto.MastodonVerifyCredentialsResponse, error) {
 
 	if len(mastodonToken) == 0 {
-		logger.Error.Printf("[PatchMastodonAccount] unable to get mastodon token error\n")
+		logger.Ctx(ctx).Error.Printf("[PatchMastodonAccount] unable to get mastodon token error\n")
 		return dto.MastodonVerifyCredentialsResponse{}, fmt.Errorf("[PatchMastodonAccount] unable to get mastodon token error")
 	}
 
 	verifyCredentialsResponse := dto.MastodonVerifyCredentialsResponse{}
 
-	response, err := client.NewHTTPRequest().
+	request := client.NewHTTPRequest(ctx).
 		SetHeader(constant.HeaderAuthorization, mastodonToken).
 		SetResult(&verifyCredentialsResponse).
 		SetFormData(map[string]string{
 			"display_name": patchRequest.DisplayName,
-		}).
-		Patch(config.GetMastodonUpdateCredentialsURI())
+		})
+
+	response, err := client.Execute(request, resty.MethodPatch, config.GetMastodonUpdateCredentialsURI())
 
 	if err != nil {
-		logger.Error.Printf("[PatchMastodonAccount] request error: %v\n", err)
+		logger.Ctx(ctx).Error.Printf("[PatchMastodonAccount] request error: %v\n", err)
 		return dto.MastodonVerifyCredentialsResponse{}, err
 	}
 
 	if !response.IsSuccess() {
-		logger.Error.Printf("[PatchMastodonAccount] server response error status: %v\n", response.StatusCode())
+		logger.Ctx(ctx).Error.Printf("[PatchMastodonAccount] server response error status: %v\n", response.StatusCode())
 		return dto.MastodonVerifyCredentialsResponse{}, fmt.Errorf("[PatchMastodonAccount] server response error status: %v", response.StatusCode())
 	}
 
 	if !verifyCredentialsResponse.Validate() {
-		logger.Error.Printf("[PatchMastodonAccount] %v\n", err)
+		logger.Ctx(ctx).Error.Printf("[PatchMastodonAccount] %v\n", err)
 		return dto.MastodonVerifyCredentialsResponse{}, fmt.Errorf("[PatchMastodonAccount] verifyCredentialsResponse.Validate failed")
 	}
 	return verifyCredentialsResponse, nil
 }
+
+// UpdateMastodonStatus edits a status posted with the token of a service account
+func UpdateMastodonStatus(ctx context.Context, token, statusID string, body dto.MastodonPostStatusRequest) (dto.MastodonStatusResponse, error) {
+
+	statusResponse := dto.MastodonStatusResponse{}
+
+	request := client.NewHTTPRequest(ctx).
+		SetHeader(constant.HeaderAuthorization, fmt.Sprintf("Bearer %s", token)).
+		SetBody(body).
+		SetResult(&statusResponse)
+
+	response, err := client.Execute(request, resty.MethodPut, config.GetMastodonStatusURI(statusID))
+	if err != nil {
+		logger.Ctx(ctx).Error.Printf("[UpdateMastodonStatus] request error: %v\n", err)
+		return dto.MastodonStatusResponse{}, err
+	}
+	if !response.IsSuccess() {
+		logger.Ctx(ctx).Error.Printf("[UpdateMastodonStatus] server response error status: %v\n", response.StatusCode())
+		return dto.MastodonStatusResponse{}, &MastodonStatusError{StatusCode: response.StatusCode()}
+	}
+	return statusResponse, nil
+}
+
+// MastodonStatusError is returned when mastodon rejects a status, e.g. 404 when it was deleted
+type MastodonStatusError struct {
+	StatusCode int
+}
+
+func (e *MastodonStatusError) Error() string {
+	return fmt.Sprintf("mastodon response error status: %v", e.StatusCode)
+}
+
+// UploadMastodonMedia uploads an attachment with the token of a service account and waits until mastodon has processed it
+func UploadMastodonMedia(ctx context.Context, token, fileName string, data []byte, description string) (dto.MastodonMediaResponse, error) {
+
+	mediaResponse := dto.MastodonMediaResponse{}
+
+	request := client.NewHTTPRequest(ctx).
+		SetHeader(constant.HeaderAuthorization, fmt.Sprintf("Bearer %s", token)).
+		SetFileReader("file", fileName, bytes.NewReader(data)).
+		SetFormData(map[string]string{"description": description}).
+		SetResult(&mediaResponse)
+
+	response, err := client.Execute(request, resty.MethodPost, config.GetMastodonUploadMediaURI())
+	if err != nil {
+		logger.Ctx(ctx).Error.Printf("[UploadMastodonMedia] request error: %v\n", err)
+		return dto.MastodonMediaResponse{}, err
+	}
+	if !response.IsSuccess() {
+		logger.Ctx(ctx).Error.Printf("[UploadMastodonMedia] server response error status: %v\n", response.StatusCode())
+		return dto.MastodonMediaResponse{}, fmt.Errorf("[UploadMastodonMedia] server response error status: %v", response.StatusCode())
+	}
+
+	// 202 Accepted, the attachment cannot be posted before it is processed
+	for wait := mastodonMediaPollWait; len(mediaResponse.URL) == 0; wait *= 2 {
+		if wait > mastodonMediaPollMaxWait {
+			return dto.MastodonMediaResponse{}, fmt.Errorf("[UploadMastodonMedia] media %v is not processed in time", mediaResponse.ID)
+		}
+		select {
+		case <-ctx.Done():
+			return dto.MastodonMediaResponse{}, ctx.Err()
+		case <-time.After(wait):
+		}
+
+		request = client.NewHTTPRequest(ctx).
+			SetHeader(constant.HeaderAuthorization, fmt.Sprintf("Bearer %s", token)).
+			SetResult(&mediaResponse)
+		if response, err = client.Execute(request, resty.MethodGet, config.GetMastodonMediaURI(mediaResponse.ID)); err != nil {
+			return dto.MastodonMediaResponse{}, err
+		}
+		if response.StatusCode() != http.StatusOK && response.StatusCode() != http.StatusPartialContent {
+			return dto.MastodonMediaResponse{}, fmt.Errorf("[UploadMastodonMedia] server response error status: %v", response.StatusCode())
+		}
+	}
+	return mediaResponse, nil
+}
+
+// PostMastodonStatus posts a status with the token of a service account,
+// mastodon ignores a repeated idempotencyKey for an hour so that a retried post is not sent twice
+func PostMastodonStatus(ctx context.Context, token, idempotencyKey string, body dto.MastodonPostStatusRequest) (dto.MastodonStatusResponse, error) {
+
+	statusResponse := dto.MastodonStatusResponse{}
+
+	request := client.NewHTTPRequest(ctx).
+		SetHeader(constant.HeaderAuthorization, fmt.Sprintf("Bearer %s", token)).
+		SetBody(body).
+		SetResult(&statusResponse)
+	if len(idempotencyKey) > 0 {
+		request.SetHeader("Idempotency-Key", idempotencyKey)
+	}
+
+	response, err := client.Execute(request, resty.MethodPost, config.GetMastodonStatusesURI())
+	if err != nil {
+		logger.Ctx(ctx).Error.Printf("[PostMastodonStatus] request error: %v\n", err)
+		return dto.MastodonStatusResponse{}, err
+	}
+	if !response.IsSuccess() {
+		logger.Ctx(ctx).Error.Printf("[PostMastodonStatus] server response error status: %v\n", response.StatusCode())
+		return dto.MastodonStatusResponse{}, fmt.Errorf("[PostMastodonStatus] server response error status: %v", response.StatusCode())
+	}
+	return statusResponse, nil
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/logger"
+	"sort"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// requiredDirectusPermissions lists the grants this service needs, "*" stands for all fields
+var requiredDirectusPermissions = []dto.DirectusPermission{
+	{Collection: "room", Action: "read", Fields: []string{"*"}},
+	{Collection: "room", Action: "update", Fields: []string{"like_count", "view_count", "mastodon_status_id", "gallery_thumbnails"}},
+	{Collection: "event", Action: "read", Fields: []string{"*"}},
+	{Collection: "event", Action: "update", Fields: []string{"like_count", "view_count", "reminded_at", "started_at", "ended_at", "mastodon_status_id"}},
+	{Collection: "account", Action: "read", Fields: []string{"*"}},
+	{Collection: "account", Action: "create", Fields: []string{"mastodon_account", "mastodon_avatar", "display_name", "is_admin"}},
+	{Collection: "account", Action: "update", Fields: []string{"display_name", "mastodon_avatar", "active_avatar", "liked_rooms", "liked_events", "favorite_avatars", "avatar_quota_count", "avatar_quota_mb"}},
+	{Collection: "avatar", Action: "read", Fields: []string{"*"}},
+	{Collection: "avatar", Action: "create", Fields: []string{"snapshot", "snapshot_thumbnails", "glb", "owner", "source", "title", "is_public", "tags"}},
+	{Collection: "avatar", Action: "update", Fields: []string{"title", "source", "is_public", "snapshot", "snapshot_thumbnails", "glb", "tags"}},
+	{Collection: "avatar", Action: "delete"},
+	{Collection: "directus_files", Action: "read", Fields: []string{"*"}},
+	{Collection: "directus_files", Action: "create", Fields: []string{"*"}},
+	{Collection: "directus_files", Action: "update", Fields: []string{"*"}},
+	{Collection: "directus_files", Action: "delete"},
+}
+
+// CheckDirectusPermissions compares the grants of the directus token with requiredDirectusPermissions.
+// Grants on other collections are not reported since they are needed to expand relations, e.g. translations
+func CheckDirectusPermissions(ctx context.Context) (dto.DirectusPermissionReport, error) {
+
+	report := dto.DirectusPermissionReport{}
+
+	usersMeResponse := dto.DirectusGetUsersMeResponse{}
+	request := client.NewHTTPRequest(ctx).SetResult(&usersMeResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusUsersMeURI()
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[CheckDirectusPermissions] %s error: %v\n", config.GetDirectusUsersMeURI(), err)
+		return report, err
+	}
+
+	// admins bypass permissions, directus returns none of them
+	if usersMeResponse.Data.Role != nil && usersMeResponse.Data.Role.AdminAccess {
+		report.AdminAccess = true
+		return report, nil
+	}
+
+	permissionsResponse := dto.DirectusGetPermissionsResponse{}
+	request = client.NewHTTPRequest(ctx).SetResult(&permissionsResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusPermissionsMeURI()
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[CheckDirectusPermissions] %s error: %v\n", config.GetDirectusPermissionsMeURI(), err)
+		return report, err
+	}
+
+	report.Missing, report.Excess = compareDirectusPermissions(requiredDirectusPermissions, permissionsResponse.Data)
+	return report, nil
+}
+
+// ReportDirectusPermissions logs the result of CheckDirectusPermissions, it is run once at boot
+func ReportDirectusPermissions(ctx context.Context) {
+
+	report, err := CheckDirectusPermissions(ctx)
+	if err != nil {
+		logger.Ctx(ctx).Error.Printf("[ReportDirectusPermissions] unable to check directus permissions: %v\n", err)
+		return
+	}
+
+	if report.AdminAccess {
+		logger.Ctx(ctx).Warn.Printf("[ReportDirectusPermissions] directus token has admin access, use DIRECTUS_STATIC_TOKEN of a least-privilege user instead\n")
+		return
+	}
+
+	for _, grant := range report.Missing {
+		logger.Ctx(ctx).Error.Printf("[ReportDirectusPermissions] missing directus grant: %s\n", grant)
+	}
+	for _, grant := range report.Excess {
+		logger.Ctx(ctx).Warn.Printf("[ReportDirectusPermissions] unneeded directus grant: %s\n", grant)
+	}
+	if len(report.Missing) == 0 && len(report.Excess) == 0 {
+		logger.Ctx(ctx).Info.Printf("[ReportDirectusPermissions] directus grants match\n")
+	}
+}
+
+// compareDirectusPermissions returns grants formatted as "collection.action(fields)"
+func compareDirectusPermissions(required, granted []dto.DirectusPermission) (missing []string, excess []string) {
+
+	grantedFields := map[string]map[string]bool{}
+	for _, p := range granted {
+		key := p.Collection + "." + p.Action
+		if grantedFields[key] == nil {
+			grantedFields[key] = map[string]bool{}
+		}
+		for _, f := range p.Fields {
+			grantedFields[key][f] = true
+		}
+	}
+
+	requiredFields := map[string]map[string]bool{}
+	checkedCollections := map[string]bool{}
+	for _, p := range required {
+		key := p.Collection + "." + p.Action
+		checkedCollections[p.Collection] = true
+		requiredFields[key] = map[string]bool{}
+		for _, f := range p.Fields {
+			requiredFields[key][f] = true
+		}
+
+		fields, found := grantedFields[key]
+		if !found {
+			missing = append(missing, formatDirectusGrant(key, p.Fields))
+			continue
+		}
+		if fields["*"] {
+			continue
+		}
+
+		var missingFields []string
+		for _, f := range p.Fields {
+			if !fields[f] {
+				missingFields = append(missingFields, f)
+			}
+		}
+		if len(missingFields) > 0 {
+			missing = append(missing, formatDirectusGrant(key, missingFields))
+		}
+	}
+
+	for key, fields := range grantedFields {
+		collection := strings.SplitN(key, ".", 2)[0]
+		if !checkedCollections[collection] {
+			continue
+		}
+
+		needed, found := requiredFields[key]
+		if !found {
+			excess = append(excess, formatDirectusGrant(key, sortedKeys(fields)))
+			continue
+		}
+		if needed["*"] {
+			continue
+		}
+
+		var excessFields []string
+		for f := range fields {
+			if !needed[f] {
+				excessFields = append(excessFields, f)
+			}
+		}
+		if len(excessFields) > 0 {
+			sort.Strings(excessFields)
+			excess = append(excess, formatDirectusGrant(key, excessFields))
+		}
+	}
+	sort.Strings(excess)
+
+	return
+}
+
+func formatDirectusGrant(key string, fields []string) string {
+	if len(fields) == 0 {
+		return key
+	}
+	return fmt.Sprintf("%s(%s)", key, strings.Join(fields, ","))
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/logger"
+	"sort"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// AvatarQuota returns the avatar count and bytes the account may use, AVATAR_QUOTA_* unless the account overrides them
+func AvatarQuota(quota dto.AvatarQuota) (maxCount int64, maxBytes int64) {
+	maxCount, maxMB := config.EnvVariable.AvatarQuotaCount, config.EnvVariable.AvatarQuotaMB
+	if quota.AvatarQuotaCount != nil {
+		maxCount = *quota.AvatarQuotaCount
+	}
+	if quota.AvatarQuotaMB != nil {
+		maxMB = *quota.AvatarQuotaMB
+	}
+	return maxCount, maxMB << 20
+}
+
+// GetAvatarUsage counts the avatars owned by the account and sums the filesize of their snapshots, thumbnails and models.
+// The size of each file is also returned, files shared by avatars are counted once
+func GetAvatarUsage(ctx context.Context, accountID string, quota dto.AvatarQuota) (dto.AvatarUsage, map[string]int64, error) {
+
+	usage := dto.AvatarUsage{}
+	usage.MaxAvatarCount, usage.MaxBytes = AvatarQuota(quota)
+
+	avatars := []dto.DirectusAvatarResponseData{}
+	request := client.NewHTTPRequest(ctx).SetResult(&dto.DirectusGetResponse{Data: &avatars})
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusGetAvatarFilesURI(accountID)
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetAvatarUsage] %s error: %v\n", request.URL, err)
+		return dto.AvatarUsage{}, nil, err
+	}
+	usage.AvatarCount = int64(len(avatars))
+
+	fileIDs := map[string]bool{}
+	for _, avatar := range avatars {
+		for _, id := range avatar.SnapshotThumbnails {
+			fileIDs[id] = true
+		}
+		fileIDs[avatar.Snapshot] = true
+		fileIDs[avatar.GLB] = true
+	}
+	delete(fileIDs, "")
+
+	fileSizes, err := GetFileSizes(ctx, fileIDs)
+	if err != nil {
+		return dto.AvatarUsage{}, nil, err
+	}
+	for _, size := range fileSizes {
+		usage.Bytes += size
+	}
+
+	return usage, fileSizes, nil
+}
+
+// GetFileSizes reads the filesize of the files from their directus metadata
+func GetFileSizes(ctx context.Context, fileIDs map[string]bool) (map[string]int64, error) {
+
+	fileSizes := map[string]int64{}
+	if len(fileIDs) == 0 {
+		return fileSizes, nil
+	}
+
+	ids := make([]string, 0, len(fileIDs))
+	for id := range fileIDs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	directusGetFileSizesResponse := dto.DirectusGetFileSizesResponse{}
+	request := client.NewHTTPRequest(ctx).SetResult(&directusGetFileSizesResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusGetFileSizesURI(ids)
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetFileSizes] %s error: %v\n", request.URL, err)
+		return nil, err
+	}
+
+	// a missing or invalid filesize counts as 0, one broken file should not block the account
+	for _, file := range directusGetFileSizesResponse.Data {
+		size, err := file.FileSize.Int64()
+		if err != nil {
+			logger.Ctx(ctx).Warn.Printf("[GetFileSizes] file %v filesize %q is counted as 0: %v\n", file.ID, file.FileSize, err)
+			size = 0
+		}
+		fileSizes[file.ID] = size
+	}
+
+	return fileSizes, nil
+}
+
+// GetDirectusAccountQuota reads the quota overrides of the account
+func GetDirectusAccountQuota(ctx context.Context, accountID string) (dto.AvatarQuota, error) {
+
+	directusUpsertAccountResponse := dto.DirectusUpsertAccountResponse{}
+	request := client.NewHTTPRequest(ctx).SetResult(&directusUpsertAccountResponse)
+	request.Method = resty.MethodGet
+	request.URL = config.GetDirectusPatchAccountURI(accountID)
+
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[GetDirectusAccountQuota] %s error: %v\n", request.URL, err)
+		return dto.AvatarQuota{}, err
+	}
+
+	if !directusUpsertAccountResponse.Validate() {
+		logger.Ctx(ctx).Error.Printf("[GetDirectusAccountQuota] server response invalid payload: %v\n", directusUpsertAccountResponse)
+		return dto.AvatarQuota{}, fmt.Errorf("[GetDirectusAccountQuota] server response invalid payload: %v", directusUpsertAccountResponse)
+	}
+
+	return directusUpsertAccountResponse.Data.AvatarQuota, nil
+}
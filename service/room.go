@@ -1,9 +1,9 @@
 package service
 
 import (
+	"context"
 	"hubs-cms-go/client"
 	"hubs-cms-go/config"
-	"hubs-cms-go/constant"
 	"hubs-cms-go/dto"
 	"hubs-cms-go/errors"
 	"hubs-cms-go/logger"
@@ -11,20 +11,22 @@ import (
 	"github.com/go-resty/resty/v2"
 )
 
-func PatchDirectusRoom(roomID string, patchBody interface{}) (err error) {
-	request := client.NewHTTPRequest().
+func PatchDirectusRoom(ctx context.Context, roomID string, patchBody interface{}) (err error) {
+	request := client.NewHTTPRequest(ctx).
 		SetHeader("Content-Type", "application/json").
 		SetBody(patchBody)
 	request.Method = resty.MethodPatch
 	request.URL = config.GetDirectusGetRoomURISimple(roomID)
 
-	_, err = directusRequestHandler(&request)
+	if _, err = directusRequestHandler(&request); err == nil {
+		InvalidateDirectusCache(ctx, "room", "")
+	}
 	return
 }
 
-func GetDirectusMyRoomList(accountID, locale string, start, limit int64) (ret []dto.DierctusRoomData, total int64, err error) {
+func GetDirectusMyRoomList(ctx context.Context, accountID, locale string, start, limit int64) (ret []dto.DierctusRoomData, total int64, err error) {
 	directusResponse := dto.DirectusGetResponse{Data: &ret}
-	request := client.NewHTTPRequest().SetResult(&directusResponse)
+	request := client.NewHTTPRequest(ctx).SetResult(&directusResponse)
 	request.Method = resty.MethodGet
 	request.URL = config.GetDirectusGetMyRoomListURI(accountID, locale, start, limit)
 
@@ -41,13 +43,9 @@ func GetDirectusMyRoomList(accountID, locale string, start, limit int64) (ret []
 	return
 }
 
-func GetDirectusRoomList(pHasNFT *bool, hubsID, locale string, start, limit int64) (ret []dto.DierctusRoomData, total int64, err error) {
+func GetDirectusRoomList(ctx context.Context, pHasNFT *bool, hubsID, locale string, start, limit int64) (ret []dto.DierctusRoomData, total int64, err error) {
 	directusResponse := dto.DirectusGetResponse{Data: &ret}
-	request := client.NewHTTPRequest().SetResult(&directusResponse)
-	request.Method = resty.MethodGet
-	request.URL = config.GetDirectusGetRoomListURI(pHasNFT, hubsID, locale, start, limit)
-
-	if _, err = directusRequestHandler(&request); err != nil {
+	if err = cachedDirectusGet(ctx, config.GetDirectusGetRoomListURI(pHasNFT, hubsID, locale, start, limit), &directusResponse); err != nil {
 		return
 	}
 
@@ -60,8 +58,8 @@ func GetDirectusRoomList(pHasNFT *bool, hubsID, locale string, start, limit int6
 	return
 }
 
-func GetDirectusRoomWithCustomData(roomID, locale string, customData interface{}) (err error) {
-	request := client.NewHTTPRequest().SetResult(&dto.DirectusGetResponse{Data: customData})
+func GetDirectusRoomWithCustomData(ctx context.Context, roomID, locale string, customData interface{}) (err error) {
+	request := client.NewHTTPRequest(ctx).SetResult(&dto.DirectusGetResponse{Data: customData})
 	request.Method = resty.MethodGet
 	request.URL = config.GetDirectusGetRoomURI(roomID, locale)
 
@@ -72,12 +70,8 @@ func GetDirectusRoomWithCustomData(roomID, locale string, customData interface{}
 	return
 }
 
-func GetDirectusRoom(roomID, locale string) (ret dto.DierctusRoomData, err error) {
-	request := client.NewHTTPRequest().SetResult(&dto.DirectusGetResponse{Data: &ret})
-	request.Method = resty.MethodGet
-	request.URL = config.GetDirectusGetRoomURI(roomID, locale)
-
-	if _, err = directusRequestHandler(&request); err != nil {
+func GetDirectusRoom(ctx context.Context, roomID, locale string) (ret dto.DierctusRoomData, err error) {
+	if err = cachedDirectusGet(ctx, config.GetDirectusGetRoomURI(roomID, locale), &dto.DirectusGetResponse{Data: &ret}); err != nil {
 		return
 	}
 
@@ -88,47 +82,29 @@ func GetDirectusRoom(roomID, locale string) (ret dto.DierctusRoomData, err error
 	return
 }
 
-func PostRoomViewCount(roomData dto.DierctusRoomData, locale string, isRetried bool) (dto.DierctusRoomData, errors.ErrorInfo) {
+func PostRoomViewCount(ctx context.Context, roomData dto.DierctusRoomData, locale string) (dto.DierctusRoomData, errors.ErrorInfo) {
 	ret := dto.DierctusRoomData{}
 
-	directusAccessToken, tokenErr := GetDirectusAccessToken(isRetried)
-	if tokenErr != nil {
-		logger.Error.Printf("[PostRoomViewCount] fail to get token. Turn on retry flag: %v\n", isRetried)
-		if !isRetried {
-			return PostRoomViewCount(roomData, locale, true)
-		}
-		logger.Error.Printf("[PostRoomViewCount] unable to get directus access token error: %v\n", tokenErr)
-		return dto.DierctusRoomData{}, errors.InternalError
-	}
-
 	addNumber, convertNumberErr := roomData.ViewCount.Int64()
 	newViewCount := addNumber + 1
 	if convertNumberErr != nil {
-		logger.Error.Printf("[PostRoomViewCount] unable to convert number error: %v\n", convertNumberErr)
+		logger.Ctx(ctx).Error.Printf("[PostRoomViewCount] unable to convert number error: %v\n", convertNumberErr)
 		return dto.DierctusRoomData{}, errors.InternalError
 	}
 
-	response, err := client.NewHTTPRequest().
-		SetHeader(constant.HeaderAuthorization, directusAccessToken).
+	request := client.NewHTTPRequest(ctx).
 		SetBody(dto.RoomDataIncreaseViewCountRequest{
 			ViewCount: newViewCount,
 		}).
-		SetResult(&dto.DirectusGetResponse{Data: &ret}).
-		Patch(config.GetDirectusGetRoomURI(roomData.ID, locale))
-
-	if err != nil {
-		logger.Error.Printf("[PostRoomViewCount] request update room count error: %v\n", err)
-		return dto.DierctusRoomData{}, errors.InternalError
-	}
+		SetResult(&dto.DirectusGetResponse{Data: &ret})
+	request.Method = resty.MethodPatch
+	request.URL = config.GetDirectusGetRoomURI(roomData.ID, locale)
 
-	if !response.IsSuccess() {
-		logger.Error.Printf("[PostRoomViewCount] fail to update view count. Turn on retry flag: %v\n", isRetried)
-		if !isRetried {
-			return PostRoomViewCount(roomData, locale, true)
-		}
-		logger.Error.Printf("[PostRoomViewCount] error: %v\n", err)
+	if _, err := directusRequestHandler(&request); err != nil {
+		logger.Ctx(ctx).Error.Printf("[PostRoomViewCount] request update view count error: %v\n", err)
 		return dto.DierctusRoomData{}, errors.InternalError
 	}
+	InvalidateDirectusCache(ctx, "room", roomData.ID)
 
 	return ret, errors.ErrorInfo{}
 }
@@ -3,6 +3,7 @@ package service
 import (
 	"bytes"
 	"fmt"
+	"hubs-cms-go/client"
 	"hubs-cms-go/constant"
 	"hubs-cms-go/dto"
 	"hubs-cms-go/logger"
@@ -12,44 +13,58 @@ import (
 	goCache "github.com/patrickmn/go-cache"
 )
 
-func directusRequestHandler(request **resty.Request) (response *resty.Response, err error) {
+// directusRequestHandler sends the request with the admin token through client.Execute, the token is refreshed once on 401.
+// prepare runs on the copy of each attempt, e.g. to reopen a file to upload
+func directusRequestHandler(request **resty.Request, prepare ...func(*resty.Request) error) (response *resty.Response, err error) {
 	var directusAccessToken string
+	ctx := (*request).Context()
 
-	for forceFetch := false; true; {
-		directusAccessToken, err = GetDirectusAccessToken(forceFetch)
+	for renew := false; true; {
+		if renew {
+			directusAccessToken, err = renewDirectusAccessToken(ctx, directusAccessToken)
+		} else {
+			directusAccessToken, err = GetDirectusAccessToken(ctx, false)
+		}
 		if err != nil {
-			logger.Error.Printf("[requestHandler] unable to get directus access token error: %v\n", err)
+			logger.Ctx(ctx).Error.Printf("[requestHandler] unable to get directus access token error: %v\n", err)
 			return
 		}
 
 		copyReq := **request
+		for _, p := range prepare {
+			if err = p(&copyReq); err != nil {
+				logger.Ctx(ctx).Error.Printf("[requestHandler] prepare request error: %v\n", err)
+				return
+			}
+		}
+
 		directusErr := dto.DirectusErrorResponse{}
-		response, err = copyReq.
+		copyReq.
 			SetHeader(constant.HeaderAuthorization, directusAccessToken).
-			SetError(&directusErr).
-			Send()
-		logger.Debug.Println("[requestHandler] response:", response)
+			SetError(&directusErr)
+		response, err = client.Execute(&copyReq, copyReq.Method, copyReq.URL)
+		logger.Ctx(ctx).Debug.Println("[requestHandler] response:", response)
 
 		if err != nil {
-			logger.Error.Printf("[requestHandler] get data error: %v\n", err)
+			logger.Ctx(ctx).Error.Printf("[requestHandler] get data error: %v\n", err)
 			return
 		}
 
 		// 4XX~
 		if response.IsError() {
-			if response.StatusCode() == http.StatusUnauthorized && !forceFetch {
-				forceFetch = true
+			if response.StatusCode() == http.StatusUnauthorized && !renew {
+				renew = true
 				continue
 			}
 			directusErr.Status = response.StatusCode()
-			logger.Error.Printf("[requestHandler] server response error status: %v\n", response.StatusCode())
+			logger.Ctx(ctx).Error.Printf("[requestHandler] server response error status: %v\n", response.StatusCode())
 			err = &directusErr
 			return
 		}
 
 		// 3xx
 		if !response.IsSuccess() {
-			logger.Error.Printf("[requestHandler] server response error status: %v\n", response.StatusCode())
+			logger.Ctx(ctx).Error.Printf("[requestHandler] server response error status: %v\n", response.StatusCode())
 			err = dto.DirectusErrorResponseFromHttpStstus(response.StatusCode())
 			return
 		}
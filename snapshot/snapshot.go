@@ -0,0 +1,149 @@
+package snapshot
+
+import (
+	"context"
+	"fmt"
+	"hubs-cms-go/config"
+	"hubs-cms-go/imaging"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/service"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// timeout bounds the processing of a gallery started from a request
+const timeout = time.Minute
+
+var (
+	// lock serializes the processing of galleries so that a room changed twice in a row is not processed twice at once
+	lock    sync.Mutex
+	running sync.WaitGroup
+)
+
+// Process checks an image against IMAGE_MAX_MB and IMAGE_MAX_DIMENSION, re-encodes it and makes its IMAGE_THUMBNAIL_SIZES thumbnails
+func Process(data []byte) (imaging.Result, error) {
+	limits := imaging.Limits{
+		MaxSize:      int64(config.EnvVariable.ImageMaxMB) << 20,
+		MaxDimension: config.EnvVariable.ImageMaxDimension,
+	}
+	return imaging.Process(data, limits, config.EnvVariable.ImageThumbnailSizes)
+}
+
+// Upload uploads a processed image and its thumbnails. It returns the id of the image and the ids of the thumbnails
+// keyed by size, sizes the image already fits in refer to the image itself. Nothing is left in directus on failure
+func Upload(ctx context.Context, fileName string, result imaging.Result) (string, map[string]string, error) {
+	name := baseName(fileName)
+	id, err := service.UploadAssetData(ctx, name+result.Ext(), result.ContentType, result.Data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	thumbnails, err := uploadThumbnails(ctx, name, id, result)
+	if err != nil {
+		service.RemoveFiles(ctx, id)
+		return "", nil, err
+	}
+	return id, thumbnails, nil
+}
+
+// uploadThumbnails uploads the thumbnails of the image id, the thumbnails already uploaded are removed on failure
+func uploadThumbnails(ctx context.Context, name, id string, result imaging.Result) (map[string]string, error) {
+	thumbnails := map[string]string{}
+	uploaded := []string{}
+	for _, size := range config.EnvVariable.ImageThumbnailSizes {
+		thumbnail, found := result.Thumbnails[size]
+		if !found {
+			thumbnails[strconv.Itoa(size)] = id
+			continue
+		}
+
+		thumbnailID, err := service.UploadAssetData(ctx, fmt.Sprintf("%s-%d%s", name, size, thumbnail.Ext()), thumbnail.ContentType, thumbnail.Data)
+		if err != nil {
+			service.RemoveFiles(ctx, uploaded...)
+			return nil, err
+		}
+		thumbnails[strconv.Itoa(size)] = thumbnailID
+		uploaded = append(uploaded, thumbnailID)
+	}
+	return thumbnails, nil
+}
+
+// baseName is the file name without its directory and extension, the extension follows the encoded type
+func baseName(fileName string) string {
+	name := path.Base(strings.ReplaceAll(fileName, "\\", "/"))
+	name = strings.TrimSuffix(name, path.Ext(name))
+	if name == "" || name == "." || name == "/" {
+		return "snapshot"
+	}
+	return name
+}
+
+// Start processes the gallery of the room in the background, see Room
+func Start(ctx context.Context, roomID string) {
+	running.Add(1)
+	go func() {
+		defer running.Done()
+
+		ctx, cancel := context.WithTimeout(service.Detach(ctx), timeout)
+		defer cancel()
+
+		if err := Room(ctx, roomID); err != nil {
+			logger.Ctx(ctx).Error.Printf("[snapshot.Start] process gallery of room %v error: %v\n", roomID, err)
+		}
+	}()
+}
+
+// Wait waits for the galleries in progress until ctx is done
+func Wait(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// Room rewrites the gallery of the room in place without its metadata, and stores the ids of its thumbnails
+// on the room as gallery_thumbnails. Galleries are uploaded in directus, so they are processed after the fact
+func Room(ctx context.Context, roomID string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	room, err := service.GetDirectusRoom(service.NoCache(ctx), roomID, "")
+	if err != nil {
+		return err
+	}
+	if !room.Gallery.Validate() {
+		if room.GalleryThumbnails == nil {
+			return nil
+		}
+		return service.PatchDirectusRoom(ctx, roomID, map[string]interface{}{"gallery_thumbnails": nil})
+	}
+
+	data, _, err := service.GetDirectusAsset(ctx, room.Gallery.ID)
+	if err != nil {
+		return err
+	}
+	result, err := Process(data)
+	if err != nil {
+		return fmt.Errorf("gallery %v: %w", room.Gallery.ID, err)
+	}
+
+	if err := service.ReplaceAssetData(ctx, room.Gallery.ID, "gallery"+result.Ext(), result.ContentType, result.Data); err != nil {
+		return err
+	}
+	thumbnails, err := uploadThumbnails(ctx, "gallery", room.Gallery.ID, result)
+	if err != nil {
+		return err
+	}
+
+	logger.Ctx(ctx).Info.Printf("[snapshot.Room] gallery %v of room %v: %dx%d, thumbnails %v\n", room.Gallery.ID, roomID, result.Width, result.Height, thumbnails)
+	return service.PatchDirectusRoom(ctx, roomID, map[string]interface{}{"gallery_thumbnails": thumbnails})
+}
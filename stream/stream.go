@@ -0,0 +1,240 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/logger"
+	"hubs-cms-go/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrTooManySubscriptions is returned when a subscriber exceeds STREAM_MAX_SUBSCRIPTIONS
+var ErrTooManySubscriptions = errors.New("too many subscriptions")
+
+// ErrSubscriberClosed is returned when subscribing after Close
+var ErrSubscriberClosed = errors.New("subscriber closed")
+
+// Subscriber receives the messages of the rooms and events it subscribed on C,
+// messages are dropped when C is full so that a slow client never blocks the publishers
+type Subscriber struct {
+	C      chan dto.StreamMessage
+	topics map[string]struct{}
+	closed bool
+}
+
+// eventWatch emits the status transitions of a subscribed event when its start_time and end_time pass
+type eventWatch struct {
+	startTime time.Time
+	endTime   time.Time
+	status    string
+	timer     *time.Timer
+}
+
+var lock sync.Mutex
+var topics = map[string]map[*Subscriber]struct{}{}
+var watches = map[string]*eventWatch{} // by topic
+
+var done = make(chan struct{})
+var doneOnce sync.Once
+
+// Done is closed on Shutdown, streams should end when it is
+func Done() <-chan struct{} {
+	return done
+}
+
+// Shutdown ends the open streams, they never finish by themselves and would hold the http server from draining
+func Shutdown() {
+	doneOnce.Do(func() { close(done) })
+}
+
+func topicOf(target, id string) string {
+	return target + ":" + id
+}
+
+// NewSubscriber creates a subscriber without any subscription
+func NewSubscriber() *Subscriber {
+	return &Subscriber{
+		C:      make(chan dto.StreamMessage, config.EnvVariable.StreamBufferSize),
+		topics: map[string]struct{}{},
+	}
+}
+
+// Subscribe adds the room or event of id to the subscriptions
+func (s *Subscriber) Subscribe(target, id string) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if s.closed {
+		return ErrSubscriberClosed
+	}
+
+	topic := topicOf(target, id)
+	if _, found := s.topics[topic]; found {
+		return nil
+	}
+	if len(s.topics) >= config.EnvVariable.StreamMaxSubscriptions {
+		return ErrTooManySubscriptions
+	}
+
+	s.topics[topic] = struct{}{}
+	if topics[topic] == nil {
+		topics[topic] = map[*Subscriber]struct{}{}
+	}
+	topics[topic][s] = struct{}{}
+	return nil
+}
+
+// Unsubscribe removes the room or event of id from the subscriptions
+func (s *Subscriber) Unsubscribe(target, id string) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	s.unsubscribe(topicOf(target, id))
+}
+
+// Close removes all subscriptions, C is left open for a late reader and receives nothing more
+func (s *Subscriber) Close() {
+	lock.Lock()
+	defer lock.Unlock()
+
+	s.closed = true
+	for topic := range s.topics {
+		s.unsubscribe(topic)
+	}
+}
+
+// Len returns the number of subscriptions
+func (s *Subscriber) Len() int {
+	lock.Lock()
+	defer lock.Unlock()
+	return len(s.topics)
+}
+
+func (s *Subscriber) unsubscribe(topic string) {
+	delete(s.topics, topic)
+
+	subscribers := topics[topic]
+	delete(subscribers, s)
+	if len(subscribers) > 0 {
+		return
+	}
+
+	delete(topics, topic)
+	if watch, found := watches[topic]; found {
+		watch.timer.Stop()
+		delete(watches, topic)
+	}
+}
+
+// Publish sends msg to the subscribers of its room or event
+func Publish(msg dto.StreamMessage) {
+	if len(msg.Timestamp) == 0 {
+		msg.Timestamp = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	publish(msg)
+}
+
+func publish(msg dto.StreamMessage) {
+	for s := range topics[topicOf(msg.Target, msg.ID)] {
+		select {
+		case s.C <- msg:
+		default:
+			metrics.StreamDroppedMessagesTotal.Inc()
+		}
+	}
+}
+
+// PublishLikeCount sends a like count change of a room or an event
+func PublishLikeCount(target, id string, delta, likes int64) {
+	Publish(dto.StreamMessage{Type: dto.StreamTypeLikeCount, Target: target, ID: id, Delta: delta, Value: json.Number(strconv.FormatInt(likes, 10))})
+}
+
+// PublishViewCount sends a view count change of a room or an event
+func PublishViewCount(target, id string, delta, views int64) {
+	Publish(dto.StreamMessage{Type: dto.StreamTypeViewCount, Target: target, ID: id, Delta: delta, Value: json.Number(strconv.FormatInt(views, 10))})
+}
+
+// WatchEvent schedules the status transitions of a subscribed event, it is a no-op when the event has no subscribers.
+// Watching again with changed times emits the transition at once if the status moved
+func WatchEvent(id string, startTime, endTime time.Time) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	topic := topicOf(dto.StreamTargetEvent, id)
+	if len(topics[topic]) == 0 {
+		return
+	}
+
+	previous, found := watches[topic]
+	if found {
+		if previous.startTime.Equal(startTime) && previous.endTime.Equal(endTime) {
+			return
+		}
+		previous.timer.Stop()
+	}
+
+	watch := &eventWatch{startTime: startTime, endTime: endTime, status: dto.EventStatusAt(startTime, endTime, time.Now())}
+	watches[topic] = watch
+	if found && previous.status != watch.status {
+		publishStatus(id, watch.status, previous.status)
+	}
+	watch.timer = time.AfterFunc(nextTransition(watch), func() { fireEventWatch(id, watch) })
+}
+
+// IsWatchingEvent tells whether the status transitions of an event are scheduled
+func IsWatchingEvent(id string) bool {
+	lock.Lock()
+	defer lock.Unlock()
+
+	_, found := watches[topicOf(dto.StreamTargetEvent, id)]
+	return found
+}
+
+func fireEventWatch(id string, watch *eventWatch) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if watches[topicOf(dto.StreamTargetEvent, id)] != watch {
+		return
+	}
+
+	status := dto.EventStatusAt(watch.startTime, watch.endTime, time.Now())
+	if status != watch.status {
+		logger.Debug.Printf("[fireEventWatch] event %v %v -> %v\n", id, watch.status, status)
+		publishStatus(id, status, watch.status)
+		watch.status = status
+	}
+	if status != dto.EventStatusClosed {
+		watch.timer.Reset(nextTransition(watch))
+	}
+}
+
+func publishStatus(id, status, previous string) {
+	publish(dto.StreamMessage{
+		Type:      dto.StreamTypeStatus,
+		Target:    dto.StreamTargetEvent,
+		ID:        id,
+		Status:    status,
+		Previous:  previous,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// nextTransition returns the wait until the next boundary of the event, closed events wait forever
+func nextTransition(watch *eventWatch) time.Duration {
+	switch watch.status {
+	case dto.EventStatusSoon:
+		return time.Until(watch.startTime)
+	case dto.EventStatusOpened:
+		return time.Until(watch.endTime)
+	}
+	return time.Duration(1<<63 - 1)
+}
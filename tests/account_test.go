@@ -2,6 +2,7 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"hubs-cms-go/client"
@@ -55,7 +56,7 @@ func TestGetDirectusAccountData(t *testing.T) {
 		mockDirectusGetResponse := dto.DirectusGetResponse{Data: &accountData}
 		setUpResponder(http.StatusOK, mockDirectusGetResponse, http.MethodGet, config.GetDirectusGetAccountURI(mastodonAccount))
 
-		response, err := service.GetDirectusAccountData(mastodonAccount)
+		response, err := service.GetDirectusAccountData(context.Background(), mastodonAccount)
 		assert.Nil(t, err)
 
 		assert.Equal(t, accountData[0].ID, response.ID)
@@ -116,7 +117,7 @@ func TestGetDirectusAccount(t *testing.T) {
 
 		setUpResponder(http.StatusOK, accountData, http.MethodGet, config.GetDirectusGetAccountURI(mastodonAccount))
 
-		response, err := service.GetDirectusAccount(mastodonAccount, true)
+		response, err := service.GetDirectusAccount(context.Background(), mastodonAccount)
 		assert.Nil(t, err)
 
 		assert.Equal(t, accountData.Data[0].ID, response.ID)
@@ -188,7 +189,7 @@ func TestCreateDirectusAccount(t *testing.T) {
 			MastodonToken:   "testMastodonToken",
 		}
 
-		result, err := service.CreateDirectusAccount(mockMastodonAccountInfo, false)
+		result, err := service.CreateDirectusAccount(context.Background(), mockMastodonAccountInfo)
 		assert.Nil(t, err)
 
 		assert.Equal(t, mockMastodonAccountInfo.ID, result.ID)
@@ -244,7 +245,7 @@ func TestPatchDirectusAccount(t *testing.T) {
 		}
 		setUpResponder(http.StatusOK, mockAccountData, http.MethodPatch, config.GetDirectusPatchAccountURI(testID))
 
-		result, err := service.PatchDirectusAccount(testID, &mockPatchAccountRequestBody, false)
+		result, err := service.PatchDirectusAccount(context.Background(), testID, &mockPatchAccountRequestBody)
 		assert.Nil(t, err)
 
 		assert.Equal(t, mockPatchAccountRequestBody.DisplayName, result.DisplayName)
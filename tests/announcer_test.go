@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"hubs-cms-go/announce"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpAnnouncer(t *testing.T, token string) {
+	announcerToken, eventURL := config.EnvVariable.MastodonAnnouncerToken, config.EnvVariable.AnnouncerEventURL
+	config.EnvVariable.MastodonAnnouncerToken = token
+	config.EnvVariable.AnnouncerEventURL = "https://hubs.test/events/{id}"
+	t.Cleanup(func() {
+		config.EnvVariable.MastodonAnnouncerToken, config.EnvVariable.AnnouncerEventURL = announcerToken, eventURL
+	})
+}
+
+// setUpStatusResponders records the statuses posted and edited, and answers edits with editStatus
+func setUpStatusResponders(statusID string, editStatus int, statuses *sync.Map) {
+	record := func(key string, req *http.Request) {
+		status := dto.MastodonPostStatusRequest{}
+		b, _ := ioutil.ReadAll(req.Body)
+		json.Unmarshal(b, &status)
+		statuses.Store(key, status)
+	}
+	httpmock.RegisterResponder(http.MethodPost, config.GetMastodonStatusesURI(), func(req *http.Request) (*http.Response, error) {
+		record("posted", req)
+		return httpmock.NewJsonResponse(http.StatusOK, dto.MastodonStatusResponse{ID: statusID})
+	})
+	httpmock.RegisterResponder(http.MethodPut, "=~^"+config.GetMastodonStatusesURI()+"/", func(req *http.Request) (*http.Response, error) {
+		record("edited", req)
+		return httpmock.NewJsonResponse(editStatus, dto.MastodonStatusResponse{ID: statusID})
+	})
+}
+
+// setUpItemPatchResponder records the body patched to the item
+func setUpItemPatchResponder(url string, patches *sync.Map) {
+	httpmock.RegisterResponder(http.MethodPatch, url, func(req *http.Request) (*http.Response, error) {
+		patch := map[string]interface{}{}
+		b, _ := ioutil.ReadAll(req.Body)
+		json.Unmarshal(b, &patch)
+		patches.Store(url, patch)
+		return httpmock.NewStringResponse(http.StatusOK, `{"data":{}}`), nil
+	})
+}
+
+func TestAnnounceEvent(t *testing.T) {
+	t.Run("New event is posted with its gallery and hashtags", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpAnnouncer(t, "announcer-token")
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		event := dto.DirectusEventResponseData{
+			ID:          gofakeit.UUID(),
+			Gallery:     gofakeit.UUID(),
+			Title:       "Opening",
+			Description: "<p>Welcome &amp; " + strings.Repeat("enjoy ", 200) + "</p>",
+			StartTime:   time.Date(2030, 1, 1, 10, 0, 0, 0, time.UTC),
+			EndTime:     time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC),
+			Hashtags: []dto.DirectusHashtag{
+				{HashtagID: dto.HashtagID{ID: "1", Name: "Hubs"}},
+				{HashtagID: dto.HashtagID{ID: "2", Name: "virtual event"}},
+				{HashtagID: dto.HashtagID{ID: "3", Name: "hubs"}},
+			},
+		}
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: event}, http.MethodGet, config.GetDirectusGetEventURI(event.ID, ""))
+
+		httpmock.RegisterResponder(http.MethodGet, config.GetDirectusGetAssetURI(event.Gallery), func(req *http.Request) (*http.Response, error) {
+			resp := httpmock.NewBytesResponse(http.StatusOK, []byte("\x89PNG\r\n\x1a\n"))
+			resp.Header.Set("Content-Type", "image/png")
+			return resp, nil
+		})
+		var description, authorization string
+		httpmock.RegisterResponder(http.MethodPost, config.GetMastodonUploadMediaURI(), func(req *http.Request) (*http.Response, error) {
+			authorization = req.Header.Get(constant.HeaderAuthorization)
+			description = req.FormValue("description")
+			return httpmock.NewJsonResponse(http.StatusAccepted, dto.MastodonMediaResponse{ID: "m1", Type: "image"})
+		})
+		setUpResponder(http.StatusOK, dto.MastodonMediaResponse{ID: "m1", Type: "image", URL: "https://mastodon.test/m1.png"}, http.MethodGet, config.GetMastodonMediaURI("m1"))
+
+		statuses, patches := &sync.Map{}, &sync.Map{}
+		setUpStatusResponders("s1", http.StatusOK, statuses)
+		setUpItemPatchResponder(config.GetDirectusGetEventURISimple(event.ID), patches)
+
+		assert.Nil(t, announce.Event(context.Background(), event.ID, true))
+
+		assert.Equal(t, "Bearer announcer-token", authorization)
+		assert.Equal(t, "Opening", description)
+
+		value, found := statuses.Load("posted")
+		assert.True(t, found)
+		status, _ := value.(dto.MastodonPostStatusRequest)
+		assert.Equal(t, []string{"m1"}, status.MediaIDs)
+		assert.Equal(t, "public", status.Visibility)
+		assert.True(t, strings.HasPrefix(status.Status, "Opening\n\n2030-01-01 10:00 – 12:00 UTC\n\nWelcome & enjoy"))
+		assert.True(t, strings.HasSuffix(status.Status, "…\n\nhttps://hubs.test/events/"+event.ID+"\n\n#Hubs #virtualevent"))
+		assert.LessOrEqual(t, utf8.RuneCountInString(status.Status), config.EnvVariable.AnnouncerMaxChars)
+
+		patch, _ := patches.Load(config.GetDirectusGetEventURISimple(event.ID))
+		assert.Equal(t, map[string]interface{}{"mastodon_status_id": "s1"}, patch)
+	})
+
+	t.Run("Announced event is edited and posted again once deleted", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpAnnouncer(t, "announcer-token")
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		event := dto.DirectusEventResponseData{ID: gofakeit.UUID(), Title: "Opening", MastodonStatusID: "s1"}
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: event}, http.MethodGet, config.GetDirectusGetEventURI(event.ID, ""))
+
+		statuses, patches := &sync.Map{}, &sync.Map{}
+		setUpStatusResponders("s2", http.StatusOK, statuses)
+		setUpItemPatchResponder(config.GetDirectusGetEventURISimple(event.ID), patches)
+
+		assert.Nil(t, announce.Event(context.Background(), event.ID, false))
+		assert.Equal(t, 1, httpmock.GetCallCountInfo()["PUT "+config.GetMastodonStatusURI("s1")])
+		_, found := statuses.Load("posted")
+		assert.False(t, found)
+		_, found = patches.Load(config.GetDirectusGetEventURISimple(event.ID))
+		assert.False(t, found)
+
+		setUpStatusResponders("s2", http.StatusNotFound, statuses)
+		assert.Nil(t, announce.Event(context.Background(), event.ID, false))
+		_, found = statuses.Load("posted")
+		assert.True(t, found)
+		patch, _ := patches.Load(config.GetDirectusGetEventURISimple(event.ID))
+		assert.Equal(t, map[string]interface{}{"mastodon_status_id": "s2"}, patch)
+	})
+
+	t.Run("Unannounced event is not posted on edits", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpAnnouncer(t, "announcer-token")
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		event := dto.DirectusEventResponseData{ID: gofakeit.UUID(), Title: "Opening"}
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: event}, http.MethodGet, config.GetDirectusGetEventURI(event.ID, ""))
+
+		statuses := &sync.Map{}
+		setUpStatusResponders("s1", http.StatusOK, statuses)
+
+		assert.Nil(t, announce.Event(context.Background(), event.ID, false))
+		_, found := statuses.Load("posted")
+		assert.False(t, found)
+	})
+}
+
+func TestAnnounceRoom(t *testing.T) {
+	t.Run("Public room is posted with its hubs url and private room is not", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpAnnouncer(t, "announcer-token")
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		room := dto.DierctusRoomData{ID: gofakeit.UUID(), Title: "Lobby", Description: "Meet here", HubsID: "abc123", IsPublic: true}
+		privateRoom := dto.DierctusRoomData{ID: gofakeit.UUID(), Title: "Backstage", HubsID: "def456"}
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: room}, http.MethodGet, config.GetDirectusGetRoomURI(room.ID, ""))
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: privateRoom}, http.MethodGet, config.GetDirectusGetRoomURI(privateRoom.ID, ""))
+
+		statuses, patches := &sync.Map{}, &sync.Map{}
+		setUpStatusResponders("s1", http.StatusOK, statuses)
+		setUpItemPatchResponder(config.GetDirectusGetRoomURISimple(room.ID), patches)
+
+		assert.Nil(t, announce.Room(context.Background(), privateRoom.ID, true))
+		_, found := statuses.Load("posted")
+		assert.False(t, found)
+
+		assert.Nil(t, announce.Room(context.Background(), room.ID, true))
+		value, _ := statuses.Load("posted")
+		status, _ := value.(dto.MastodonPostStatusRequest)
+		hubsURL, _ := config.GetHubsURL(room.HubsID)
+		assert.Equal(t, "Lobby\n\nMeet here\n\n"+hubsURL, status.Status)
+		assert.Empty(t, status.MediaIDs)
+
+		patch, _ := patches.Load(config.GetDirectusGetRoomURISimple(room.ID))
+		assert.Equal(t, map[string]interface{}{"mastodon_status_id": "s1"}, patch)
+	})
+}
+
+func TestAnnounceWebhook(t *testing.T) {
+	t.Run("Created event is announced and updates of service fields are not", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpAnnouncer(t, "announcer-token")
+		setUpWebhookSecret(t, testWebhookSecret)
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		event := dto.DirectusEventResponseData{ID: gofakeit.UUID(), Title: "Opening"}
+		var gets int32
+		httpmock.RegisterResponder(http.MethodGet, config.GetDirectusGetEventURI(event.ID, ""), func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&gets, 1)
+			return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusGetResponse{Data: event})
+		})
+		statuses, patches := &sync.Map{}, &sync.Map{}
+		setUpStatusResponders("s1", http.StatusOK, statuses)
+		setUpItemPatchResponder(config.GetDirectusGetEventURISimple(event.ID), patches)
+
+		for _, body := range []string{
+			`{"event":"items.update","collection":"event","keys":["` + event.ID + `"],"payload":{"like_count":3,"mastodon_status_id":"s0"}}`,
+			`{"event":"items.delete","collection":"event","payload":["` + event.ID + `"]}`,
+		} {
+			resp := postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSecret: testWebhookSecret})
+			assert.Equal(t, http.StatusNoContent, resp.Code)
+		}
+		announce.Wait(context.Background())
+		assert.Equal(t, int32(0), atomic.LoadInt32(&gets))
+
+		body := `{"event":"items.create","collection":"event","key":"` + event.ID + `","payload":{"title":"Opening"}}`
+		resp := postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSecret: testWebhookSecret})
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		announce.Wait(context.Background())
+		_, found := statuses.Load("posted")
+		assert.True(t, found)
+		patch, _ := patches.Load(config.GetDirectusGetEventURISimple(event.ID))
+		assert.Equal(t, map[string]interface{}{"mastodon_status_id": "s1"}, patch)
+	})
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	hubsErrorInfo "hubs-cms-go/errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// setUpAssetProxy enables the asset proxy and returns a func restoring the config
+func setUpAssetProxy(cdnBaseURI string) func() {
+	key, base, cdn := config.EnvVariable.AssetSigningKey, config.EnvVariable.AssetBaseURI, config.EnvVariable.AssetCDNBaseURI
+	config.EnvVariable.AssetSigningKey = "0123456789abcdef0123456789abcdef"
+	config.EnvVariable.AssetBaseURI = "https://cms.example.com/"
+	config.EnvVariable.AssetCDNBaseURI = cdnBaseURI
+	return func() {
+		config.EnvVariable.AssetSigningKey, config.EnvVariable.AssetBaseURI, config.EnvVariable.AssetCDNBaseURI = key, base, cdn
+	}
+}
+
+func sendAssetRequest(uri string, header http.Header) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodGet, uri, nil)
+	for key := range header {
+		req.Header.Set(key, header.Get(key))
+	}
+	resp := httptest.NewRecorder()
+	SetupRouter().ServeHTTP(resp, req)
+	return resp
+}
+
+func TestAssetURI(t *testing.T) {
+	t.Run("Private assets are signed and public assets go to the CDN", func(t *testing.T) {
+		Init()
+		defer setUpAssetProxy("https://cdn.example.com/assets/")()
+
+		assetID := gofakeit.UUID()
+		assert.Equal(t, "https://cdn.example.com/assets/"+assetID, config.GetAssetURI(assetID, true))
+
+		link, err := url.Parse(config.GetAssetURI(assetID, false))
+		assert.Nil(t, err)
+		assert.Equal(t, "cms.example.com", link.Host)
+		assert.Equal(t, "/api/hubs-cms/v1/assets/"+assetID, link.Path)
+		exp, err := strconv.ParseInt(link.Query().Get("exp"), 10, 64)
+		assert.Nil(t, err)
+		assert.True(t, config.VerifyAsset(assetID, "", exp, link.Query().Get("sig")))
+		assert.False(t, config.VerifyAsset(gofakeit.UUID(), "", exp, link.Query().Get("sig")))
+		assert.False(t, config.VerifyAsset(assetID, "", exp+1, link.Query().Get("sig")))
+
+		// links are stable within a window and valid for at least ASSET_URL_TTL
+		now := time.Unix(1700000000, 0)
+		ttl := config.EnvVariable.AssetURLTTL
+		assert.Equal(t, config.GetSignedAssetURI(assetID, "", now), config.GetSignedAssetURI(assetID, "", now.Add(time.Second)))
+		link, _ = url.Parse(config.GetSignedAssetURI(assetID, "", now))
+		exp, _ = strconv.ParseInt(link.Query().Get("exp"), 10, 64)
+		assert.True(t, time.Unix(exp, 0).Sub(now) >= ttl)
+		assert.True(t, time.Unix(exp, 0).Sub(now) <= 2*ttl)
+
+		// private rooms of events are signed
+		event := dto.NewEventResponse(dto.DirectusEventResponseData{Rooms: []dto.DirectusRoom{
+			{RoomID: dto.RoomID{ID: "public", Gallery: assetID, IsPublic: true}},
+			{RoomID: dto.RoomID{ID: "private", Gallery: assetID}},
+		}}, nil)
+		if assert.Len(t, event.Rooms, 2) {
+			assert.Equal(t, "https://cdn.example.com/assets/"+assetID, event.Rooms[0].Gallery)
+			assert.True(t, strings.HasPrefix(event.Rooms[1].Gallery, "https://cms.example.com/api/hubs-cms/v1/assets/"+assetID+"?"))
+		}
+
+		// without the signing key the directus asset is linked as before
+		config.EnvVariable.AssetSigningKey, config.EnvVariable.AssetCDNBaseURI = "", ""
+		assert.Equal(t, config.GetDirectusGetAssetURI(assetID), config.GetAssetURI(assetID, false))
+	})
+}
+
+func TestGetAsset(t *testing.T) {
+	t.Run("Signed links stream the asset with its range and cache headers", func(t *testing.T) {
+		Init()
+		client.Setup()
+		defer setUpAssetProxy("")()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		assetID, content := gofakeit.UUID(), "glTF binary content"
+		httpmock.RegisterResponder(http.MethodGet, config.GetDirectusGetAssetURI(assetID), func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") == "" {
+				return httpmock.NewStringResponse(http.StatusUnauthorized, ""), nil
+			}
+			resp := httpmock.NewStringResponse(http.StatusOK, content)
+			if req.Header.Get("Range") == "bytes=0-3" {
+				resp = httpmock.NewStringResponse(http.StatusPartialContent, content[:4])
+				resp.Header.Set("Content-Range", fmt.Sprintf("bytes 0-3/%d", len(content)))
+			}
+			resp.Header.Set("Content-Type", "model/gltf-binary")
+			resp.Header.Set("Accept-Ranges", "bytes")
+			resp.Header.Set("ETag", `"asset-etag"`)
+			return resp, nil
+		})
+
+		link, _ := url.Parse(config.GetAssetURI(assetID, false))
+		resp := sendAssetRequest(link.RequestURI(), nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, content, resp.Body.String())
+		assert.Equal(t, "model/gltf-binary", resp.Header().Get("Content-Type"))
+		assert.Equal(t, `"asset-etag"`, resp.Header().Get("ETag"))
+		assert.True(t, strings.HasPrefix(resp.Header().Get("Cache-Control"), "private, max-age="))
+
+		resp = sendAssetRequest(link.RequestURI(), http.Header{"Range": {"bytes=0-3"}})
+		assert.Equal(t, http.StatusPartialContent, resp.Code)
+		assert.Equal(t, "glTF", resp.Body.String())
+		assert.Equal(t, fmt.Sprintf("bytes 0-3/%d", len(content)), resp.Header().Get("Content-Range"))
+		assert.Equal(t, "bytes", resp.Header().Get("Accept-Ranges"))
+	})
+
+	t.Run("Forged, expired and missing assets are refused", func(t *testing.T) {
+		Init()
+		client.Setup()
+		restore := setUpAssetProxy("")
+		defer restore()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		errorCode := func(resp *httptest.ResponseRecorder) int {
+			errorBody := hubsErrorInfo.ErrorInfo{}
+			json.Unmarshal(resp.Body.Bytes(), &errorBody)
+			return errorBody.ErrorBody.Code
+		}
+
+		assetID := gofakeit.UUID()
+		link, _ := url.Parse(config.GetAssetURI(assetID, false))
+		resp := sendAssetRequest("/api/hubs-cms/v1/assets/"+gofakeit.UUID()+"?"+link.RawQuery, nil)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+		assert.Equal(t, hubsErrorInfo.AssetsInvalidSignature.ErrorBody.Code, errorCode(resp))
+
+		exp := time.Now().Add(-time.Minute).Unix()
+		resp = sendAssetRequest(fmt.Sprintf("/api/hubs-cms/v1/assets/%s?exp=%d&sig=%s", assetID, exp, config.SignAsset(assetID, "", exp)), nil)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+		assert.Equal(t, hubsErrorInfo.AssetsURLExpired.ErrorBody.Code, errorCode(resp))
+
+		resp = sendAssetRequest("/api/hubs-cms/v1/assets/"+assetID, nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		// directus answers 403 for files which do not exist
+		setUpResponder(http.StatusForbidden, dto.DirectusErrorResponseFromHttpStstus(http.StatusForbidden), http.MethodGet, config.GetDirectusGetAssetURI(assetID))
+		resp = sendAssetRequest(link.RequestURI(), nil)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+		assert.Equal(t, hubsErrorInfo.AssetsNotFound.ErrorBody.Code, errorCode(resp))
+
+		restore()
+		resp = sendAssetRequest(link.RequestURI(), nil)
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+}
@@ -0,0 +1,206 @@
+package tests
+
+import (
+	"encoding/json"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	hubsErrorInfo "hubs-cms-go/errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func sendAvatarLibraryRequest(method, uri string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, uri, nil)
+	req.Header.Set(constant.HeaderAuthorization, "Bearer avatar-owner")
+
+	resp := httptest.NewRecorder()
+	SetupRouter().ServeHTTP(resp, req)
+	return resp
+}
+
+func TestSearchPublicAvatars(t *testing.T) {
+	t.Run("Avatars are filtered by tags and source and sorted by popularity", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		avatars := []dto.DirectusAvatarResponseData{}
+		for i := 1; i <= 3; i++ {
+			avatar := AddNumberToDirectusAvatarResponseData(i)
+			avatar.ID, avatar.Tags = gofakeit.UUID(), []string{"vr", "anime"}
+			avatars = append(avatars, avatar)
+		}
+		setUpResponder(http.StatusOK, dto.DirectusGetAvatarsResponse{Meta: dto.DirectusMeta{FilterCount: 3}, Data: avatars},
+			http.MethodGet, config.GetDirectusSearchPublicAvatarURI([]string{"vr", "anime"}, "vroid", "", "title", 0, config.EnvVariable.AvatarPopularLimit))
+		setUpResponder(http.StatusOK, map[string]interface{}{"data": []map[string]interface{}{
+			{"active_avatar": avatars[2].ID, "count": map[string]interface{}{"id": "5"}},
+			{"active_avatar": avatars[1].ID, "count": map[string]interface{}{"id": 2}},
+		}}, http.MethodGet, config.GetDirectusAvatarPopularityURI())
+
+		resp := sendAvatarLibraryRequest(http.MethodGet, "/api/hubs-cms/v1/avatars?tags=VR,anime,vr&source=vroid&sort=popular&start=0&limit=2")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		result := dto.GetAvatarsResponse{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		if assert.Len(t, result.Results, 2) {
+			assert.Equal(t, avatars[2].ID, result.Results[0].ID)
+			assert.Equal(t, int64(5), *result.Results[0].Popularity)
+			assert.Equal(t, avatars[1].ID, result.Results[1].ID)
+			assert.Equal(t, []string{"vr", "anime"}, result.Results[1].Tags)
+		}
+		assert.Empty(t, result.Pages.Prev)
+		next, err := url.Parse(result.Pages.Next)
+		assert.Nil(t, err)
+		assert.Equal(t, "/api/hubs-cms/v1/avatars", next.Path)
+		assert.Equal(t, url.Values{"tags": {"vr,anime"}, "source": {"vroid"}, "sort": {"popular"}, "start": {"2"}, "limit": {"2"}}, next.Query())
+
+		resp = sendAvatarLibraryRequest(http.MethodGet, "/api/hubs-cms/v1/avatars?tags=vr,anime&source=vroid&sort=popular&start=2&limit=2")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		result = dto.GetAvatarsResponse{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		if assert.Len(t, result.Results, 1) {
+			assert.Equal(t, avatars[0].ID, result.Results[0].ID)
+			assert.Equal(t, int64(0), *result.Results[0].Popularity)
+		}
+	})
+
+	t.Run("Popular avatars are ranked among a capped candidate set", func(t *testing.T) {
+		Init()
+		client.Setup()
+		popularLimit := config.EnvVariable.AvatarPopularLimit
+		defer func() { config.EnvVariable.AvatarPopularLimit = popularLimit }()
+		config.EnvVariable.AvatarPopularLimit = 2
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		avatars := []dto.DirectusAvatarResponseData{}
+		for i := 1; i <= 2; i++ {
+			avatar := AddNumberToDirectusAvatarResponseData(i)
+			avatar.ID = gofakeit.UUID()
+			avatars = append(avatars, avatar)
+		}
+		setUpResponder(http.StatusOK, dto.DirectusGetAvatarsResponse{Meta: dto.DirectusMeta{FilterCount: 3}, Data: avatars},
+			http.MethodGet, config.GetDirectusSearchPublicAvatarURI(nil, "", "", "title", 0, 2))
+		setUpResponder(http.StatusOK, map[string]interface{}{"data": []map[string]interface{}{}}, http.MethodGet, config.GetDirectusAvatarPopularityURI())
+
+		resp := sendAvatarLibraryRequest(http.MethodGet, "/api/hubs-cms/v1/avatars?sort=popular&start=0&limit=2")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		result := dto.GetAvatarsResponse{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		assert.Len(t, result.Results, 2)
+		assert.Empty(t, result.Pages.Next)
+	})
+
+	t.Run("Title search is sorted by title and invalid queries are refused", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		avatar := AddNumberToDirectusAvatarResponseData(1)
+		setUpResponder(http.StatusOK, dto.DirectusGetAvatarsResponse{Meta: dto.DirectusMeta{FilterCount: 1}, Data: []dto.DirectusAvatarResponseData{avatar}},
+			http.MethodGet, config.GetDirectusSearchPublicAvatarURI(nil, "", "robot cat", "title", 0, 10))
+
+		resp := sendAvatarLibraryRequest(http.MethodGet, "/api/hubs-cms/v1/avatars?search=robot+cat&sort=title&limit=10")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		result := dto.GetAvatarsResponse{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		if assert.Len(t, result.Results, 1) {
+			assert.Equal(t, avatar.ID, result.Results[0].ID)
+			assert.Nil(t, result.Results[0].Popularity)
+		}
+
+		resp = sendAvatarLibraryRequest(http.MethodGet, "/api/hubs-cms/v1/avatars?tags=vr,no%20spaces")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		errorBody := hubsErrorInfo.ErrorInfo{}
+		json.Unmarshal(resp.Body.Bytes(), &errorBody)
+		assert.Equal(t, hubsErrorInfo.AvatarsInvalidTags.ErrorBody.Code, errorBody.ErrorBody.Code)
+
+		resp = sendAvatarLibraryRequest(http.MethodGet, "/api/hubs-cms/v1/avatars?sort=newest")
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+}
+
+func TestFavoriteAvatar(t *testing.T) {
+	t.Run("Public avatars are favorited once and private avatars of others are refused", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		accountID, mastodonAccount := gofakeit.UUID(), gofakeit.Email()
+		favorite := AddNumberToDirectusAvatarResponseData(1)
+		favorite.ID = gofakeit.UUID()
+		setUpResponder(http.StatusOK, dto.MastodonVerifyCredentialsResponse{ID: gofakeit.UUID(), UserName: gofakeit.Username(), MastodonAccount: mastodonAccount},
+			http.MethodGet, config.GetMastodonVerifyCredentialsURI())
+		setUpResponder(http.StatusOK, dto.DirectusGetAccountResponse{Data: []dto.DirectusAccountResponseData{{
+			ID:              accountID,
+			MastodonAccount: mastodonAccount,
+			FavoriteAvatars: []dto.DirectusAccountFavoriteAvatar{{ID: "7", AvatarID: favorite.ID}},
+		}}}, http.MethodGet, config.GetDirectusGetAccountURI(mastodonAccount))
+
+		patches := &sync.Map{}
+		httpmock.RegisterResponder(http.MethodPatch, config.GetDirectusPatchAccountURI(accountID), func(req *http.Request) (*http.Response, error) {
+			b, _ := ioutil.ReadAll(req.Body)
+			patch := map[string]dto.DirectusM2MPatchRequest{}
+			json.Unmarshal(b, &patch)
+			patches.Store(len(patch["favorite_avatars"].Create), patch["favorite_avatars"])
+			return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusUpsertAccountResponse{Data: dto.DirectusAccountResponseData{ID: accountID, MastodonAccount: mastodonAccount}})
+		})
+
+		public := AddNumberToDirectusAvatarResponseData(2)
+		public.ID, public.IsPublic = gofakeit.UUID(), true
+		setUpResponder(http.StatusOK, dto.DirectusGetAvatarResponse{Data: public}, http.MethodGet, config.GetDirectusSingleAvatarURI(public.ID))
+		private := AddNumberToDirectusAvatarResponseData(3)
+		private.ID, private.IsPublic, private.Owner = gofakeit.UUID(), false, gofakeit.UUID()
+		setUpResponder(http.StatusOK, dto.DirectusGetAvatarResponse{Data: private}, http.MethodGet, config.GetDirectusSingleAvatarURI(private.ID))
+
+		resp := sendAvatarLibraryRequest(http.MethodPost, "/api/hubs-cms/v1/avatars/"+public.ID+"/favorited")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, `{"is_favorite":true}`, resp.Body.String())
+		created, _ := patches.Load(1)
+		assert.Equal(t, map[string]interface{}{"avatar_id": public.ID, "account_id": accountID}, created.(dto.DirectusM2MPatchRequest).Create[0])
+
+		resp = sendAvatarLibraryRequest(http.MethodPost, "/api/hubs-cms/v1/avatars/"+private.ID+"/favorited")
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		// already a favorite, nothing to patch
+		resp = sendAvatarLibraryRequest(http.MethodPost, "/api/hubs-cms/v1/avatars/"+favorite.ID+"/favorited")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, 1, httpmock.GetCallCountInfo()["PATCH "+config.GetDirectusPatchAccountURI(accountID)])
+
+		resp = sendAvatarLibraryRequest(http.MethodPost, "/api/hubs-cms/v1/avatars/"+favorite.ID+"/unfavorited")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, `{"is_favorite":false}`, resp.Body.String())
+		deleted, _ := patches.Load(0)
+		assert.Equal(t, []int64{7}, deleted.(dto.DirectusM2MPatchRequest).Delete)
+
+		setUpResponder(http.StatusOK, dto.DirectusGetAvatarsResponse{Meta: dto.DirectusMeta{FilterCount: 1}, Data: []dto.DirectusAvatarResponseData{favorite}},
+			http.MethodGet, config.GetDirectusGetFavoriteAvatarURI(accountID, []string{favorite.ID}, 0, 10))
+		resp = sendAvatarLibraryRequest(http.MethodGet, "/api/hubs-cms/v1/my-favorite-avatars?limit=10")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		result := dto.GetAvatarsResponse{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		if assert.Len(t, result.Results, 1) {
+			assert.Equal(t, favorite.ID, result.Results[0].ID)
+		}
+	})
+}
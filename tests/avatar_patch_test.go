@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	hubsErrorInfo "hubs-cms-go/errors"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// setUpAvatarOwner answers the credentials of the caller with the directus account accountID
+func setUpAvatarOwner(accountID string) {
+	mastodonAccount := gofakeit.Email()
+	setUpResponder(http.StatusOK, dto.MastodonVerifyCredentialsResponse{ID: gofakeit.UUID(), UserName: gofakeit.Username(), MastodonAccount: mastodonAccount},
+		http.MethodGet, config.GetMastodonVerifyCredentialsURI())
+	setUpResponder(http.StatusOK, dto.DirectusGetAccountResponse{Data: []dto.DirectusAccountResponseData{{ID: accountID, MastodonAccount: mastodonAccount}}},
+		http.MethodGet, config.GetDirectusGetAccountURI(mastodonAccount))
+}
+
+// setUpAvatarItem serves the avatar and records the patches of it and of the accounts using it
+func setUpAvatarItem(avatar dto.DirectusAvatarResponseData, accountIDs []string, patches *sync.Map) {
+	setUpResponder(http.StatusOK, dto.DirectusGetAvatarResponse{Data: avatar}, http.MethodGet, config.GetDirectusSingleAvatarURI(avatar.ID))
+	httpmock.RegisterResponder(http.MethodPatch, config.GetDirectusSingleAvatarURI(avatar.ID), func(req *http.Request) (*http.Response, error) {
+		patch := dto.DirectusPatchAvatarRequest{}
+		b, _ := ioutil.ReadAll(req.Body)
+		json.Unmarshal(b, &patch)
+		patches.Store("avatar", patch)
+
+		patched := avatar
+		if patch.IsPublic != nil {
+			patched.IsPublic = *patch.IsPublic
+		}
+		if patch.Title != nil {
+			patched.Title = *patch.Title
+		}
+		if len(patch.Snapshot) > 0 {
+			patched.Snapshot, patched.SnapshotThumbnails = patch.Snapshot, patch.SnapshotThumbnails
+		}
+		return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusGetAvatarResponse{Data: patched})
+	})
+
+	accounts := []dto.DirectusAccountResponseData{}
+	for _, id := range accountIDs {
+		accounts = append(accounts, dto.DirectusAccountResponseData{ID: id})
+	}
+	setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: accounts}, http.MethodGet, config.GetDirectusAccountsByActiveAvatarURI(avatar.ID))
+	httpmock.RegisterResponder(http.MethodPatch, config.GetDirectusAccountsURI(), func(req *http.Request) (*http.Response, error) {
+		clear := dto.DirectusClearActiveAvatarRequest{}
+		b, _ := ioutil.ReadAll(req.Body)
+		json.Unmarshal(b, &clear)
+		patches.Store("accounts", clear)
+		return httpmock.NewStringResponse(http.StatusOK, `{"data":[]}`), nil
+	})
+}
+
+func sendAvatarRequest(method, avatarID, contentType string, body io.Reader) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/api/hubs-cms/v1/avatars/"+avatarID, body)
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set(constant.HeaderAuthorization, "Bearer avatar-owner")
+
+	resp := httptest.NewRecorder()
+	SetupRouter().ServeHTTP(resp, req)
+	return resp
+}
+
+func TestPatchAvatar(t *testing.T) {
+	t.Run("Owner un-publishes an avatar and other accounts lose it", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		ownerID, otherID := gofakeit.UUID(), gofakeit.UUID()
+		setUpAvatarOwner(ownerID)
+		avatar := AddNumberToDirectusAvatarResponseData(1)
+		avatar.ID, avatar.Owner = gofakeit.UUID(), ownerID
+		patches := &sync.Map{}
+		setUpAvatarItem(avatar, []string{ownerID, otherID}, patches)
+
+		resp := sendAvatarRequest(http.MethodPatch, avatar.ID, "application/json", bytes.NewBufferString(`{}`))
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = sendAvatarRequest(http.MethodPatch, avatar.ID, "application/json", bytes.NewBufferString(`{"is_public":false,"title":"Retired"}`))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		patched := dto.DirectusAvatar{}
+		json.Unmarshal(resp.Body.Bytes(), &patched)
+		assert.False(t, patched.IsPublic)
+		assert.Equal(t, "Retired", patched.Title)
+
+		patch, _ := patches.Load("avatar")
+		assert.Equal(t, false, *patch.(dto.DirectusPatchAvatarRequest).IsPublic)
+		assert.Nil(t, patch.(dto.DirectusPatchAvatarRequest).Source)
+		clear, _ := patches.Load("accounts")
+		assert.Equal(t, []string{otherID}, clear.(dto.DirectusClearActiveAvatarRequest).Keys)
+		assert.Contains(t, clear.(dto.DirectusClearActiveAvatarRequest).Data, "active_avatar")
+	})
+
+	t.Run("Retrying the patch clears the accounts after a failed clear", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		ownerID, otherID := gofakeit.UUID(), gofakeit.UUID()
+		setUpAvatarOwner(ownerID)
+		avatar := AddNumberToDirectusAvatarResponseData(1)
+		avatar.ID, avatar.Owner, avatar.IsPublic = gofakeit.UUID(), ownerID, true
+		patches := &sync.Map{}
+		setUpAvatarItem(avatar, []string{ownerID, otherID}, patches)
+		setUpResponder(http.StatusServiceUnavailable, map[string]interface{}{"errors": []map[string]interface{}{{"message": "unavailable"}}},
+			http.MethodGet, config.GetDirectusAccountsByActiveAvatarURI(avatar.ID))
+
+		resp := sendAvatarRequest(http.MethodPatch, avatar.ID, "application/json", bytes.NewBufferString(`{"is_public":false}`))
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		_, cleared := patches.Load("accounts")
+		assert.False(t, cleared)
+
+		// the avatar is already private when the client retries
+		avatar.IsPublic = false
+		setUpAvatarItem(avatar, []string{ownerID, otherID}, patches)
+		resp = sendAvatarRequest(http.MethodPatch, avatar.ID, "application/json", bytes.NewBufferString(`{"is_public":false}`))
+		assert.Equal(t, http.StatusOK, resp.Code)
+		clear, _ := patches.Load("accounts")
+		assert.Equal(t, []string{otherID}, clear.(dto.DirectusClearActiveAvatarRequest).Keys)
+	})
+
+	t.Run("Snapshot is replaced and other accounts are refused", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		ownerID := gofakeit.UUID()
+		setUpAvatarOwner(ownerID)
+		avatar := AddNumberToDirectusAvatarResponseData(1)
+		avatar.ID, avatar.Owner = gofakeit.UUID(), ownerID
+		patches := &sync.Map{}
+		setUpAvatarItem(avatar, nil, patches)
+		setUpAvatarUsage(ownerID, []dto.DirectusAvatarResponseData{avatar}, nil)
+		var uploads int32
+		setUpUploadResponder(&uploads)
+
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("snapshot", "snapshot.webp")
+		part.Write([]byte("not an image"))
+		writer.Close()
+		resp := sendAvatarRequest(http.MethodPatch, avatar.ID, writer.FormDataContentType(), body)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		errorBody := hubsErrorInfo.ErrorInfo{}
+		json.Unmarshal(resp.Body.Bytes(), &errorBody)
+		assert.Equal(t, hubsErrorInfo.AvatarsInvalidSnapshot.ErrorBody.Code, errorBody.ErrorBody.Code)
+		assert.Equal(t, int32(0), uploads)
+
+		body = &bytes.Buffer{}
+		writer = multipart.NewWriter(body)
+		part, _ = writer.CreateFormFile("snapshot", "snapshot.png")
+		part.Write(encodePNG(8))
+		writer.Close()
+		resp = sendAvatarRequest(http.MethodPatch, avatar.ID, writer.FormDataContentType(), body)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, int32(1), uploads)
+		patch, _ := patches.Load("avatar")
+		assert.NotEmpty(t, patch.(dto.DirectusPatchAvatarRequest).Snapshot)
+		assert.Nil(t, patch.(dto.DirectusPatchAvatarRequest).IsPublic)
+		_, cleared := patches.Load("accounts")
+		assert.False(t, cleared)
+
+		other := AddNumberToDirectusAvatarResponseData(2)
+		other.ID = gofakeit.UUID()
+		setUpAvatarItem(other, nil, patches)
+		resp = sendAvatarRequest(http.MethodPatch, other.ID, "application/json", bytes.NewBufferString(`{"title":"Mine"}`))
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+}
+
+func TestDeletePublicAvatar(t *testing.T) {
+	t.Run("Public avatar is un-published before it is deleted", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		ownerID, otherID := gofakeit.UUID(), gofakeit.UUID()
+		setUpAvatarOwner(ownerID)
+		avatar := AddNumberToDirectusAvatarResponseData(1)
+		avatar.ID, avatar.Owner = gofakeit.UUID(), ownerID
+		patches := &sync.Map{}
+		setUpAvatarItem(avatar, []string{ownerID, otherID}, patches)
+		setUpResponder(http.StatusNoContent, nil, http.MethodDelete, config.GetDirectusSingleAvatarURI(avatar.ID))
+
+		resp := sendAvatarRequest(http.MethodDelete, avatar.ID, "", nil)
+		assert.Equal(t, http.StatusConflict, resp.Code)
+		errorBody := hubsErrorInfo.ErrorInfo{}
+		json.Unmarshal(resp.Body.Bytes(), &errorBody)
+		assert.Equal(t, hubsErrorInfo.AvatarsDeletePublic.ErrorBody.Code, errorBody.ErrorBody.Code)
+		assert.Equal(t, 0, httpmock.GetCallCountInfo()["DELETE "+config.GetDirectusSingleAvatarURI(avatar.ID)])
+
+		avatar.IsPublic = false
+		setUpAvatarItem(avatar, []string{ownerID, otherID}, patches)
+		resp = sendAvatarRequest(http.MethodDelete, avatar.ID, "", nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		clear, _ := patches.Load("accounts")
+		assert.Equal(t, []string{ownerID, otherID}, clear.(dto.DirectusClearActiveAvatarRequest).Keys)
+		assert.Equal(t, 1, httpmock.GetCallCountInfo()["DELETE "+config.GetDirectusSingleAvatarURI(avatar.ID)])
+	})
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	hubsErrorInfo "hubs-cms-go/errors"
+	"hubs-cms-go/service"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// setUpAvatarUsage answers the avatars owned by the account and the filesize of the files in fileSizes
+func setUpAvatarUsage(accountID string, avatars []dto.DirectusAvatarResponseData, fileSizes map[string]int64) {
+	setUpResponder(http.StatusOK, dto.DirectusGetAvatarsResponse{Data: avatars}, http.MethodGet, config.GetDirectusGetAvatarFilesURI(accountID))
+	httpmock.RegisterResponder(http.MethodGet, "=~^"+config.GetDirectusUploadAssetURI()+`\?`, func(req *http.Request) (*http.Response, error) {
+		files := []dto.DirectusFileSize{}
+		for _, id := range strings.Split(req.URL.Query().Get("filter[id][_in]"), ",") {
+			if size, found := fileSizes[id]; found {
+				files = append(files, dto.DirectusFileSize{ID: id, FileSize: json.Number(fmt.Sprint(size))})
+			}
+		}
+		return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusGetFileSizesResponse{Data: files})
+	})
+}
+
+// setUpQuotaAccount answers the credentials of the caller with the directus account accountID and its quota
+func setUpQuotaAccount(accountID string, quota dto.AvatarQuota) {
+	mastodonAccount := gofakeit.Email()
+	setUpResponder(http.StatusOK, dto.MastodonVerifyCredentialsResponse{ID: gofakeit.UUID(), UserName: gofakeit.Username(), MastodonAccount: mastodonAccount},
+		http.MethodGet, config.GetMastodonVerifyCredentialsURI())
+	setUpResponder(http.StatusOK, dto.DirectusGetAccountResponse{Data: []dto.DirectusAccountResponseData{{ID: accountID, MastodonAccount: mastodonAccount, AvatarQuota: quota}}},
+		http.MethodGet, config.GetDirectusGetAccountURI(mastodonAccount))
+}
+
+func TestAvatarQuota(t *testing.T) {
+	t.Run("Uploads over the avatar count or the storage quota are refused", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		var uploads int32
+		setUpUploadResponder(&uploads)
+		fields := map[string]string{"source": "upload", "is_public": "false"}
+		files := map[string][]byte{"snapshot": encodePNG(8), "glb_file": buildGLB(avatarDocument(10, encodePNG(8)))}
+
+		// the override of the account wins over AVATAR_QUOTA_COUNT
+		accountID, two := gofakeit.UUID(), int64(2)
+		setUpQuotaAccount(accountID, dto.AvatarQuota{AvatarQuotaCount: &two})
+		avatars := []dto.DirectusAvatarResponseData{AddNumberToDirectusAvatarResponseData(1), AddNumberToDirectusAvatarResponseData(2)}
+		setUpAvatarUsage(accountID, avatars, nil)
+
+		resp := postAvatar(fields, files)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+		errorBody := hubsErrorInfo.ErrorInfo{}
+		json.Unmarshal(resp.Body.Bytes(), &errorBody)
+		assert.Equal(t, hubsErrorInfo.AvatarsQuotaExceeded.ErrorBody.Code, errorBody.ErrorBody.Code)
+
+		// one avatar left but only 10 bytes
+		quotaMB := config.EnvVariable.AvatarQuotaMB
+		defer func() { config.EnvVariable.AvatarQuotaMB = quotaMB }()
+		config.EnvVariable.AvatarQuotaMB = 1
+		accountID = gofakeit.UUID()
+		setUpQuotaAccount(accountID, dto.AvatarQuota{})
+		avatar := AddNumberToDirectusAvatarResponseData(1)
+		avatar.SnapshotThumbnails = map[string]string{"128": "thumbnail-1", "512": avatar.Snapshot}
+		setUpAvatarUsage(accountID, []dto.DirectusAvatarResponseData{avatar}, map[string]int64{avatar.Snapshot: 1<<20 - 1000, "thumbnail-1": 490, avatar.GLB: 500})
+
+		resp = postAvatar(fields, files)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+		assert.Equal(t, int32(0), atomic.LoadInt32(&uploads))
+
+		usageReq, _ := http.NewRequest(http.MethodGet, "/api/hubs-cms/v1/me/usage", nil)
+		usageReq.Header.Set(constant.HeaderAuthorization, "Bearer avatar-owner")
+		usageResp := httptest.NewRecorder()
+		SetupRouter().ServeHTTP(usageResp, usageReq)
+		assert.Equal(t, http.StatusOK, usageResp.Code)
+		usage := dto.AvatarUsage{}
+		json.Unmarshal(usageResp.Body.Bytes(), &usage)
+		assert.Equal(t, dto.AvatarUsage{AvatarCount: 1, Bytes: 1<<20 - 10, MaxAvatarCount: config.EnvVariable.AvatarQuotaCount, MaxBytes: 1 << 20}, usage)
+	})
+
+	t.Run("Admins override the quota of an account", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		adminToken := config.EnvVariable.AdminToken
+		defer func() { config.EnvVariable.AdminToken = adminToken }()
+		config.EnvVariable.AdminToken = "admin-token"
+
+		accountID := gofakeit.UUID()
+		setUpAvatarUsage(accountID, nil, nil)
+		var patched []byte
+		httpmock.RegisterResponder(http.MethodPatch, config.GetDirectusPatchAccountURI(accountID), func(req *http.Request) (*http.Response, error) {
+			patched, _ = ioutil.ReadAll(req.Body)
+			quota := dto.AvatarQuota{}
+			json.Unmarshal(patched, &quota)
+			return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusUpsertAccountResponse{Data: dto.DirectusAccountResponseData{ID: accountID, MastodonAccount: gofakeit.Email(), AvatarQuota: quota}})
+		})
+
+		sendQuota := func(body string) *httptest.ResponseRecorder {
+			req, _ := http.NewRequest(http.MethodPut, "/api/hubs-cms/v1/admin/accounts/"+accountID+"/avatar-quota", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set(constant.HeaderAuthorization, "Bearer admin-token")
+			resp := httptest.NewRecorder()
+			SetupRouter().ServeHTTP(resp, req)
+			return resp
+		}
+
+		resp := sendQuota(`{"avatar_quota_count":-1}`)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = sendQuota(`{"avatar_quota_count":50,"avatar_quota_mb":null}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, `{"avatar_quota_count":50,"avatar_quota_mb":null}`, string(patched))
+		usage := dto.AvatarUsage{}
+		json.Unmarshal(resp.Body.Bytes(), &usage)
+		assert.Equal(t, int64(50), usage.MaxAvatarCount)
+		assert.Equal(t, config.EnvVariable.AvatarQuotaMB<<20, usage.MaxBytes)
+	})
+
+	t.Run("Files without a filesize count as 0", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		setUpResponder(http.StatusOK, map[string]interface{}{"data": []map[string]interface{}{{"id": "file-1", "filesize": nil}, {"id": "file-2", "filesize": "100"}}},
+			http.MethodGet, "=~^"+config.GetDirectusUploadAssetURI()+`\?`)
+
+		fileSizes, err := service.GetFileSizes(context.Background(), map[string]bool{"file-1": true, "file-2": true})
+		assert.Nil(t, err)
+		assert.Equal(t, map[string]int64{"file-1": 0, "file-2": 100}, fileSizes)
+	})
+}
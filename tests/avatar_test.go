@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"errors"
 	"hubs-cms-go/client"
 	"hubs-cms-go/config"
@@ -39,7 +40,7 @@ func TestGetDirectusAvatar(t *testing.T) {
 			config.GetDirectusGetAvatarURI(testAvatarID),
 			getDirectusAvatarResponder)
 
-		result, err := service.GetDirectusAvatar(testAvatarID, false)
+		result, err := service.GetDirectusAvatar(context.Background(), testAvatarID)
 		assert.Nil(t, err)
 
 		directusAssetsHost := getAssetPath()
@@ -72,7 +73,7 @@ func TestGetDirectusAvatarError(t *testing.T) {
 			config.GetDirectusGetAvatarURI(testAvatarID),
 			testErrorResponder)
 
-		emptyResult, responseErr := service.GetDirectusAvatar(testAvatarID, false)
+		emptyResult, responseErr := service.GetDirectusAvatar(context.Background(), testAvatarID)
 		assert.Equal(t, dto.DirectusAvatar{}, emptyResult)
 		assert.True(t, strings.Contains(responseErr.Error(), expectResult))
 	})
@@ -109,7 +110,7 @@ func TestGetPublicAvatarsWithPagination1(t *testing.T) {
 			config.GetDirectusGetPublicAvatarURI(start, limit),
 			getDirectusAvatarResponder)
 
-		result, err := service.GetPublicAvatars(start, limit, false)
+		result, err := service.GetPublicAvatars(context.Background(), start, limit)
 
 		assert.Equal(t, hubsErrorInfo.ErrorInfo{}, err)
 		assert.Equal(t, len(mockData), len(result.Results))
@@ -149,7 +150,7 @@ func TestGetPublicAvatarsWithPagination2(t *testing.T) {
 			config.GetDirectusGetPublicAvatarURI(start, limit),
 			getDirectusAvatarResponder)
 
-		result, err := service.GetPublicAvatars(start, limit, false)
+		result, err := service.GetPublicAvatars(context.Background(), start, limit)
 
 		assert.Equal(t, hubsErrorInfo.ErrorInfo{}, err)
 		assert.Equal(t, len(mockData), len(result.Results))
@@ -189,7 +190,7 @@ func TestGetPublicAvatarsWithPagination3(t *testing.T) {
 			config.GetDirectusGetPublicAvatarURI(start, limit),
 			getDirectusAvatarResponder)
 
-		result, err := service.GetPublicAvatars(start, limit, false)
+		result, err := service.GetPublicAvatars(context.Background(), start, limit)
 
 		assert.Equal(t, hubsErrorInfo.ErrorInfo{}, err)
 		assert.Equal(t, len(mockData), len(result.Results))
@@ -215,7 +216,7 @@ func TestGetPublicAvatarsError(t *testing.T) {
 			config.GetDirectusGetPublicAvatarURI(start, limit),
 			testErrorResponder)
 
-		emptyResult, responseErr := service.GetPublicAvatars(start, limit, false)
+		emptyResult, responseErr := service.GetPublicAvatars(context.Background(), start, limit)
 		assert.Equal(t, dto.GetAvatarsResponse{}, emptyResult)
 		assert.Equal(t, hubsErrorInfo.InternalError, responseErr)
 	})
@@ -252,7 +253,7 @@ func TestGetMyAvatars(t *testing.T) {
 			config.GetDirectusGetMyAvatarURI(testAccountID, start, limit),
 			getDirectusAvatarResponder)
 
-		result, err := service.GetMyAvatars(testAccountID, start, limit, false)
+		result, err := service.GetMyAvatars(context.Background(), testAccountID, start, limit)
 
 		assert.Equal(t, hubsErrorInfo.ErrorInfo{}, err)
 		assert.Equal(t, len(mockData), len(result.Results))
@@ -280,7 +281,7 @@ func TestGetMyAvatarsError(t *testing.T) {
 			config.GetDirectusGetMyAvatarURI(accountID, start, limit),
 			testErrorResponder)
 
-		emptyResult, responseErr := service.GetMyAvatars(accountID, start, limit, false)
+		emptyResult, responseErr := service.GetMyAvatars(context.Background(), accountID, start, limit)
 		assert.Equal(t, dto.GetAvatarsResponse{}, emptyResult)
 		assert.Equal(t, hubsErrorInfo.InternalError, responseErr)
 	})
@@ -310,7 +311,7 @@ func TestImportAsset(t *testing.T) {
 
 		mockAssetURL := getAssetPath() + "Test-GLB"
 
-		glbID, err := service.ImportAsset(mockAssetURL, false)
+		glbID, err := service.ImportAsset(context.Background(), mockAssetURL)
 		assert.Nil(t, err)
 		assert.Equal(t, mockDirectusUploadAssetResponse.Data.ID, glbID)
 	})
@@ -333,7 +334,7 @@ func TestImportAssetError(t *testing.T) {
 
 		mockAssetURL := getAssetPath() + "Test-GLB"
 
-		glbID, err := service.ImportAsset(mockAssetURL, false)
+		glbID, err := service.ImportAsset(context.Background(), mockAssetURL)
 
 		assert.True(t, strings.Contains(err.Error(), expectResult))
 		assert.Equal(t, "", glbID)
@@ -368,7 +369,7 @@ func TestCreateAvatar(t *testing.T) {
 			IsPublic: true,
 		}
 
-		createdAvatar, err := service.CreateAvatar(mockRequest, false)
+		createdAvatar, err := service.CreateAvatar(context.Background(), mockRequest)
 
 		directusAssetsHost := getAssetPath()
 
@@ -404,7 +405,7 @@ func TestCreateAvatarError(t *testing.T) {
 			Title:    "Test Avatar",
 			IsPublic: true,
 		}
-		emptyAvatar, err := service.CreateAvatar(mockRequest, false)
+		emptyAvatar, err := service.CreateAvatar(context.Background(), mockRequest)
 
 		assert.True(t, strings.Contains(err.Error(), expectResult))
 		assert.Equal(t, dto.DirectusAvatar{}, emptyAvatar)
@@ -433,7 +434,7 @@ func TestGetAvatar(t *testing.T) {
 			config.GetDirectusSingleAvatarURI(testAvatarID),
 			getDirectusAvatarResponder)
 
-		getAvatar, err := service.GetAvatar(testAvatarID, false)
+		getAvatar, err := service.GetAvatar(context.Background(), testAvatarID)
 		assert.Nil(t, err)
 		assert.Equal(t, mockAvatarResponse.Data.ID, getAvatar.Data.ID)
 		assert.Equal(t, mockAvatarResponse.Data.IsPublic, getAvatar.Data.IsPublic)
@@ -457,7 +458,7 @@ func TestGetAvatarError(t *testing.T) {
 			config.GetDirectusSingleAvatarURI(testAvatarID),
 			testErrorResponder)
 
-		emptyAvatar, err := service.GetAvatar(testAvatarID, false)
+		emptyAvatar, err := service.GetAvatar(context.Background(), testAvatarID)
 		assert.True(t, strings.Contains(err.Error(), expectResult))
 		assert.Equal(t, dto.DirectusGetAvatarResponse{}, emptyAvatar)
 	})
@@ -485,7 +486,7 @@ func TestDeleteAvatar(t *testing.T) {
 			config.GetDirectusSingleAvatarURI(testAvatarID),
 			deleteAvatarResponder)
 
-		result := service.DeleteAvatar(mockDeleteAvatarRequest.ID, false)
+		result := service.DeleteAvatar(context.Background(), mockDeleteAvatarRequest.ID)
 		assert.Nil(t, result)
 	})
 }
@@ -507,7 +508,7 @@ func TestDeleteAvatarError(t *testing.T) {
 			config.GetDirectusSingleAvatarURI(testAvatarID),
 			testErrorResponder)
 
-		result := service.DeleteAvatar(testAvatarID, false)
+		result := service.DeleteAvatar(context.Background(), testAvatarID)
 		assert.True(t, strings.Contains(result.Error(), expectResult))
 	})
 }
@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"context"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/service"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpSlowRoomResponder(roomID string, delay time.Duration) {
+	httpmock.RegisterResponder(http.MethodGet, config.GetDirectusGetRoomURI(roomID, ""),
+		func(req *http.Request) (*http.Response, error) {
+			time.Sleep(delay)
+			return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusGetResponse{Data: dto.DierctusRoomData{ID: roomID}})
+		})
+}
+
+func TestUpstreamTimeout(t *testing.T) {
+	t.Run("Directus call is bounded by DIRECTUS_TIMEOUT", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		directusTimeout := config.EnvVariable.DirectusTimeout
+		config.EnvVariable.DirectusTimeout = 50 * time.Millisecond
+		defer func() { config.EnvVariable.DirectusTimeout = directusTimeout }()
+
+		testRoomID := gofakeit.UUID()
+		setUpSlowRoomResponder(testRoomID, time.Second)
+
+		startTime := time.Now()
+		_, err := service.GetDirectusRoom(context.Background(), testRoomID, "")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, int64(time.Since(startTime)), int64(500*time.Millisecond))
+	})
+}
+
+func TestUpstreamCancellation(t *testing.T) {
+	t.Run("Directus call stops when the caller goes away", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testRoomID := gofakeit.UUID()
+		setUpSlowRoomResponder(testRoomID, time.Second)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel)
+
+		startTime := time.Now()
+		_, err := service.GetDirectusRoom(ctx, testRoomID, "")
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, int64(time.Since(startTime)), int64(500*time.Millisecond))
+	})
+}
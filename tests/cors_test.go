@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"hubs-cms-go/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORSAllowedOrigin(t *testing.T) {
+	t.Run("Echo allowed origin with wildcard subdomain", func(t *testing.T) {
+		testRouter := Init()
+		config.EnvVariable.CORSAllowedOrigins = []string{"https://verse.viveport.com", "https://*.viveport.com"}
+		defer func() { config.EnvVariable.CORSAllowedOrigins = nil }()
+
+		req, _ := http.NewRequest(http.MethodGet, "/version", nil)
+		req.Header.Set("Origin", "https://beta.viveport.com")
+
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "https://beta.viveport.com", resp.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", resp.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Contains(t, resp.Header().Values("Vary"), "Origin")
+	})
+}
+
+func TestCORSDisallowedOrigin(t *testing.T) {
+	t.Run("Skip CORS headers for disallowed origin", func(t *testing.T) {
+		testRouter := Init()
+		config.EnvVariable.CORSAllowedOrigins = []string{"https://*.viveport.com"}
+		defer func() { config.EnvVariable.CORSAllowedOrigins = nil }()
+
+		for _, origin := range []string{"https://viveport.com.evil.com", "https://evil.com", "http://beta.viveport.com"} {
+			req, _ := http.NewRequest(http.MethodGet, "/version", nil)
+			req.Header.Set("Origin", origin)
+
+			resp := httptest.NewRecorder()
+			testRouter.ServeHTTP(resp, req)
+
+			assert.Equal(t, http.StatusOK, resp.Code)
+			assert.Equal(t, "", resp.Header().Get("Access-Control-Allow-Origin"), origin)
+		}
+	})
+
+	t.Run("Reject preflight for disallowed origin", func(t *testing.T) {
+		testRouter := Init()
+		config.EnvVariable.CORSAllowedOrigins = []string{"https://*.viveport.com"}
+		defer func() { config.EnvVariable.CORSAllowedOrigins = nil }()
+
+		req, _ := http.NewRequest(http.MethodOptions, "/api/hubs-cms/v1/events", nil)
+		req.Header.Set("Origin", "https://evil.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+}
+
+func TestCORSPreflight(t *testing.T) {
+	t.Run("Cache preflight response", func(t *testing.T) {
+		testRouter := Init()
+		config.EnvVariable.CORSAllowedOrigins = []string{"https://verse.viveport.com"}
+		config.EnvVariable.CORSMaxAge = 300
+		defer func() { config.EnvVariable.CORSAllowedOrigins = nil }()
+
+		req, _ := http.NewRequest(http.MethodOptions, "/api/hubs-cms/v1/events", nil)
+		req.Header.Set("Origin", "https://verse.viveport.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+		assert.Equal(t, "https://verse.viveport.com", resp.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "300", resp.Header().Get("Access-Control-Max-Age"))
+		assert.Contains(t, resp.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+	})
+}
+
+func TestCORSDefaultOrigins(t *testing.T) {
+	t.Run("Default origins by environment", func(t *testing.T) {
+		Init()
+		config.EnvVariable.CORSAllowedOrigins = nil
+		defer func() { config.EnvVariable.Environment = "DEVELOP" }()
+
+		config.EnvVariable.Environment = "DEVELOP"
+		assert.True(t, config.IsCORSOriginAllowed("https://any.origin.com"))
+
+		config.EnvVariable.Environment = "PRODUCTION"
+		assert.True(t, config.IsCORSOriginAllowed("https://test.com"))
+		assert.False(t, config.IsCORSOriginAllowed("https://any.origin.com"))
+	})
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	t.Run("Answer a literal * without credentials", func(t *testing.T) {
+		testRouter := Init()
+		config.EnvVariable.CORSAllowedOrigins = []string{"https://verse.viveport.com", "*"}
+		defer func() { config.EnvVariable.CORSAllowedOrigins = nil }()
+
+		req, _ := http.NewRequest(http.MethodGet, "/version", nil)
+		req.Header.Set("Origin", "https://any.origin.com")
+
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, "*", resp.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "", resp.Header().Get("Access-Control-Allow-Credentials"))
+
+		req.Header.Set("Origin", "https://verse.viveport.com")
+		resp = httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, "https://verse.viveport.com", resp.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", resp.Header().Get("Access-Control-Allow-Credentials"))
+	})
+}
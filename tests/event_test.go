@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"hubs-cms-go/cache"
@@ -34,6 +35,7 @@ func Init() *gin.Engine {
 	config.Setup()
 	cache.Setup()
 	logger.Setup(config.EnvVariable.LogLevel)
+	client.ResetCircuitBreakers()
 	return SetupRouter()
 }
 
@@ -247,8 +249,8 @@ func TestGetEvent(t *testing.T) {
 		testVideoId := dto.DirectusVideo{
 			dto.DirectusVideoID{
 				CoverImage: "http://testlink/cover_image.png",
-				Mp4:        "http://testlink/test.mp4",
-				Webm:       "http://testlink/test.webm",
+				Mp4:        dto.DirectusVideoFile{ID: "http://testlink/test.mp4"},
+				Webm:       dto.DirectusVideoFile{ID: "http://testlink/test.webm"},
 			},
 		}
 		testImageId := dto.DirectusFilesID{
@@ -424,8 +426,8 @@ func genDirEvent(id string, viewCount string) *dto.DirectusEventResponseData {
 	testVideoId := dto.DirectusVideo{
 		dto.DirectusVideoID{
 			CoverImage: "http://testlink/cover_image.png",
-			Mp4:        "http://testlink/test.mp4",
-			Webm:       "http://testlink/test.webm",
+			Mp4:        dto.DirectusVideoFile{ID: "http://testlink/test.mp4"},
+			Webm:       dto.DirectusVideoFile{ID: "http://testlink/test.webm"},
 		},
 	}
 	testImageId := dto.DirectusFilesID{
@@ -674,8 +676,8 @@ func TestPostEventViewCount(t *testing.T) {
 		testVideoId := dto.DirectusVideo{
 			dto.DirectusVideoID{
 				CoverImage: "http://testlink/cover_image.png",
-				Mp4:        "http://testlink/test.mp4",
-				Webm:       "http://testlink/test.webm",
+				Mp4:        dto.DirectusVideoFile{ID: "http://testlink/test.mp4"},
+				Webm:       dto.DirectusVideoFile{ID: "http://testlink/test.webm"},
 			},
 		}
 		testImageId := dto.DirectusFilesID{
@@ -832,7 +834,7 @@ func TestPatchDirectusEvent(t *testing.T) {
 			LikeCount: "100",
 		}
 		// verify service flow
-		err := service.PatchDirectusEvent(testID, &likeCountPatchBody)
+		err := service.PatchDirectusEvent(context.Background(), testID, &likeCountPatchBody)
 		assert.Nil(t, err)
 	})
 }
@@ -0,0 +1,194 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	hubsErrorInfo "hubs-cms-go/errors"
+	"hubs-cms-go/glb"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildGLB packs the document and the binary chunk into a binary glTF container
+func buildGLB(doc map[string]interface{}, bin []byte) []byte {
+	pad := func(b []byte, c byte) []byte {
+		for len(b)%4 != 0 {
+			b = append(b, c)
+		}
+		return b
+	}
+	jsonChunk, _ := json.Marshal(doc)
+	jsonChunk = pad(jsonChunk, ' ')
+	bin = pad(bin, 0)
+
+	out := &bytes.Buffer{}
+	length := 12 + 8 + len(jsonChunk)
+	if len(bin) > 0 {
+		length += 8 + len(bin)
+	}
+	binary.Write(out, binary.LittleEndian, []uint32{0x46546C67, 2, uint32(length), uint32(len(jsonChunk)), 0x4E4F534A})
+	out.Write(jsonChunk)
+	if len(bin) > 0 {
+		binary.Write(out, binary.LittleEndian, []uint32{uint32(len(bin)), 0x004E4942})
+		out.Write(bin)
+	}
+	return out.Bytes()
+}
+
+func encodePNG(size int) []byte {
+	b := &bytes.Buffer{}
+	png.Encode(b, image.NewRGBA(image.Rect(0, 0, size, size)))
+	return b.Bytes()
+}
+
+// avatarDocument is a model with a Head node, a mesh of triangles indices/3 and an embedded texture
+func avatarDocument(triangles int, texture []byte) (map[string]interface{}, []byte) {
+	return map[string]interface{}{
+		"asset":       map[string]interface{}{"version": "2.0"},
+		"buffers":     []interface{}{map[string]interface{}{"byteLength": len(texture)}},
+		"bufferViews": []interface{}{map[string]interface{}{"buffer": 0, "byteLength": len(texture)}},
+		"accessors":   []interface{}{map[string]interface{}{"count": 4}, map[string]interface{}{"count": triangles * 3}},
+		"meshes": []interface{}{map[string]interface{}{"primitives": []interface{}{
+			map[string]interface{}{"attributes": map[string]interface{}{"POSITION": 0}, "indices": 1},
+		}}},
+		"nodes":  []interface{}{map[string]interface{}{"name": "AvatarRoot", "children": []int{1}}, map[string]interface{}{"name": "Head", "mesh": 0}},
+		"images": []interface{}{map[string]interface{}{"bufferView": 0, "mimeType": "image/png"}},
+	}, texture
+}
+
+func TestValidateGLB(t *testing.T) {
+	limits := glb.Limits{MaxSize: 1 << 20, MaxTriangles: 1000, MaxTextures: 1, MaxTextureSize: 64, RequiredNodes: []string{"Head"}}
+
+	t.Run("Valid avatar", func(t *testing.T) {
+		stats, err := glb.Validate(buildGLB(avatarDocument(500, encodePNG(64))), limits)
+		assert.Nil(t, err)
+		assert.Equal(t, glb.Stats{Triangles: 500, Textures: 1, Nodes: 2}, stats)
+	})
+
+	t.Run("Skinned avatar without the required nodes", func(t *testing.T) {
+		doc, bin := avatarDocument(500, encodePNG(8))
+		doc["nodes"] = []interface{}{map[string]interface{}{"name": "mixamorig:Head"}, map[string]interface{}{"name": "Body", "mesh": 0, "skin": 0}}
+		doc["skins"] = []interface{}{map[string]interface{}{"joints": []int{0}}}
+		_, err := glb.Validate(buildGLB(doc, bin), limits)
+		assert.Nil(t, err)
+	})
+
+	modify := func(fn func(doc map[string]interface{})) []byte {
+		doc, bin := avatarDocument(500, encodePNG(8))
+		fn(doc)
+		return buildGLB(doc, bin)
+	}
+	valid := buildGLB(avatarDocument(500, encodePNG(8)))
+	badMagic := append([]byte("gltf"), valid[4:]...)
+	badVersion := append([]byte{}, valid...)
+	binary.LittleEndian.PutUint32(badVersion[4:8], 1)
+	headerOnly := append([]byte{}, valid[:12]...)
+	binary.LittleEndian.PutUint32(headerOnly[8:12], 12)
+
+	for _, test := range []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"Too large", make([]byte, limits.MaxSize+1), glb.ErrTooLarge},
+		{"Bad magic", badMagic, glb.ErrInvalid},
+		{"Bad version", badVersion, glb.ErrInvalid},
+		{"Truncated", valid[:len(valid)-4], glb.ErrInvalid},
+		{"Missing JSON chunk", headerOnly, glb.ErrInvalid},
+		{"Asset version 1.0", modify(func(doc map[string]interface{}) { doc["asset"] = map[string]interface{}{"version": "1.0"} }), glb.ErrInvalid},
+		{"Missing Head", modify(func(doc map[string]interface{}) { doc["nodes"] = []interface{}{map[string]interface{}{"name": "Body", "mesh": 0}} }), glb.ErrMissingNodes},
+		{"Mesh out of range", modify(func(doc map[string]interface{}) { doc["nodes"] = []interface{}{map[string]interface{}{"name": "Head", "mesh": 3}} }), glb.ErrInvalid},
+		{"BufferView out of range", modify(func(doc map[string]interface{}) {
+			doc["bufferViews"] = []interface{}{map[string]interface{}{"buffer": 0, "byteOffset": 8, "byteLength": 1 << 30}}
+		}), glb.ErrInvalid},
+		{"Too many triangles", buildGLB(avatarDocument(1001, encodePNG(8))), glb.ErrTooManyTriangles},
+		{"Too many textures", modify(func(doc map[string]interface{}) {
+			doc["images"] = append(doc["images"].([]interface{}), map[string]interface{}{"uri": "data:image/png;base64," + base64.StdEncoding.EncodeToString(encodePNG(8))})
+		}), glb.ErrTextureLimit},
+		{"Too large texture", buildGLB(avatarDocument(500, encodePNG(65))), glb.ErrTextureLimit},
+		{"External texture", modify(func(doc map[string]interface{}) {
+			doc["images"] = []interface{}{map[string]interface{}{"uri": "https://example.com/texture.png"}}
+		}), glb.ErrExternalResource},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := glb.Validate(test.data, limits)
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+}
+
+func postAvatar(fields map[string]string, files map[string][]byte) *httptest.ResponseRecorder {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for k, v := range fields {
+		writer.WriteField(k, v)
+	}
+	for k, v := range files {
+		part, _ := writer.CreateFormFile(k, k+".bin")
+		part.Write(v)
+	}
+	writer.Close()
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/hubs-cms/v1/avatars", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set(constant.HeaderAuthorization, "Bearer glb-upload")
+
+	resp := httptest.NewRecorder()
+	SetupRouter().ServeHTTP(resp, req)
+	return resp
+}
+
+func TestCreateAvatarWithGLBFile(t *testing.T) {
+	t.Run("Invalid glb is rejected before uploading and valid glb is uploaded", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		accountID, mastodonAccount := gofakeit.UUID(), gofakeit.Email()
+		setUpResponder(http.StatusOK, dto.MastodonVerifyCredentialsResponse{ID: gofakeit.UUID(), UserName: gofakeit.Username(), MastodonAccount: mastodonAccount},
+			http.MethodGet, config.GetMastodonVerifyCredentialsURI())
+		setUpResponder(http.StatusOK, dto.DirectusGetAccountResponse{Data: []dto.DirectusAccountResponseData{{ID: accountID, MastodonAccount: mastodonAccount}}},
+			http.MethodGet, config.GetDirectusGetAccountURI(mastodonAccount))
+		setUpAvatarUsage(accountID, nil, nil)
+		setUpResponder(http.StatusOK, dto.DirectusUploadAssetResponse{Data: dto.DirectusUploadAssetResponseData{ID: gofakeit.UUID()}},
+			http.MethodPost, config.GetDirectusUploadAssetURI())
+		setUpResponder(http.StatusOK, dto.DirectusGetAvatarResponse{Data: AddNumberToDirectusAvatarResponseData(1)},
+			http.MethodPost, config.GetDirectusCreateAvatarURI())
+
+		fields := map[string]string{"source": "upload", "is_public": "false"}
+		doc, bin := avatarDocument(10, encodePNG(8))
+		doc["nodes"] = []interface{}{map[string]interface{}{"name": "Body", "mesh": 0}}
+
+		resp := postAvatar(fields, map[string][]byte{"snapshot": encodePNG(8), "glb_file": buildGLB(doc, bin)})
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		errorBody := hubsErrorInfo.ErrorInfo{}
+		json.Unmarshal(resp.Body.Bytes(), &errorBody)
+		assert.Equal(t, hubsErrorInfo.AvatarsGLBMissingNodes.ErrorBody.Code, errorBody.ErrorBody.Code)
+		assert.Equal(t, 0, httpmock.GetCallCountInfo()["POST "+config.GetDirectusUploadAssetURI()])
+
+		resp = postAvatar(fields, map[string][]byte{"snapshot": encodePNG(8)})
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+
+		resp = postAvatar(fields, map[string][]byte{"snapshot": encodePNG(8), "glb_file": buildGLB(avatarDocument(10, encodePNG(8)))})
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, 2, httpmock.GetCallCountInfo()["POST "+config.GetDirectusUploadAssetURI()])
+		assert.Equal(t, 0, httpmock.GetCallCountInfo()["POST "+config.GetDirectusImportAssetURI()])
+	})
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/jobs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpReadinessResponders(mastodonStatus int) {
+	regDirTokenRes()
+	setUpResponder(http.StatusOK, map[string]interface{}{"data": map[string]string{"id": "service-user"}}, http.MethodGet, config.GetDirectusUsersMeIDURI())
+	setUpResponder(http.StatusOK, map[string]interface{}{"data": map[string]string{"__typename": "Query"}}, http.MethodPost, config.GetDirectusGraphQLURI())
+	setUpResponder(mastodonStatus, map[string]string{"uri": "mastodon.test.com"}, http.MethodGet, config.GetMastodonInstanceURI())
+	setUpResponder(http.StatusOK, dto.DirectusGetAccountLikesResponse{}, http.MethodGet, config.GetDirectusGetAccountsLikedStuffURI("event", 0, 100))
+	setUpResponder(http.StatusOK, dto.DirectusGetAccountLikesResponse{}, http.MethodGet, config.GetDirectusGetAccountsLikedStuffURI("room", 0, 100))
+	setUpLifecycleEventsResponder(nil)
+}
+
+func TestReadiness(t *testing.T) {
+	t.Run("Ready when all dependencies are up", func(t *testing.T) {
+		testRouter := Init()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		setUpReadinessResponders(http.StatusOK)
+
+		jobs.Setup()
+		defer jobs.Shutdown(context.Background())
+
+		req, _ := http.NewRequest(http.MethodGet, "/health/ready", nil)
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+
+		readiness := dto.ReadinessResponse{}
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &readiness))
+		assert.Equal(t, dto.HealthStatusUp, readiness.Status)
+		assert.Equal(t, dto.LikeCacheStatusReady, readiness.LikeCache.Status)
+		for _, name := range []string{"directus_token", "directus_graphql", "mastodon"} {
+			assert.Equal(t, dto.HealthStatusUp, readiness.Checks[name].Status, name)
+		}
+	})
+
+	t.Run("Not ready when mastodon is unreachable", func(t *testing.T) {
+		testRouter := Init()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		setUpReadinessResponders(http.StatusBadGateway)
+
+		jobs.Setup()
+		defer jobs.Shutdown(context.Background())
+
+		req, _ := http.NewRequest(http.MethodGet, "/health/ready", nil)
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+		readiness := dto.ReadinessResponse{}
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &readiness))
+		assert.Equal(t, dto.HealthStatusDown, readiness.Status)
+		assert.Equal(t, dto.HealthStatusDown, readiness.Checks["mastodon"].Status)
+		assert.Equal(t, dto.HealthStatusUp, readiness.Checks["directus_graphql"].Status)
+	})
+
+	t.Run("Not ready when like counts cannot be restored", func(t *testing.T) {
+		testRouter := Init()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		setUpReadinessResponders(http.StatusOK)
+		setUpResponder(http.StatusServiceUnavailable, map[string]interface{}{"errors": []map[string]interface{}{{"message": "unavailable"}}},
+			http.MethodGet, config.GetDirectusGetAccountsLikedStuffURI("room", 0, 100))
+
+		jobs.Setup()
+		defer jobs.Shutdown(context.Background())
+
+		req, _ := http.NewRequest(http.MethodGet, "/health/ready", nil)
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+		readiness := dto.ReadinessResponse{}
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &readiness))
+		assert.Equal(t, dto.LikeCacheStatusFailed, readiness.LikeCache.Status)
+		assert.NotEmpty(t, readiness.LikeCache.Error)
+		assert.Equal(t, dto.HealthStatusUp, readiness.Checks["directus_token"].Status)
+	})
+
+	t.Run("Not ready when directus refuses the token", func(t *testing.T) {
+		testRouter := Init()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		setUpReadinessResponders(http.StatusOK)
+		setUpResponder(http.StatusForbidden, map[string]interface{}{"errors": []map[string]interface{}{{"message": "forbidden"}}},
+			http.MethodGet, config.GetDirectusUsersMeIDURI())
+
+		jobs.Setup()
+		defer jobs.Shutdown(context.Background())
+
+		req, _ := http.NewRequest(http.MethodGet, "/health/ready", nil)
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+
+		readiness := dto.ReadinessResponse{}
+		assert.Nil(t, json.Unmarshal(resp.Body.Bytes(), &readiness))
+		assert.Equal(t, dto.HealthStatusDown, readiness.Checks["directus_token"].Status)
+	})
+
+	t.Run("Cache readiness result", func(t *testing.T) {
+		testRouter := Init()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		setUpReadinessResponders(http.StatusOK)
+
+		jobs.Setup()
+		defer jobs.Shutdown(context.Background())
+
+		req, _ := http.NewRequest(http.MethodGet, "/health/ready", nil)
+		testRouter.ServeHTTP(httptest.NewRecorder(), req)
+		callCount := httpmock.GetTotalCallCount()
+
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, callCount, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestLiveness(t *testing.T) {
+	testRouter := Init()
+
+	req, _ := http.NewRequest(http.MethodGet, "/health/live", nil)
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+}
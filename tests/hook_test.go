@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"hubs-cms-go/cache"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	goCache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+const testWebhookSecret = "webhook-secret"
+
+func setUpWebhookSecret(t *testing.T, secret string) {
+	webhookSecret := config.EnvVariable.DirectusWebhookSecret
+	config.EnvVariable.DirectusWebhookSecret = secret
+	t.Cleanup(func() { config.EnvVariable.DirectusWebhookSecret = webhookSecret })
+}
+
+func postDirectusWebhook(body string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(http.MethodPost, "/api/hubs-cms/v1/hooks/directus", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp := httptest.NewRecorder()
+	SetupRouter().ServeHTTP(resp, req)
+	return resp
+}
+
+func signWebhook(body string) string {
+	mac := hmac.New(sha256.New, []byte(testWebhookSecret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestDirectusWebhookAuth(t *testing.T) {
+	body := `{"event":"items.update","collection":"room","keys":["id"],"payload":{"title":"x"}}`
+
+	t.Run("Webhook is disabled without DIRECTUS_WEBHOOK_SECRET", func(t *testing.T) {
+		setUpWebhookSecret(t, "")
+		resp := postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSecret: testWebhookSecret})
+		assert.Equal(t, http.StatusNotFound, resp.Code)
+	})
+
+	t.Run("Missing secret and signature", func(t *testing.T) {
+		setUpWebhookSecret(t, testWebhookSecret)
+		resp := postDirectusWebhook(body, nil)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("Wrong secret and tampered signature", func(t *testing.T) {
+		setUpWebhookSecret(t, testWebhookSecret)
+		resp := postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSecret: "wrong"})
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSignature: signWebhook(body + " ")})
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+	})
+
+	t.Run("Shared secret or signature is accepted", func(t *testing.T) {
+		setUpWebhookSecret(t, testWebhookSecret)
+		resp := postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSecret: testWebhookSecret})
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		resp = postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSignature: signWebhook(body)})
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+	})
+}
+
+func TestDirectusWebhookEviction(t *testing.T) {
+	t.Run("Deleted room loses its like count", func(t *testing.T) {
+		setUpWebhookSecret(t, testWebhookSecret)
+
+		deletedRoomID, otherRoomID := gofakeit.UUID(), gofakeit.UUID()
+		cache.RoomLikes.Set(deletedRoomID, int64(3), goCache.NoExpiration)
+		cache.RoomLikes.Set(otherRoomID, int64(5), goCache.NoExpiration)
+		defer cache.RoomLikes.Delete(otherRoomID)
+
+		body := `{"event":"items.delete","collection":"room","payload":["` + deletedRoomID + `"]}`
+		resp := postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSignature: signWebhook(body)})
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		_, found := cache.RoomLikes.Get(deletedRoomID)
+		assert.False(t, found)
+		_, found = cache.RoomLikes.Get(otherRoomID)
+		assert.True(t, found)
+	})
+
+	t.Run("Flow event on an event evicts cached directus reads", func(t *testing.T) {
+		setUpWebhookSecret(t, testWebhookSecret)
+
+		testEventID := gofakeit.UUID()
+		responses := cache.Responses
+		cache.Responses = cache.NewResponseCache(time.Minute, 0, 1<<20)
+		defer func() { cache.Responses = responses }()
+
+		eventURL := config.GetDirectusGetEventURI(testEventID, "")
+		roomURL := config.GetDirectusGetRoomURI(testEventID, "")
+		cache.Responses.Set(eventURL, []byte(`{}`), cache.Responses.Generation())
+		cache.Responses.Set(roomURL, []byte(`{}`), cache.Responses.Generation())
+
+		body := `{"event":"event.items.update","keys":["` + testEventID + `"],"payload":{"start_time":"2030-01-01T00:00:00"}}`
+		resp := postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSecret: testWebhookSecret})
+		assert.Equal(t, http.StatusNoContent, resp.Code)
+
+		_, _, found := cache.Responses.Get(eventURL)
+		assert.False(t, found)
+		_, _, found = cache.Responses.Get(roomURL)
+		assert.True(t, found)
+	})
+
+	t.Run("Invalid payload", func(t *testing.T) {
+		setUpWebhookSecret(t, testWebhookSecret)
+
+		body := `{"collection":"room"}`
+		resp := postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSecret: testWebhookSecret})
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+}
@@ -0,0 +1,151 @@
+package tests
+
+import (
+	"encoding/json"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImagePresetURI(t *testing.T) {
+	t.Run("Presets link directus transforms or storage asset presets", func(t *testing.T) {
+		Init()
+		mode := config.EnvVariable.ImagePresetMode
+		defer func() { config.EnvVariable.ImagePresetMode = mode }()
+
+		assetID := gofakeit.UUID()
+		config.EnvVariable.ImagePresetMode = "transform"
+		link, err := url.Parse(config.GetImageURI(assetID, false, "card"))
+		assert.Nil(t, err)
+		assert.Equal(t, config.GetDirectusGetAssetURI(assetID), strings.Split(link.String(), "?")[0])
+		assert.Equal(t, "cover", link.Query().Get("fit"))
+		assert.Equal(t, "640", link.Query().Get("width"))
+		assert.Equal(t, "360", link.Query().Get("height"))
+		assert.Equal(t, "webp", link.Query().Get("format"))
+		assert.Equal(t, strconv.Itoa(config.EnvVariable.ImagePresetQuality), link.Query().Get("quality"))
+		assert.Equal(t, config.GetDirectusGetAssetURI(assetID), config.GetImageURI(assetID, false, ""))
+
+		config.EnvVariable.ImagePresetMode = "key"
+		assert.Equal(t, config.GetDirectusGetAssetURI(assetID)+"?key=hero", config.GetImageURI(assetID, false, "hero"))
+
+		srcset := config.GetImageSrcset(assetID, true)
+		assert.Len(t, srcset, len(config.ImagePresetNames))
+		assert.Equal(t, config.GetDirectusGetAssetURI(assetID)+"?key=thumb", srcset["thumb"])
+	})
+
+	t.Run("Signed links carry and sign the preset", func(t *testing.T) {
+		Init()
+		defer setUpAssetProxy("https://cdn.example.com/assets")()
+
+		assetID := gofakeit.UUID()
+		assert.True(t, strings.HasPrefix(config.GetImageURI(assetID, true, "thumb"), "https://cdn.example.com/assets/"+assetID+"?fit=cover"))
+
+		link, _ := url.Parse(config.GetImageURI(assetID, false, "thumb"))
+		assert.Equal(t, "thumb", link.Query().Get("preset"))
+		exp, _ := strconv.ParseInt(link.Query().Get("exp"), 10, 64)
+		assert.True(t, config.VerifyAsset(assetID, "thumb", exp, link.Query().Get("sig")))
+		assert.False(t, config.VerifyAsset(assetID, "hero", exp, link.Query().Get("sig")))
+		assert.False(t, config.VerifyAsset(assetID, "", exp, link.Query().Get("sig")))
+	})
+}
+
+func TestGetAssetWithPreset(t *testing.T) {
+	t.Run("The proxy fetches the transformed image of a signed preset", func(t *testing.T) {
+		Init()
+		client.Setup()
+		defer setUpAssetProxy("")()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		assetID := gofakeit.UUID()
+		var query url.Values
+		httpmock.RegisterResponder(http.MethodGet, "=~^"+config.GetDirectusGetAssetURI(assetID), func(req *http.Request) (*http.Response, error) {
+			query = req.URL.Query()
+			resp := httpmock.NewStringResponse(http.StatusOK, "webp content")
+			resp.Header.Set("Content-Type", "image/webp")
+			return resp, nil
+		})
+
+		link, _ := url.Parse(config.GetImageURI(assetID, false, "hero"))
+		resp := sendAssetRequest(link.RequestURI(), nil)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.Equal(t, "image/webp", resp.Header().Get("Content-Type"))
+		assert.Equal(t, "1920", query.Get("width"))
+		assert.Equal(t, "inside", query.Get("fit"))
+
+		// a preset swapped on the link breaks its signature
+		resp = sendAssetRequest(strings.Replace(link.RequestURI(), "preset=hero", "preset=card", 1), nil)
+		assert.Equal(t, http.StatusForbidden, resp.Code)
+
+		resp = sendAssetRequest(strings.Replace(link.RequestURI(), "preset=hero", "preset=huge", 1), nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+}
+
+func TestImagePresetResponses(t *testing.T) {
+	t.Run("Events carry srcset maps without a preset and preset links with one", func(t *testing.T) {
+		Init()
+		response := dto.DirectusGetEventsResponse{}
+		json.Unmarshal([]byte(mockDataEvents), &response)
+
+		events := dto.NewDirectusEvents(response.Data, 0, 2, "en-US", 10)
+		event := events.Results[0]
+		assert.Equal(t, config.GetAssetURI("7137dc70-5a10-47d7-83e5-4cfb159f2638", true), event.Gallery)
+		assert.Equal(t, config.GetImageURI("7137dc70-5a10-47d7-83e5-4cfb159f2638", true, "card"), event.GallerySrcset["card"])
+		assert.Len(t, event.ImagesSrcset, len(event.Images))
+
+		events = dto.NewDirectusEventsWithPreset(response.Data, 0, 2, "en-US", "thumb", 10)
+		event = events.Results[0]
+		assert.Equal(t, config.GetImageURI("7137dc70-5a10-47d7-83e5-4cfb159f2638", true, "thumb"), event.Gallery)
+		assert.Nil(t, event.GallerySrcset)
+		assert.Nil(t, event.ImagesSrcset)
+		assert.True(t, strings.HasSuffix(events.Pages.Next, "&image_preset=thumb"))
+	})
+
+	t.Run("Room lists link the gallery in the requested preset", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		room := setPreconditionForViewCount(gofakeit.UUID(), true, "10")
+		room.Gallery = dto.DirectusFile{ID: gofakeit.UUID()}
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: []dto.DierctusRoomData{room}, Meta: dto.DirectusMeta{FilterCount: 1}},
+			http.MethodGet, "=~^"+config.EnvVariable.DirectusBaseURI+`/items/room\?`)
+
+		getRooms := func(query string) dto.GetRoomResponse {
+			resp := sendAssetRequest("/api/hubs-cms/v1/rooms"+query, nil)
+			assert.Equal(t, http.StatusOK, resp.Code)
+			rooms := dto.GetRoomListResponse{}
+			json.Unmarshal(resp.Body.Bytes(), &rooms)
+			if !assert.Len(t, rooms.Results, 1) {
+				return dto.GetRoomResponse{}
+			}
+			return *rooms.Results[0].GetRoomResponse
+		}
+
+		result := getRooms("")
+		assert.Equal(t, config.GetAssetURI(room.Gallery.ID, true), result.ImageURL)
+		assert.Equal(t, config.GetImageSrcset(room.Gallery.ID, true), result.ImageSrcset)
+
+		result = getRooms("?image_preset=hero")
+		assert.Equal(t, config.GetImageURI(room.Gallery.ID, true, "hero"), result.ImageURL)
+		assert.Nil(t, result.ImageSrcset)
+
+		resp := sendAssetRequest("/api/hubs-cms/v1/rooms?image_preset=huge", nil)
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+	})
+}
@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	hubsErrorInfo "hubs-cms-go/errors"
+	"hubs-cms-go/imaging"
+	"hubs-cms-go/snapshot"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// lossless 1x1 webp
+const webpImage = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+func encodeRectPNG(width, height int) []byte {
+	b := &bytes.Buffer{}
+	png.Encode(b, image.NewRGBA(image.Rect(0, 0, width, height)))
+	return b.Bytes()
+}
+
+// encodeOrientedJPEG encodes a red left half and a blue right half, with an EXIF segment holding the orientation and a GPS tag
+func encodeOrientedJPEG(width, height int, orientation uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, image.Rect(0, 0, width/2, height), &image.Uniform{color.RGBA{255, 0, 0, 255}}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(width/2, 0, width, height), &image.Uniform{color.RGBA{0, 0, 255, 255}}, image.Point{}, draw.Src)
+	encoded := &bytes.Buffer{}
+	jpeg.Encode(encoded, img, &jpeg.Options{Quality: 95})
+
+	tiff := &bytes.Buffer{}
+	tiff.WriteString("II")
+	binary.Write(tiff, binary.LittleEndian, []uint16{42})
+	binary.Write(tiff, binary.LittleEndian, []uint32{8})
+	binary.Write(tiff, binary.LittleEndian, []uint16{2})
+	binary.Write(tiff, binary.LittleEndian, []uint16{0x0112, 3})
+	binary.Write(tiff, binary.LittleEndian, []uint32{1})
+	binary.Write(tiff, binary.LittleEndian, []uint16{orientation, 0})
+	binary.Write(tiff, binary.LittleEndian, []uint16{0x8825, 4})
+	binary.Write(tiff, binary.LittleEndian, []uint32{1, 0})
+	binary.Write(tiff, binary.LittleEndian, []uint32{0})
+	segment := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+
+	out := &bytes.Buffer{}
+	out.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(out, binary.BigEndian, uint16(len(segment)+2))
+	out.Write(segment)
+	out.Write(encoded.Bytes()[2:])
+	return out.Bytes()
+}
+
+func TestProcessImage(t *testing.T) {
+	limits := imaging.Limits{MaxSize: 1 << 20, MaxDimension: 1024}
+	sizes := []int{128, 256, 512}
+
+	t.Run("Png is re-encoded with thumbnails smaller than itself", func(t *testing.T) {
+		result, err := imaging.Process(encodeRectPNG(300, 150), limits, sizes)
+		assert.Nil(t, err)
+		assert.Equal(t, "image/png", result.ContentType)
+		assert.Equal(t, []int{300, 150}, []int{result.Width, result.Height})
+		assert.Len(t, result.Thumbnails, 2)
+		assert.Equal(t, []int{128, 64}, []int{result.Thumbnails[128].Width, result.Thumbnails[128].Height})
+		assert.Equal(t, []int{256, 128}, []int{result.Thumbnails[256].Width, result.Thumbnails[256].Height})
+
+		cfg, err := png.DecodeConfig(bytes.NewReader(result.Thumbnails[256].Data))
+		assert.Nil(t, err)
+		assert.Equal(t, 256, cfg.Width)
+	})
+
+	t.Run("Jpeg is rotated upright and loses its EXIF segment", func(t *testing.T) {
+		data := encodeOrientedJPEG(40, 20, 6)
+		assert.True(t, bytes.Contains(data, []byte("Exif")))
+
+		result, err := imaging.Process(data, limits, sizes)
+		assert.Nil(t, err)
+		assert.Equal(t, "image/jpeg", result.ContentType)
+		assert.Equal(t, ".jpg", result.Ext())
+		assert.Equal(t, []int{20, 40}, []int{result.Width, result.Height})
+		assert.False(t, bytes.Contains(result.Data, []byte("Exif")))
+
+		// rotated clockwise, the red left half is on top
+		img, err := jpeg.Decode(bytes.NewReader(result.Data))
+		assert.Nil(t, err)
+		r, _, b, _ := img.At(10, 5).RGBA()
+		assert.Greater(t, r, b)
+		r, _, b, _ = img.At(10, 35).RGBA()
+		assert.Less(t, r, b)
+	})
+
+	t.Run("Webp becomes png", func(t *testing.T) {
+		data, _ := base64.StdEncoding.DecodeString(webpImage)
+		result, err := imaging.Process(data, limits, sizes)
+		assert.Nil(t, err)
+		assert.Equal(t, "image/png", result.ContentType)
+		assert.Empty(t, result.Thumbnails)
+	})
+
+	gif, _ := base64.StdEncoding.DecodeString("R0lGODlhAQABAIAAAP///wAAACH5BAEAAAAALAAAAAABAAEAAAICRAEAOw==")
+	valid := encodeRectPNG(8, 8)
+	for _, test := range []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"Text", []byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"), imaging.ErrNotImage},
+		{"Gif", gif, imaging.ErrNotImage},
+		{"Truncated png", valid[:len(valid)-20], imaging.ErrInvalid},
+		{"Too large file", append(encodeRectPNG(8, 8), make([]byte, limits.MaxSize)...), imaging.ErrTooLarge},
+		{"Too wide", encodeRectPNG(1025, 8), imaging.ErrDimensions},
+		{"Too high", encodeRectPNG(8, 1025), imaging.ErrDimensions},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := imaging.Process(test.data, limits, sizes)
+			assert.ErrorIs(t, err, test.err)
+		})
+	}
+}
+
+// setUpUploadResponder answers every upload with a new file id and counts them
+func setUpUploadResponder(uploads *int32) {
+	httpmock.RegisterResponder(http.MethodPost, config.GetDirectusUploadAssetURI(), func(req *http.Request) (*http.Response, error) {
+		atomic.AddInt32(uploads, 1)
+		return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusUploadAssetResponse{Data: dto.DirectusUploadAssetResponseData{ID: gofakeit.UUID()}})
+	})
+}
+
+func TestCreateAvatarSnapshot(t *testing.T) {
+	t.Run("Snapshot is checked before uploading and stored with its thumbnails", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		accountID, mastodonAccount := gofakeit.UUID(), gofakeit.Email()
+		setUpResponder(http.StatusOK, dto.MastodonVerifyCredentialsResponse{ID: gofakeit.UUID(), UserName: gofakeit.Username(), MastodonAccount: mastodonAccount},
+			http.MethodGet, config.GetMastodonVerifyCredentialsURI())
+		setUpResponder(http.StatusOK, dto.DirectusGetAccountResponse{Data: []dto.DirectusAccountResponseData{{ID: accountID, MastodonAccount: mastodonAccount}}},
+			http.MethodGet, config.GetDirectusGetAccountURI(mastodonAccount))
+		setUpAvatarUsage(accountID, nil, nil)
+		var uploads int32
+		setUpUploadResponder(&uploads)
+
+		created := dto.DirectusCreateAvatarRequest{}
+		httpmock.RegisterResponder(http.MethodPost, config.GetDirectusCreateAvatarURI(), func(req *http.Request) (*http.Response, error) {
+			b, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(b, &created)
+			data := AddNumberToDirectusAvatarResponseData(1)
+			data.SnapshotThumbnails = created.SnapshotThumbnails
+			return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusGetAvatarResponse{Data: data})
+		})
+
+		fields := map[string]string{"source": "upload", "is_public": "false"}
+		model := buildGLB(avatarDocument(10, encodePNG(8)))
+		for _, test := range []struct {
+			snapshot []byte
+			code     int
+		}{
+			{[]byte("GIF89a"), hubsErrorInfo.AvatarsInvalidSnapshot.ErrorBody.Code},
+			{encodeRectPNG(config.EnvVariable.ImageMaxDimension+1, 8), hubsErrorInfo.AvatarsSnapshotDimensions.ErrorBody.Code},
+		} {
+			resp := postAvatar(fields, map[string][]byte{"snapshot": test.snapshot, "glb_file": model})
+			assert.Equal(t, http.StatusBadRequest, resp.Code)
+			errorBody := hubsErrorInfo.ErrorInfo{}
+			json.Unmarshal(resp.Body.Bytes(), &errorBody)
+			assert.Equal(t, test.code, errorBody.ErrorBody.Code)
+		}
+		assert.Equal(t, int32(0), atomic.LoadInt32(&uploads))
+
+		resp := postAvatar(fields, map[string][]byte{"snapshot": encodeOrientedJPEG(300, 200, 1), "glb_file": model})
+		assert.Equal(t, http.StatusOK, resp.Code)
+		// snapshot, 128 and 256 thumbnails, glb
+		assert.Equal(t, int32(4), atomic.LoadInt32(&uploads))
+		assert.Len(t, created.SnapshotThumbnails, 3)
+		assert.Equal(t, created.Snapshot, created.SnapshotThumbnails["512"])
+		assert.NotEqual(t, created.Snapshot, created.SnapshotThumbnails["128"])
+
+		avatar := dto.DirectusAvatar{}
+		json.Unmarshal(resp.Body.Bytes(), &avatar)
+		assert.Equal(t, config.GetDirectusGetAssetURI(created.SnapshotThumbnails["256"]), avatar.SnapshotThumbnails["256"])
+	})
+}
+
+func TestProcessRoomGallery(t *testing.T) {
+	t.Run("Gallery set in directus is rewritten and gets thumbnails", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpWebhookSecret(t, testWebhookSecret)
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		room := dto.DierctusRoomData{ID: gofakeit.UUID(), Gallery: dto.DirectusFile{ID: gofakeit.UUID()}}
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: room}, http.MethodGet, config.GetDirectusGetRoomURI(room.ID, ""))
+		httpmock.RegisterResponder(http.MethodGet, config.GetDirectusGetAssetURI(room.Gallery.ID),
+			httpmock.NewBytesResponder(http.StatusOK, encodeOrientedJPEG(600, 400, 8)))
+		var replaced int32
+		httpmock.RegisterResponder(http.MethodPatch, config.GetDirectusFileURI(room.Gallery.ID), func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&replaced, 1)
+			return httpmock.NewStringResponse(http.StatusOK, `{"data":{}}`), nil
+		})
+		var uploads int32
+		setUpUploadResponder(&uploads)
+		patches := &sync.Map{}
+		setUpItemPatchResponder(config.GetDirectusGetRoomURISimple(room.ID), patches)
+
+		for _, body := range []string{
+			`{"event":"items.update","collection":"room","keys":["` + room.ID + `"],"payload":{"gallery_thumbnails":{"128":"x"}}}`,
+			`{"event":"items.update","collection":"room","keys":["` + room.ID + `"],"payload":{"gallery":"` + room.Gallery.ID + `"}}`,
+		} {
+			resp := postDirectusWebhook(body, map[string]string{constant.HeaderWebhookSecret: testWebhookSecret})
+			assert.Equal(t, http.StatusNoContent, resp.Code)
+		}
+		snapshot.Wait(context.Background())
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&replaced))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&uploads))
+		patch, _ := patches.Load(config.GetDirectusGetRoomURISimple(room.ID))
+		thumbnails := patch.(map[string]interface{})["gallery_thumbnails"].(map[string]interface{})
+		assert.Len(t, thumbnails, 3)
+		assert.NotEqual(t, room.Gallery.ID, thumbnails["512"])
+	})
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"context"
+	"hubs-cms-go/cache"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/jobs"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	goCache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownFlushLikeCount(t *testing.T) {
+	t.Run("Flush like counts to directus on shutdown", func(t *testing.T) {
+		Init()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		setUpReadinessResponders(http.StatusOK)
+		jobs.Setup()
+
+		cache.EventLikes.Set("event-id", int64(3), goCache.NoExpiration)
+		cache.RoomLikes.Set("room-id", int64(2), goCache.NoExpiration)
+
+		var mutation string
+		httpmock.RegisterResponder(http.MethodPost, config.GetDirectusGraphQLURI(), func(req *http.Request) (*http.Response, error) {
+			b, _ := ioutil.ReadAll(req.Body)
+			mutation = string(b)
+			return httpmock.NewStringResponse(http.StatusOK, `{"data":{"e0":{"like_count":3},"r1":{"like_count":2}}}`), nil
+		})
+
+		jobs.Shutdown(context.Background())
+
+		assert.True(t, strings.Contains(mutation, `update_event_item(id: \"event-id\", data: { like_count: 3 })`))
+		assert.True(t, strings.Contains(mutation, `update_room_item(id: \"room-id\", data: { like_count: 2 })`))
+	})
+
+	t.Run("Skip flush without like counts", func(t *testing.T) {
+		Init()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		regDirTokenRes()
+		defer httpmock.DeactivateAndReset()
+
+		jobs.Shutdown(context.Background())
+
+		assert.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("Skip backups when like counts cannot be restored", func(t *testing.T) {
+		Init()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		setUpReadinessResponders(http.StatusOK)
+		setUpResponder(http.StatusOK, dto.DirectusGetAccountLikesResponse{Data: []dto.DirectusGetAccountLikesResponseData{{LikedEvents: []dto.LikedEvents{{EventID: "event-id"}}}}},
+			http.MethodGet, config.GetDirectusGetAccountsLikedStuffURI("event", 0, 100))
+		setUpResponder(http.StatusServiceUnavailable, map[string]interface{}{"errors": []map[string]interface{}{{"message": "unavailable"}}},
+			http.MethodGet, config.GetDirectusGetAccountsLikedStuffURI("room", 0, 100))
+
+		cache.EventLikes.Set("stale-id", int64(3), goCache.NoExpiration)
+		jobs.Setup()
+
+		// partial counts would overwrite the counts in directus
+		assert.Equal(t, dto.LikeCacheStatusFailed, jobs.GetLikeCacheStatus().Status)
+		assert.Equal(t, 0, cache.EventLikes.ItemCount())
+		assert.Equal(t, 0, cache.RoomLikes.ItemCount())
+
+		httpmock.Reset()
+		regDirTokenRes()
+		jobs.Shutdown(context.Background())
+		assert.Equal(t, 0, httpmock.GetCallCountInfo()["POST "+config.GetDirectusGraphQLURI()])
+	})
+}
@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/jobs"
+	"hubs-cms-go/notify"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// setUpLifecycleEventsResponder answers the lifecycle event list, its url holds the current time
+func setUpLifecycleEventsResponder(events []dto.DirectusEventLifecycleData) {
+	jsonResponder, _ := httpmock.NewJsonResponder(http.StatusOK, dto.DirectusGetResponse{Data: events, Meta: dto.DirectusMeta{FilterCount: int64(len(events))}})
+	httpmock.RegisterResponder(http.MethodGet, "=~^"+regexp.QuoteMeta(config.EnvVariable.DirectusBaseURI+"/items/event?")+".*ended_at", jsonResponder)
+}
+
+// setUpLifecyclePatchResponder records the bodies patched to the event
+func setUpLifecyclePatchResponder(eventID string, patches *sync.Map) {
+	httpmock.RegisterResponder(http.MethodPatch, config.GetDirectusGetEventURISimple(eventID), func(req *http.Request) (*http.Response, error) {
+		patch := map[string]interface{}{}
+		b, _ := ioutil.ReadAll(req.Body)
+		json.Unmarshal(b, &patch)
+		patches.Store(eventID, patch)
+		return httpmock.NewStringResponse(http.StatusOK, `{"data":{}}`), nil
+	})
+}
+
+func setUpNotifier(t *testing.T, notifier notify.Notifier) {
+	defaultNotifier := notify.Default
+	notify.Default = notifier
+	t.Cleanup(func() { notify.Default = defaultNotifier })
+}
+
+func TestEventReminderMessage(t *testing.T) {
+	t.Run("Reminders follow the configured template", func(t *testing.T) {
+		Init()
+		template := config.EnvVariable.EventReminderMessage
+		defer func() { config.EnvVariable.EventReminderMessage = template }()
+
+		assert.Equal(t, "Opening starts in 10 minutes", config.GetEventReminderMessage("Opening", 10))
+
+		config.EnvVariable.EventReminderMessage = "{title} 將於 {minutes} 分鐘後開始"
+		assert.Equal(t, "開幕式 將於 15 分鐘後開始", config.GetEventReminderMessage("開幕式", 15))
+	})
+}
+
+func TestEventLifecycle(t *testing.T) {
+	t.Run("Likers are reminded and passed boundaries are marked", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		buffer := &bytes.Buffer{}
+		setUpNotifier(t, &notify.LogNotifier{Writer: buffer})
+
+		now := time.Now()
+		upcoming := dto.DirectusEventLifecycleData{ID: gofakeit.UUID(), Title: "Opening", StartTime: now.Add(10 * time.Minute), EndTime: now.Add(time.Hour)}
+		started := dto.DirectusEventLifecycleData{ID: gofakeit.UUID(), StartTime: now.Add(-time.Minute), EndTime: now.Add(time.Hour)}
+		ended := dto.DirectusEventLifecycleData{ID: gofakeit.UUID(), StartTime: now.Add(-2 * time.Hour), EndTime: now.Add(-time.Minute)}
+		setUpLifecycleEventsResponder([]dto.DirectusEventLifecycleData{upcoming, started, ended})
+
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{
+			Data: []dto.DirectusAccountResponseData{{ID: "a1", MastodonAccount: "alice@mastodon.test"}, {ID: "a2", MastodonAccount: "bob@mastodon.test"}},
+			Meta: dto.DirectusMeta{FilterCount: 2},
+		}, http.MethodGet, config.GetDirectusGetAccountsLikedEventURI(upcoming.ID, 0, 100))
+
+		patches := &sync.Map{}
+		for _, event := range []dto.DirectusEventLifecycleData{upcoming, started, ended} {
+			setUpLifecyclePatchResponder(event.ID, patches)
+		}
+
+		assert.Nil(t, jobs.RefreshEventLifecycle(context.Background()))
+
+		lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		assert.Len(t, lines, 2)
+		assert.Contains(t, lines[0], "alice@mastodon.test")
+		assert.Contains(t, lines[0], "Opening starts in 10 minutes")
+		assert.Contains(t, lines[1], "bob@mastodon.test")
+
+		patch, _ := patches.Load(upcoming.ID)
+		assert.Contains(t, patch, "reminded_at")
+		assert.NotContains(t, patch, "started_at")
+		patch, _ = patches.Load(started.ID)
+		assert.Contains(t, patch, "started_at")
+		assert.NotContains(t, patch, "ended_at")
+		patch, _ = patches.Load(ended.ID)
+		assert.Contains(t, patch, "started_at")
+		assert.Contains(t, patch, "ended_at")
+
+		// reminded events are not reminded again
+		upcoming.RemindedAt = &now
+		setUpLifecycleEventsResponder([]dto.DirectusEventLifecycleData{upcoming, started})
+		assert.Nil(t, jobs.RefreshEventLifecycle(context.Background()))
+		assert.Equal(t, 1, httpmock.GetCallCountInfo()["GET "+config.GetDirectusGetAccountsLikedEventURI(upcoming.ID, 0, 100)])
+
+		setUpLifecycleEventsResponder(nil)
+		assert.Nil(t, jobs.RefreshEventLifecycle(context.Background()))
+	})
+
+	t.Run("Boundaries are handled as they pass", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		now := time.Now()
+		event := dto.DirectusEventLifecycleData{ID: gofakeit.UUID(), StartTime: now.Add(100 * time.Millisecond), EndTime: now.Add(200 * time.Millisecond), RemindedAt: &now}
+		setUpLifecycleEventsResponder([]dto.DirectusEventLifecycleData{event})
+
+		patches := &sync.Map{}
+		setUpLifecyclePatchResponder(event.ID, patches)
+
+		assert.Nil(t, jobs.RefreshEventLifecycle(context.Background()))
+		_, found := patches.Load(event.ID)
+		assert.False(t, found)
+
+		assert.Eventually(t, func() bool {
+			patch, _ := patches.Load(event.ID)
+			fields, _ := patch.(map[string]interface{})
+			_, found := fields["started_at"]
+			return found
+		}, time.Second, 10*time.Millisecond)
+		assert.Eventually(t, func() bool {
+			patch, _ := patches.Load(event.ID)
+			fields, _ := patch.(map[string]interface{})
+			_, found := fields["ended_at"]
+			return found
+		}, time.Second, 10*time.Millisecond)
+
+		// waits for the timer to finish and forgets the events
+		setUpLifecycleEventsResponder(nil)
+		assert.Nil(t, jobs.RefreshEventLifecycle(context.Background()))
+	})
+
+	t.Run("Passed boundaries are marked when the reminder fails", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		// an end_time before the start_time is due while the reminder is
+		now := time.Now()
+		event := dto.DirectusEventLifecycleData{ID: gofakeit.UUID(), StartTime: now.Add(10 * time.Minute), EndTime: now.Add(-time.Minute)}
+		setUpLifecycleEventsResponder([]dto.DirectusEventLifecycleData{event})
+		setUpResponder(http.StatusServiceUnavailable, map[string]interface{}{"errors": []map[string]interface{}{{"message": "unavailable"}}},
+			http.MethodGet, config.GetDirectusGetAccountsLikedEventURI(event.ID, 0, 100))
+
+		patches := &sync.Map{}
+		setUpLifecyclePatchResponder(event.ID, patches)
+
+		assert.Nil(t, jobs.RefreshEventLifecycle(context.Background()))
+		patch, found := patches.Load(event.ID)
+		assert.True(t, found)
+		assert.Contains(t, patch, "ended_at")
+		assert.NotContains(t, patch, "reminded_at")
+
+		setUpLifecycleEventsResponder(nil)
+		assert.Nil(t, jobs.RefreshEventLifecycle(context.Background()))
+	})
+
+	t.Run("Mastodon notifier sends a direct status", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+
+		var authorization, idempotencyKey string
+		status := dto.MastodonPostStatusRequest{}
+		httpmock.RegisterResponder(http.MethodPost, config.GetMastodonStatusesURI(), func(req *http.Request) (*http.Response, error) {
+			authorization = req.Header.Get(constant.HeaderAuthorization)
+			idempotencyKey = req.Header.Get("Idempotency-Key")
+			b, _ := ioutil.ReadAll(req.Body)
+			json.Unmarshal(b, &status)
+			return httpmock.NewJsonResponse(http.StatusOK, dto.MastodonStatusResponse{ID: "1"})
+		})
+
+		notifier := &notify.MastodonNotifier{Token: "service-token"}
+		notification := notify.Notification{Key: "event-reminder:1", Account: dto.DirectusAccountResponseData{ID: "a1", MastodonAccount: "alice@mastodon.test"}, Message: "hello"}
+		assert.Nil(t, notifier.Notify(context.Background(), notification))
+
+		assert.Equal(t, "Bearer service-token", authorization)
+		assert.NotEmpty(t, idempotencyKey)
+		assert.Equal(t, "direct", status.Visibility)
+		assert.Equal(t, "@alice@mastodon.test hello", status.Status)
+
+		assert.NotNil(t, notifier.Notify(context.Background(), notify.Notification{Account: dto.DirectusAccountResponseData{ID: "a2"}, Message: "hello"}))
+	})
+}
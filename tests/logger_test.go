@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/logger"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func captureLogs(t *testing.T) *bytes.Buffer {
+	buf := &bytes.Buffer{}
+	logger.SetOutput(buf, buf)
+	t.Cleanup(func() {
+		logger.SetOutput(os.Stdout, os.Stderr)
+	})
+	return buf
+}
+
+func TestLoggerRedact(t *testing.T) {
+	cases := map[string]string{
+		`{"email":"admin@example.com","password":"p@ss\"word"}`:     `{"email":"admin@example.com","password":"[REDACTED]"}`,
+		`{"data":{"access_token":"abc.def","refresh_token":"xyz"}}`: `{"data":{"access_token":"[REDACTED]","refresh_token":"[REDACTED]"}}`,
+		`{Passcode:1234 HubsID:abc}`:                                `{Passcode:[REDACTED] HubsID:abc}`,
+		`https://directus/items/room?access_token=abc&limit=1`:      `https://directus/items/room?access_token=[REDACTED]&limit=1`,
+		`Authorization: Bearer abc.def`:                             `Authorization: [REDACTED]`,
+		`header [Bearer abc.def]`:                                   `header [Bearer [REDACTED]]`,
+	}
+	for msg, expected := range cases {
+		assert.Equal(t, expected, logger.Redact(msg))
+	}
+}
+
+func TestLoggerJSONLine(t *testing.T) {
+	logger.Setup("INFO")
+	defer logger.Setup(config.EnvVariable.LogLevel)
+	buf := captureLogs(t)
+
+	logger.Debug.Printf("[TestLoggerJSONLine] hidden\n")
+	logger.Info.With(logger.Fields{"token": "abc", "room": "lobby"}).Printf("[TestLoggerJSONLine] login %v\n", `{"password":"secret"}`)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if assert.Len(t, lines, 1) {
+		line := map[string]interface{}{}
+		assert.Nil(t, json.Unmarshal([]byte(lines[0]), &line))
+		assert.Equal(t, "info", line["level"])
+		assert.Equal(t, `[TestLoggerJSONLine] login {"password":"[REDACTED]"}`, line["msg"])
+		assert.Equal(t, "[REDACTED]", line["token"])
+		assert.Equal(t, "lobby", line["room"])
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	t.Run("Propagate X-Request-ID of the caller", func(t *testing.T) {
+		logger.Setup("INFO")
+		defer logger.Setup(config.EnvVariable.LogLevel)
+		buf := captureLogs(t)
+		testRouter := SetupRouter()
+
+		// /version is not access logged, an invalid limit is answered without upstream calls
+		req, _ := http.NewRequest(http.MethodGet, "/api/hubs-cms/v1/events?limit=-1", nil)
+		req.Header.Set(constant.HeaderRequestID, "test-request-id")
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, "test-request-id", resp.Header().Get(constant.HeaderRequestID))
+		assert.Contains(t, buf.String(), `"request_id":"test-request-id"`)
+		assert.Contains(t, buf.String(), `"route":"/api/hubs-cms/v1/events"`)
+	})
+
+	t.Run("Generate X-Request-ID for invalid one", func(t *testing.T) {
+		testRouter := SetupRouter()
+
+		req, _ := http.NewRequest(http.MethodGet, "/version", nil)
+		req.Header.Set(constant.HeaderRequestID, "bad id\twith spaces")
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Len(t, resp.Header().Get(constant.HeaderRequestID), 32)
+	})
+}
+
+func TestAdminLogLevel(t *testing.T) {
+	defer logger.Setup(config.EnvVariable.LogLevel)
+	adminToken := config.EnvVariable.AdminToken
+	defer func() { config.EnvVariable.AdminToken = adminToken }()
+
+	putLogLevel := func(token, body string) *httptest.ResponseRecorder {
+		testRouter := SetupRouter()
+		req, _ := http.NewRequest(http.MethodPut, "/api/hubs-cms/v1/admin/log-level", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if len(token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+		return resp
+	}
+
+	t.Run("Disabled without ADMIN_TOKEN", func(t *testing.T) {
+		config.EnvVariable.AdminToken = ""
+		assert.Equal(t, http.StatusNotFound, putLogLevel("anything", `{"level":"debug"}`).Code)
+	})
+
+	t.Run("Reject invalid token", func(t *testing.T) {
+		config.EnvVariable.AdminToken = "admin-token"
+		assert.Equal(t, http.StatusUnauthorized, putLogLevel("", `{"level":"debug"}`).Code)
+		assert.Equal(t, http.StatusForbidden, putLogLevel("wrong-token", `{"level":"debug"}`).Code)
+	})
+
+	t.Run("Reject unknown level", func(t *testing.T) {
+		config.EnvVariable.AdminToken = "admin-token"
+		assert.Equal(t, http.StatusBadRequest, putLogLevel("admin-token", `{"level":"verbose"}`).Code)
+	})
+
+	t.Run("Change level", func(t *testing.T) {
+		config.EnvVariable.AdminToken = "admin-token"
+		resp := putLogLevel("admin-token", `{"level":"WARN"}`)
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.JSONEq(t, `{"level":"warn"}`, resp.Body.String())
+		assert.Equal(t, logger.LevelWarn, logger.GetLevel())
+	})
+}
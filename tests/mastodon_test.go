@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"context"
 	"errors"
 	"hubs-cms-go/client"
 	"hubs-cms-go/config"
@@ -33,7 +34,7 @@ func TestGetMastodonVerifyCredentials(t *testing.T) {
 			config.GetDirectusAccessTokenURI(),
 			getDirectusAccessTokenJsonResponder)
 
-		directusAccessToken, err := service.GetDirectusAccessToken(false)
+		directusAccessToken, err := service.GetDirectusAccessToken(context.Background(), false)
 		assert.Nil(t, err)
 		assert.Equal(t, "Bearer xyz", directusAccessToken)
 
@@ -60,7 +61,7 @@ func TestGetMastodonVerifyCredentials(t *testing.T) {
 			config.GetMastodonVerifyCredentialsURI(),
 			getDirectusAccountDataResponder)
 
-		result, err := service.GetMastodonVerifyCredentials(directusAccessToken)
+		result, err := service.GetMastodonVerifyCredentials(context.Background(), directusAccessToken)
 		assert.Nil(t, err)
 
 		assert.Equal(t, mockMastodonAccountInfo.ID, result.ID)
@@ -88,7 +89,7 @@ func TestGetMastodonVerifyCredentialsError(t *testing.T) {
 			config.GetDirectusAccessTokenURI(),
 			getDirectusAccessTokenJsonResponder)
 
-		directusAccessToken, err := service.GetDirectusAccessToken(false)
+		directusAccessToken, err := service.GetDirectusAccessToken(context.Background(), false)
 		assert.Nil(t, err)
 		assert.Equal(t, "Bearer xyz", directusAccessToken)
 
@@ -100,7 +101,7 @@ func TestGetMastodonVerifyCredentialsError(t *testing.T) {
 			config.GetMastodonVerifyCredentialsURI(),
 			testErrorResponder)
 
-		emptyResult, responseErr := service.GetMastodonVerifyCredentials(directusAccessToken)
+		emptyResult, responseErr := service.GetMastodonVerifyCredentials(context.Background(), directusAccessToken)
 		assert.Equal(t, dto.MastodonVerifyCredentialsResponse{}, emptyResult)
 		assert.True(t, strings.Contains(responseErr.Error(), expectResult))
 	})
@@ -125,7 +126,7 @@ func TestPatchMastodonAccount(t *testing.T) {
 			config.GetDirectusAccessTokenURI(),
 			getDirectusAccessTokenJsonResponder)
 
-		directusAccessToken, err := service.GetDirectusAccessToken(false)
+		directusAccessToken, err := service.GetDirectusAccessToken(context.Background(), false)
 		assert.Nil(t, err)
 		assert.Equal(t, "Bearer xyz", directusAccessToken)
 
@@ -156,7 +157,7 @@ func TestPatchMastodonAccount(t *testing.T) {
 			DisplayName: testNewDisplayName,
 		}
 
-		result, err := service.PatchMastodonAccount(directusAccessToken, mockRequestBody)
+		result, err := service.PatchMastodonAccount(context.Background(), directusAccessToken, mockRequestBody)
 		assert.Nil(t, err)
 
 		assert.Equal(t, mockMastodonAccountInfo.ID, result.ID)
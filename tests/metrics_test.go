@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrapeMetrics(t *testing.T) string {
+	testRouter := SetupRouter()
+
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	resp := httptest.NewRecorder()
+	testRouter.ServeHTTP(resp, req)
+
+	assert.Equal(t, http.StatusOK, resp.Code)
+	return resp.Body.String()
+}
+
+func TestMetricsHTTPRequests(t *testing.T) {
+	t.Run("Count requests by gin route", func(t *testing.T) {
+		testRouter := Init()
+
+		req, _ := http.NewRequest(http.MethodGet, "/version", nil)
+		testRouter.ServeHTTP(httptest.NewRecorder(), req)
+
+		body := scrapeMetrics(t)
+		assert.Contains(t, body, `hubs_cms_http_requests_total{method="GET",route="/version",status="200"}`)
+		assert.Contains(t, body, `hubs_cms_http_request_duration_seconds_bucket{method="GET",route="/version",status="200"`)
+		assert.Contains(t, body, `hubs_cms_like_cache_items{type="event"}`)
+	})
+}
+
+func TestMetricsUpstreamRequests(t *testing.T) {
+	t.Run("Observe directus calls with ids stripped from endpoint", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testRoomID := gofakeit.UUID()
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: dto.DierctusRoomData{ID: testRoomID}}, http.MethodGet, config.GetDirectusGetRoomURI(testRoomID, ""))
+
+		_, err := service.GetDirectusRoom(context.Background(), testRoomID, "")
+		assert.Nil(t, err)
+
+		body := scrapeMetrics(t)
+		assert.Contains(t, body, `hubs_cms_upstream_request_duration_seconds_count{endpoint="/items/room/:id",method="GET",status="200",upstream="directus"}`)
+		assert.Contains(t, body, `hubs_cms_directus_token_refresh_total{result="success"}`)
+		assert.NotContains(t, body, testRoomID)
+	})
+}
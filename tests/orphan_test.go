@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"encoding/json"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// setUpFileReferences answers the items of each collection, paging is not exercised
+func setUpFileReferences(references map[string][]map[string]interface{}) {
+	for collection, items := range references {
+		setUpResponder(http.StatusOK, map[string]interface{}{"data": items, "meta": map[string]interface{}{"filter_count": len(items)}},
+			http.MethodGet, "=~^"+config.EnvVariable.DirectusBaseURI+"/items/"+collection+`\?`)
+	}
+}
+
+// setUpDeleteFilesResponder records the ids of the deleted files
+func setUpDeleteFilesResponder(deleted *[]string, lock *sync.Mutex) {
+	httpmock.RegisterResponder(http.MethodDelete, config.GetDirectusDeleteFilesURI(), func(req *http.Request) (*http.Response, error) {
+		b, _ := ioutil.ReadAll(req.Body)
+		ids := []string{}
+		json.Unmarshal(b, &ids)
+		lock.Lock()
+		*deleted = append(*deleted, ids...)
+		lock.Unlock()
+		return httpmock.NewStringResponse(http.StatusNoContent, ""), nil
+	})
+}
+
+func sendOrphanFilesRequest(method, query string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, "/api/hubs-cms/v1/admin/orphan-files"+query, nil)
+	req.Header.Set(constant.HeaderAuthorization, "Bearer admin-token")
+	resp := httptest.NewRecorder()
+	SetupRouter().ServeHTTP(resp, req)
+	return resp
+}
+
+func TestCollectOrphanFiles(t *testing.T) {
+	references := map[string][]map[string]interface{}{
+		"avatar":            {{"snapshot": "snapshot-1", "snapshot_thumbnails": map[string]interface{}{"128": "thumbnail-1", "512": "snapshot-1"}, "glb": "glb-1"}},
+		"room":              {{"gallery": "gallery-1", "gallery_thumbnails": nil}},
+		"event":             {{"gallery": "gallery-2", "images": []interface{}{map[string]interface{}{"directus_files_id": "image-1"}}}},
+		"video":             {{"cover_image": "cover-1", "mp4": "mp4-1", "webm": nil}},
+		"event_participate": {{"image": "speaker-1"}},
+	}
+	files := []dto.DirectusOrphanFile{}
+	for _, id := range []string{"snapshot-1", "thumbnail-1", "glb-1", "gallery-1", "gallery-2", "image-1", "cover-1", "mp4-1", "speaker-1"} {
+		files = append(files, dto.DirectusOrphanFile{ID: id, FileSize: "1"})
+	}
+	files = append(files, dto.DirectusOrphanFile{ID: "orphan-1", FilenameDownload: "snapshot.png", FileSize: "100"}, dto.DirectusOrphanFile{ID: "orphan-2", FileSize: "200"})
+
+	t.Run("Files no item refers to are reported in a dry run and deleted otherwise", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		adminToken := config.EnvVariable.AdminToken
+		defer func() { config.EnvVariable.AdminToken = adminToken }()
+		config.EnvVariable.AdminToken = "admin-token"
+
+		setUpResponder(http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"id": "service-user"}}, http.MethodGet, config.GetDirectusUsersMeIDURI())
+		var query map[string][]string
+		httpmock.RegisterResponder(http.MethodGet, "=~^"+config.GetDirectusDeleteFilesURI()+`\?`, func(req *http.Request) (*http.Response, error) {
+			query = req.URL.Query()
+			return httpmock.NewJsonResponse(http.StatusOK, map[string]interface{}{"data": files, "meta": map[string]interface{}{"filter_count": len(files)}})
+		})
+		setUpFileReferences(references)
+		deleted, lock := []string{}, &sync.Mutex{}
+		setUpDeleteFilesResponder(&deleted, lock)
+
+		resp := sendOrphanFilesRequest(http.MethodPost, "?dry_run=true")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		report := dto.OrphanReport{}
+		json.Unmarshal(resp.Body.Bytes(), &report)
+		assert.Equal(t, dto.OrphanReportStatusCompleted, report.Status)
+		assert.True(t, report.DryRun)
+		assert.Equal(t, 11, report.Scanned)
+		assert.Equal(t, 2, report.OrphanCount)
+		assert.Equal(t, int64(300), report.Bytes)
+		assert.Equal(t, 0, report.Deleted)
+		if assert.Len(t, report.Orphans, 2) {
+			assert.Equal(t, dto.OrphanFile{ID: "orphan-1", FileName: "snapshot.png", FileSize: 100}, report.Orphans[0])
+		}
+		assert.Empty(t, deleted)
+
+		// only the files of the service user uploaded before the grace period are listed
+		assert.Equal(t, []string{"service-user"}, query["filter[uploaded_by][_eq]"])
+		before, err := time.Parse(time.RFC3339, query["filter[uploaded_on][_lt]"][0])
+		assert.Nil(t, err)
+		assert.WithinDuration(t, time.Now().Add(-config.EnvVariable.OrphanGCGracePeriod), before, time.Minute)
+
+		resp = sendOrphanFilesRequest(http.MethodGet, "")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		last := dto.OrphanReport{}
+		json.Unmarshal(resp.Body.Bytes(), &last)
+		assert.Equal(t, report.OrphanCount, last.OrphanCount)
+		assert.True(t, last.DryRun)
+
+		resp = sendOrphanFilesRequest(http.MethodPost, "?dry_run=false")
+		assert.Equal(t, http.StatusOK, resp.Code)
+		report = dto.OrphanReport{}
+		json.Unmarshal(resp.Body.Bytes(), &report)
+		assert.False(t, report.DryRun)
+		assert.Equal(t, 2, report.Deleted)
+		assert.Equal(t, []string{"orphan-1", "orphan-2"}, deleted)
+	})
+
+	t.Run("Nothing is deleted when a collection cannot be read", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		adminToken, scope := config.EnvVariable.AdminToken, config.EnvVariable.OrphanGCScope
+		defer func() { config.EnvVariable.AdminToken, config.EnvVariable.OrphanGCScope = adminToken, scope }()
+		config.EnvVariable.AdminToken, config.EnvVariable.OrphanGCScope = "admin-token", "all"
+
+		setUpResponder(http.StatusOK, map[string]interface{}{"data": files, "meta": map[string]interface{}{"filter_count": len(files)}},
+			http.MethodGet, "=~^"+config.GetDirectusDeleteFilesURI()+`\?`)
+		setUpFileReferences(references)
+		setUpResponder(http.StatusForbidden, map[string]interface{}{"errors": []map[string]interface{}{{"message": "forbidden"}}},
+			http.MethodGet, "=~^"+config.EnvVariable.DirectusBaseURI+`/items/video\?`)
+		deleted, lock := []string{}, &sync.Mutex{}
+		setUpDeleteFilesResponder(&deleted, lock)
+
+		resp := sendOrphanFilesRequest(http.MethodPost, "?dry_run=false")
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+		report := dto.OrphanReport{}
+		json.Unmarshal(resp.Body.Bytes(), &report)
+		assert.Equal(t, dto.OrphanReportStatusFailed, report.Status)
+		assert.NotEmpty(t, report.Error)
+		assert.Equal(t, 0, report.OrphanCount)
+		assert.Empty(t, deleted)
+		assert.Zero(t, httpmock.GetCallCountInfo()["GET "+config.GetDirectusUsersMeIDURI()])
+	})
+}
+
+func TestCreateAvatarRemovesUploads(t *testing.T) {
+	t.Run("Uploads are deleted when the avatar is not created", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		accountID, mastodonAccount := gofakeit.UUID(), gofakeit.Email()
+		setUpResponder(http.StatusOK, dto.MastodonVerifyCredentialsResponse{ID: gofakeit.UUID(), UserName: gofakeit.Username(), MastodonAccount: mastodonAccount},
+			http.MethodGet, config.GetMastodonVerifyCredentialsURI())
+		setUpResponder(http.StatusOK, dto.DirectusGetAccountResponse{Data: []dto.DirectusAccountResponseData{{ID: accountID, MastodonAccount: mastodonAccount}}},
+			http.MethodGet, config.GetDirectusGetAccountURI(mastodonAccount))
+		setUpAvatarUsage(accountID, nil, nil)
+
+		uploaded, lock := []string{}, &sync.Mutex{}
+		httpmock.RegisterResponder(http.MethodPost, config.GetDirectusUploadAssetURI(), func(req *http.Request) (*http.Response, error) {
+			id := gofakeit.UUID()
+			lock.Lock()
+			uploaded = append(uploaded, id)
+			lock.Unlock()
+			return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusUploadAssetResponse{Data: dto.DirectusUploadAssetResponseData{ID: id}})
+		})
+		setUpResponder(http.StatusForbidden, map[string]interface{}{"errors": []map[string]interface{}{{"message": "forbidden"}}},
+			http.MethodPost, config.GetDirectusCreateAvatarURI())
+		deleted := []string{}
+		setUpDeleteFilesResponder(&deleted, lock)
+
+		resp := postAvatar(map[string]string{"source": "upload", "is_public": "false"},
+			map[string][]byte{"snapshot": encodePNG(1024), "glb_file": buildGLB(avatarDocument(10, encodePNG(8)))})
+		assert.Equal(t, http.StatusInternalServerError, resp.Code)
+
+		// the snapshot, its thumbnails and the model
+		assert.Len(t, uploaded, 2+len(config.EnvVariable.ImageThumbnailSizes))
+		sort.Strings(uploaded)
+		sort.Strings(deleted)
+		assert.Equal(t, uploaded, deleted)
+	})
+}
@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"context"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/service"
+	"net/http"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpStaticToken(t *testing.T, token string) {
+	staticToken := config.EnvVariable.DirectusStaticToken
+	config.EnvVariable.DirectusStaticToken = token
+	t.Cleanup(func() { config.EnvVariable.DirectusStaticToken = staticToken })
+}
+
+func TestDirectusStaticToken(t *testing.T) {
+	t.Run("Static token is sent without logging in", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpStaticToken(t, "static-token")
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+
+		testRoomID := gofakeit.UUID()
+		var authorization string
+		httpmock.RegisterResponder(http.MethodGet, config.GetDirectusGetRoomURI(testRoomID, ""), func(req *http.Request) (*http.Response, error) {
+			authorization = req.Header.Get(constant.HeaderAuthorization)
+			return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusGetResponse{Data: dto.DierctusRoomData{ID: testRoomID}})
+		})
+
+		_, err := service.GetDirectusRoom(context.Background(), testRoomID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer static-token", authorization)
+		assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("Rejected static token is not renewed", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpStaticToken(t, "revoked-token")
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+
+		testRoomID := gofakeit.UUID()
+		httpmock.RegisterResponder(http.MethodGet, config.GetDirectusGetRoomURI(testRoomID, ""), httpmock.NewStringResponder(http.StatusUnauthorized, ""))
+
+		_, err := service.GetDirectusRoom(context.Background(), testRoomID, "")
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestCheckDirectusPermissions(t *testing.T) {
+	t.Run("Report missing and unneeded grants", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpStaticToken(t, "service-token")
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+
+		setUpResponder(http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"role": map[string]interface{}{"admin_access": false}}},
+			http.MethodGet, config.GetDirectusUsersMeURI())
+		setUpResponder(http.StatusOK, dto.DirectusGetPermissionsResponse{Data: []dto.DirectusPermission{
+			{Collection: "room", Action: "read", Fields: []string{"*"}},
+			{Collection: "room", Action: "update", Fields: []string{"like_count", "mastodon_status_id", "gallery_thumbnails"}},
+			{Collection: "room", Action: "delete", Fields: []string{"*"}},
+			{Collection: "room_translations", Action: "read", Fields: []string{"*"}},
+			{Collection: "event", Action: "read", Fields: []string{"*"}},
+			{Collection: "event", Action: "update", Fields: []string{"like_count", "view_count", "reminded_at", "started_at", "ended_at", "mastodon_status_id", "title"}},
+			{Collection: "account", Action: "read", Fields: []string{"*"}},
+			{Collection: "account", Action: "create", Fields: []string{"*"}},
+			{Collection: "account", Action: "update", Fields: []string{"*"}},
+			{Collection: "avatar", Action: "read", Fields: []string{"*"}},
+			{Collection: "avatar", Action: "create", Fields: []string{"*"}},
+			{Collection: "avatar", Action: "update", Fields: []string{"title", "source", "is_public", "snapshot", "snapshot_thumbnails", "glb", "tags"}},
+			{Collection: "directus_files", Action: "read", Fields: []string{"*"}},
+			{Collection: "directus_files", Action: "create", Fields: []string{"*"}},
+			{Collection: "directus_files", Action: "update", Fields: []string{"*"}},
+			{Collection: "directus_files", Action: "delete"},
+		}}, http.MethodGet, config.GetDirectusPermissionsMeURI())
+
+		report, err := service.CheckDirectusPermissions(context.Background())
+		assert.Nil(t, err)
+		assert.False(t, report.AdminAccess)
+		assert.Equal(t, []string{"room.update(view_count)", "avatar.delete"}, report.Missing)
+		assert.Equal(t, []string{"account.create(*)", "account.update(*)", "avatar.create(*)", "event.update(title)", "room.delete(*)"}, report.Excess)
+	})
+
+	t.Run("Admin token skips the grant comparison", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpStaticToken(t, "admin-token")
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+
+		setUpResponder(http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"role": map[string]interface{}{"admin_access": true}}},
+			http.MethodGet, config.GetDirectusUsersMeURI())
+
+		report, err := service.CheckDirectusPermissions(context.Background())
+		assert.Nil(t, err)
+		assert.True(t, report.AdminAccess)
+		assert.Empty(t, report.Missing)
+		assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/errors"
+	"hubs-cms-go/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpFastRetries(t *testing.T) {
+	retryWait, retryMaxWait := config.EnvVariable.UpstreamRetryWait, config.EnvVariable.UpstreamRetryMaxWait
+	config.EnvVariable.UpstreamRetryWait = time.Millisecond
+	config.EnvVariable.UpstreamRetryMaxWait = 5 * time.Millisecond
+	t.Cleanup(func() {
+		config.EnvVariable.UpstreamRetryWait, config.EnvVariable.UpstreamRetryMaxWait = retryWait, retryMaxWait
+		client.ResetCircuitBreakers()
+	})
+}
+
+func TestUpstreamRetry(t *testing.T) {
+	t.Run("GET is retried on 5xx until it succeeds", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpFastRetries(t)
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testRoomID := gofakeit.UUID()
+		roomURL := config.GetDirectusGetRoomURI(testRoomID, "")
+		attempts := 0
+		httpmock.RegisterResponder(http.MethodGet, roomURL, func(req *http.Request) (*http.Response, error) {
+			if attempts++; attempts < 3 {
+				return httpmock.NewStringResponse(http.StatusBadGateway, ""), nil
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusGetResponse{Data: dto.DierctusRoomData{ID: testRoomID}})
+		})
+
+		roomData, err := service.GetDirectusRoom(context.Background(), testRoomID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, testRoomID, roomData.ID)
+		assert.Equal(t, 3, httpmock.GetCallCountInfo()["GET "+roomURL])
+	})
+
+	t.Run("GET gives up after UPSTREAM_RETRY_COUNT", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpFastRetries(t)
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testRoomID := gofakeit.UUID()
+		roomURL := config.GetDirectusGetRoomURI(testRoomID, "")
+		httpmock.RegisterResponder(http.MethodGet, roomURL, httpmock.NewStringResponder(http.StatusBadGateway, ""))
+
+		_, err := service.GetDirectusRoom(context.Background(), testRoomID, "")
+		assert.NotNil(t, err)
+		assert.Equal(t, config.EnvVariable.UpstreamRetryCount+1, httpmock.GetCallCountInfo()["GET "+roomURL])
+	})
+
+	t.Run("PATCH is not retried", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpFastRetries(t)
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testAccountID := gofakeit.UUID()
+		accountURL := config.GetDirectusPatchAccountURI(testAccountID)
+		httpmock.RegisterResponder(http.MethodPatch, accountURL, httpmock.NewStringResponder(http.StatusBadGateway, ""))
+
+		_, err := service.PatchDirectusAccount(context.Background(), testAccountID, map[string]interface{}{})
+		assert.NotNil(t, err)
+		assert.Equal(t, 1, httpmock.GetCallCountInfo()["PATCH "+accountURL])
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	t.Run("Circuit opens after consecutive failures and fails fast with 503", func(t *testing.T) {
+		testRouter := Init()
+		client.Setup()
+		setUpFastRetries(t)
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testRoomID := gofakeit.UUID()
+		roomURL := config.GetDirectusGetRoomURI(testRoomID, "")
+		httpmock.RegisterResponder(http.MethodGet, roomURL, httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+
+		for !client.IsCircuitOpen("directus") {
+			_, err := service.GetDirectusRoom(context.Background(), testRoomID, "")
+			assert.NotNil(t, err)
+		}
+		assert.Equal(t, config.EnvVariable.CircuitBreakerThreshold, httpmock.GetCallCountInfo()["GET "+roomURL])
+
+		_, err := service.GetDirectusRoom(context.Background(), testRoomID, "")
+		assert.ErrorIs(t, err, client.ErrCircuitOpen)
+		assert.Equal(t, config.EnvVariable.CircuitBreakerThreshold, httpmock.GetCallCountInfo()["GET "+roomURL])
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/hubs-cms/v1/avatars", nil)
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+		assert.NotEmpty(t, resp.Header().Get("Retry-After"))
+		b, _ := json.Marshal(errors.ServiceUnavailableError)
+		assert.Equal(t, string(b), resp.Body.String())
+	})
+
+	t.Run("Half-open probe closes the circuit on success", func(t *testing.T) {
+		Init()
+		client.Setup()
+		setUpFastRetries(t)
+
+		openTimeout := config.EnvVariable.CircuitBreakerOpenTimeout
+		config.EnvVariable.CircuitBreakerOpenTimeout = 20 * time.Millisecond
+		defer func() { config.EnvVariable.CircuitBreakerOpenTimeout = openTimeout }()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testRoomID := gofakeit.UUID()
+		roomURL := config.GetDirectusGetRoomURI(testRoomID, "")
+		httpmock.RegisterResponder(http.MethodGet, roomURL, httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+
+		for !client.IsCircuitOpen("directus") {
+			service.GetDirectusRoom(context.Background(), testRoomID, "")
+		}
+
+		httpmock.RegisterResponder(http.MethodGet, roomURL,
+			httpmock.NewJsonResponderOrPanic(http.StatusOK, dto.DirectusGetResponse{Data: dto.DierctusRoomData{ID: testRoomID}}))
+		time.Sleep(30 * time.Millisecond)
+
+		roomData, err := service.GetDirectusRoom(context.Background(), testRoomID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, testRoomID, roomData.ID)
+		assert.False(t, client.IsCircuitOpen("directus"))
+	})
+}
@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"hubs-cms-go/cache"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func setUpEventResponder(eventID string) string {
+	eventURL := config.GetDirectusGetEventURI(eventID, "")
+	setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: dto.DirectusEventResponseData{ID: eventID}}, http.MethodGet, eventURL)
+	return eventURL
+}
+
+func TestResponseCache(t *testing.T) {
+	t.Run("Repeated reads hit directus once", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testEventID := gofakeit.UUID()
+		eventURL := setUpEventResponder(testEventID)
+
+		for i := 0; i < 3; i++ {
+			event, err := service.GetDirectusEvent(context.Background(), testEventID, "")
+			assert.Nil(t, err)
+			assert.Equal(t, testEventID, event.ID)
+		}
+		assert.Equal(t, 1, httpmock.GetCallCountInfo()["GET "+eventURL])
+
+		_, err := service.GetDirectusEvent(service.NoCache(context.Background()), testEventID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, httpmock.GetCallCountInfo()["GET "+eventURL])
+	})
+
+	t.Run("Patching an event invalidates its cached reads", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testEventID := gofakeit.UUID()
+		eventURL := setUpEventResponder(testEventID)
+		setUpResponder(http.StatusOK, nil, http.MethodPatch, config.GetDirectusGetEventURISimple(testEventID))
+
+		service.GetDirectusEvent(context.Background(), testEventID, "")
+		assert.Nil(t, service.PatchDirectusEvent(context.Background(), testEventID, map[string]int64{"like_count": 1}))
+		service.GetDirectusEvent(context.Background(), testEventID, "")
+
+		assert.Equal(t, 2, httpmock.GetCallCountInfo()["GET "+eventURL])
+	})
+
+	t.Run("Stale read is served and revalidated in the background", func(t *testing.T) {
+		Init()
+		client.Setup()
+		cache.Responses = cache.NewResponseCache(20*time.Millisecond, time.Minute, 1<<20)
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testEventID := gofakeit.UUID()
+		eventURL := setUpEventResponder(testEventID)
+
+		service.GetDirectusEvent(context.Background(), testEventID, "")
+		time.Sleep(30 * time.Millisecond)
+
+		event, err := service.GetDirectusEvent(context.Background(), testEventID, "")
+		assert.Nil(t, err)
+		assert.Equal(t, testEventID, event.ID)
+
+		assert.Eventually(t, func() bool {
+			_, fresh, _ := cache.Responses.Get(eventURL)
+			return fresh
+		}, time.Second, 10*time.Millisecond)
+		assert.Equal(t, 2, httpmock.GetCallCountInfo()["GET "+eventURL])
+	})
+
+	t.Run("Least recently used entries are evicted beyond the size bound", func(t *testing.T) {
+		responses := cache.NewResponseCache(time.Minute, 0, 10)
+
+		responses.Set("a", []byte("aaaa"), responses.Generation())
+		responses.Set("b", []byte("bbbb"), responses.Generation())
+		responses.Get("a")
+		responses.Set("c", []byte("cccc"), responses.Generation())
+
+		_, _, foundA := responses.Get("a")
+		_, _, foundB := responses.Get("b")
+		assert.True(t, foundA)
+		assert.False(t, foundB)
+		assert.Equal(t, 2, responses.Len())
+
+		generation := responses.Generation()
+		responses.DeletePrefix("c")
+		responses.Set("d", []byte("dddd"), generation)
+		_, _, foundD := responses.Get("d")
+		assert.False(t, foundD)
+	})
+
+	t.Run("Invalidating an item drops the lists of its collection", func(t *testing.T) {
+		Init()
+		responses := cache.Responses
+		cache.Responses = cache.NewResponseCache(time.Minute, 0, 1<<20)
+		defer func() { cache.Responses = responses }()
+
+		base := config.EnvVariable.DirectusBaseURI + "/items/room"
+		for _, key := range []string{base + "/1", base + "/2", base + "?limit=10", base + "_tag?limit=10"} {
+			cache.Responses.Set(key, []byte(key), cache.Responses.Generation())
+		}
+		service.InvalidateDirectusCache(context.Background(), "room", "1")
+
+		for _, key := range []string{base + "/1", base + "?limit=10"} {
+			_, _, found := cache.Responses.Get(key)
+			assert.False(t, found, key)
+		}
+		for _, key := range []string{base + "/2", base + "_tag?limit=10"} {
+			_, _, found := cache.Responses.Get(key)
+			assert.True(t, found, key)
+		}
+	})
+
+	t.Run("Deleting a path keeps paths it only prefixes", func(t *testing.T) {
+		responses := cache.NewResponseCache(time.Minute, 0, 1024)
+
+		for _, key := range []string{"/items/room", "/items/room?limit=10", "/items/room/1", "/items/room_tag?limit=10"} {
+			responses.Set(key, []byte(key), responses.Generation())
+		}
+		responses.DeletePath("/items/room")
+
+		for _, key := range []string{"/items/room", "/items/room?limit=10", "/items/room/1"} {
+			_, _, found := responses.Get(key)
+			assert.False(t, found, key)
+		}
+		_, _, found := responses.Get("/items/room_tag?limit=10")
+		assert.True(t, found)
+	})
+}
+
+func TestETag(t *testing.T) {
+	t.Run("Matching If-None-Match returns 304", func(t *testing.T) {
+		testRouter := Init()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testLocale := "zh-TW"
+		testStatus := "closed|opened|soon"
+		setUpResponder(http.StatusOK, dto.DirectusGetEventsResponse{Data: []dto.DirectusEventResponseData{}},
+			http.MethodGet, config.GetDirectusGetEventsURI(testLocale, testStatus, 0, 2))
+		testApi := fmt.Sprintf("/api/hubs-cms/v1/events?locale=%s&status=%s&limit=2", testLocale, testStatus)
+
+		req, _ := http.NewRequest(http.MethodGet, testApi, nil)
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		etag := resp.Header().Get("ETag")
+		assert.NotEmpty(t, etag)
+		assert.NotEmpty(t, resp.Body.String())
+
+		req, _ = http.NewRequest(http.MethodGet, testApi, nil)
+		req.Header.Set("If-None-Match", "W/"+etag)
+		resp = httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusNotModified, resp.Code)
+		assert.Equal(t, etag, resp.Header().Get("ETag"))
+		assert.Contains(t, resp.Header().Values("Vary"), "Origin")
+		assert.Contains(t, resp.Header().Values("Vary"), "Authorization")
+		assert.Empty(t, resp.Body.String())
+
+		req, _ = http.NewRequest(http.MethodGet, testApi, nil)
+		req.Header.Set("If-None-Match", `"outdated"`)
+		resp = httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusOK, resp.Code)
+		assert.NotEmpty(t, resp.Body.String())
+	})
+
+	t.Run("Errors are sent without ETag", func(t *testing.T) {
+		testRouter := SetupRouter()
+
+		req, _ := http.NewRequest(http.MethodGet, "/api/hubs-cms/v1/events?limit=-1", nil)
+		resp := httptest.NewRecorder()
+		testRouter.ServeHTTP(resp, req)
+
+		assert.Equal(t, http.StatusBadRequest, resp.Code)
+		assert.Empty(t, resp.Header().Get("ETag"))
+		assert.NotEmpty(t, resp.Body.String())
+	})
+}
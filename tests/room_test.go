@@ -2,6 +2,7 @@ package tests
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -329,7 +330,7 @@ func TestPatchDirectusRoom(t *testing.T) {
 			LikeCount: "77000",
 		}
 		// verify service flow
-		err := service.PatchDirectusRoom(testID, &likeCountPatchBody)
+		err := service.PatchDirectusRoom(context.Background(), testID, &likeCountPatchBody)
 		assert.Nil(t, err)
 	})
 }
@@ -348,7 +349,7 @@ func TestGetDirectusRoom(t *testing.T) {
 		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: &mockRoomResponse}, http.MethodGet, config.GetDirectusGetRoomURI(testID, testLocale))
 
 		// verify service flow
-		responseFormat, err := service.GetDirectusRoom(testID, testLocale)
+		responseFormat, err := service.GetDirectusRoom(context.Background(), testID, testLocale)
 		assert.Nil(t, err)
 
 		assert.Equal(t, mockRoomResponse.ID, responseFormat.ID)
@@ -383,7 +384,7 @@ func TestGetMyRoomList(t *testing.T) {
 		setUpResponder(http.StatusOK, mockResponse, http.MethodGet, config.GetDirectusGetMyRoomListURI(testAccountID, testLocale, start, limit))
 
 		// verify service flow
-		directusRoomList, _, err := service.GetDirectusMyRoomList(testAccountID, testLocale, start, limit)
+		directusRoomList, _, err := service.GetDirectusMyRoomList(context.Background(), testAccountID, testLocale, start, limit)
 		assert.Equal(t, 1, len(directusRoomList))
 		assert.Nil(t, err)
 	})
@@ -419,7 +420,7 @@ func TestGetRoomListService(t *testing.T) {
 		setUpResponder(http.StatusOK, mockResponse, http.MethodGet, config.GetDirectusGetRoomListURI(&hasNFT, testHubsID, testLocale, start, limit))
 
 		// verify service flow
-		directusRoomList, _, err := service.GetDirectusRoomList(&hasNFT, testHubsID, testLocale, start, limit)
+		directusRoomList, _, err := service.GetDirectusRoomList(context.Background(), &hasNFT, testHubsID, testLocale, start, limit)
 
 		assert.Equal(t, 3, len(directusRoomList))
 		assert.Nil(t, err)
@@ -690,7 +691,7 @@ func TestGetRoomDetailByID(t *testing.T) {
 		mockRoomResponse := setPreconditionForViewCount(testID, true, "80000")
 		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: &mockRoomResponse}, http.MethodGet, config.GetDirectusGetRoomURI(testID, testLocale))
 
-		serviceResult, err := service.GetDirectusRoom(testID, testLocale)
+		serviceResult, err := service.GetDirectusRoom(context.Background(), testID, testLocale)
 		assert.Nil(t, err)
 		assert.Equal(t, mockRoomResponse.LikeCount, serviceResult.LikeCount)
 
@@ -785,7 +786,7 @@ func TestPatchedViewCountForPublicRoom(t *testing.T) {
 		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: &mockPatchRoomResponse}, http.MethodPatch, config.GetDirectusGetRoomURI(testID, testLocale))
 
 		// verify service flow
-		result, err := service.PostRoomViewCount(mockRoomResponse, testLocale, false)
+		result, err := service.PostRoomViewCount(context.Background(), mockRoomResponse, testLocale)
 		assert.Equal(t, hubsErrorInfo.ErrorInfo{}, err)
 		assert.Equal(t, mockPatchRoomResponse, result)
 
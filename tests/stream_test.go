@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/stream"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/gorilla/websocket"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+)
+
+// readSSEMessage returns the next message of a server-sent event stream, skipping heartbeats
+func readSSEMessage(t *testing.T, reader *bufio.Reader) (event string, msg dto.StreamMessage) {
+	for {
+		line, err := reader.ReadString('\n')
+		if !assert.Nil(t, err) {
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			assert.Nil(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data:")), &msg))
+			return
+		}
+	}
+}
+
+func receiveStreamMessage(t *testing.T, subscriber *stream.Subscriber) (msg dto.StreamMessage) {
+	select {
+	case msg = <-subscriber.C:
+	case <-time.After(time.Second):
+		t.Fatal("no stream message received")
+	}
+	return
+}
+
+func TestStream(t *testing.T) {
+	t.Run("View counts reach server-sent event subscribers", func(t *testing.T) {
+		testServer := httptest.NewServer(Init())
+		defer testServer.Close()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testRoomID := gofakeit.UUID()
+		mockRoomResponse := setPreconditionForViewCount(testRoomID, true, "7")
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: &mockRoomResponse}, http.MethodGet, config.GetDirectusGetRoomURI(testRoomID, ""))
+		mockPatchRoomResponse := setPreconditionForViewCount(testRoomID, true, "8")
+		setUpResponder(http.StatusOK, dto.DirectusGetResponse{Data: &mockPatchRoomResponse}, http.MethodPatch, config.GetDirectusGetRoomURI(testRoomID, ""))
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/hubs-cms/v1/stream?rooms=%s", testServer.URL, testRoomID))
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		viewed, err := http.Post(fmt.Sprintf("%s/api/hubs-cms/v1/rooms/%s/viewed", testServer.URL, testRoomID), "application/json", nil)
+		assert.Nil(t, err)
+		viewed.Body.Close()
+		assert.Equal(t, http.StatusOK, viewed.StatusCode)
+
+		event, msg := readSSEMessage(t, bufio.NewReader(resp.Body))
+		assert.Equal(t, dto.StreamTypeViewCount, event)
+		assert.Equal(t, dto.StreamTargetRoom, msg.Target)
+		assert.Equal(t, testRoomID, msg.ID)
+		assert.Equal(t, int64(1), msg.Delta)
+		assert.Equal(t, json.Number("8"), msg.Value)
+	})
+
+	t.Run("Invalid or missing ids are rejected", func(t *testing.T) {
+		testRouter := SetupRouter()
+
+		for _, query := range []string{"rooms=not-a-uuid", "events=" + gofakeit.UUID() + ",1", ""} {
+			req, _ := http.NewRequest(http.MethodGet, "/api/hubs-cms/v1/stream?"+query, nil)
+			resp := httptest.NewRecorder()
+			testRouter.ServeHTTP(resp, req)
+			assert.Equal(t, http.StatusBadRequest, resp.Code, query)
+		}
+	})
+
+	t.Run("Websocket subscribes after connecting", func(t *testing.T) {
+		testServer := httptest.NewServer(SetupRouter())
+		defer testServer.Close()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		testEventID := gofakeit.UUID()
+		setUpEventResponder(testEventID)
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(testServer.URL, "http")+"/api/hubs-cms/v1/stream", nil)
+		if !assert.Nil(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		assert.Nil(t, conn.WriteJSON(dto.StreamRequest{Action: "subscribe", Events: []string{testEventID}}))
+		// requests are handled in order, the reply of a bad one follows the subscription
+		assert.Nil(t, conn.WriteJSON(dto.StreamRequest{Action: "subscribe", Rooms: []string{"not-a-uuid"}}))
+		reply := map[string]interface{}{}
+		assert.Nil(t, conn.ReadJSON(&reply))
+		assert.Contains(t, fmt.Sprint(reply), "400700")
+
+		stream.PublishLikeCount(dto.StreamTargetEvent, testEventID, -1, 0)
+
+		msg := dto.StreamMessage{}
+		assert.Nil(t, conn.ReadJSON(&msg))
+		assert.Equal(t, dto.StreamTypeLikeCount, msg.Type)
+		assert.Equal(t, testEventID, msg.ID)
+		assert.Equal(t, int64(-1), msg.Delta)
+		assert.Equal(t, json.Number("0"), msg.Value)
+	})
+}
+
+func TestStreamSubscriber(t *testing.T) {
+	config.Setup()
+
+	t.Run("Event status transitions are emitted as start and end pass", func(t *testing.T) {
+		testEventID := gofakeit.UUID()
+
+		subscriber := stream.NewSubscriber()
+		defer subscriber.Close()
+		assert.Nil(t, subscriber.Subscribe(dto.StreamTargetEvent, testEventID))
+
+		now := time.Now()
+		stream.WatchEvent(testEventID, now.Add(50*time.Millisecond), now.Add(100*time.Millisecond))
+		assert.True(t, stream.IsWatchingEvent(testEventID))
+
+		msg := receiveStreamMessage(t, subscriber)
+		assert.Equal(t, dto.StreamTypeStatus, msg.Type)
+		assert.Equal(t, dto.EventStatusOpened, msg.Status)
+		assert.Equal(t, dto.EventStatusSoon, msg.Previous)
+
+		msg = receiveStreamMessage(t, subscriber)
+		assert.Equal(t, dto.EventStatusClosed, msg.Status)
+		assert.Equal(t, dto.EventStatusOpened, msg.Previous)
+
+		// moving a closed event to the future reopens it at once
+		stream.WatchEvent(testEventID, now.Add(-time.Hour), now.Add(time.Hour))
+		msg = receiveStreamMessage(t, subscriber)
+		assert.Equal(t, dto.EventStatusOpened, msg.Status)
+		assert.Equal(t, dto.EventStatusClosed, msg.Previous)
+
+		subscriber.Unsubscribe(dto.StreamTargetEvent, testEventID)
+		assert.False(t, stream.IsWatchingEvent(testEventID))
+	})
+
+	t.Run("Slow subscribers drop messages and subscriptions are capped", func(t *testing.T) {
+		bufferSize, maxSubscriptions := config.EnvVariable.StreamBufferSize, config.EnvVariable.StreamMaxSubscriptions
+		config.EnvVariable.StreamBufferSize, config.EnvVariable.StreamMaxSubscriptions = 1, 2
+		defer func() {
+			config.EnvVariable.StreamBufferSize, config.EnvVariable.StreamMaxSubscriptions = bufferSize, maxSubscriptions
+		}()
+
+		testRoomID := gofakeit.UUID()
+		subscriber := stream.NewSubscriber()
+		defer subscriber.Close()
+
+		assert.Nil(t, subscriber.Subscribe(dto.StreamTargetRoom, testRoomID))
+		assert.Nil(t, subscriber.Subscribe(dto.StreamTargetRoom, testRoomID))
+		assert.Nil(t, subscriber.Subscribe(dto.StreamTargetEvent, gofakeit.UUID()))
+		assert.Equal(t, stream.ErrTooManySubscriptions, subscriber.Subscribe(dto.StreamTargetEvent, gofakeit.UUID()))
+
+		stream.PublishLikeCount(dto.StreamTargetRoom, testRoomID, 1, 1)
+		stream.PublishLikeCount(dto.StreamTargetRoom, testRoomID, 1, 2)
+		stream.PublishLikeCount(gofakeit.UUID(), testRoomID, 1, 3)
+
+		msg := receiveStreamMessage(t, subscriber)
+		assert.Equal(t, json.Number("1"), msg.Value)
+		assert.Len(t, subscriber.C, 0)
+	})
+}
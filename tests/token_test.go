@@ -0,0 +1,130 @@
+package tests
+
+import (
+	"context"
+	"hubs-cms-go/cache"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/constant"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/service"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	goCache "github.com/patrickmn/go-cache"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDirectusTokenResponder(accessToken, refreshToken string) httpmock.Responder {
+	return httpmock.NewJsonResponderOrPanic(http.StatusOK, dto.DirectusAuthLoginResponse{
+		Data: dto.DirectusAuthLoginResponseData{
+			AccessToken:  accessToken,
+			Expires:      900000,
+			RefreshToken: refreshToken}})
+}
+
+func TestDirectusTokenSingleFlight(t *testing.T) {
+	t.Run("Concurrent refreshes log in once", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+
+		release := make(chan struct{})
+		httpmock.RegisterResponder(http.MethodPost, config.GetDirectusAccessTokenURI(), func(req *http.Request) (*http.Response, error) {
+			<-release
+			return newDirectusTokenResponder("login-token", "login-refresh")(req)
+		})
+
+		var wg sync.WaitGroup
+		tokens := make([]string, 20)
+		for i := range tokens {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				tokens[i], _ = service.GetDirectusAccessToken(context.Background(), true)
+			}(i)
+		}
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		for _, token := range tokens {
+			assert.Equal(t, "Bearer login-token", token)
+		}
+		assert.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
+
+func TestDirectusTokenRefresh(t *testing.T) {
+	t.Run("Expired access token is renewed with the refresh token", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+
+		cache.Store.Set(constant.CacheKeyDirectusRefreshToken, "old-refresh", goCache.NoExpiration)
+
+		var refreshBody string
+		httpmock.RegisterResponder(http.MethodPost, config.GetDirectusRefreshTokenURI(), func(req *http.Request) (*http.Response, error) {
+			b, _ := ioutil.ReadAll(req.Body)
+			refreshBody = string(b)
+			return newDirectusTokenResponder("refreshed-token", "new-refresh")(req)
+		})
+
+		token, err := service.GetDirectusAccessToken(context.Background(), false)
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer refreshed-token", token)
+		assert.Contains(t, refreshBody, `"refresh_token":"old-refresh"`)
+		assert.Equal(t, 0, httpmock.GetCallCountInfo()["POST "+config.GetDirectusAccessTokenURI()])
+
+		refreshToken, _ := cache.Store.Get(constant.CacheKeyDirectusRefreshToken)
+		assert.Equal(t, "new-refresh", refreshToken)
+	})
+
+	t.Run("Rejected refresh token falls back to the admin password", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+
+		cache.Store.Set(constant.CacheKeyDirectusRefreshToken, "expired-refresh", goCache.NoExpiration)
+		httpmock.RegisterResponder(http.MethodPost, config.GetDirectusRefreshTokenURI(), httpmock.NewStringResponder(http.StatusUnauthorized, ""))
+		httpmock.RegisterResponder(http.MethodPost, config.GetDirectusAccessTokenURI(), newDirectusTokenResponder("login-token", "login-refresh"))
+
+		token, err := service.GetDirectusAccessToken(context.Background(), false)
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer login-token", token)
+
+		refreshToken, _ := cache.Store.Get(constant.CacheKeyDirectusRefreshToken)
+		assert.Equal(t, "login-refresh", refreshToken)
+	})
+
+	t.Run("Token about to expire is refreshed in the background", func(t *testing.T) {
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+
+		cache.Store.Set(constant.CacheKeyDirectusAccessToken, "Bearer old-token", config.EnvVariable.DirectusTokenRefreshAhead/2)
+		cache.Store.Set(constant.CacheKeyDirectusRefreshToken, "old-refresh", goCache.NoExpiration)
+		httpmock.RegisterResponder(http.MethodPost, config.GetDirectusRefreshTokenURI(), newDirectusTokenResponder("refreshed-token", "new-refresh"))
+
+		token, err := service.GetDirectusAccessToken(context.Background(), false)
+		assert.Nil(t, err)
+		assert.Equal(t, "Bearer old-token", token)
+
+		assert.Eventually(t, func() bool {
+			token, _ := service.GetDirectusAccessToken(context.Background(), false)
+			return token == "Bearer refreshed-token"
+		}, time.Second, 10*time.Millisecond)
+	})
+}
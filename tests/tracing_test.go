@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"hubs-cms-go/client"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"hubs-cms-go/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setUpSpanRecorder(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(trace.NewNoopTracerProvider())
+	})
+	return recorder
+}
+
+func TestTracingServerSpan(t *testing.T) {
+	t.Run("Continue incoming trace with route named span", func(t *testing.T) {
+		recorder := setUpSpanRecorder(t)
+		testRouter := SetupRouter()
+
+		traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+		req, _ := http.NewRequest(http.MethodGet, "/api/hubs-cms/v1/events?limit=-1", nil)
+		req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+		testRouter.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := recorder.Ended()
+		if assert.NotEmpty(t, spans) {
+			span := spans[len(spans)-1]
+			assert.Equal(t, "GET /api/hubs-cms/v1/events", span.Name())
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind())
+			assert.Equal(t, traceID, span.SpanContext().TraceID().String())
+		}
+	})
+
+	t.Run("Skip probe routes", func(t *testing.T) {
+		recorder := setUpSpanRecorder(t)
+		testRouter := SetupRouter()
+
+		for _, path := range []string{"/health/live", "/version"} {
+			req, _ := http.NewRequest(http.MethodGet, path, nil)
+			testRouter.ServeHTTP(httptest.NewRecorder(), req)
+		}
+
+		assert.Empty(t, recorder.Ended())
+	})
+}
+
+func TestTracingUpstreamSpan(t *testing.T) {
+	t.Run("Inject trace context into directus calls", func(t *testing.T) {
+		recorder := setUpSpanRecorder(t)
+		Init()
+		client.Setup()
+
+		httpmock.ActivateNonDefault(client.RestyClient.GetClient())
+		defer httpmock.DeactivateAndReset()
+		regDirTokenRes()
+
+		var traceparent string
+		testRoomID := gofakeit.UUID()
+		httpmock.RegisterResponder(http.MethodGet, config.GetDirectusGetRoomURI(testRoomID, ""),
+			func(req *http.Request) (*http.Response, error) {
+				traceparent = req.Header.Get("traceparent")
+				return httpmock.NewJsonResponse(http.StatusOK, dto.DirectusGetResponse{Data: dto.DierctusRoomData{ID: testRoomID}})
+			})
+
+		_, err := service.GetDirectusRoom(context.Background(), testRoomID, "")
+		assert.Nil(t, err)
+
+		var roomSpan sdktrace.ReadOnlySpan
+		for _, span := range recorder.Ended() {
+			if span.Name() == "directus GET /items/room/:id" {
+				roomSpan = span
+			}
+		}
+		if assert.NotNil(t, roomSpan) {
+			assert.Equal(t, trace.SpanKindClient, roomSpan.SpanKind())
+			assert.Contains(t, traceparent, roomSpan.SpanContext().TraceID().String())
+		}
+	})
+}
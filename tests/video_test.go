@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"encoding/json"
+	"hubs-cms-go/config"
+	"hubs-cms-go/dto"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mockDataVideo = `{
+  "video_id": {
+    "cover_image": "cover-1",
+    "mp4": {"id": "mp4-1", "type": "video/mp4", "filesize": "1048576", "width": 1920, "height": 1080, "duration": 95},
+    "webm": "webm-1",
+    "hls": {"id": "hls-1", "type": "application/octet-stream", "filesize": "512", "width": null, "height": null, "duration": null},
+    "captions": [
+      {"languages_code": "zh-TW", "kind": "captions", "label": "中文", "file": "vtt-1"},
+      {"languages_code": "en-US", "kind": null, "label": null, "file": "vtt-2"},
+      {"languages_code": "ja-JP", "kind": "subtitles", "label": "日本語", "file": null}
+    ]
+  }
+}`
+
+func TestNewVideo(t *testing.T) {
+	t.Run("Renditions and tracks are read from the directus files", func(t *testing.T) {
+		Init()
+		directusVideo := dto.DirectusVideo{}
+		assert.Nil(t, json.Unmarshal([]byte(mockDataVideo), &directusVideo))
+
+		video, err := directusVideo.NewVideo()
+		assert.Nil(t, err)
+		assert.Equal(t, config.GetAssetURI("cover-1", true), video.CoverImage)
+		assert.Equal(t, config.GetAssetURI("mp4-1", true), video.Mp4)
+		assert.Equal(t, config.GetAssetURI("webm-1", true), video.Webm)
+		assert.Equal(t, config.GetAssetURI("hls-1", true), video.HLS)
+		assert.Equal(t, 95, video.Duration)
+		assert.Equal(t, 1920, video.Width)
+		assert.Equal(t, 1080, video.Height)
+
+		assert.Equal(t, []dto.VideoSource{
+			{URL: config.GetAssetURI("hls-1", true), MimeType: "application/vnd.apple.mpegurl", FileSize: 512},
+			{URL: config.GetAssetURI("mp4-1", true), MimeType: "video/mp4", Width: 1920, Height: 1080, FileSize: 1048576},
+			{URL: config.GetAssetURI("webm-1", true), MimeType: "video/webm"},
+		}, video.Sources)
+
+		// tracks without a file are left out
+		assert.Equal(t, []dto.VideoTrack{
+			{URL: config.GetAssetURI("vtt-1", true), Kind: "captions", SrcLang: "zh-TW", Label: "中文"},
+			{URL: config.GetAssetURI("vtt-2", true), Kind: "subtitles", SrcLang: "en-US", Label: "en-US"},
+		}, video.Tracks)
+	})
+
+	t.Run("Videos without a source are skipped", func(t *testing.T) {
+		Init()
+		directusVideo := dto.DirectusVideo{}
+		assert.Nil(t, json.Unmarshal([]byte(`{"video_id": {"cover_image": "cover-1", "mp4": null, "webm": null}}`), &directusVideo))
+		_, err := directusVideo.NewVideo()
+		assert.NotNil(t, err)
+	})
+
+	t.Run("Captions are filtered in the request locale", func(t *testing.T) {
+		Init()
+		hls, captions := config.EnvVariable.VideoHLSEnabled, config.EnvVariable.VideoCaptionsEnabled
+		defer func() { config.EnvVariable.VideoHLSEnabled, config.EnvVariable.VideoCaptionsEnabled = hls, captions }()
+
+		// directus refuses fields the schema does not have
+		config.EnvVariable.VideoHLSEnabled, config.EnvVariable.VideoCaptionsEnabled = false, false
+		link, _ := url.Parse(config.GetDirectusGetEventURI("event-1", "ja-JP"))
+		assert.NotContains(t, strings.Join(link.Query()["fields"], ","), "hls")
+		assert.NotContains(t, strings.Join(link.Query()["fields"], ","), "captions")
+		assert.Empty(t, link.Query().Get("deep[videos][video_id][captions][_filter][languages_code][_eq]"))
+
+		config.EnvVariable.VideoHLSEnabled, config.EnvVariable.VideoCaptionsEnabled = true, true
+		link, _ = url.Parse(config.GetDirectusGetEventURI("event-1", "ja-JP"))
+		assert.Contains(t, strings.Join(link.Query()["fields"], ","), "videos.video_id.hls.id")
+		assert.Contains(t, strings.Join(link.Query()["fields"], ","), "videos.video_id.captions.file")
+		assert.Equal(t, "ja-JP", link.Query().Get("deep[videos][video_id][captions][_filter][languages_code][_eq]"))
+
+		link, _ = url.Parse(config.GetDirectusGetEventURI("event-1", ""))
+		assert.Empty(t, link.Query().Get("deep[videos][video_id][captions][_filter][languages_code][_eq]"))
+	})
+}
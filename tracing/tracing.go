@@ -0,0 +1,76 @@
+package tracing
+
+import (
+	"context"
+	"hubs-cms-go/config"
+	"hubs-cms-go/logger"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+const tracerName = "hubs-cms-go"
+
+var provider *sdktrace.TracerProvider
+
+// Setup configures the global tracer provider and the W3C trace context propagator
+func Setup() {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(config.EnvVariable.TracingExporter) {
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	case ExporterOTLP:
+		// endpoint and headers are read from OTEL_EXPORTER_OTLP_* environment variables
+		exporter, err = otlptracehttp.New(context.Background())
+	default:
+		return
+	}
+	if err != nil {
+		logger.Error.Printf("[tracing.Setup] create %v exporter error: %v\n", config.EnvVariable.TracingExporter, err)
+		return
+	}
+
+	provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.EnvVariable.TracingSampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(
+			semconv.SchemaURL,
+			semconv.ServiceNameKey.String(tracerName),
+			semconv.ServiceVersionKey.String(config.EnvVariable.Version),
+			semconv.DeploymentEnvironmentKey.String(strings.ToLower(config.EnvVariable.Environment)),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+}
+
+// Shutdown flushes the pending spans
+func Shutdown(ctx context.Context) {
+	if provider == nil {
+		return
+	}
+	if err := provider.Shutdown(ctx); err != nil {
+		logger.Error.Printf("[tracing.Shutdown] error: %v\n", err)
+	}
+}
+
+// Tracer returns the tracer of this service
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}